@@ -0,0 +1,178 @@
+package pgrepo
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// maxReportedQueryLength truncates the text of the queries reported by the activity monitor.
+const maxReportedQueryLength = 256
+
+// WithActivityMonitor enables the sampling of pg_stat_activity for the connections of this application,
+// at every interval.
+//
+// The number of active and idle in transaction connections are exposed as metrics (see Repository.Collector).
+//
+// When maxQueryDuration is positive, queries of this application running for longer are cancelled
+// with pg_cancel_backend.
+//
+// Connections are attributed to the application by their application_name (see WithTag). This spans all the instances
+// of the application connected to the database: the monitor of one instance counts, and cancels, the queries of all instances.
+//
+// The sessions of the administrative tasks of this package (migrations, maintenance jobs and the lock of tasks run with
+// RunExclusive) have a distinct application_name, suffixed with "/admin": they are neither counted nor cancelled.
+// The statements issued by a task run with RunExclusive are not administrative, and may be cancelled.
+func WithActivityMonitor(interval, maxQueryDuration time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.ActivityMonitor.Enabled = true
+		o.ActivityMonitor.Interval = interval
+		o.ActivityMonitor.MaxQueryDuration = maxQueryDuration
+	}
+}
+
+type (
+	// activityMonitor holds the last sample of pg_stat_activity.
+	activityMonitor struct {
+		active            atomic.Int64
+		idleInTransaction atomic.Int64
+		cancelled         atomic.Int64
+	}
+
+	// longQuery is a query of this application running for too long.
+	longQuery struct {
+		PID       int     `db:"pid"`
+		Seconds   float64 `db:"seconds"`
+		Query     string  `db:"query"`
+		Cancelled bool    `db:"cancelled"`
+	}
+)
+
+// sampleActivity counts the active and idle in transaction connections of an application.
+func (m *activityMonitor) sampleActivity(ctx context.Context, db sqlx.QueryerContext, appName string) error {
+	var active, idleInTx int64
+
+	if err := db.QueryRowxContext(ctx, `SELECT
+  count(*) FILTER (WHERE state = 'active'),
+  count(*) FILTER (WHERE state IN ('idle in transaction', 'idle in transaction (aborted)'))
+FROM pg_stat_activity
+WHERE application_name = $1 AND datname = current_database() AND pid <> pg_backend_pid()`, appName,
+	).Scan(&active, &idleInTx); err != nil {
+		return err
+	}
+
+	m.active.Store(active)
+	m.idleInTransaction.Store(idleInTx)
+
+	return nil
+}
+
+// cancelLongQueries cancels the queries of an application running for longer than maxDuration.
+func (m *activityMonitor) cancelLongQueries(ctx context.Context, db sqlx.QueryerContext, appName string, maxDuration time.Duration) ([]longQuery, error) {
+	var queries []longQuery
+
+	if err := sqlx.SelectContext(ctx, db, &queries, `SELECT
+  pid,
+  extract(epoch FROM now() - query_start)::float8 AS seconds,
+  left(query, $3) AS query,
+  pg_cancel_backend(pid) AS cancelled
+FROM pg_stat_activity
+WHERE application_name = $1 AND datname = current_database() AND pid <> pg_backend_pid()
+  AND state = 'active' AND query_start < now() - make_interval(secs => $2)`,
+		appName, maxDuration.Seconds(), maxReportedQueryLength,
+	); err != nil {
+		return nil, err
+	}
+
+	for _, q := range queries {
+		if q.Cancelled {
+			m.cancelled.Add(1)
+		}
+	}
+
+	return queries, nil
+}
+
+// startActivityMonitor periodically samples pg_stat_activity, and cancels long running queries if configured to.
+//
+// It returns a function to stop the monitor.
+func (r *Repository) startActivityMonitor(m activityMonitorSettings, l log.Logger) func() {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultSettings.PGConfig.ActivityMonitor.Interval
+	}
+
+	appName := r.applicationName(r.app)
+	if appName == "" {
+		l.Warn("activity monitor disabled: connections cannot be attributed without an application name")
+
+		return func() {}
+	}
+
+	r.activity = &activityMonitor{}
+	ctx, cancel := context.WithCancel(context.Background())
+	db := r.db
+	l = l.With(zap.String("application_name", appName))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.monitorActivity(ctx, db, appName, m.MaxQueryDuration, r.maxWait(), l)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (r *Repository) monitorActivity(ctx context.Context, db *sqlx.DB, appName string, maxQueryDuration, timeout time.Duration, l log.Logger) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := r.activity.sampleActivity(ctx, db, appName); err != nil {
+		if ctx.Err() == nil {
+			l.Warn("could not sample pg_stat_activity", zap.Error(err))
+		}
+
+		return
+	}
+
+	if maxQueryDuration <= 0 {
+		return
+	}
+
+	queries, err := r.activity.cancelLongQueries(ctx, db, appName, maxQueryDuration)
+	if err != nil {
+		if ctx.Err() == nil {
+			l.Warn("could not cancel long running queries", zap.Error(err))
+		}
+
+		return
+	}
+
+	for _, q := range queries {
+		l.Warn("long running query exceeded the maximum duration",
+			zap.Int("pid", q.PID),
+			zap.Duration("duration", time.Duration(q.Seconds*float64(time.Second))),
+			zap.Duration("max_duration", maxQueryDuration),
+			zap.Bool("cancelled", q.Cancelled),
+			zap.String("query", q.Query),
+		)
+	}
+}
@@ -0,0 +1,115 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestActivityMonitorSettings(t *testing.T) {
+	ps := poolSettingsFromOptions([]PoolOption{WithActivityMonitor(time.Minute, 5*time.Minute)})
+	require.True(t, ps.ActivityMonitor.Enabled)
+	require.Equal(t, time.Minute, ps.ActivityMonitor.Interval)
+	require.Equal(t, 5*time.Minute, ps.ActivityMonitor.MaxQueryDuration)
+
+	require.False(t, defaultSettings.PGConfig.ActivityMonitor.Enabled)
+	require.Equal(t, 30*time.Second, defaultSettings.PGConfig.ActivityMonitor.Interval)
+}
+
+func TestMonitorActivity(t *testing.T) {
+	server := &fakeActivity{
+		active:   3,
+		idleInTx: 1,
+		long: [][]driver.Value{
+			{int64(42), float64(600), "SELECT pg_sleep(3600)", true},
+			{int64(43), float64(400), "SELECT pg_sleep(3600)", false},
+		},
+	}
+	db := sqlx.NewDb(sql.OpenDB(server), driverName)
+	t.Cleanup(func() { _ = db.Close() })
+
+	r := New(DefaultDBAlias, WithLogger(zap.NewNop()))
+	r.db = db
+	r.activity = &activityMonitor{}
+	l := log.NewFactory(zap.NewNop()).Bg()
+	ctx := context.Background()
+
+	t.Run("should only sample activity", func(t *testing.T) {
+		r.monitorActivity(ctx, db, "billing", 0, time.Second, l)
+
+		require.Equal(t, int64(3), r.activity.active.Load())
+		require.Equal(t, int64(1), r.activity.idleInTransaction.Load())
+		require.Equal(t, int64(0), r.activity.cancelled.Load())
+		require.Len(t, server.queries(), 1)
+		require.Equal(t, []driver.Value{"billing"}, server.args)
+	})
+
+	t.Run("should cancel long running queries", func(t *testing.T) {
+		server.active = 2
+		r.monitorActivity(ctx, db, "billing", 5*time.Minute, time.Second, l)
+
+		require.Equal(t, int64(2), r.activity.active.Load())
+		require.Equal(t, int64(1), r.activity.cancelled.Load())
+		queries := server.queries()
+		require.Len(t, queries, 3)
+		require.Contains(t, queries[2], "pg_cancel_backend")
+		require.Equal(t, []driver.Value{"billing", float64(300), int64(maxReportedQueryLength)}, server.args)
+	})
+
+	t.Run("should expose activity metrics", func(t *testing.T) {
+		collector := r.Collector()
+		reg := prometheus.NewPedanticRegistry()
+		require.NoError(t, reg.Register(collector))
+
+		count, err := testutil.GatherAndCount(reg,
+			"pgrepo_activity_active_connections",
+			"pgrepo_activity_idle_in_transaction_connections",
+			"pgrepo_activity_cancelled_queries_total",
+		)
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+	})
+
+	t.Run("should not cancel queries when the sampling fails", func(t *testing.T) {
+		server.err = errors.New("permission denied")
+		defer func() { server.err = nil }()
+
+		before := len(server.queries())
+		r.monitorActivity(ctx, db, "billing", 5*time.Minute, time.Second, l)
+		require.Len(t, server.queries(), before+1)
+	})
+}
+
+// fakeActivity is a fake server which answers queries on pg_stat_activity.
+type fakeActivity struct {
+	fakeServer
+	active   int64
+	idleInTx int64
+	long     [][]driver.Value
+}
+
+func (f *fakeActivity) Connect(context.Context) (driver.Conn, error) {
+	return f.connect(f.answer)
+}
+
+func (f *fakeActivity) answer(query string, _ []driver.Value) (*fakeRows, error) {
+	if strings.Contains(query, "pg_cancel_backend") {
+		return &fakeRows{columns: []string{"pid", "seconds", "query", "cancelled"}, rows: f.long}, nil
+	}
+
+	return &fakeRows{
+		columns: []string{"active", "idle_in_transaction"},
+		rows:    [][]driver.Value{{f.active, f.idleInTx}},
+	}, nil
+}
@@ -0,0 +1,97 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type (
+	// BulkOption tunes the behavior of bulk loading helpers.
+	BulkOption func(*bulkOptions)
+
+	bulkOptions struct {
+		analyzeThreshold int64
+		vacuum           bool
+		format           string
+		progressInterval time.Duration
+		progress         func(CopyProgress)
+	}
+)
+
+func bulkOptionsWithDefaults(opts []BulkOption) bulkOptions {
+	var o bulkOptions
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	return o
+}
+
+// WithAnalyzeThreshold triggers an ANALYZE of the loaded table whenever at least "rows" rows have been loaded.
+//
+// This avoids pathological query plans on freshly loaded test or staging data.
+//
+// A zero or negative threshold disables the automatic ANALYZE (the default).
+func WithAnalyzeThreshold(rows int64) BulkOption {
+	return func(o *bulkOptions) {
+		o.analyzeThreshold = rows
+	}
+}
+
+// WithVacuumAnalyze runs VACUUM ANALYZE rather than a plain ANALYZE after a bulk load.
+func WithVacuumAnalyze(enabled bool) BulkOption {
+	return func(o *bulkOptions) {
+		o.vacuum = enabled
+	}
+}
+
+// Analyze refreshes the planner statistics of a table, optionally qualified by its schema (e.g. "public.users").
+//
+// With vacuum set to true, a VACUUM ANALYZE is performed instead.
+//
+// The default deadline of admin operations applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) Analyze(ctx context.Context, table string, vacuum bool) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationAdmin)
+	defer cancel()
+
+	cmd := "ANALYZE"
+	if vacuum {
+		cmd = "VACUUM ANALYZE"
+	}
+
+	if _, err := r.db.ExecContext(ctx, fmt.Sprintf(`%s %s`, cmd, quoteQualifiedIdentifier(table))); err != nil {
+		return fmt.Errorf("could not analyze table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// AnalyzeAfterLoad analyzes a table after a bulk load of "rows" rows,
+// provided the threshold configured with WithAnalyzeThreshold is reached.
+//
+// It returns true if the table has been analyzed.
+func (r *Repository) AnalyzeAfterLoad(ctx context.Context, table string, rows int64, opts ...BulkOption) (bool, error) {
+	o := bulkOptionsWithDefaults(opts)
+	if o.analyzeThreshold <= 0 || rows < o.analyzeThreshold {
+		return false, nil
+	}
+
+	r.logger(ctx).Debug("analyzing table after bulk load",
+		zap.String("table", table),
+		zap.Int64("rows", rows),
+		zap.Bool("vacuum", o.vacuum),
+	)
+
+	if err := r.Analyze(ctx, table, o.vacuum); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,115 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAnalyze(t *testing.T) {
+	ctx := context.Background()
+
+	newRepository := func(t *testing.T) (*Repository, *fakeServer) {
+		server := &fakeServer{}
+		db := sqlx.NewDb(sql.OpenDB(server), driverName)
+		t.Cleanup(func() { _ = db.Close() })
+
+		r := New(DefaultDBAlias, WithLogger(zap.NewNop()))
+		r.db = db
+
+		return r, server
+	}
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		require.ErrorIs(t, (&Repository{}).Analyze(ctx, "users", false), ErrDBNotInitialized)
+	})
+
+	t.Run("should analyze a table", func(t *testing.T) {
+		r, server := newRepository(t)
+
+		require.NoError(t, r.Analyze(ctx, "users", false))
+		require.Equal(t, []string{`ANALYZE "users"`}, server.queries())
+	})
+
+	t.Run("should vacuum and analyze a table", func(t *testing.T) {
+		r, server := newRepository(t)
+
+		require.NoError(t, r.Analyze(ctx, "users", true))
+		require.Equal(t, []string{`VACUUM ANALYZE "users"`}, server.queries())
+	})
+
+	t.Run("should quote identifiers", func(t *testing.T) {
+		r, server := newRepository(t)
+
+		require.NoError(t, r.Analyze(ctx, "public.Users", false))
+		require.NoError(t, r.Analyze(ctx, `users"; DROP TABLE users; --`, false))
+		require.Equal(t, []string{
+			`ANALYZE "public"."Users"`,
+			`ANALYZE "users""; DROP TABLE users; --"`,
+		}, server.queries())
+	})
+
+	t.Run("should report failures", func(t *testing.T) {
+		r, server := newRepository(t)
+		server.err = errors.New("permission denied")
+
+		err := r.Analyze(ctx, "users", false)
+		require.ErrorIs(t, err, server.err)
+		require.Contains(t, err.Error(), "users")
+	})
+}
+
+func TestAnalyzeAfterLoad(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	db := sqlx.NewDb(sql.OpenDB(server), driverName)
+	t.Cleanup(func() { _ = db.Close() })
+
+	r := New(DefaultDBAlias, WithLogger(zap.NewNop()))
+	r.db = db
+
+	for _, toPin := range []struct {
+		name     string
+		rows     int64
+		opts     []BulkOption
+		expected string
+	}{
+		{name: "disabled by default", rows: 1_000_000},
+		{name: "disabled with a zero threshold", rows: 1_000_000, opts: []BulkOption{WithAnalyzeThreshold(0)}},
+		{name: "disabled with a negative threshold", rows: 1_000_000, opts: []BulkOption{WithAnalyzeThreshold(-1)}},
+		{name: "below the threshold", rows: 999, opts: []BulkOption{WithAnalyzeThreshold(1000)}},
+		{name: "at the threshold", rows: 1000, opts: []BulkOption{WithAnalyzeThreshold(1000)}, expected: `ANALYZE "staging"."orders"`},
+		{name: "above the threshold", rows: 1001, opts: []BulkOption{WithAnalyzeThreshold(1000)}, expected: `ANALYZE "staging"."orders"`},
+		{
+			name:     "with a vacuum",
+			rows:     1000,
+			opts:     []BulkOption{WithAnalyzeThreshold(1000), WithVacuumAnalyze(true)},
+			expected: `VACUUM ANALYZE "staging"."orders"`,
+		},
+	} {
+		tc := toPin
+
+		t.Run(tc.name, func(t *testing.T) {
+			before := len(server.queries())
+
+			analyzed, err := r.AnalyzeAfterLoad(ctx, "staging.orders", tc.rows, tc.opts...)
+			require.NoError(t, err)
+
+			queries := server.queries()[before:]
+			if tc.expected == "" {
+				require.False(t, analyzed)
+				require.Empty(t, queries)
+
+				return
+			}
+
+			require.True(t, analyzed)
+			require.Equal(t, []string{tc.expected}, queries)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Authentication modes.
+const (
+	// AuthPassword authenticates with a static password (the default).
+	AuthPassword = "password"
+
+	// AuthAWSIAM authenticates against AWS RDS with short-lived IAM authentication tokens.
+	//
+	// This mode is provided by the awsiam package, which must be imported to register it.
+	AuthAWSIAM = "aws-iam"
+)
+
+type (
+	// AuthSettings are the settings passed to an authentication mode.
+	AuthSettings struct {
+		Region string // the region of the cloud provider, e.g. the AWS region of an RDS instance
+	}
+
+	// BeforeConnectFunc is called before a new connection is established, e.g. to set a short-lived password.
+	BeforeConnectFunc func(context.Context, *pgx.ConnConfig) error
+
+	// AuthMode builds the function which sets credentials before connecting, for an authentication mode.
+	AuthMode func(AuthSettings) BeforeConnectFunc
+
+	awsSettings struct {
+		Region string // the AWS region of the RDS instance. Defaults to the region resolved by the AWS SDK.
+	}
+)
+
+var (
+	authModes   = map[string]AuthMode{}
+	authModesMx sync.RWMutex
+)
+
+// RegisterAuthMode registers an authentication mode, which may then be selected by the "auth" setting of a database.
+//
+// Authentication modes which depend on third-party SDKs are provided by separate packages, which register them
+// when imported, e.g.:
+//
+//	import _ "github.com/fredbi/pgxutils/pgrepo/awsiam"
+func RegisterAuthMode(name string, mode AuthMode) {
+	authModesMx.Lock()
+	defer authModesMx.Unlock()
+
+	authModes[name] = mode
+}
+
+func registeredAuthMode(name string) (AuthMode, bool) {
+	authModesMx.RLock()
+	defer authModesMx.RUnlock()
+
+	mode, ok := authModes[name]
+
+	return mode, ok
+}
+
+// WithAWSIAMAuth authenticates against AWS RDS with IAM authentication tokens, instead of a password.
+//
+// A new token is generated before each connection is established. If region is empty, the region resolved by the AWS SDK
+// (e.g. AWS_REGION) is used.
+//
+// The awsiam package must be imported to register this authentication mode.
+func WithAWSIAMAuth(region string) DBOption {
+	return func(o *databaseSettings) {
+		o.Auth = AuthAWSIAM
+		o.AWS.Region = region
+	}
+}
+
+func (r databaseSettings) validateAuth() error {
+	switch r.Auth {
+	case "", AuthPassword:
+		return nil
+	}
+
+	if _, ok := registeredAuthMode(r.Auth); !ok {
+		return fmt.Errorf("unsupported authentication mode %q (is the package providing it imported?): %w", r.Auth, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// authenticate returns the function which sets credentials before connecting, with the authentication mode
+// of the database, if any.
+func (r databaseSettings) authenticate() func(context.Context, *pgx.ConnConfig) error {
+	switch r.Auth {
+	case "", AuthPassword:
+		return nil
+	}
+
+	mode, ok := registeredAuthMode(r.Auth)
+	if !ok {
+		return nil
+	}
+
+	return mode(AuthSettings{Region: r.AWS.Region})
+}
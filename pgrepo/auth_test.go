@@ -0,0 +1,46 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthModes(t *testing.T) {
+	t.Run("should require a registered mode", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL}
+		WithAWSIAMAuth("eu-west-1")(&dbs)
+
+		// the awsiam package is not imported
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should set credentials before connecting", func(t *testing.T) {
+		const mode = "test-token"
+		RegisterAuthMode(mode, func(s AuthSettings) BeforeConnectFunc {
+			return func(_ context.Context, cfg *pgx.ConnConfig) error {
+				cfg.Password = "token-" + s.Region
+
+				return nil
+			}
+		})
+
+		dbs := databaseSettings{URL: DefaultURL, Auth: mode, AWS: awsSettings{Region: "eu-west-1"}}
+		require.NoError(t, dbs.Validate())
+
+		before := dbs.beforeConnect()
+		require.NotNil(t, before)
+
+		cfg, err := pgx.ParseConfig(dbs.DBURL())
+		require.NoError(t, err)
+		require.NoError(t, before(context.Background(), cfg))
+		require.Equal(t, "token-eu-west-1", cfg.Password)
+	})
+
+	t.Run("should reject unknown authentication modes", func(t *testing.T) {
+		require.ErrorIs(t, databaseSettings{URL: DefaultURL, Auth: "kerberos"}.Validate(), ErrInvalidConfig)
+		require.Nil(t, databaseSettings{URL: DefaultURL}.beforeConnect())
+	})
+}
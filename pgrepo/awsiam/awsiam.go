@@ -0,0 +1,84 @@
+// Package awsiam authenticates against AWS RDS with short-lived IAM authentication tokens, instead of a password.
+//
+// Importing this package registers the pgrepo.AuthAWSIAM authentication mode, e.g.:
+//
+//	import _ "github.com/fredbi/pgxutils/pgrepo/awsiam"
+//
+// then select it with the "auth: aws-iam" setting of a database, or with pgrepo.WithAWSIAMAuth.
+//
+// AWS credentials are resolved from the environment, using the default credential chain of the AWS SDK.
+//
+// It is a separate module, so that the dependencies of the AWS SDK are not required by pgrepo.
+package awsiam
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/jackc/pgx/v5"
+)
+
+func init() {
+	pgrepo.RegisterAuthMode(pgrepo.AuthAWSIAM, New)
+}
+
+// New returns a function which sets an RDS authentication token as the password, before connecting.
+//
+// The AWS configuration is loaded once. AWS credentials are cached and refreshed by the AWS SDK.
+// If the region is empty, the region resolved by the AWS SDK (e.g. AWS_REGION) is used.
+func New(s pgrepo.AuthSettings) pgrepo.BeforeConnectFunc {
+	var (
+		mx     sync.Mutex
+		awsCfg *aws.Config
+	)
+
+	loadConfig := func(ctx context.Context) (*aws.Config, error) {
+		mx.Lock()
+		defer mx.Unlock()
+
+		if awsCfg != nil {
+			return awsCfg, nil
+		}
+
+		var opts []func(*config.LoadOptions) error
+		if s.Region != "" {
+			opts = append(opts, config.WithRegion(s.Region))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("could not load AWS configuration: %w", err)
+		}
+
+		if cfg.Region == "" {
+			return nil, fmt.Errorf("no AWS region configured for IAM authentication: %w", pgrepo.ErrInvalidConfig)
+		}
+		awsCfg = &cfg
+
+		return awsCfg, nil
+	}
+
+	return func(ctx context.Context, cfg *pgx.ConnConfig) error {
+		awsCfg, err := loadConfig(ctx)
+		if err != nil {
+			return err
+		}
+
+		endpoint := net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port)))
+		token, err := auth.BuildAuthToken(ctx, endpoint, awsCfg.Region, cfg.User, awsCfg.Credentials)
+		if err != nil {
+			return fmt.Errorf("could not build an RDS authentication token: %w", err)
+		}
+
+		cfg.Password = token
+
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+package awsiam
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAWSIAMAuth(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	const url = "postgresql://app@mydb.123456789012.eu-west-1.rds.amazonaws.com:5432/orders"
+
+	t.Run("should generate a token before connecting", func(t *testing.T) {
+		before := New(pgrepo.AuthSettings{Region: "eu-west-1"})
+
+		cfg, err := pgx.ParseConfig(url)
+		require.NoError(t, err)
+		require.NoError(t, before(context.Background(), cfg))
+
+		require.Contains(t, cfg.Password, "mydb.123456789012.eu-west-1.rds.amazonaws.com:5432?Action=connect")
+		require.Contains(t, cfg.Password, "DBUser=app")
+		require.Contains(t, cfg.Password, "X-Amz-Signature=")
+	})
+
+	t.Run("should require a region", func(t *testing.T) {
+		t.Setenv("AWS_REGION", "")
+		t.Setenv("AWS_DEFAULT_REGION", "")
+
+		cfg, err := pgx.ParseConfig(url)
+		require.NoError(t, err)
+		require.ErrorIs(t, New(pgrepo.AuthSettings{})(context.Background(), cfg), pgrepo.ErrInvalidConfig)
+	})
+
+	t.Run("should be registered", func(t *testing.T) {
+		r := pgrepo.New(pgrepo.DefaultDBAlias,
+			pgrepo.WithDatabaseSettings(pgrepo.DefaultDBAlias, pgrepo.WithURL(url), pgrepo.WithAWSIAMAuth("eu-west-1")),
+		)
+		require.NoError(t, r.Validate())
+	})
+}
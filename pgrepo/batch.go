@@ -0,0 +1,45 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// SendBatch sends a batch of statements to the master database in a single round trip.
+//
+// This pipelines many small statements, e.g. to insert or update a few rows in several tables.
+// Results are processed by the callbacks of the queued statements, e.g.:
+//
+//	batch := &pgx.Batch{}
+//	batch.Queue(`UPDATE accounts SET balance = balance - $1 WHERE id = $2`, amount, from)
+//	batch.Queue(`SELECT balance FROM accounts WHERE id = $1`, to).QueryRow(func(row pgx.Row) error {
+//		return row.Scan(&balance)
+//	})
+//
+//	err := repo.SendBatch(ctx, batch)
+//
+// Statements are run in an implicit transaction: when a statement fails, the previous ones are rolled back.
+//
+// The batch runs on a native pgx connection (see Repository.CopyFrom). The default deadline of writes applies.
+func (r *Repository) SendBatch(ctx context.Context, batch *pgx.Batch) error {
+	if batch == nil || batch.Len() == 0 {
+		return nil
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationWrite)
+	defer cancel()
+
+	err := r.withConn(ctx, func(ctx context.Context, conn *pgx.Conn) error {
+		return conn.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return fmt.Errorf("could not send a batch of %d statements: %w", batch.Len(), err)
+	}
+
+	r.logger(ctx).Debug("batch sent", zap.Int("statements", batch.Len()))
+
+	return nil
+}
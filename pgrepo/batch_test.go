@@ -0,0 +1,25 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendBatch(t *testing.T) {
+	repo := New(DefaultDBAlias)
+
+	t.Run("should not send an empty batch", func(t *testing.T) {
+		require.NoError(t, repo.SendBatch(context.Background(), nil))
+		require.NoError(t, repo.SendBatch(context.Background(), &pgx.Batch{}))
+	})
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		batch := &pgx.Batch{}
+		batch.Queue(`SELECT 1`)
+
+		require.ErrorIs(t, repo.SendBatch(context.Background(), batch), ErrDBNotInitialized)
+	})
+}
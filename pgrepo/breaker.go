@@ -0,0 +1,175 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker is open, after consecutive connection failures or timeouts.
+//
+// Callers fail fast until the cool-down period elapses.
+var ErrCircuitOpen = errors.New("circuit open: database unavailable")
+
+var _ pgx.QueryTracer = &circuitBreaker{}
+
+// WithCircuitBreaker enables a circuit breaker, which trips after threshold consecutive connection failures
+// or timeouts, then fast-fails callers with ErrCircuitOpen during the cool-down period.
+func WithCircuitBreaker(threshold int, coolDown time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Breaker.Enabled = true
+		o.Breaker.Threshold = threshold
+		o.Breaker.CoolDown = coolDown
+	}
+}
+
+// circuitBreaker guards the connections to the master database.
+//
+// Failures are recorded when connecting and when queries complete. When open, new connections fail with
+// ErrCircuitOpen and idle connections are discarded when reused, so callers fail fast.
+//
+// After the cool-down period, a single trial is allowed through (half-open): the circuit closes if it succeeds,
+// and opens again otherwise.
+type circuitBreaker struct {
+	mx        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	armed     bool
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	l         log.Logger
+}
+
+func newCircuitBreaker(bs breakerSettings, l log.Logger) *circuitBreaker {
+	if !bs.Enabled {
+		return nil
+	}
+
+	defaults := defaultSettings.PGConfig.Breaker
+	if bs.Threshold <= 0 {
+		bs.Threshold = defaults.Threshold
+	}
+	if bs.CoolDown <= 0 {
+		bs.CoolDown = defaults.CoolDown
+	}
+
+	return &circuitBreaker{
+		threshold: bs.Threshold,
+		coolDown:  bs.CoolDown,
+		l:         l,
+	}
+}
+
+// arm starts recording failures, e.g. once the repository is started.
+func (b *circuitBreaker) arm() {
+	if b == nil {
+		return
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	b.armed = true
+}
+
+// allow tells if a connection may be attempted.
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if b.openedAt.IsZero() {
+		return nil
+	}
+
+	if b.probing || time.Since(b.openedAt) < b.coolDown {
+		return ErrCircuitOpen
+	}
+
+	// half-open: let a trial through
+	b.probing = true
+
+	return nil
+}
+
+// isOpen tells if callers are currently fast-failed.
+func (b *circuitBreaker) isOpen() bool {
+	if b == nil {
+		return false
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	return !b.openedAt.IsZero() && (b.probing || time.Since(b.openedAt) < b.coolDown)
+}
+
+// record the outcome of a connection or a query.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	if !b.armed {
+		return
+	}
+
+	if !isBreakerFailure(err) {
+		if errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+			// no outcome: another trial may be attempted
+			b.probing = false
+
+			return
+		}
+
+		if !b.openedAt.IsZero() {
+			b.l.Info("circuit breaker closed: database available")
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		b.probing = false
+
+		return
+	}
+
+	b.failures++
+	if b.probing || (b.openedAt.IsZero() && b.failures >= b.threshold) {
+		b.l.Warn("circuit breaker open: database unavailable",
+			zap.Int("consecutive_failures", b.failures),
+			zap.Duration("cool_down", b.coolDown),
+			zap.Error(err),
+		)
+		b.openedAt = time.Now()
+		b.probing = false
+	}
+}
+
+func (b *circuitBreaker) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (b *circuitBreaker) TraceQueryEnd(_ context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	b.record(data.Err)
+}
+
+// isBreakerFailure tells if an error counts as a failure of the database: a connection failure or a timeout.
+func isBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, context.DeadlineExceeded) || IsConnectionError(err)
+}
@@ -0,0 +1,94 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	l := log.NewFactory(zap.NewNop()).Bg()
+	errRefused := &pgconn.PgError{Code: "08006", Message: "connection failure"}
+
+	t.Run("should be disabled by default", func(t *testing.T) {
+		b := newCircuitBreaker(breakerSettings{}, l)
+		require.Nil(t, b)
+		require.NoError(t, b.allow())
+		require.False(t, b.isOpen())
+		b.record(errRefused)
+	})
+
+	t.Run("should trip after consecutive failures", func(t *testing.T) {
+		b := newCircuitBreaker(breakerSettings{Enabled: true, Threshold: 3, CoolDown: 50 * time.Millisecond}, l)
+
+		b.record(errRefused)
+		require.False(t, b.isOpen(), "failures before the breaker is armed should be ignored")
+
+		b.arm()
+		b.record(errRefused)
+		b.record(errRefused)
+		b.record(nil)
+		b.record(errRefused)
+		b.record(context.DeadlineExceeded)
+		require.NoError(t, b.allow(), "a success should reset consecutive failures")
+
+		b.record(&pgconn.PgError{Code: "23505", Message: "duplicate key"})
+		require.NoError(t, b.allow(), "a query error should count as a success")
+
+		b.record(errRefused)
+		b.record(driver.ErrBadConn)
+		b.record(context.DeadlineExceeded)
+		require.True(t, b.isOpen())
+		require.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+		t.Run("should let a single trial through after the cool-down", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return b.allow() == nil
+			}, time.Second, 10*time.Millisecond)
+			require.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+			b.record(errRefused)
+			require.ErrorIs(t, b.allow(), ErrCircuitOpen, "a failed trial should open the circuit again")
+
+			require.Eventually(t, func() bool {
+				return b.allow() == nil
+			}, time.Second, 10*time.Millisecond)
+
+			b.record(nil)
+			require.False(t, b.isOpen())
+			require.NoError(t, b.allow())
+		})
+	})
+
+	t.Run("should record query outcomes", func(t *testing.T) {
+		b := newCircuitBreaker(breakerSettings{Enabled: true, Threshold: 1}, l)
+		b.arm()
+
+		ctx := b.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+		b.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.Join(errors.New("timeout"), context.DeadlineExceeded)})
+		require.True(t, b.isOpen())
+	})
+
+	t.Run("should fail fast on connect", func(t *testing.T) {
+		b := newCircuitBreaker(breakerSettings{Enabled: true, Threshold: 1, CoolDown: time.Minute}, l)
+		b.arm()
+		b.record(errRefused)
+
+		dbs := databaseSettings{URL: DefaultURL}
+		dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "", b)
+		require.NotNil(t, dcfg)
+
+		connector := newReloadableConnector(dcfg, dbs, b)
+		_, err := connector.Connect(context.Background())
+		require.ErrorIs(t, err, ErrCircuitOpen)
+		require.ErrorIs(t, connector.resetSession(context.Background(), nil), driver.ErrBadConn)
+	})
+}
@@ -0,0 +1,118 @@
+package pgrepo
+
+import (
+	"runtime/debug"
+	"sort"
+)
+
+const (
+	modulePath = "github.com/fredbi/pgxutils"
+	pgxPath    = "github.com/jackc/pgx/v5"
+
+	unknownVersion = "unknown"
+)
+
+// readBuildInfo is the source of build information, which may be replaced by tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// VersionInfo describes the version of the data layer a service runs.
+type VersionInfo struct {
+	Version    string   `json:"version"`            // version of this module
+	PGXVersion string   `json:"pgx_version"`        // version of the pgx driver
+	GoVersion  string   `json:"go_version"`         // version of go used to build the service
+	Features   []string `json:"features,omitempty"` // features enabled for a repository
+}
+
+// BuildInfo returns the versions of this module and of the pgx driver, as built into the service.
+//
+// Versions are "unknown" when the service is built without module support.
+func BuildInfo() VersionInfo {
+	info := VersionInfo{
+		Version:    unknownVersion,
+		PGXVersion: unknownVersion,
+		GoVersion:  unknownVersion,
+	}
+
+	bi, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+
+	info.GoVersion = bi.GoVersion
+	if bi.Main.Path == modulePath {
+		// e.g. when running the tests of this module
+		info.Version = bi.Main.Version
+	}
+
+	for _, dep := range bi.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+
+		switch dep.Path {
+		case modulePath:
+			info.Version = dep.Version
+		case pgxPath:
+			info.PGXVersion = dep.Version
+		}
+	}
+
+	return info
+}
+
+// BuildInfo returns the versions of this module and of the pgx driver, and the features enabled for this repository.
+func (r *Repository) BuildInfo() VersionInfo {
+	info := BuildInfo()
+	info.Features = r.features()
+
+	return info
+}
+
+// features lists the optional features enabled by the settings, in alphabetical order.
+func (r databaseSettings) features() []string {
+	enabled := make(map[string]bool)
+
+	enabled["aws-iam"] = r.Auth == AuthAWSIAM
+	enabled["credentials-provider"] = r.credentials != nil
+	enabled["dry-run"] = r.dryRun
+	enabled["endpoint-resolver"] = r.resolver != nil
+	enabled["history"] = len(r.History.Tables) > 0
+	enabled["maintenance-jobs"] = len(r.MaintenanceJobs) > 0
+	enabled["metrics"] = r.registerer != nil
+	enabled["namespace"] = r.namespace != ""
+	enabled["otel-metrics"] = r.meterProvider != nil
+	enabled["push-gateway"] = r.pushGateway != nil
+	enabled["replicas"] = len(r.Replicas) > 0
+	enabled["standby"] = r.Standby.URL != ""
+	enabled["tls"] = r.TLS.isSet()
+
+	if ps := r.PGConfig; ps != nil {
+		enabled["activity-monitor"] = ps.ActivityMonitor.Enabled
+		enabled["circuit-breaker"] = ps.Breaker.Enabled
+		enabled["compat-pgbouncer"] = ps.transactionPooling()
+		enabled["maintenance"] = ps.Maintenance.Enabled
+		enabled["partition"] = ps.Partition.Enabled
+		enabled["prepared-xact-check"] = ps.PreparedXacts.Enabled
+		enabled["recent-queries"] = ps.RecentQueries > 0
+		enabled["reset-policy"] = ps.resetPolicy() != ResetPolicyNone
+		enabled["retry-queries"] = ps.Retry.Queries
+		enabled["sensitive-params"] = ps.SensitiveParams
+		enabled["trace-datadog"] = ps.Trace.usesTraceProvider(TraceProviderDatadog)
+		enabled["trace-explain"] = ps.Trace.usesTraceProvider(TraceProviderOTel) && ps.Trace.ExplainThreshold > 0
+		enabled["trace-opencensus"] = ps.Trace.usesTraceProvider(TraceProviderOpenCensus)
+		enabled["trace-otel"] = ps.Trace.usesTraceProvider(TraceProviderOTel)
+		enabled["trace-stats"] = ps.Trace.statsInterval() > 0
+		enabled["tx-check"] = ps.TxCheck.Enabled
+		enabled["wait-monitor"] = ps.WaitMonitor.Enabled
+	}
+
+	features := make([]string, 0, len(enabled))
+	for feature, ok := range enabled {
+		if ok {
+			features = append(features, feature)
+		}
+	}
+	sort.Strings(features)
+
+	return features
+}
@@ -0,0 +1,84 @@
+package pgrepo
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildInfo(t *testing.T) {
+	t.Run("should report versions from the build", func(t *testing.T) {
+		stubBuildInfo(t, &debug.BuildInfo{
+			GoVersion: "go1.21.4",
+			Main:      debug.Module{Path: "example.com/service", Version: "v1.2.3"},
+			Deps: []*debug.Module{
+				{Path: modulePath, Version: "v0.4.0"},
+				{Path: pgxPath, Version: "v5.4.0", Replace: &debug.Module{Path: pgxPath, Version: "v5.5.0"}},
+				{Path: "github.com/jmoiron/sqlx", Version: "v1.3.5"},
+			},
+		}, true)
+
+		require.Equal(t, VersionInfo{
+			Version:    "v0.4.0",
+			PGXVersion: "v5.5.0",
+			GoVersion:  "go1.21.4",
+		}, BuildInfo())
+	})
+
+	t.Run("should report the version of the main module", func(t *testing.T) {
+		stubBuildInfo(t, &debug.BuildInfo{
+			GoVersion: "go1.21.4",
+			Main:      debug.Module{Path: modulePath, Version: "(devel)"},
+		}, true)
+
+		info := BuildInfo()
+		require.Equal(t, "(devel)", info.Version)
+		require.Equal(t, unknownVersion, info.PGXVersion)
+	})
+
+	t.Run("should report unknown versions without build information", func(t *testing.T) {
+		stubBuildInfo(t, nil, false)
+
+		require.Equal(t, VersionInfo{
+			Version:    unknownVersion,
+			PGXVersion: unknownVersion,
+			GoVersion:  unknownVersion,
+		}, BuildInfo())
+	})
+
+	t.Run("should report the features of a repository", func(t *testing.T) {
+		r := New(DefaultDBAlias,
+			WithDryRun(),
+			WithDatabaseSettings(DefaultDBAlias,
+				WithURL(DefaultURL),
+				WithReplicas("postgres://replica:5432/testdb"),
+				WithPoolSettings(WithCircuitBreaker(3, 0), WithTracing(true), WithTraceProvider(TraceProviderOTel)),
+			),
+		)
+
+		require.Equal(t, []string{"circuit-breaker", "dry-run", "replicas", "trace-otel"}, r.BuildInfo().Features)
+		require.Empty(t, New(DefaultDBAlias).BuildInfo().Features)
+	})
+}
+
+func TestHealthCheckVerbose(t *testing.T) {
+	r := New("reporting")
+
+	status := r.HealthCheckVerbose(context.Background())
+	require.Equal(t, "reporting", status.Alias)
+	require.False(t, status.Healthy)
+	require.Equal(t, ErrDBNotInitialized.Error(), status.Error)
+	require.NotEmpty(t, status.Build.GoVersion)
+}
+
+func stubBuildInfo(t *testing.T, bi *debug.BuildInfo, ok bool) {
+	t.Helper()
+
+	previous := readBuildInfo
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, ok }
+	t.Cleanup(func() {
+		readBuildInfo = previous
+	})
+}
@@ -0,0 +1,205 @@
+package pgrepo
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultChunkSize is the maximum number of elements of an array bound to "= ANY($n)" before a query is split into chunks.
+const DefaultChunkSize = 10000
+
+var (
+	rexAnyArg = regexp.MustCompile(`(?i)=\s*ANY\s*\(\s*\$(\d+)\s*(?:::[\w\s\[\]]+)?\)`)
+
+	// the operand of "= ANY($n)", preceded by the keyword which introduces the predicate
+	rexConjunct = regexp.MustCompile(`(?i)\b(WHERE|AND)\s+[\w."]+\s*$`)
+
+	rexDisjunction = regexp.MustCompile(`(?i)\bOR\b`)
+	rexPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+	// results of queries with these clauses would change if the query was split
+	rexNotChunkable = regexp.MustCompile(`(?i)\b(LIMIT|OFFSET|GROUP\s+BY|ORDER\s+BY|DISTINCT|HAVING|count|sum|avg|min|max|array_agg|string_agg)\b`)
+)
+
+// Select runs a query and scans all rows into a slice of T, like sqlx.Select.
+//
+// When the query filters on a large array, with "= ANY($n)", the array is split into chunks of DefaultChunkSize
+// elements and the results of each chunk are merged. Huge arrays otherwise degrade query plans and may exceed
+// message size limits.
+//
+// Queries which results would change when split (e.g. with ORDER BY, LIMIT, DISTINCT or aggregates) are not split.
+// Neither are queries where the predicate is not a top-level conjunct of the WHERE clause (e.g. with OR or NOT),
+// or where the array is bound more than once.
+//
+// Duplicate elements of a split array are removed, so that rows are not matched by several chunks.
+// Arrays of elements which cannot be compared (e.g. interfaces or slices) are not split.
+func Select[T any](ctx context.Context, db sqlx.QueryerContext, query string, args ...interface{}) ([]T, error) {
+	return SelectChunked[T](ctx, db, DefaultChunkSize, query, args...)
+}
+
+// SelectChunked is like Select, with a chunk size.
+func SelectChunked[T any](ctx context.Context, db sqlx.QueryerContext, chunkSize int, query string, args ...interface{}) ([]T, error) {
+	var result []T
+	err := forEachChunk(query, args, chunkSize, func(chunkArgs []interface{}) error {
+		var rows []T
+		if err := sqlx.SelectContext(ctx, db, &rows, query, chunkArgs...); err != nil {
+			return err
+		}
+		result = append(result, rows...)
+
+		return nil
+	})
+
+	return result, err
+}
+
+// Exec executes a statement and returns the number of affected rows.
+//
+// Like Select, large arrays bound to "= ANY($n)" are split into chunks of DefaultChunkSize elements.
+// Affected rows are summed over all chunks.
+//
+// Chunks are not executed atomically: use a transaction if required.
+func Exec(ctx context.Context, db sqlx.ExecerContext, query string, args ...interface{}) (int64, error) {
+	return ExecChunked(ctx, db, DefaultChunkSize, query, args...)
+}
+
+// ExecChunked is like Exec, with a chunk size.
+func ExecChunked(ctx context.Context, db sqlx.ExecerContext, chunkSize int, query string, args ...interface{}) (int64, error) {
+	var total int64
+	err := forEachChunk(query, args, chunkSize, func(chunkArgs []interface{}) error {
+		res, err := db.ExecContext(ctx, query, chunkArgs...)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		total += affected
+
+		return nil
+	})
+
+	return total, err
+}
+
+// forEachChunk calls fn with the arguments of each chunk, splitting the largest array bound to "= ANY($n)"
+// if it exceeds the chunk size. Otherwise, fn is called once with the original arguments.
+//
+// The elements of a split array are deduplicated first.
+func forEachChunk(query string, args []interface{}, chunkSize int, fn func([]interface{}) error) error {
+	pos, array := chunkedArg(query, args, chunkSize)
+	if pos < 0 {
+		return fn(args)
+	}
+
+	chunkArgs := make([]interface{}, len(args))
+	copy(chunkArgs, args)
+	array = distinct(array)
+
+	for start := 0; start < array.Len(); start += chunkSize {
+		end := min(start+chunkSize, array.Len())
+		chunkArgs[pos] = array.Slice(start, end).Interface()
+
+		if err := fn(chunkArgs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chunkedArg returns the position of the largest array argument bound to "= ANY($n)" which exceeds the chunk size,
+// or -1 if the query should not be split.
+func chunkedArg(query string, args []interface{}, chunkSize int) (int, reflect.Value) {
+	if chunkSize <= 0 || rexNotChunkable.MatchString(query) {
+		return -1, reflect.Value{}
+	}
+
+	if rexDisjunction.MatchString(query) {
+		// a chunk would yield the rows matching the other terms again
+		return -1, reflect.Value{}
+	}
+
+	bound := make(map[string]int)
+	for _, match := range rexPlaceholder.FindAllStringSubmatch(query, -1) {
+		bound[match[1]]++
+	}
+
+	pos, largest := -1, reflect.Value{}
+	for _, match := range rexAnyArg.FindAllStringSubmatchIndex(query, -1) {
+		placeholder := query[match[2]:match[3]]
+		if bound[placeholder] != 1 || !isTopLevelConjunct(query[:match[0]]) {
+			continue
+		}
+
+		n, err := strconv.Atoi(placeholder)
+		if err != nil || n < 1 || n > len(args) {
+			continue
+		}
+
+		v := reflect.ValueOf(args[n-1])
+		if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 || v.Len() <= chunkSize {
+			// []byte is not an array
+			continue
+		}
+
+		if elem := v.Type().Elem(); elem.Kind() == reflect.Interface || !elem.Comparable() {
+			// elements cannot be deduplicated
+			continue
+		}
+
+		if pos < 0 || v.Len() > largest.Len() {
+			pos, largest = n-1, v
+		}
+	}
+
+	return pos, largest
+}
+
+// distinct returns the elements of an array without duplicates, in their original order.
+func distinct(array reflect.Value) reflect.Value {
+	seen := make(map[interface{}]struct{}, array.Len())
+	unique := reflect.MakeSlice(array.Type(), 0, array.Len())
+	for i := 0; i < array.Len(); i++ {
+		elem := array.Index(i)
+		if _, duplicate := seen[elem.Interface()]; duplicate {
+			continue
+		}
+
+		seen[elem.Interface()] = struct{}{}
+		unique = reflect.Append(unique, elem)
+	}
+
+	return unique
+}
+
+// isTopLevelConjunct tells if the predicate following a prefix of the query is a term of the WHERE clause
+// combined with AND, outside of any parenthesis.
+func isTopLevelConjunct(prefix string) bool {
+	if !rexConjunct.MatchString(prefix) {
+		// e.g. "NOT id = ANY($1)"
+		return false
+	}
+
+	var depth int
+	var quoted bool
+	for _, c := range prefix {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		}
+	}
+
+	return depth == 0
+}
@@ -0,0 +1,88 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type execRecorder struct {
+	calls [][]interface{}
+}
+
+func (e *execRecorder) ExecContext(_ context.Context, _ string, args ...interface{}) (sql.Result, error) {
+	e.calls = append(e.calls, append([]interface{}(nil), args...))
+
+	return driver.RowsAffected(len(args[1].([]int64))), nil
+}
+
+func TestChunks(t *testing.T) {
+	ids := make([]int64, 25)
+	for i := range ids {
+		ids[i] = int64(i)
+	}
+
+	t.Run("should split large arrays", func(t *testing.T) {
+		recorder := &execRecorder{}
+		affected, err := ExecChunked(context.Background(), recorder, 10,
+			`UPDATE users SET active = $1 WHERE id = ANY($2::bigint[])`, false, ids,
+		)
+		require.NoError(t, err)
+
+		require.Equal(t, int64(25), affected)
+		require.Len(t, recorder.calls, 3)
+		require.Equal(t, []interface{}{false, ids[:10]}, recorder.calls[0])
+		require.Equal(t, []interface{}{false, ids[10:20]}, recorder.calls[1])
+		require.Equal(t, []interface{}{false, ids[20:]}, recorder.calls[2])
+	})
+
+	t.Run("should remove duplicates before splitting", func(t *testing.T) {
+		duplicated := append(append([]int64(nil), ids...), ids[:12]...)
+
+		recorder := &execRecorder{}
+		affected, err := ExecChunked(context.Background(), recorder, 10,
+			`UPDATE users SET active = $1 WHERE id = ANY($2::bigint[])`, false, duplicated,
+		)
+		require.NoError(t, err)
+
+		require.Equal(t, int64(25), affected)
+		require.Len(t, recorder.calls, 3)
+		require.Equal(t, []interface{}{false, ids[:10]}, recorder.calls[0])
+		require.Equal(t, []interface{}{false, ids[10:20]}, recorder.calls[1])
+		require.Equal(t, []interface{}{false, ids[20:]}, recorder.calls[2])
+	})
+
+	t.Run("should split the largest array", func(t *testing.T) {
+		pos, array := chunkedArg(`SELECT * FROM t WHERE a = ANY($1) AND b = any ( $2 )`, []interface{}{ids[:15], ids}, 10)
+		require.Equal(t, 1, pos)
+		require.Equal(t, 25, array.Len())
+	})
+
+	t.Run("should not split", func(t *testing.T) {
+		for _, fixture := range []struct {
+			Name  string
+			Query string
+			Args  []interface{}
+		}{
+			{Name: "small arrays", Query: `SELECT * FROM t WHERE id = ANY($1)`, Args: []interface{}{ids[:5]}},
+			{Name: "bytes", Query: `SELECT * FROM t WHERE id = ANY($1)`, Args: []interface{}{make([]byte, 50)}},
+			{Name: "interfaces", Query: `SELECT * FROM t WHERE id = ANY($1)`, Args: []interface{}{make([]interface{}, 50)}},
+			{Name: "ordered results", Query: `SELECT * FROM t WHERE id = ANY($1) ORDER BY id`, Args: []interface{}{ids}},
+			{Name: "aggregates", Query: `SELECT count(*) FROM t WHERE id = ANY($1)`, Args: []interface{}{ids}},
+			{Name: "limit", Query: `SELECT * FROM t WHERE id = ANY($1) LIMIT 10`, Args: []interface{}{ids}},
+			{Name: "other operators", Query: `SELECT * FROM t WHERE id <> ALL($1)`, Args: []interface{}{ids}},
+			{Name: "missing argument", Query: `SELECT * FROM t WHERE id = ANY($2)`, Args: []interface{}{ids}},
+			{Name: "negation", Query: `SELECT * FROM t WHERE NOT id = ANY($1)`, Args: []interface{}{ids}},
+			{Name: "disjunction", Query: `SELECT * FROM t WHERE id = ANY($1) OR owner = $2`, Args: []interface{}{ids, "bob"}},
+			{Name: "array bound twice", Query: `SELECT * FROM t WHERE a = ANY($1) AND b = ANY($1)`, Args: []interface{}{ids}},
+			{Name: "nested predicate", Query: `SELECT * FROM t WHERE NOT (id = ANY($1) AND active)`, Args: []interface{}{ids}},
+			{Name: "not a filter", Query: `SELECT id = ANY($1) AS found FROM t`, Args: []interface{}{ids}},
+		} {
+			pos, _ := chunkedArg(fixture.Query, fixture.Args, 10)
+			require.Equalf(t, -1, pos, "unexpected split with %s", fixture.Name)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ClaimBatch claims a batch of at most limit rows of a table matching a condition, and hands them to a callback.
+//
+// The rows are selected and locked with FOR UPDATE SKIP LOCKED within a transaction: concurrent workers claim
+// distinct batches, without waiting for each other. The transaction is committed if the callback returns no error,
+// so the callback should mark the claimed rows as processed (e.g. update their status, or delete them).
+// Otherwise, the transaction is rolled back and the rows may be claimed again.
+//
+// The condition may be empty, and may be followed by an ORDER BY clause (or be only an ORDER BY clause). It is bound to args.
+//
+// The callback is not called when there is no row to claim. The number of claimed rows is returned, e.g.:
+//
+//	for {
+//		n, err := ClaimBatch(ctx, repo, "outbox", `sent_at IS NULL ORDER BY id`, 100,
+//			func(ctx context.Context, tx *sqlx.Tx, batch []Message) error {
+//				// send messages, then mark them as sent within tx
+//			},
+//		)
+//		if err != nil || n == 0 {
+//			break
+//		}
+//	}
+//
+// Use ClaimBatchTx to pass transaction options (e.g. WithRetries).
+func ClaimBatch[T any](ctx context.Context, r *Repository, table, where string, limit int, fn func(context.Context, *sqlx.Tx, []T) error, args ...any) (int, error) {
+	return ClaimBatchTx[T](ctx, r, table, where, limit, nil, fn, args...)
+}
+
+// ClaimBatchTx is like ClaimBatch, with transaction options.
+func ClaimBatchTx[T any](ctx context.Context, r *Repository, table, where string, limit int, opts []TxOption, fn func(context.Context, *sqlx.Tx, []T) error, args ...any) (int, error) {
+	query, err := claimStatement(table, where, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var claimed int
+	err = r.RunInTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		var batch []T
+		if err := sqlx.SelectContext(ctx, tx, &batch, query, args...); err != nil {
+			return fmt.Errorf("could not claim rows from %s: %w", table, err)
+		}
+
+		claimed = len(batch)
+		if claimed == 0 {
+			return nil
+		}
+
+		return fn(ctx, tx, batch)
+	}, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	return claimed, nil
+}
+
+var rexOrderBy = regexp.MustCompile(`(?i)^ORDER\s+BY\b`)
+
+// claimStatement builds the statement which selects and locks a batch of rows, skipping locked rows.
+func claimStatement(table, where string, limit int) (string, error) {
+	if limit <= 0 {
+		return "", fmt.Errorf("the size of a batch to claim must be positive, but got %d: %w", limit, ErrInvalidConfig)
+	}
+
+	query := `SELECT * FROM ` + quoteQualifiedIdentifier(table)
+	switch where = strings.TrimSpace(where); {
+	case where == "":
+	case rexOrderBy.MatchString(where):
+		// only an ORDER BY clause
+		query += ` ` + where
+	default:
+		query += ` WHERE ` + where
+	}
+
+	return fmt.Sprintf(`%s LIMIT %d FOR UPDATE SKIP LOCKED`, query, limit), nil
+}
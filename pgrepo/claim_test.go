@@ -0,0 +1,53 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+type claimedRow struct {
+	ID int64 `db:"id"`
+}
+
+func TestClaimStatement(t *testing.T) {
+	t.Run("with a condition", func(t *testing.T) {
+		q, err := claimStatement("jobs.outbox", `sent_at IS NULL ORDER BY id`, 100)
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM "jobs"."outbox" WHERE sent_at IS NULL ORDER BY id LIMIT 100 FOR UPDATE SKIP LOCKED`, q)
+	})
+
+	t.Run("without a condition", func(t *testing.T) {
+		q, err := claimStatement("outbox", "", 10)
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM "outbox" LIMIT 10 FOR UPDATE SKIP LOCKED`, q)
+	})
+
+	t.Run("with an ORDER BY clause only", func(t *testing.T) {
+		q, err := claimStatement("outbox", " order by id", 10)
+		require.NoError(t, err)
+		require.Equal(t, `SELECT * FROM "outbox" order by id LIMIT 10 FOR UPDATE SKIP LOCKED`, q)
+	})
+
+	t.Run("should require a positive limit", func(t *testing.T) {
+		_, err := claimStatement("outbox", "", 0)
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+func TestClaimBatch(t *testing.T) {
+	noop := func(context.Context, *sqlx.Tx, []claimedRow) error { return nil }
+
+	t.Run("repository is not started", func(t *testing.T) {
+		n, err := ClaimBatch(context.Background(), &Repository{}, "outbox", "", 10, noop)
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+		require.Zero(t, n)
+	})
+
+	t.Run("should require a positive limit", func(t *testing.T) {
+		_, err := ClaimBatch(context.Background(), &Repository{}, "outbox", "", -1, noop)
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,163 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// Collation providers.
+const (
+	CollationProviderLibc = "libc"
+	CollationProviderICU  = "icu"
+)
+
+// ErrCollationMismatch is returned by Start when the collation of the database does not match the expected one,
+// and the check is strict.
+var ErrCollationMismatch = errors.New("collation mismatch")
+
+// collationSettings describe the collation expected for a database.
+type collationSettings struct {
+	Provider string // icu or libc. Defaults to libc
+	Locale   string
+	Strict   bool // fail to start on mismatch, instead of warning
+}
+
+// WithCollationCheck sets the collation expected for a database, with a locale provider ("icu" or "libc")
+// and a locale.
+//
+// The collation of the database is checked when the repository starts: a mismatch is logged as a warning,
+// or fails Start with ErrCollationMismatch when strict. This prevents sort order differences between environments.
+//
+// This is only a check: the collation of databases created with CreateDB or EnsureDB is set with WithCollation.
+func WithCollationCheck(provider, locale string, strict bool) DBOption {
+	return func(o *databaseSettings) {
+		o.Collation = collationSettings{
+			Provider: provider,
+			Locale:   locale,
+			Strict:   strict,
+		}
+	}
+}
+
+func (c collationSettings) isSet() bool {
+	return c.Locale != ""
+}
+
+func (c collationSettings) provider() string {
+	if c.Provider == "" {
+		return CollationProviderLibc
+	}
+
+	return strings.ToLower(c.Provider)
+}
+
+func (c collationSettings) validate() error {
+	if !c.isSet() {
+		return nil
+	}
+
+	switch c.provider() {
+	case CollationProviderLibc, CollationProviderICU:
+		return nil
+	default:
+		return fmt.Errorf("unsupported collation provider %q, expected %s or %s: %w",
+			c.Provider, CollationProviderICU, CollationProviderLibc, ErrInvalidConfig,
+		)
+	}
+}
+
+// matches tells if an actual collation matches the expected one.
+//
+// Locales are compared regardless of case and separators, e.g. "en_US.UTF-8" matches "en_US.utf8".
+func (c collationSettings) matches(actual collationSettings) bool {
+	normalize := func(locale string) string {
+		return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(locale))
+	}
+
+	return c.provider() == actual.provider() && normalize(c.Locale) == normalize(actual.Locale)
+}
+
+func (c collationSettings) String() string {
+	return c.provider() + ":" + c.Locale
+}
+
+// databaseCollation returns the collation of the current database.
+//
+// The catalog is read as JSON, since its columns depend on the version of postgres
+// (e.g. daticulocale is renamed datlocale in postgres 17, and there is no locale provider before postgres 15).
+func databaseCollation(ctx context.Context, db sqlx.QueryerContext) (collationSettings, error) {
+	var raw []byte
+	if err := db.QueryRowxContext(ctx,
+		`SELECT to_jsonb(d) FROM pg_database d WHERE datname = current_database()`,
+	).Scan(&raw); err != nil {
+		return collationSettings{}, fmt.Errorf("could not read the collation of the database: %w", err)
+	}
+
+	return parseCollation(raw)
+}
+
+// parseCollation reads a collation from a row of the pg_database catalog, as JSON.
+func parseCollation(raw []byte) (collationSettings, error) {
+	var catalog struct {
+		Provider  string  `json:"datlocprovider"`
+		Collate   string  `json:"datcollate"`
+		ICULocale *string `json:"daticulocale"`
+		Locale    *string `json:"datlocale"`
+	}
+	if err := json.Unmarshal(raw, &catalog); err != nil {
+		return collationSettings{}, fmt.Errorf("could not read the collation of the database: %w", err)
+	}
+
+	actual := collationSettings{Provider: CollationProviderLibc, Locale: catalog.Collate}
+	if catalog.Provider == "i" {
+		actual.Provider = CollationProviderICU
+		switch {
+		case catalog.Locale != nil:
+			actual.Locale = *catalog.Locale
+		case catalog.ICULocale != nil:
+			actual.Locale = *catalog.ICULocale
+		}
+	}
+
+	return actual, nil
+}
+
+// checkCollation verifies that the collation of the database matches the expected one.
+func (r databaseSettings) checkCollation(ctx context.Context, db sqlx.QueryerContext, l log.Logger) error {
+	expected := r.Collation
+	if !expected.isSet() {
+		return nil
+	}
+
+	actual, err := databaseCollation(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return expected.verify(actual, l)
+}
+
+// verify an actual collation: a mismatch is an error when the check is strict, and a warning otherwise.
+func (c collationSettings) verify(actual collationSettings, l log.Logger) error {
+	if c.matches(actual) {
+		return nil
+	}
+
+	if c.Strict {
+		return fmt.Errorf("%w: expected %v, but the database uses %v", ErrCollationMismatch, c, actual)
+	}
+
+	l.Warn("collation mismatch: sort order may differ from other environments",
+		zap.Stringer("expected", c),
+		zap.Stringer("actual", actual),
+	)
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCollation(t *testing.T) {
+	t.Run("should create a database with a collation", func(t *testing.T) {
+		s := settingsFromOptions([]Option{WithCreateOptions(WithTemplate("template0"), WithCollation("ICU", "en-US"))})
+		require.Equal(t,
+			`CREATE DATABASE "app" TEMPLATE "template0" LOCALE_PROVIDER icu ICU_LOCALE 'en-US'`,
+			s.create.createStatement("app"),
+		)
+
+		s = settingsFromOptions([]Option{WithCreateOptions(WithCollation("", "en_US.UTF-8"))})
+		require.Equal(t,
+			`CREATE DATABASE "app" LOCALE 'en_US.UTF-8'`,
+			s.create.createStatement("app"),
+		)
+	})
+
+	t.Run("should not combine a collation with LC_COLLATE", func(t *testing.T) {
+		s := settingsFromOptions([]Option{WithCreateOptions(WithLCCollate("en_US.UTF-8"), WithCollation("icu", "en-US"))})
+		require.ErrorIs(t, s.create.validate(), ErrInvalidConfig)
+
+		s = settingsFromOptions([]Option{WithCreateOptions(WithLCCollate("en_US.UTF-8"))})
+		require.NoError(t, s.create.validate())
+	})
+
+	t.Run("should validate the provider", func(t *testing.T) {
+		require.NoError(t, collationSettings{}.validate())
+		require.NoError(t, collationSettings{Provider: "ICU", Locale: "en-US"}.validate())
+
+		dbs := databaseSettingsFromOptions([]DBOption{WithURL(DefaultURL), WithCollationCheck("builtin", "C", false)})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should read the collation from the catalog", func(t *testing.T) {
+		for _, tc := range []struct {
+			catalog  string
+			expected collationSettings
+		}{
+			{ // postgres 14
+				catalog:  `{"datname": "app", "datcollate": "en_US.utf8"}`,
+				expected: collationSettings{Provider: CollationProviderLibc, Locale: "en_US.utf8"},
+			},
+			{ // postgres 16
+				catalog:  `{"datlocprovider": "i", "datcollate": "en_US.utf8", "daticulocale": "en-US"}`,
+				expected: collationSettings{Provider: CollationProviderICU, Locale: "en-US"},
+			},
+			{ // postgres 17
+				catalog:  `{"datlocprovider": "i", "datcollate": "C", "datlocale": "und-x-icu"}`,
+				expected: collationSettings{Provider: CollationProviderICU, Locale: "und-x-icu"},
+			},
+			{
+				catalog:  `{"datlocprovider": "c", "datcollate": "C", "datlocale": null}`,
+				expected: collationSettings{Provider: CollationProviderLibc, Locale: "C"},
+			},
+		} {
+			actual, err := parseCollation([]byte(tc.catalog))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+		}
+	})
+
+	t.Run("should verify the collation", func(t *testing.T) {
+		core, logs := observer.New(zap.WarnLevel)
+		l := log.NewFactory(zap.New(core)).Bg()
+		actual := collationSettings{Provider: CollationProviderLibc, Locale: "en_US.utf8"}
+
+		require.NoError(t, collationSettings{Locale: "en_US.UTF-8", Strict: true}.verify(actual, l))
+		require.Zero(t, logs.Len())
+
+		require.NoError(t, collationSettings{Provider: "icu", Locale: "en-US"}.verify(actual, l))
+		require.Equal(t, 1, logs.FilterMessageSnippet("collation mismatch").Len())
+
+		err := collationSettings{Locale: "fr_FR.UTF-8", Strict: true}.verify(actual, l)
+		require.ErrorIs(t, err, ErrCollationMismatch)
+		require.ErrorContains(t, err, "libc:fr_FR.UTF-8")
+	})
+}
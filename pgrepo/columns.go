@@ -0,0 +1,167 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrColumnNotAllowed is returned when a requested column is not in the allowlist of a Columns set.
+var ErrColumnNotAllowed = errors.New("column not allowed")
+
+// Columns is an allowlist of column names, to build projections or sort clauses from names requested
+// by API callers, without injecting arbitrary SQL.
+//
+// Requested names must match an allowed column exactly. Columns are quoted when interpolated.
+type Columns struct {
+	names   []string
+	allowed map[string]struct{}
+}
+
+// NewColumns builds an allowlist of columns.
+func NewColumns(names ...string) *Columns {
+	c := &Columns{allowed: make(map[string]struct{}, len(names))}
+	for _, name := range names {
+		if _, ok := c.allowed[name]; ok {
+			continue
+		}
+		c.allowed[name] = struct{}{}
+		c.names = append(c.names, name)
+	}
+
+	return c
+}
+
+// ColumnsOf builds an allowlist of columns from the "db" struct tags of a model, like sqlx maps them.
+//
+// Nested structs are not flattened: only the columns of the model and of its embedded structs are allowed.
+func ColumnsOf[T any]() *Columns {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	var names []string
+	if t.Kind() == reflect.Struct {
+		for _, fi := range modelMapper.TypeMap(t).Index {
+			if fi.Embedded || strings.Contains(fi.Path, ".") {
+				continue
+			}
+			names = append(names, fi.Name)
+		}
+	}
+
+	return NewColumns(names...)
+}
+
+// IntrospectColumns builds an allowlist with the columns of a table, as declared in the database catalog.
+func IntrospectColumns(ctx context.Context, db sqlx.QueryerContext, table string) (*Columns, error) {
+	var names []string
+	err := sqlx.SelectContext(ctx, db, &names,
+		`SELECT attname FROM pg_attribute WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped ORDER BY attnum`,
+		table,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not introspect the columns of table %s: %w", table, err)
+	}
+
+	return NewColumns(names...), nil
+}
+
+// Names returns the allowed columns, in their declaration order.
+func (c *Columns) Names() []string {
+	return append([]string(nil), c.names...)
+}
+
+// Allowed tells if a column is allowed.
+func (c *Columns) Allowed(name string) bool {
+	_, ok := c.allowed[name]
+
+	return ok
+}
+
+// Quote returns an allowed column as a quoted identifier.
+func (c *Columns) Quote(name string) (string, error) {
+	if !c.Allowed(name) {
+		return "", fmt.Errorf("%w: %q", ErrColumnNotAllowed, name)
+	}
+
+	return quoteIdentifier(name), nil
+}
+
+// Select returns a projection on the requested columns, e.g. `"id", "name"`.
+//
+// All allowed columns are selected when none is requested.
+func (c *Columns) Select(names ...string) (string, error) {
+	if len(names) == 0 {
+		names = c.names
+	}
+
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		q, err := c.Quote(name)
+		if err != nil {
+			return "", err
+		}
+		quoted = append(quoted, q)
+	}
+
+	return strings.Join(quoted, ", "), nil
+}
+
+// OrderBy returns an ORDER BY clause, from sort specifications such as "name", "name desc" or "-created_at"
+// (descending), e.g. `ORDER BY "name" ASC, "created_at" DESC`.
+//
+// The clause is empty when no specification is given.
+func (c *Columns) OrderBy(specs ...string) (string, error) {
+	if len(specs) == 0 {
+		return "", nil
+	}
+
+	terms := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		name, direction, err := parseSortSpec(spec)
+		if err != nil {
+			return "", err
+		}
+
+		q, err := c.Quote(name)
+		if err != nil {
+			return "", err
+		}
+		terms = append(terms, q+" "+direction)
+	}
+
+	return "ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+func parseSortSpec(spec string) (name, direction string, err error) {
+	spec = strings.TrimSpace(spec)
+	direction = "ASC"
+
+	if rest, ok := strings.CutPrefix(spec, "-"); ok {
+		return rest, "DESC", nil
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		return fields[0], direction, nil
+	case 2:
+		switch strings.ToUpper(fields[1]) {
+		case "ASC":
+		case "DESC":
+			direction = "DESC"
+		default:
+			return "", "", fmt.Errorf("invalid sort direction in %q: %w", spec, ErrColumnNotAllowed)
+		}
+
+		return fields[0], direction, nil
+	default:
+		return "", "", fmt.Errorf("invalid sort specification %q: %w", spec, ErrColumnNotAllowed)
+	}
+}
@@ -0,0 +1,84 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type columnsModel struct {
+	columnsBase
+	Name     string `db:"name"`
+	Email    string `db:"email"`
+	Internal string `db:"-"`
+	Address  struct {
+		City string `db:"city"`
+	} `db:"address"`
+}
+
+type columnsBase struct {
+	ID int64 `db:"id"`
+}
+
+func TestColumnsOf(t *testing.T) {
+	c := ColumnsOf[columnsModel]()
+	require.ElementsMatch(t, []string{"id", "name", "email", "address"}, c.Names())
+	require.False(t, c.Allowed("Internal"))
+	require.False(t, c.Allowed("city"))
+
+	require.Equal(t, c.Names(), ColumnsOf[*columnsModel]().Names())
+}
+
+func TestColumns(t *testing.T) {
+	c := NewColumns("id", "name", "created_at", "name")
+	require.Equal(t, []string{"id", "name", "created_at"}, c.Names())
+
+	t.Run("should select allowed columns", func(t *testing.T) {
+		projection, err := c.Select("name", "id")
+		require.NoError(t, err)
+		require.Equal(t, `"name", "id"`, projection)
+
+		projection, err = c.Select()
+		require.NoError(t, err)
+		require.Equal(t, `"id", "name", "created_at"`, projection)
+	})
+
+	t.Run("should sort on allowed columns", func(t *testing.T) {
+		order, err := c.OrderBy("name", "-created_at", "id desc", " id  ASC ")
+		require.NoError(t, err)
+		require.Equal(t, `ORDER BY "name" ASC, "created_at" DESC, "id" DESC, "id" ASC`, order)
+
+		order, err = c.OrderBy()
+		require.NoError(t, err)
+		require.Empty(t, order)
+	})
+
+	t.Run("should reject injections", func(t *testing.T) {
+		for _, requested := range []string{
+			`name; DROP TABLE users`,
+			`"name"`,
+			`Name`,
+			`(SELECT password FROM users LIMIT 1)`,
+		} {
+			_, err := c.Select(requested)
+			require.ErrorIs(t, err, ErrColumnNotAllowed)
+
+			_, err = c.OrderBy(requested)
+			require.ErrorIs(t, err, ErrColumnNotAllowed)
+		}
+
+		_, err := c.OrderBy("name desc, (SELECT 1)")
+		require.ErrorIs(t, err, ErrColumnNotAllowed)
+		_, err = c.OrderBy("name sideways")
+		require.ErrorIs(t, err, ErrColumnNotAllowed)
+	})
+}
+
+func TestIntrospectColumns(t *testing.T) {
+	db := &recordingQueryer{}
+	_, err := IntrospectColumns(context.Background(), db, "public.users")
+	require.ErrorIs(t, err, errRecorded)
+	require.Contains(t, db.query, `attrelid = $1::regclass`)
+	require.Equal(t, []any{"public.users"}, db.args)
+}
@@ -0,0 +1,211 @@
+package pgrepo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// CompatNone connects directly to postgres, or to a session-pooling proxy (the default).
+	CompatNone = "none"
+
+	// CompatPgBouncer runs safely behind a transaction-pooling proxy, such as PgBouncer in transaction mode
+	// or RDS Proxy: consecutive transactions may run on different server sessions.
+	CompatPgBouncer = "pgbouncer"
+)
+
+// Query execution modes of the pgx driver (see pgx.QueryExecMode).
+const (
+	QueryExecModeCacheStatement = "cache_statement"
+	QueryExecModeCacheDescribe  = "cache_describe"
+	QueryExecModeDescribeExec   = "describe_exec"
+	QueryExecModeExec           = "exec"
+	QueryExecModeSimpleProtocol = "simple_protocol"
+)
+
+// ErrPreparedStatementsDisabled is returned when preparing a statement behind a transaction-pooling proxy.
+var ErrPreparedStatementsDisabled = errors.New("prepared statements are disabled behind a transaction-pooling proxy")
+
+var queryExecModes = map[string]pgx.QueryExecMode{
+	QueryExecModeCacheStatement: pgx.QueryExecModeCacheStatement,
+	QueryExecModeCacheDescribe:  pgx.QueryExecModeCacheDescribe,
+	QueryExecModeDescribeExec:   pgx.QueryExecModeDescribeExec,
+	QueryExecModeExec:           pgx.QueryExecModeExec,
+	QueryExecModeSimpleProtocol: pgx.QueryExecModeSimpleProtocol,
+}
+
+// WithCompat sets the compatibility mode with a connection proxy.
+//
+// With CompatPgBouncer, no state is kept in server sessions:
+//   - queries are executed with the "describe_exec" mode (unless specified otherwise with WithQueryExecMode),
+//     which uses unnamed prepared statements only
+//   - the statement and description caches of the driver are disabled
+//   - Preparex returns ErrPreparedStatementsDisabled, and AcquireSession, Subscribe and RunExclusive
+//     return ErrSessionPinningDisabled
+//   - session-level SET parameters and timeouts are rejected: configure them on the role or database
+//     instead (e.g. ALTER ROLE app SET statement_timeout = '30s')
+//
+// Features relying on a dedicated session (e.g. LISTEN for maintenance notifications) require a direct connection.
+func WithCompat(mode string) PoolOption {
+	return func(o *poolSettings) {
+		o.Compat = mode
+	}
+}
+
+// WithQueryExecMode sets the default mode used by the pgx driver to execute queries, e.g. QueryExecModeSimpleProtocol.
+//
+// A "default_query_exec_mode" parameter in the URL takes precedence.
+func WithQueryExecMode(mode string) PoolOption {
+	return func(o *poolSettings) {
+		o.QueryExecMode = mode
+	}
+}
+
+// WithStatementCacheCapacity sets the number of prepared statements cached by every connection,
+// with the "cache_statement" query exec mode. Zero disables the cache.
+//
+// A "statement_cache_capacity" parameter in the URL takes precedence.
+func WithStatementCacheCapacity(n int) PoolOption {
+	return func(o *poolSettings) {
+		o.StatementCacheCapacity = &n
+	}
+}
+
+// WithDescriptionCacheCapacity sets the number of statement descriptions cached by every connection,
+// with the "cache_describe" query exec mode. Zero disables the cache.
+//
+// A "description_cache_capacity" parameter in the URL takes precedence.
+func WithDescriptionCacheCapacity(n int) PoolOption {
+	return func(o *poolSettings) {
+		o.DescriptionCacheCapacity = &n
+	}
+}
+
+// compat returns the normalized compatibility mode.
+func (p *poolSettings) compat() string {
+	if p == nil {
+		return CompatNone
+	}
+
+	mode := strings.TrimSpace(strings.ToLower(p.Compat))
+	if mode == "" {
+		return CompatNone
+	}
+
+	return mode
+}
+
+func (p *poolSettings) transactionPooling() bool {
+	return p.compat() == CompatPgBouncer
+}
+
+// queryExecMode returns the configured query execution mode, or an empty string to use the driver default.
+func (p *poolSettings) queryExecMode() string {
+	if p == nil {
+		return ""
+	}
+
+	if mode := strings.TrimSpace(strings.ToLower(p.QueryExecMode)); mode != "" {
+		return mode
+	}
+
+	if p.transactionPooling() {
+		return QueryExecModeDescribeExec
+	}
+
+	return ""
+}
+
+func (r databaseSettings) validateCompat() error {
+	switch r.PGConfig.compat() {
+	case CompatNone, CompatPgBouncer:
+	default:
+		return fmt.Errorf("unsupported compatibility mode %q: %w", r.PGConfig.Compat, ErrInvalidConfig)
+	}
+
+	mode := r.PGConfig.queryExecMode()
+	if _, ok := queryExecModes[mode]; mode != "" && !ok {
+		return fmt.Errorf("unsupported query exec mode %q: %w", mode, ErrInvalidConfig)
+	}
+
+	if err := r.PGConfig.validateCacheCapacities(mode); err != nil {
+		return err
+	}
+
+	if !r.PGConfig.transactionPooling() {
+		return nil
+	}
+
+	switch mode {
+	case QueryExecModeCacheStatement, QueryExecModeCacheDescribe:
+		return fmt.Errorf("query exec mode %q requires a statement cache, which is disabled with compat %q: %w", mode, CompatPgBouncer, ErrInvalidConfig)
+	}
+
+	if capacity := r.PGConfig.StatementCacheCapacity; capacity != nil && *capacity > 0 {
+		return fmt.Errorf("the statement cache is disabled with compat %q: %w", CompatPgBouncer, ErrInvalidConfig)
+	}
+
+	if capacity := r.PGConfig.DescriptionCacheCapacity; capacity != nil && *capacity > 0 {
+		return fmt.Errorf("the description cache is disabled with compat %q: %w", CompatPgBouncer, ErrInvalidConfig)
+	}
+
+	if params := r.setParams(); len(params) > 0 {
+		return fmt.Errorf("SET parameters are session-level and not supported with compat %q: configure them on the role or database: %w", CompatPgBouncer, ErrInvalidConfig)
+	}
+
+	if timeouts := r.PGConfig.timeoutParams(); len(timeouts) > 0 {
+		return fmt.Errorf("timeouts are session-level and not supported with compat %q: configure them on the role or database: %w", CompatPgBouncer, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+func (p *poolSettings) validateCacheCapacities(mode string) error {
+	if p == nil {
+		return nil
+	}
+
+	for key, capacity := range map[string]*int{
+		"statementCacheCapacity":   p.StatementCacheCapacity,
+		"descriptionCacheCapacity": p.DescriptionCacheCapacity,
+	} {
+		if capacity != nil && *capacity < 0 {
+			return fmt.Errorf("%s must not be negative, but got %d: %w", key, *capacity, ErrInvalidConfig)
+		}
+	}
+
+	switch {
+	case mode == QueryExecModeCacheStatement && p.StatementCacheCapacity != nil && *p.StatementCacheCapacity == 0:
+		return fmt.Errorf("query exec mode %q requires a statement cache: %w", mode, ErrInvalidConfig)
+	case mode == QueryExecModeCacheDescribe && p.DescriptionCacheCapacity != nil && *p.DescriptionCacheCapacity == 0:
+		return fmt.Errorf("query exec mode %q requires a description cache: %w", mode, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// applyCompat configures the query execution mode and the caches of the driver.
+//
+// Parameters specified in the URL take precedence.
+func (r databaseSettings) applyCompat(dcfg *pgx.ConnConfig, u string) {
+	if mode, ok := queryExecModes[r.PGConfig.queryExecMode()]; ok && !strings.Contains(u, "default_query_exec_mode") {
+		dcfg.DefaultQueryExecMode = mode
+	}
+
+	if p := r.PGConfig; p != nil {
+		if p.StatementCacheCapacity != nil && !strings.Contains(u, "statement_cache_capacity") {
+			dcfg.StatementCacheCapacity = *p.StatementCacheCapacity
+		}
+		if p.DescriptionCacheCapacity != nil && !strings.Contains(u, "description_cache_capacity") {
+			dcfg.DescriptionCacheCapacity = *p.DescriptionCacheCapacity
+		}
+	}
+
+	if r.PGConfig.transactionPooling() {
+		dcfg.StatementCacheCapacity = 0
+		dcfg.DescriptionCacheCapacity = 0
+	}
+}
@@ -0,0 +1,118 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCompat(t *testing.T) {
+	lg := log.NewFactory(zap.NewNop())
+
+	t.Run("should keep driver defaults", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions(nil)}
+		require.NoError(t, dbs.Validate())
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), lg, "")
+		require.NotNil(t, dcfg)
+		require.Equal(t, pgx.QueryExecModeCacheStatement, dcfg.DefaultQueryExecMode)
+		require.NotZero(t, dcfg.StatementCacheCapacity)
+	})
+
+	t.Run("should disable caches behind pgbouncer", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{WithCompat(" PgBouncer ")})}
+		require.NoError(t, dbs.Validate())
+		require.Contains(t, dbs.features(), "compat-pgbouncer")
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), lg, "")
+		require.NotNil(t, dcfg)
+		require.Equal(t, pgx.QueryExecModeDescribeExec, dcfg.DefaultQueryExecMode)
+		require.Zero(t, dcfg.StatementCacheCapacity)
+		require.Zero(t, dcfg.DescriptionCacheCapacity)
+	})
+
+	t.Run("should apply the query exec mode", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{
+			WithCompat(CompatPgBouncer),
+			WithQueryExecMode(QueryExecModeSimpleProtocol),
+		})}
+		require.NoError(t, dbs.Validate())
+		require.Equal(t, pgx.QueryExecModeSimpleProtocol, dbs.ConnConfig(dbs.DBURL(), lg, "").DefaultQueryExecMode)
+	})
+
+	t.Run("the query exec mode in the URL takes precedence", func(t *testing.T) {
+		dbs := databaseSettings{
+			URL:      DefaultURL + "&default_query_exec_mode=exec",
+			PGConfig: poolSettingsFromOptions([]PoolOption{WithQueryExecMode(QueryExecModeSimpleProtocol)}),
+		}
+		require.NoError(t, dbs.Validate())
+		require.Equal(t, pgx.QueryExecModeExec, dbs.ConnConfig(dbs.DBURL(), lg, "").DefaultQueryExecMode)
+	})
+
+	t.Run("should configure the driver caches", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{
+			WithStatementCacheCapacity(64),
+			WithDescriptionCacheCapacity(0),
+		})}
+		require.NoError(t, dbs.Validate())
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), lg, "")
+		require.Equal(t, 64, dcfg.StatementCacheCapacity)
+		require.Zero(t, dcfg.DescriptionCacheCapacity)
+
+		dbs.URL = DefaultURL + "&statement_cache_capacity=8"
+		require.Equal(t, 8, dbs.ConnConfig(dbs.DBURL(), lg, "").StatementCacheCapacity)
+
+		previous := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{WithStatementCacheCapacity(64)})}
+		current := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{WithStatementCacheCapacity(64)})}
+		require.False(t, driverSettingsChanged(previous, current))
+		current.PGConfig = poolSettingsFromOptions([]PoolOption{WithStatementCacheCapacity(32)})
+		require.True(t, driverSettingsChanged(previous, current))
+	})
+
+	t.Run("should reject invalid settings", func(t *testing.T) {
+		for name, opts := range map[string][]PoolOption{
+			"unknown compat":         {WithCompat("odyssey")},
+			"unknown exec mode":      {WithQueryExecMode("turbo")},
+			"statement cache":        {WithCompat(CompatPgBouncer), WithQueryExecMode(QueryExecModeCacheStatement)},
+			"session-level SET":      {WithCompat(CompatPgBouncer), WithSetClause("work_mem", "'64MB'")},
+			"session-level timeouts": {WithCompat(CompatPgBouncer), WithStatementTimeout(time.Second)},
+		} {
+			t.Run(name, func(t *testing.T) {
+				dbs := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions(opts)}
+				require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+			})
+		}
+	})
+
+	t.Run("should refuse to prepare statements", func(t *testing.T) {
+		r := &Repository{
+			db:               &sqlx.DB{},
+			stmts:            newStmtCache(nil),
+			databaseSettings: databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithCompat(CompatPgBouncer)})},
+		}
+
+		_, err := r.Preparex(context.Background(), "SELECT 1")
+		require.ErrorIs(t, err, ErrPreparedStatementsDisabled)
+	})
+
+	t.Run("should refuse to run exclusive tasks", func(t *testing.T) {
+		r := &Repository{
+			db:               &sqlx.DB{},
+			databaseSettings: databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithCompat(CompatPgBouncer)})},
+		}
+
+		err := r.RunExclusive(context.Background(), "nightly", func(context.Context) error {
+			require.Fail(t, "the task should not run")
+
+			return nil
+		})
+		require.ErrorIs(t, err, ErrSessionPinningDisabled)
+	})
+}
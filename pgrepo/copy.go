@@ -0,0 +1,338 @@
+package pgrepo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Supported formats for COPY streams.
+const (
+	CopyFormatText   = "text"
+	CopyFormatCSV    = "csv"
+	CopyFormatBinary = "binary"
+)
+
+// CopyProgress reports the progress of a COPY operation.
+type CopyProgress struct {
+	Rows           int64
+	Bytes          int64
+	Elapsed        time.Duration
+	RowsPerSecond  float64
+	BytesPerSecond float64
+	Done           bool // the last report, after the operation is complete
+}
+
+// WithProgress calls a progress callback every interval during a COPY operation, then once after it completes.
+//
+// Rows are counted as lines while streaming text or csv. The last report holds the exact number of copied rows.
+// The callback is called from a separate goroutine.
+func WithProgress(interval time.Duration, fn func(CopyProgress)) BulkOption {
+	return func(o *bulkOptions) {
+		o.progressInterval = interval
+		o.progress = fn
+	}
+}
+
+// WithCopyFormat sets the format of a COPY stream: CopyFormatText (the default), CopyFormatCSV or CopyFormatBinary.
+func WithCopyFormat(format string) BulkOption {
+	return func(o *bulkOptions) {
+		o.format = format
+	}
+}
+
+func (o bulkOptions) copyFormat() (string, error) {
+	format := strings.ToLower(o.format)
+	switch format {
+	case "":
+		return CopyFormatText, nil
+	case CopyFormatText, CopyFormatCSV, CopyFormatBinary:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported COPY format %q", o.format)
+	}
+}
+
+// CopyFromReader loads a table from a stream in the COPY format, optionally restricted to some columns.
+//
+// The load is aborted when the context is cancelled.
+//
+// It returns the number of rows loaded.
+func (r *Repository) CopyFromReader(ctx context.Context, table string, columns []string, src io.Reader, opts ...BulkOption) (int64, error) {
+	o := bulkOptionsWithDefaults(opts)
+	format, err := o.copyFormat()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := fmt.Sprintf(`COPY %s%s FROM STDIN WITH (FORMAT %s)`, quoteQualifiedIdentifier(table), columnList(columns), format)
+	tracker := o.startProgress(format != CopyFormatBinary)
+
+	var rows int64
+	err = r.withConn(ctx, func(ctx context.Context, conn *pgx.Conn) error {
+		tag, err := conn.PgConn().CopyFrom(ctx, &progressReader{ctx: ctx, r: src, tracker: tracker}, stmt)
+		rows = tag.RowsAffected()
+
+		return err
+	})
+	tracker.stop(rows)
+
+	if err != nil {
+		return rows, fmt.Errorf("could not copy into %s: %w", table, err)
+	}
+
+	r.logger(ctx).Debug("copied rows from stream", zap.String("table", table), zap.Int64("rows", rows))
+
+	if _, err = r.AnalyzeAfterLoad(ctx, table, rows, opts...); err != nil {
+		return rows, err
+	}
+
+	return rows, nil
+}
+
+// CopyFrom bulk loads rows into a table with the COPY protocol, which is much faster than INSERT statements.
+//
+// Rows are provided by a pgx.CopyFromSource, e.g. pgx.CopyFromRows or pgx.CopyFromSlice. Values are encoded
+// according to the types of the columns. The table name may be qualified by its schema (e.g. "public.users").
+//
+// The load is aborted when the context is cancelled.
+//
+// It returns the number of rows loaded.
+func (r *Repository) CopyFrom(ctx context.Context, table string, columns []string, rows pgx.CopyFromSource, opts ...BulkOption) (int64, error) {
+	o := bulkOptionsWithDefaults(opts)
+	tracker := o.startProgress(false)
+
+	var copied int64
+	err := r.withConn(ctx, func(ctx context.Context, conn *pgx.Conn) error {
+		var err error
+		copied, err = conn.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")), columns,
+			&progressSource{ctx: ctx, CopyFromSource: rows, tracker: tracker},
+		)
+
+		return err
+	})
+	tracker.stop(copied)
+
+	if err != nil {
+		return copied, fmt.Errorf("could not copy into %s: %w", table, err)
+	}
+
+	r.logger(ctx).Debug("copied rows", zap.String("table", table), zap.Int64("rows", copied))
+
+	if _, err = r.AnalyzeAfterLoad(ctx, table, copied, opts...); err != nil {
+		return copied, err
+	}
+
+	return copied, nil
+}
+
+// CopyTo streams the result of a query to a writer in the COPY format.
+//
+// The query may be a table name or a SELECT statement (without parameters).
+// The copy is aborted when the context is cancelled.
+//
+// It returns the number of rows copied.
+func (r *Repository) CopyTo(ctx context.Context, dst io.Writer, query string, opts ...BulkOption) (int64, error) {
+	o := bulkOptionsWithDefaults(opts)
+	format, err := o.copyFormat()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := fmt.Sprintf(`COPY %s TO STDOUT WITH (FORMAT %s)`, copySource(query), format)
+	tracker := o.startProgress(format != CopyFormatBinary)
+
+	var rows int64
+	err = r.withConn(ctx, func(ctx context.Context, conn *pgx.Conn) error {
+		tag, err := conn.PgConn().CopyTo(ctx, &progressWriter{ctx: ctx, w: dst, tracker: tracker}, stmt)
+		rows = tag.RowsAffected()
+
+		return err
+	})
+	tracker.stop(rows)
+
+	if err != nil {
+		return rows, fmt.Errorf("could not copy from %s: %w", query, err)
+	}
+
+	return rows, nil
+}
+
+// copySource quotes a table name, or encloses a query in parentheses.
+func copySource(query string) string {
+	source := strings.TrimSpace(query)
+	if strings.ContainsAny(source, " \t\r\n(") {
+		return "(" + source + ")"
+	}
+
+	return quoteQualifiedIdentifier(source)
+}
+
+func columnList(columns []string) string {
+	if len(columns) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, quoteIdentifier(column))
+	}
+
+	return " (" + strings.Join(quoted, ", ") + ")"
+}
+
+// progressTracker accumulates the progress of a COPY operation and reports it periodically.
+type progressTracker struct {
+	rows       atomic.Int64
+	bytes      atomic.Int64
+	countLines bool
+	start      time.Time
+	report     func(CopyProgress)
+	cancel     func()
+	done       chan struct{}
+}
+
+// startProgress starts reporting progress, if a callback is configured.
+func (o bulkOptions) startProgress(countLines bool) *progressTracker {
+	t := &progressTracker{
+		countLines: countLines,
+		start:      time.Now(),
+		report:     o.progress,
+	}
+
+	if t.report == nil || o.progressInterval <= 0 {
+		return t
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.done = make(chan struct{})
+
+	go func() {
+		defer close(t.done)
+
+		ticker := time.NewTicker(o.progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.report(t.snapshot(false))
+			}
+		}
+	}()
+
+	return t
+}
+
+func (t *progressTracker) add(p []byte) {
+	t.bytes.Add(int64(len(p)))
+	if t.countLines {
+		t.rows.Add(int64(bytes.Count(p, []byte{'\n'})))
+	}
+}
+
+func (t *progressTracker) snapshot(done bool) CopyProgress {
+	p := CopyProgress{
+		Rows:    t.rows.Load(),
+		Bytes:   t.bytes.Load(),
+		Elapsed: time.Since(t.start),
+		Done:    done,
+	}
+
+	if seconds := p.Elapsed.Seconds(); seconds > 0 {
+		p.RowsPerSecond = float64(p.Rows) / seconds
+		p.BytesPerSecond = float64(p.Bytes) / seconds
+	}
+
+	return p
+}
+
+// stop periodic reports, then issue a last report with the exact number of rows.
+func (t *progressTracker) stop(rows int64) {
+	if t.cancel != nil {
+		t.cancel()
+		<-t.done
+	}
+
+	if t.report == nil {
+		return
+	}
+
+	t.rows.Store(rows)
+	t.report(t.snapshot(true))
+}
+
+// progressReader tracks the progress of a COPY FROM stream, and interrupts it when the context is cancelled.
+type progressReader struct {
+	ctx     context.Context
+	r       io.Reader
+	tracker *progressTracker
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := r.r.Read(p)
+	r.tracker.add(p[:n])
+
+	return n, err
+}
+
+// progressWriter tracks the progress of a COPY TO stream, and interrupts it when the context is cancelled.
+type progressWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	tracker *progressTracker
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := w.w.Write(p)
+	w.tracker.add(p[:n])
+
+	return n, err
+}
+
+// progressSource tracks the progress of a COPY FROM source of rows, and interrupts it when the context is cancelled.
+type progressSource struct {
+	pgx.CopyFromSource
+
+	ctx     context.Context
+	tracker *progressTracker
+}
+
+func (s *progressSource) Next() bool {
+	if s.ctx.Err() != nil {
+		return false
+	}
+
+	if !s.CopyFromSource.Next() {
+		return false
+	}
+	s.tracker.rows.Add(1)
+
+	return true
+}
+
+func (s *progressSource) Err() error {
+	if err := s.CopyFromSource.Err(); err != nil {
+		return err
+	}
+
+	return s.ctx.Err()
+}
@@ -0,0 +1,129 @@
+package pgrepo
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyStatements(t *testing.T) {
+	require.Equal(t, `"public"."users"`, copySource("public.users"))
+	require.Equal(t, `(SELECT * FROM users WHERE id > 10)`, copySource(" SELECT * FROM users WHERE id > 10 "))
+	require.Equal(t, "", columnList(nil))
+	require.Equal(t, ` ("id", "Name")`, columnList([]string{"id", "Name"}))
+
+	format, err := bulkOptionsWithDefaults(nil).copyFormat()
+	require.NoError(t, err)
+	require.Equal(t, CopyFormatText, format)
+
+	format, err = bulkOptionsWithDefaults([]BulkOption{WithCopyFormat("CSV")}).copyFormat()
+	require.NoError(t, err)
+	require.Equal(t, CopyFormatCSV, format)
+
+	_, err = bulkOptionsWithDefaults([]BulkOption{WithCopyFormat("json")}).copyFormat()
+	require.Error(t, err)
+}
+
+func TestCopyProgress(t *testing.T) {
+	t.Run("progress is reported periodically, then when done", func(t *testing.T) {
+		var (
+			mx      sync.Mutex
+			reports []CopyProgress
+		)
+		o := bulkOptionsWithDefaults([]BulkOption{WithProgress(time.Millisecond, func(p CopyProgress) {
+			mx.Lock()
+			defer mx.Unlock()
+			reports = append(reports, p)
+		})})
+
+		tracker := o.startProgress(true)
+		r := &progressReader{ctx: context.Background(), r: strings.NewReader("1\ta\n2\tb\n3\tc\n"), tracker: tracker}
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Len(t, data, 12)
+
+		require.Eventually(t, func() bool {
+			mx.Lock()
+			defer mx.Unlock()
+
+			return len(reports) > 0
+		}, time.Second, time.Millisecond)
+
+		tracker.stop(3)
+
+		mx.Lock()
+		defer mx.Unlock()
+		last := reports[len(reports)-1]
+		require.True(t, last.Done)
+		require.EqualValues(t, 3, last.Rows)
+		require.EqualValues(t, 12, last.Bytes)
+		require.Positive(t, last.RowsPerSecond)
+		require.False(t, reports[0].Done)
+	})
+
+	t.Run("cancelled stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		tracker := bulkOptionsWithDefaults(nil).startProgress(false)
+		w := &progressWriter{ctx: ctx, w: io.Discard, tracker: tracker}
+
+		n, err := w.Write([]byte("abc\n"))
+		require.NoError(t, err)
+		require.Equal(t, 4, n)
+		require.Zero(t, tracker.rows.Load())
+		require.EqualValues(t, 4, tracker.bytes.Load())
+
+		cancel()
+		_, err = w.Write([]byte("def\n"))
+		require.ErrorIs(t, err, context.Canceled)
+		tracker.stop(1)
+	})
+
+	t.Run("repository is not started", func(t *testing.T) {
+		r := &Repository{}
+		_, err := r.CopyTo(context.Background(), io.Discard, "users")
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+}
+
+func TestCopyFromSource(t *testing.T) {
+	t.Run("should require a started repository", func(t *testing.T) {
+		_, err := New(DefaultDBAlias).CopyFrom(context.Background(), "users", []string{"id"}, pgx.CopyFromRows(nil))
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("should count rows", func(t *testing.T) {
+		tracker := bulkOptionsWithDefaults(nil).startProgress(false)
+		source := &progressSource{
+			ctx:            context.Background(),
+			CopyFromSource: pgx.CopyFromRows([][]any{{1, "a"}, {2, "b"}}),
+			tracker:        tracker,
+		}
+
+		for source.Next() {
+			_, err := source.Values()
+			require.NoError(t, err)
+		}
+		require.NoError(t, source.Err())
+		require.EqualValues(t, 2, tracker.rows.Load())
+	})
+
+	t.Run("should be interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		source := &progressSource{
+			ctx:            ctx,
+			CopyFromSource: pgx.CopyFromRows([][]any{{1, "a"}, {2, "b"}}),
+			tracker:        bulkOptionsWithDefaults(nil).startProgress(false),
+		}
+
+		require.True(t, source.Next())
+		cancel()
+		require.False(t, source.Next())
+		require.ErrorIs(t, source.Err(), context.Canceled)
+	})
+}
@@ -5,8 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"net/url"
-	"strings"
+	"time"
 
 	"github.com/fredbi/go-trace/log"
 	"github.com/jmoiron/sqlx"
@@ -28,19 +27,30 @@ func EnsureDB(ctx context.Context, dbName string, opts ...Option) (db *sqlx.DB,
 	}
 
 	l := s.logger
+	r := &Repository{
+		log:              log.NewFactory(l),
+		alias:            dbName,
+		databaseSettings: dbs,
+	}
+
+	if s.pushGateway != nil {
+		job := newJobMetrics(r.metricsLabels())
+		defer func() {
+			job.observe(time.Time{}, 0, err)
+			if perr := s.pushGateway.push(context.WithoutCancel(ctx), dbName, job); perr != nil {
+				l.Warn("could not push metrics", zap.Error(perr))
+			}
+		}()
+	}
 
 	created, err = CreateDB(ctx, dbName, opts...)
 	if err != nil {
 		return nil, true, err
 	}
 
-	r := &Repository{
-		log:              log.NewFactory(l),
-		databaseSettings: dbs,
-	}
 	connCfg := dbs.ConnConfig(dbs.DBURL(), r.log, "")
 
-	db, err = r.open(ctx, connCfg)
+	db, _, err = r.open(ctx, connCfg)
 	if err != nil {
 		return nil, created, fmt.Errorf("could not connect to database server %v: %w", dbs.RedactedURL(), err)
 	}
@@ -52,35 +62,45 @@ func EnsureDB(ctx context.Context, dbName string, opts ...Option) (db *sqlx.DB,
 
 // CreateDB creates a database "dbName".
 //
+// Creation options such as the owner or the encoding may be set with WithCreateOptions.
+//
 // NOTE: credentials to connect to the database must be sufficient to create the database.
 func CreateDB(parentCtx context.Context, dbName string, opts ...Option) (bool, error) {
 	s := settingsFromOptions(opts)
 	dbs := s.DBSettingsFor(dbName)
 
-	if dbs.URL == "" {
+	if dbs.URL == "" && dbs.DSN == "" && !dbs.URLFrom.isSet() {
 		return false, fmt.Errorf(`no database URL found in config file. Expected  "url" in config section %q`, dbName)
 	}
 
 	if dbName == DefaultDBAlias {
-		u, err := url.Parse(dbs.URL)
+		cp, err := parseConnString(dbs.DBURL())
 		if err != nil {
 			return false, err
 		}
 
-		dbName = strings.TrimPrefix(u.Path, "/")
+		dbName = cp.Database
+	}
+
+	if err := s.validateDBName(dbName); err != nil {
+		return false, err
+	}
+
+	if err := s.create.validate(); err != nil {
+		return false, err
 	}
 	l := s.logger.With(zap.String("db_name", dbName))
 
 	ctx, cancel := context.WithCancel(parentCtx)
 	defer cancel()
 
-	db, closer, err := connectNoDB(ctx, dbs.URL, dbs, l)
+	db, closer, err := connectNoDB(ctx, dbs.DBURL(), dbs, l)
 	if err != nil {
 		return false, err
 	}
 	defer closer()
 
-	tx, err := db.BeginTxx(ctx, nil)
+	tx, err := db.BeginTxx(withManagedTx(ctx), nil)
 	if err != nil {
 		return false, err
 	}
@@ -98,7 +118,7 @@ func CreateDB(parentCtx context.Context, dbName string, opts ...Option) (bool, e
 
 	l.Info("creating database")
 
-	_, err = db.ExecContext(ctx, fmt.Sprintf(`CREATE DATABASE %s`, dbName))
+	_, err = db.ExecContext(ctx, s.create.createStatement(dbName))
 	if err != nil {
 		return false, fmt.Errorf("could not create database %s: %w", dbName, err)
 	}
@@ -114,21 +134,31 @@ func CreateDB(parentCtx context.Context, dbName string, opts ...Option) (bool, e
 
 // DropDB drops the database "dbName".
 //
+// With WithForceDrop, active connections to the database are terminated.
+//
+// In dry-run mode (see WithDryRun), the DROP statement is only logged and the returned flag tells if
+// the database would have been dropped.
+//
 // NOTE: credentials to connect to the database must be sufficient to drop the database.
 func DropDB(parentCtx context.Context, dbName string, opts ...Option) (bool, error) {
 	ctx, cancel := context.WithCancel(parentCtx)
 	defer cancel()
 
 	s := settingsFromOptions(opts)
+	if err := s.validateDBName(dbName); err != nil {
+		return false, err
+	}
+
 	dbs := s.DBSettingsFor(dbName)
+	l := s.logger.With(zap.String("db_name", dbName))
 
-	db, closer, err := connectNoDB(ctx, dbs.URL, dbs, s.logger)
+	db, closer, err := connectNoDB(ctx, dbs.DBURL(), dbs, s.logger)
 	if err != nil {
 		return false, err
 	}
 	defer closer()
 
-	tx, err := db.BeginTxx(ctx, nil)
+	tx, err := db.BeginTxx(withManagedTx(ctx), nil)
 	if err != nil {
 		return false, err
 	}
@@ -142,7 +172,15 @@ func DropDB(parentCtx context.Context, dbName string, opts ...Option) (bool, err
 		return false, nil
 	}
 
-	_, err = db.ExecContext(ctx, fmt.Sprintf(`DROP DATABASE IF EXISTS %s`, dbName))
+	stmt := fmt.Sprintf(`DROP DATABASE IF EXISTS %s`, quoteIdentifier(dbName))
+	if s.forceDrop {
+		stmt, err = forceDropStatement(ctx, db, dbs.dryRun, l, stmt, dbName)
+		if err != nil {
+			return false, fmt.Errorf("could not drop database %s: %w", dbName, err)
+		}
+	}
+
+	err = execDestructive(ctx, db, dbs.dryRun, l, stmt)
 	if err != nil {
 		return false, fmt.Errorf("could not drop database %s: %w", dbName, err)
 	}
@@ -157,13 +195,14 @@ func DropDB(parentCtx context.Context, dbName string, opts ...Option) (bool, err
 
 // connectNoDB connects to the postgres engine pointed to by the DSN, but without any DB open
 func connectNoDB(ctx context.Context, dsn string, s databaseSettings, l *zap.Logger) (*sqlx.DB, func(), error) {
-	u, err := url.Parse(dsn)
+	target, err := switchConnStringDB(dsn, "postgres") // postgres default DB
 	if err != nil {
 		return nil, nil, errors.Join(ErrInvalidPGURL, err)
 	}
 
-	u.Path = "postgres" // postgres default DB
-	s.URL = u.String()
+	s.URL = target
+	s.URLFrom = secretSource{}
+	s.DSN = ""
 
 	if err = s.Validate(); err != nil {
 		return nil, nil, err
@@ -175,14 +214,37 @@ func connectNoDB(ctx context.Context, dsn string, s databaseSettings, l *zap.Log
 	}
 	connCfg := s.ConnConfig(s.DBURL(), r.log, "")
 
-	db, err := r.open(ctx, connCfg)
+	db, _, err := r.open(ctx, connCfg)
 	if err != nil {
-		return nil, nil, fmt.Errorf("could not connect to database server %v: %w", u, err)
+		return nil, nil, fmt.Errorf("could not connect to database server %v: %w", s.RedactedURL(), err)
 	}
 
 	return db, func() { _ = db.Close() }, nil
 }
 
+// forceDropStatement returns a DROP DATABASE statement which terminates active connections.
+//
+// On postgres 13+, this is achieved with the FORCE option. On older versions, backends connected to the database
+// are terminated before the database is dropped.
+func forceDropStatement(ctx context.Context, db *sqlx.DB, dryRun bool, l *zap.Logger, stmt, dbName string) (string, error) {
+	const pg13 = 130000
+
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT current_setting('server_version_num')::int`).Scan(&version); err != nil {
+		return "", err
+	}
+
+	if version >= pg13 {
+		return stmt + ` WITH (FORCE)`, nil
+	}
+
+	err := execDestructive(ctx, db, dryRun, l,
+		`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()`, dbName,
+	)
+
+	return stmt, err
+}
+
 func dbExists(ctx context.Context, tx *sqlx.Tx, dbName string) (bool, error) {
 	var ignored sql.NullString
 	err := tx.QueryRowContext(ctx, "SELECT datname FROM pg_database WHERE datname = $1", dbName).Scan(&ignored)
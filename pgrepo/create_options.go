@@ -0,0 +1,132 @@
+package pgrepo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidDBName is returned when a database name is rejected.
+var ErrInvalidDBName = errors.New("invalid database name")
+
+// maxIdentifierLength is the maximum length of postgres identifiers (NAMEDATALEN-1).
+const maxIdentifierLength = 63
+
+type (
+	// CreateOption controls the creation of a database.
+	CreateOption func(*createSettings)
+
+	createSettings struct {
+		owner     string
+		encoding  string
+		template  string
+		lcCollate string
+		collation collationSettings
+	}
+)
+
+// WithOwner sets the role owning a newly created database.
+func WithOwner(role string) CreateOption {
+	return func(o *createSettings) {
+		o.owner = role
+	}
+}
+
+// WithEncoding sets the character set encoding of a newly created database (e.g. "UTF8").
+func WithEncoding(encoding string) CreateOption {
+	return func(o *createSettings) {
+		o.encoding = encoding
+	}
+}
+
+// WithTemplate sets the template database used to create a new database (e.g. "template0").
+func WithTemplate(template string) CreateOption {
+	return func(o *createSettings) {
+		o.template = template
+	}
+}
+
+// WithLCCollate sets the collation order of a newly created database (e.g. "en_US.UTF-8").
+func WithLCCollate(collate string) CreateOption {
+	return func(o *createSettings) {
+		o.lcCollate = collate
+	}
+}
+
+// WithCollation sets the collation of a newly created database, with a locale provider ("icu" or "libc")
+// and a locale (e.g. "en-US" with icu, "en_US.UTF-8" with libc).
+//
+// The icu provider requires postgres 15 or later. A locale differing from the one of template1 usually requires
+// creating the database from "template0" (see WithTemplate).
+//
+// A collation may not be combined with WithLCCollate.
+func WithCollation(provider, locale string) CreateOption {
+	return func(o *createSettings) {
+		o.collation.Provider = provider
+		o.collation.Locale = locale
+	}
+}
+
+// createStatement builds a CREATE DATABASE statement, with properly quoted identifiers and literals.
+func (c createSettings) createStatement(dbName string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `CREATE DATABASE %s`, quoteIdentifier(dbName))
+
+	if c.owner != "" {
+		fmt.Fprintf(&b, ` OWNER %s`, quoteIdentifier(c.owner))
+	}
+	if c.template != "" {
+		fmt.Fprintf(&b, ` TEMPLATE %s`, quoteIdentifier(c.template))
+	}
+	if c.encoding != "" {
+		fmt.Fprintf(&b, ` ENCODING %s`, quoteLiteral(c.encoding))
+	}
+	if c.lcCollate != "" {
+		fmt.Fprintf(&b, ` LC_COLLATE %s`, quoteLiteral(c.lcCollate))
+	}
+	if c.collation.Locale != "" {
+		switch provider := c.collation.provider(); provider {
+		case CollationProviderICU:
+			fmt.Fprintf(&b, ` LOCALE_PROVIDER %s ICU_LOCALE %s`, provider, quoteLiteral(c.collation.Locale))
+		default:
+			// libc is the default provider: LOCALE_PROVIDER is omitted, so that postgres before 15 accepts the statement
+			fmt.Fprintf(&b, ` LOCALE %s`, quoteLiteral(c.collation.Locale))
+		}
+	}
+
+	return b.String()
+}
+
+func (c createSettings) validate() error {
+	if err := c.collation.validate(); err != nil {
+		return err
+	}
+
+	if c.collation.isSet() && c.lcCollate != "" {
+		return fmt.Errorf("a collation may not be combined with LC_COLLATE %q: %w", c.lcCollate, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// validateDBName checks a database name, then applies the validation hook, if any (see WithDBNameValidator).
+func (r runtimeSettings) validateDBName(dbName string) error {
+	switch {
+	case dbName == "":
+		return fmt.Errorf("%w: empty name", ErrInvalidDBName)
+	case len(dbName) > maxIdentifierLength:
+		return fmt.Errorf("%w: %q exceeds %d characters", ErrInvalidDBName, dbName, maxIdentifierLength)
+	case strings.ContainsRune(dbName, 0):
+		return fmt.Errorf("%w: %q contains a NUL character", ErrInvalidDBName, dbName)
+	}
+
+	if r.dbNameValidator == nil {
+		return nil
+	}
+
+	if err := r.dbNameValidator(dbName); err != nil {
+		return fmt.Errorf("%w: %q: %w", ErrInvalidDBName, dbName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateStatement(t *testing.T) {
+	require.Equal(t, `CREATE DATABASE "test-db"`, createSettings{}.createStatement("test-db"))
+	require.Equal(t, `CREATE DATABASE "x"";DROP DATABASE y;--"`, createSettings{}.createStatement(`x";DROP DATABASE y;--`))
+
+	s := settingsFromOptions([]Option{WithCreateOptions(
+		WithOwner("app_owner"),
+		WithTemplate("template0"),
+		WithEncoding("UTF8"),
+		WithLCCollate("en_US.UTF-8"),
+	)})
+	require.Equal(t,
+		`CREATE DATABASE "app" OWNER "app_owner" TEMPLATE "template0" ENCODING 'UTF8' LC_COLLATE 'en_US.UTF-8'`,
+		s.create.createStatement("app"),
+	)
+}
+
+func TestValidateDBName(t *testing.T) {
+	s := settingsFromOptions(nil)
+	require.NoError(t, s.validateDBName("unittest_db"))
+	require.ErrorIs(t, s.validateDBName(""), ErrInvalidDBName)
+	require.ErrorIs(t, s.validateDBName(strings.Repeat("x", 64)), ErrInvalidDBName)
+	require.ErrorIs(t, s.validateDBName("a\x00b"), ErrInvalidDBName)
+
+	errNotPrefixed := errors.New("test databases must be prefixed by test_")
+	s = settingsFromOptions([]Option{WithDBNameValidator(func(name string) error {
+		if !strings.HasPrefix(name, "test_") {
+			return errNotPrefixed
+		}
+
+		return nil
+	})})
+	require.NoError(t, s.validateDBName("test_db"))
+
+	err := s.validateDBName("production")
+	require.ErrorIs(t, err, ErrInvalidDBName)
+	require.ErrorIs(t, err, errNotPrefixed)
+
+	t.Run("names are validated before connecting", func(t *testing.T) {
+		_, err := DropDB(context.Background(), "", WithDatabaseSettings("", WithURL(DefaultURL)))
+		require.ErrorIs(t, err, ErrInvalidDBName)
+	})
+}
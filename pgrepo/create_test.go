@@ -144,6 +144,36 @@ func TestEnsureDB(t *testing.T) {
 		require.Nil(t, db)
 		require.True(t, created)
 	})
+
+	t.Run("with force drop", func(t *testing.T) {
+		ctx := context.Background()
+		dbName := randomDBName()
+		opts := []Option{
+			WithDatabaseSettings("default",
+				WithURL(urlWithoutDB),
+				WithUser(pgUser),
+				WithPassword(pgPassword),
+			),
+		}
+
+		db, created, err := EnsureDB(ctx, dbName, opts...)
+		require.NoError(t, err)
+		require.True(t, created)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		// the pool is still open: the connection held would block a regular drop
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+
+		dropped, err := DropDB(ctx, dbName, append(opts, WithForceDrop())...)
+		require.NoError(t, err)
+		require.True(t, dropped)
+	})
 }
 
 func randomDBName() string {
@@ -0,0 +1,124 @@
+package pgrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCredentialsCheckInterval = time.Minute
+	minCredentialsCheckInterval     = time.Second
+)
+
+// Credentials to connect to a database.
+type Credentials struct {
+	User     string
+	Password string
+	Expires  time.Time // the zero value means that the credentials don't expire
+}
+
+// CredentialsProvider supplies credentials to connect to a database, e.g. from a secrets manager.
+//
+// Credentials are retrieved before each new connection is established: providers should cache them.
+type CredentialsProvider interface {
+	Credentials(context.Context) (Credentials, error)
+}
+
+// CredentialsProviderFunc is a function that implements CredentialsProvider.
+type CredentialsProviderFunc func(context.Context) (Credentials, error)
+
+func (fn CredentialsProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return fn(ctx)
+}
+
+// WithCredentialsProvider retrieves the user and password from a provider, e.g. NewVaultProvider.
+//
+// The credentials from the provider take precedence over the configured user and password.
+//
+// Whenever the provider supplies new credentials (e.g. when a lease expires), connections established with
+// former credentials are renewed.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(o *settings) {
+		o.credentials = provider
+	}
+}
+
+// provideCredentials returns a function which sets the credentials supplied by the provider, before connecting.
+func (r databaseSettings) provideCredentials() func(context.Context, *pgx.ConnConfig) error {
+	if r.credentials == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, cfg *pgx.ConnConfig) error {
+		creds, err := r.credentials.Credentials(ctx)
+		if err != nil {
+			return err
+		}
+
+		if creds.User != "" {
+			cfg.User = creds.User
+		}
+		cfg.Password = creds.Password
+
+		return nil
+	}
+}
+
+// startCredentialsWatch checks the credentials supplied by the provider before they expire,
+// and calls onChange whenever they have changed.
+//
+// It returns a function to stop watching.
+func (r databaseSettings) startCredentialsWatch(l log.Logger, onChange func()) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		var (
+			last  Credentials
+			known bool
+		)
+		for {
+			wait := defaultCredentialsCheckInterval
+			creds, err := r.credentials.Credentials(ctx)
+
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				l.Warn("could not retrieve database credentials", zap.Error(err))
+			} else {
+				if known && (creds.User != last.User || creds.Password != last.Password) {
+					l.Info("database credentials have changed", zap.String("db_user", creds.User))
+					onChange()
+				}
+
+				last, known = creds, true
+				if !creds.Expires.IsZero() {
+					// check again half-way to the expiry
+					wait = max(time.Until(creds.Expires)/2, minCredentialsCheckInterval)
+				}
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
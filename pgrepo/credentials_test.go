@@ -0,0 +1,59 @@
+package pgrepo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCredentialsProvider(t *testing.T) {
+	var rotations, calls atomic.Int32
+	provider := CredentialsProviderFunc(func(context.Context) (Credentials, error) {
+		calls.Add(1)
+		if rotations.Load() > 0 {
+			return Credentials{User: "v-app-2", Password: "rotated", Expires: time.Now().Add(2 * time.Second)}, nil
+		}
+
+		return Credentials{User: "v-app-1", Password: "leased", Expires: time.Now().Add(2 * time.Second)}, nil
+	})
+
+	s := settingsFromOptions([]Option{
+		WithLogger(zap.NewNop()),
+		WithCredentialsProvider(provider),
+		WithDatabaseSettings(DefaultDBAlias, WithURL(DefaultURL), WithPassword("static")),
+	})
+	dbs := s.DBSettingsFor(DefaultDBAlias)
+
+	t.Run("should set credentials before connecting", func(t *testing.T) {
+		cfg, err := pgx.ParseConfig(dbs.DBURL())
+		require.NoError(t, err)
+
+		require.NoError(t, dbs.beforeConnect()(context.Background(), cfg))
+		require.Equal(t, "v-app-1", cfg.User)
+		require.Equal(t, "leased", cfg.Password)
+	})
+
+	t.Run("should detect new credentials", func(t *testing.T) {
+		changed := make(chan struct{}, 1)
+		stop := dbs.startCredentialsWatch(log.NewFactory(zap.NewNop()).Bg(), func() {
+			changed <- struct{}{}
+		})
+		defer stop()
+
+		initial := calls.Load()
+		require.Eventually(t, func() bool { return calls.Load() > initial }, 5*time.Second, 10*time.Millisecond)
+		rotations.Add(1)
+
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected new credentials to be detected")
+		}
+	})
+}
@@ -0,0 +1,198 @@
+// Package datadog instruments the pgx driver with Datadog APM.
+//
+// Add a tracer to a repository with pgrepo.WithPGXTracer, e.g.:
+//
+//	repo := pgrepo.New(alias, pgrepo.WithPGXTracer(datadog.NewTracer(datadog.WithService("orders.db"))))
+//
+// Importing this package also registers the pgrepo.TraceProviderDatadog trace provider, which may then be selected
+// by the trace settings of a pool (e.g. "provider: datadog").
+//
+// It is a separate module, so that the dependencies of the Datadog tracer are not required by pgrepo.
+package datadog
+
+import (
+	"context"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+const (
+	// DefaultService is the service name of spans.
+	DefaultService = "postgres.db"
+
+	component = "jackc/pgx.v5"
+)
+
+func init() {
+	pgrepo.RegisterTraceProvider(pgrepo.TraceProviderDatadog, func(s pgrepo.TracerSettings) pgx.QueryTracer {
+		return NewTracer(WithService(s.Service), WithAlias(s.Alias), WithTags(s.Tags))
+	})
+}
+
+type (
+	// Option configures a Tracer.
+	Option func(*options)
+
+	options struct {
+		service string
+		alias   string
+		tags    map[string]string
+	}
+
+	spanKey struct{}
+)
+
+// WithService sets the service name of spans. Defaults to DefaultService.
+func WithService(service string) Option {
+	return func(o *options) {
+		o.service = service
+	}
+}
+
+// WithAlias tags spans with the alias of a database, as "db.alias".
+func WithAlias(alias string) Option {
+	return func(o *options) {
+		o.alias = alias
+	}
+}
+
+// WithTags tags spans with the tags of a database, as "db.tag.{key}".
+func WithTags(tags map[string]string) Option {
+	return func(o *options) {
+		if o.tags == nil {
+			o.tags = make(map[string]string, len(tags))
+		}
+
+		for k, v := range tags {
+			o.tags[k] = v
+		}
+	}
+}
+
+// Tracer is a pgx tracer emitting Datadog APM spans, following the conventions of the Datadog integrations
+// for databases: the resource of a span is its statement.
+//
+// Spans are children of the span found in the context of the query, if any. The Datadog tracer is started
+// by the application (see tracer.Start): until then, spans are discarded.
+type Tracer struct {
+	opts []ddtrace.StartSpanOption
+}
+
+var (
+	_ pgx.QueryTracer    = &Tracer{}
+	_ pgx.BatchTracer    = &Tracer{}
+	_ pgx.CopyFromTracer = &Tracer{}
+)
+
+// NewTracer builds a pgx tracer emitting Datadog APM spans.
+func NewTracer(opts ...Option) *Tracer {
+	var o options
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	service := o.service
+	if service == "" {
+		service = DefaultService
+	}
+
+	spanOpts := []ddtrace.StartSpanOption{
+		tracer.ServiceName(service),
+		tracer.SpanType(ext.SpanTypeSQL),
+		tracer.Tag(ext.SpanKind, ext.SpanKindClient),
+		tracer.Tag(ext.Component, component),
+		tracer.Tag(ext.DBSystem, ext.DBSystemPostgreSQL),
+	}
+	if o.alias != "" {
+		spanOpts = append(spanOpts, tracer.Tag("db.alias", o.alias))
+	}
+	for k, v := range o.tags {
+		spanOpts = append(spanOpts, tracer.Tag("db.tag."+k, v))
+	}
+
+	return &Tracer{opts: spanOpts}
+}
+
+// spanOptions returns the options of spans, with the tags of the connection, if any.
+func (t *Tracer) spanOptions(conn *pgx.Conn, opts ...ddtrace.StartSpanOption) []ddtrace.StartSpanOption {
+	opts = append(opts, t.opts...)
+	if conn == nil {
+		return opts
+	}
+
+	cfg := conn.Config()
+
+	return append(opts,
+		tracer.Tag(ext.DBName, cfg.Database),
+		tracer.Tag(ext.DBUser, cfg.User),
+		tracer.Tag(ext.TargetHost, cfg.Host),
+		tracer.Tag(ext.TargetPort, int(cfg.Port)),
+	)
+}
+
+func (t *Tracer) start(ctx context.Context, conn *pgx.Conn, operation, resource string, opts ...ddtrace.StartSpanOption) context.Context {
+	opts = append(t.spanOptions(conn, opts...), tracer.ResourceName(resource))
+	span, ctx := tracer.StartSpanFromContext(ctx, operation, opts...)
+
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (t *Tracer) end(ctx context.Context, err error, rows int64) {
+	span, ok := ctx.Value(spanKey{}).(ddtrace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetTag("db.row_count", rows)
+	span.Finish(tracer.WithError(err))
+}
+
+func (t *Tracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return t.start(ctx, conn, "pgx.query", data.SQL, tracer.Tag("db.operation", pgrepo.SQLOperation(data.SQL)))
+}
+
+func (t *Tracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.Err, data.CommandTag.RowsAffected())
+}
+
+func (t *Tracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	var size int
+	if data.Batch != nil {
+		size = data.Batch.Len()
+	}
+
+	return t.start(ctx, conn, "pgx.batch", "batch", tracer.Tag("db.batch.size", size))
+}
+
+func (t *Tracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+	parent, ok := ctx.Value(spanKey{}).(ddtrace.Span)
+	if !ok {
+		return
+	}
+
+	// queries of a batch are sent at once: their spans only record the statement and its outcome
+	span := tracer.StartSpan("pgx.batch.query", t.spanOptions(conn,
+		tracer.ChildOf(parent.Context()),
+		tracer.ResourceName(data.SQL),
+	)...)
+	span.SetTag("db.row_count", data.CommandTag.RowsAffected())
+	span.Finish(tracer.WithError(data.Err))
+}
+
+func (t *Tracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, data.Err, 0)
+}
+
+func (t *Tracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	table := data.TableName.Sanitize()
+
+	return t.start(ctx, conn, "pgx.copy_from", "COPY "+table, tracer.Tag("db.sql.table", table))
+}
+
+func (t *Tracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, data.Err, data.CommandTag.RowsAffected())
+}
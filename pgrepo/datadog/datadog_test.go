@@ -0,0 +1,84 @@
+package datadog
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/ext"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+func TestTracer(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	ddt := NewTracer(WithAlias("orders"), WithTags(map[string]string{"team": "payments"}))
+
+	parent, parentCtx := tracer.StartSpanFromContext(context.Background(), "http.request")
+	ctx := ddt.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: "/* api */ SELECT * FROM users WHERE id = $1"})
+	ddt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+
+	ctx = ddt.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: "DELETE FROM users"})
+	ddt.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+
+	ctx = ddt.TraceBatchStart(parentCtx, nil, pgx.TraceBatchStartData{Batch: &pgx.Batch{}})
+	ddt.TraceBatchQuery(ctx, nil, pgx.TraceBatchQueryData{SQL: "INSERT INTO users VALUES (1)", CommandTag: pgconn.NewCommandTag("INSERT 0 1")})
+	ddt.TraceBatchEnd(ctx, nil, pgx.TraceBatchEndData{})
+	parent.Finish()
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 5)
+
+	selectSpan := spans[0]
+	require.Equal(t, "pgx.query", selectSpan.OperationName())
+	require.Equal(t, parent.Context().SpanID(), selectSpan.ParentID())
+	require.Equal(t, DefaultService, selectSpan.Tag(ext.ServiceName))
+	require.Equal(t, "/* api */ SELECT * FROM users WHERE id = $1", selectSpan.Tag(ext.ResourceName))
+	require.Equal(t, ext.SpanTypeSQL, selectSpan.Tag(ext.SpanType))
+	require.Equal(t, ext.DBSystemPostgreSQL, selectSpan.Tag(ext.DBSystem))
+	require.Equal(t, "orders", selectSpan.Tag("db.alias"))
+	require.Equal(t, "payments", selectSpan.Tag("db.tag.team"))
+	require.Equal(t, "SELECT", selectSpan.Tag("db.operation"))
+	require.Equal(t, int64(1), selectSpan.Tag("db.row_count"))
+
+	deleteSpan := spans[1]
+	require.Equal(t, "DELETE FROM users", deleteSpan.Tag(ext.ResourceName))
+	require.NotNil(t, deleteSpan.Tag(ext.Error))
+
+	batchQuery, batch := spans[2], spans[3]
+	require.Equal(t, "pgx.batch.query", batchQuery.OperationName())
+	require.Equal(t, batch.SpanID(), batchQuery.ParentID())
+	require.Equal(t, "pgx.batch", batch.OperationName())
+}
+
+func TestTraceProvider(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	r := pgrepo.New(pgrepo.DefaultDBAlias,
+		pgrepo.WithLogger(zap.NewNop()),
+		pgrepo.WithDatabaseSettings(pgrepo.DefaultDBAlias,
+			pgrepo.WithURL(pgrepo.DefaultURL),
+			pgrepo.WithPoolSettings(pgrepo.WithTracing(true), pgrepo.WithTraceProvider(pgrepo.TraceProviderDatadog)),
+		),
+	)
+	require.NoError(t, r.Validate())
+	require.Empty(t, r.TraceOptions(r.DBURL()), "opencensus should not be enabled")
+
+	dcfg := r.ConnConfig(r.DBURL(), r.Logger(), "app")
+	require.NotNil(t, dcfg.Tracer)
+
+	ctx := dcfg.Tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	dcfg.Tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	spans := mt.FinishedSpans()
+	require.Len(t, spans, 1)
+	require.Equal(t, pgrepo.DefaultDBAlias, spans[0].Tag("db.alias"))
+}
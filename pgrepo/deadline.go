@@ -0,0 +1,78 @@
+package pgrepo
+
+import (
+	"context"
+	"time"
+)
+
+// Operation categories, with a default deadline (see WithDefaultDeadline).
+const (
+	OperationRead  = "read"  // Read, read-only transactions with RunInTx
+	OperationWrite = "write" // Write, transactions with RunInTx, SendBatch
+	OperationDDL   = "ddl"   // Migrate, InstallHistory
+	OperationAdmin = "admin" // Analyze, AnalyzeAfterLoad
+)
+
+// WithDefaultDeadline sets the default deadline of an operation category: read, write, ddl or admin.
+//
+// The deadline applies to helper APIs (e.g. Read, Write, RunInTx or Migrate) when the context of the
+// caller has no deadline. A negative duration disables the default deadline of this category.
+func WithDefaultDeadline(category string, d time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		switch category {
+		case OperationRead:
+			o.Deadlines.Read = d
+		case OperationWrite:
+			o.Deadlines.Write = d
+		case OperationDDL:
+			o.Deadlines.DDL = d
+		case OperationAdmin:
+			o.Deadlines.Admin = d
+		}
+	}
+}
+
+// defaultDeadline returns the default deadline of an operation category, or 0 if none applies.
+func (r databaseSettings) defaultDeadline(category string) time.Duration {
+	var configured deadlineSettings
+	if r.PGConfig != nil {
+		configured = r.PGConfig.Deadlines
+	}
+	defaults := defaultSettings.PGConfig.Deadlines
+
+	var d, fallback time.Duration
+	switch category {
+	case OperationRead:
+		d, fallback = configured.Read, defaults.Read
+	case OperationWrite:
+		d, fallback = configured.Write, defaults.Write
+	case OperationDDL:
+		d, fallback = configured.DDL, defaults.DDL
+	case OperationAdmin:
+		d, fallback = configured.Admin, defaults.Admin
+	}
+
+	switch {
+	case d < 0:
+		return 0
+	case d == 0:
+		return max(fallback, 0)
+	default:
+		return d
+	}
+}
+
+// withDefaultDeadline bounds the context with the default deadline of an operation category,
+// unless the context already has a deadline.
+func (r databaseSettings) withDefaultDeadline(ctx context.Context, category string) (context.Context, context.CancelFunc) {
+	if _, hasDeadline := ctx.Deadline(); hasDeadline {
+		return ctx, func() {}
+	}
+
+	d := r.defaultDeadline(category)
+	if d == 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, d)
+}
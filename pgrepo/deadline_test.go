@@ -0,0 +1,66 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultDeadline(t *testing.T) {
+	t.Run("should resolve deadlines by category", func(t *testing.T) {
+		dbs := databaseSettings{}
+		require.Equal(t, 30*time.Second, dbs.defaultDeadline(OperationRead))
+		require.Equal(t, time.Minute, dbs.defaultDeadline(OperationWrite))
+		require.Equal(t, 30*time.Minute, dbs.defaultDeadline(OperationDDL))
+		require.Equal(t, time.Hour, dbs.defaultDeadline(OperationAdmin))
+		require.Zero(t, dbs.defaultDeadline("other"))
+
+		dbs = settingsFromOptions([]Option{
+			WithDefaultPoolOptions(
+				WithDefaultDeadline(OperationRead, 5*time.Second),
+				WithDefaultDeadline(OperationAdmin, -1),
+			),
+		}).DBSettingsFor(DefaultDBAlias)
+		require.Equal(t, 5*time.Second, dbs.defaultDeadline(OperationRead))
+		require.Equal(t, time.Minute, dbs.defaultDeadline(OperationWrite))
+		require.Zero(t, dbs.defaultDeadline(OperationAdmin))
+	})
+
+	t.Run("should bound contexts without a deadline", func(t *testing.T) {
+		r := &Repository{db: &sqlx.DB{}}
+
+		require.NoError(t, r.Read(context.Background(), func(ctx context.Context, _ *sqlx.DB) error {
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.WithinDuration(t, time.Now().Add(30*time.Second), deadline, time.Second)
+
+			return nil
+		}))
+
+		parent, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		expected, _ := parent.Deadline()
+
+		require.NoError(t, r.Write(parent, func(ctx context.Context, _ *sqlx.DB) error {
+			deadline, ok := ctx.Deadline()
+			require.True(t, ok)
+			require.Equal(t, expected, deadline, "the deadline of the caller should prevail")
+
+			return nil
+		}))
+	})
+
+	t.Run("should not bound contexts when disabled", func(t *testing.T) {
+		dbs := settingsFromOptions([]Option{
+			WithDefaultPoolOptions(WithDefaultDeadline(OperationRead, -1)),
+		}).DBSettingsFor(DefaultDBAlias)
+
+		ctx, cancel := dbs.withDefaultDeadline(context.Background(), OperationRead)
+		defer cancel()
+		_, ok := ctx.Deadline()
+		require.False(t, ok)
+	})
+}
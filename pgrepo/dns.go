@@ -0,0 +1,98 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+const defaultPostgresPort = 5432
+
+// DNSConfig configures a resolver of replicas from DNS records, e.g. of a headless Kubernetes service.
+type DNSConfig struct {
+	Host     string        // the name to resolve, e.g. "pg-replicas.db.svc.cluster.local"
+	Port     uint16        // the port of the replicas, when not resolved from SRV records. Defaults to 5432
+	Service  string        // when set, the replicas are resolved from the SRV records of this service, e.g. "postgres"
+	Resolver *net.Resolver // defaults to net.DefaultResolver
+}
+
+// DNSResolver discovers the replicas of a cluster from DNS records.
+//
+// With a headless Kubernetes service, each ready pod of the service is resolved as a replica. The primary is
+// not resolved: connections to the master are established with the hosts of the URL.
+type DNSResolver struct {
+	cfg DNSConfig
+}
+
+// NewDNSResolver builds a resolver of replicas from DNS records (see WithEndpointResolver).
+//
+// Replicas are resolved from the addresses of the host, or from the SRV records "_<service>._tcp.<host>"
+// if a service is specified, e.g. for a named port of a Kubernetes service.
+func NewDNSResolver(cfg DNSConfig) (*DNSResolver, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("a host name to resolve replicas is required: %w", ErrInvalidConfig)
+	}
+
+	if cfg.Port == 0 {
+		cfg.Port = defaultPostgresPort
+	}
+
+	if cfg.Resolver == nil {
+		cfg.Resolver = net.DefaultResolver
+	}
+
+	return &DNSResolver{cfg: cfg}, nil
+}
+
+// ResolveEndpoints resolves the replicas, in a stable order.
+func (d *DNSResolver) ResolveEndpoints(ctx context.Context) (Endpoints, error) {
+	var (
+		endpoints Endpoints
+		err       error
+	)
+
+	if d.cfg.Service != "" {
+		endpoints.Replicas, err = d.lookupSRV(ctx)
+	} else {
+		endpoints.Replicas, err = d.lookupHost(ctx)
+	}
+	if err != nil {
+		return Endpoints{}, err
+	}
+
+	sort.Slice(endpoints.Replicas, func(i, j int) bool {
+		return endpoints.Replicas[i].String() < endpoints.Replicas[j].String()
+	})
+
+	return endpoints, nil
+}
+
+func (d *DNSResolver) lookupHost(ctx context.Context) ([]Endpoint, error) {
+	addrs, err := d.cfg.Resolver.LookupHost(ctx, d.cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		replicas = append(replicas, Endpoint{Host: addr, Port: d.cfg.Port})
+	}
+
+	return replicas, nil
+}
+
+func (d *DNSResolver) lookupSRV(ctx context.Context) ([]Endpoint, error) {
+	_, records, err := d.cfg.Resolver.LookupSRV(ctx, d.cfg.Service, "tcp", d.cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]Endpoint, 0, len(records))
+	for _, record := range records {
+		replicas = append(replicas, Endpoint{Host: strings.TrimSuffix(record.Target, "."), Port: record.Port})
+	}
+
+	return replicas, nil
+}
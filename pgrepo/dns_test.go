@@ -0,0 +1,33 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSResolver(t *testing.T) {
+	t.Run("should require a host", func(t *testing.T) {
+		_, err := NewDNSResolver(DNSConfig{})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("should resolve the addresses of the host", func(t *testing.T) {
+		resolver, err := NewDNSResolver(DNSConfig{Host: "localhost"})
+		require.NoError(t, err)
+
+		endpoints, err := resolver.ResolveEndpoints(context.Background())
+		require.NoError(t, err)
+		require.False(t, endpoints.Primary.isSet())
+		require.Contains(t, endpoints.Replicas, Endpoint{Host: "127.0.0.1", Port: 5432})
+	})
+
+	t.Run("should fail when the host is unknown", func(t *testing.T) {
+		resolver, err := NewDNSResolver(DNSConfig{Host: "pg-replicas.invalid", Port: 6432})
+		require.NoError(t, err)
+
+		_, err = resolver.ResolveEndpoints(context.Background())
+		require.Error(t, err)
+	})
+}
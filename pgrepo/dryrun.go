@@ -0,0 +1,21 @@
+package pgrepo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// execDestructive executes a destructive statement, or only logs it when in dry-run mode.
+func execDestructive(ctx context.Context, db sqlx.ExecerContext, dryRun bool, l *zap.Logger, stmt string, args ...interface{}) error {
+	if dryRun {
+		l.Info("dry-run: statement not executed", zap.String("statement", stmt), zap.Int("args", len(args)))
+
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, stmt, args...)
+
+	return err
+}
@@ -0,0 +1,33 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mockExecer struct {
+	executed []string
+}
+
+func (m *mockExecer) ExecContext(_ context.Context, query string, _ ...interface{}) (sql.Result, error) {
+	m.executed = append(m.executed, query)
+
+	return nil, nil
+}
+
+func TestExecDestructive(t *testing.T) {
+	ctx := context.Background()
+	db := &mockExecer{}
+
+	require.NoError(t, execDestructive(ctx, db, true, zap.NewNop(), "DROP DATABASE x"))
+	require.Empty(t, db.executed)
+
+	require.NoError(t, execDestructive(ctx, db, false, zap.NewNop(), "DROP DATABASE x"))
+	require.Equal(t, []string{"DROP DATABASE x"}, db.executed)
+
+	require.True(t, settingsFromOptions([]Option{WithDryRun()}).DBSettingsFor(DefaultDBAlias).dryRun)
+}
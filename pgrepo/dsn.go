@@ -0,0 +1,262 @@
+package pgrepo
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// WithDSN sets the connection string in the keyword/value format, e.g. "host=localhost port=5432 dbname=test",
+// as an alternative to a URL.
+//
+// The DSN takes precedence over the URL. A connection string read from a file (see WithURLFromFile) may use either format.
+func WithDSN(dsn string) DBOption {
+	return func(o *databaseSettings) {
+		o.DSN = dsn
+	}
+}
+
+// isURL tells if a connection string is a URL, rather than a keyword/value DSN.
+func isURL(connString string) bool {
+	return strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://")
+}
+
+// dsnParam is a keyword/value pair of a DSN.
+type dsnParam struct {
+	key   string
+	value string
+}
+
+// parseDSN parses a connection string in the keyword/value format, preserving the order of parameters.
+//
+// Values may be single-quoted. Backslashes escape quotes and backslashes, like libpq does.
+func parseDSN(dsn string) ([]dsnParam, error) {
+	var params []dsnParam
+
+	s := strings.TrimSpace(dsn)
+	for s != "" {
+		eq := strings.IndexRune(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid DSN: missing \"=\" after %q: %w", s, ErrInvalidPGURL)
+		}
+
+		key := strings.TrimSpace(s[:eq])
+		if key == "" || strings.ContainsAny(key, " \t\r\n'") {
+			return nil, fmt.Errorf("invalid DSN: invalid keyword %q: %w", key, ErrInvalidPGURL)
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t\r\n")
+
+		var (
+			value  strings.Builder
+			quoted = strings.HasPrefix(s, "'")
+			closed = !quoted
+			i      int
+		)
+		if quoted {
+			i = 1
+		}
+
+	scan:
+		for ; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s):
+				i++
+				value.WriteByte(s[i])
+			case quoted && c == '\'':
+				closed = true
+				i++
+
+				break scan
+			case !quoted && (c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+				break scan
+			default:
+				value.WriteByte(c)
+			}
+		}
+
+		if !closed {
+			return nil, fmt.Errorf("invalid DSN: unterminated quoted value for %q: %w", key, ErrInvalidPGURL)
+		}
+
+		params = append(params, dsnParam{key: key, value: value.String()})
+		s = strings.TrimLeft(s[i:], " \t\r\n")
+	}
+
+	return params, nil
+}
+
+// formatDSN formats parameters as a keyword/value connection string.
+func formatDSN(params []dsnParam) string {
+	pairs := make([]string, 0, len(params))
+	for _, p := range params {
+		value := p.value
+		if value == "" || strings.ContainsAny(value, " \t\r\n'\\") {
+			value = "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+		}
+		pairs = append(pairs, p.key+"="+value)
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// dsnValue returns the last value of a parameter of a DSN.
+func dsnValue(params []dsnParam, key string) (string, bool) {
+	var (
+		value string
+		found bool
+	)
+	for _, p := range params {
+		if p.key == key {
+			value, found = p.value, true
+		}
+	}
+
+	return value, found
+}
+
+// setDSNValue sets a parameter of a DSN, replacing any former value.
+func setDSNValue(params []dsnParam, key, value string) []dsnParam {
+	result := make([]dsnParam, 0, len(params)+1)
+	for _, p := range params {
+		if p.key != key {
+			result = append(result, p)
+		}
+	}
+
+	return append(result, dsnParam{key: key, value: value})
+}
+
+// connParams are the parameters of a connection string which matter to this package, whatever its format.
+type connParams struct {
+	Hosts       []string // host names, IP addresses or directories of unix-domain sockets
+	Database    string
+	HasPassword bool
+	SSLMode     string
+}
+
+// isUnixSocket tells if a host is the directory of a unix-domain socket, e.g. "/var/run/postgresql".
+func isUnixSocket(host string) bool {
+	return strings.HasPrefix(host, "/")
+}
+
+// parseConnString extracts the parameters of a connection string, either a URL or a keyword/value DSN.
+//
+// URLs to a unix-domain socket specify the socket directory as a query parameter,
+// e.g. "postgres:///test?host=/var/run/postgresql".
+func parseConnString(connString string) (connParams, error) {
+	if isURL(connString) {
+		u, err := url.Parse(connString)
+		if err != nil {
+			return connParams{}, err
+		}
+		query := u.Query()
+		_, hasPassword := u.User.Password()
+
+		cp := connParams{
+			Hosts:       urlHosts(u.Host),
+			Database:    strings.TrimPrefix(u.Path, "/"),
+			HasPassword: hasPassword || query.Has("password"),
+			SSLMode:     query.Get("sslmode"),
+		}
+		if host := query.Get("host"); host != "" {
+			cp.Hosts = strings.Split(host, ",")
+		}
+		if dbName := query.Get("dbname"); dbName != "" {
+			cp.Database = dbName
+		}
+
+		return cp, nil
+	}
+
+	params, err := parseDSN(connString)
+	if err != nil {
+		return connParams{}, err
+	}
+
+	var cp connParams
+	if host, _ := dsnValue(params, "host"); host != "" {
+		cp.Hosts = strings.Split(host, ",")
+	}
+	cp.Database, _ = dsnValue(params, "dbname")
+	_, cp.HasPassword = dsnValue(params, "password")
+	cp.SSLMode, _ = dsnValue(params, "sslmode")
+
+	return cp, nil
+}
+
+// urlHosts splits the hosts of a URL, e.g. "h1:5432,h2:5433", like pgx does.
+func urlHosts(hostPorts string) []string {
+	var hosts []string
+	for _, hostPort := range strings.Split(hostPorts, ",") {
+		if hostPort == "" {
+			continue
+		}
+
+		host, _, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			// no port
+			host = strings.Trim(hostPort, "[]")
+		}
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+// redactConnString masks the password of a connection string, whatever its format.
+func redactConnString(connString string) string {
+	if isURL(connString) {
+		u, err := url.Parse(connString)
+		if err != nil {
+			return "<invalid url>"
+		}
+
+		if query := u.Query(); query.Has("password") {
+			query.Set("password", "xxxxx")
+			u.RawQuery = query.Encode()
+		}
+
+		return u.Redacted()
+	}
+
+	params, err := parseDSN(connString)
+	if err != nil {
+		return "<invalid dsn>"
+	}
+
+	if _, ok := dsnValue(params, "password"); ok {
+		params = setDSNValue(params, "password", "xxxxx")
+	}
+
+	return formatDSN(params)
+}
+
+// switchConnStringDB returns a connection string to another database on the same server, whatever its format.
+func switchConnStringDB(connString, dbName string) (string, error) {
+	if isURL(connString) {
+		u, err := url.Parse(connString)
+		if err != nil {
+			return "", err
+		}
+
+		// the database may be specified as a query parameter, which takes precedence over the path
+		if query := u.Query(); query.Has("dbname") {
+			query.Set("dbname", dbName)
+			u.RawQuery = query.Encode()
+		} else {
+			u.Path = "/" + dbName
+		}
+
+		return u.String(), nil
+	}
+
+	params, err := parseDSN(connString)
+	if err != nil {
+		return "", err
+	}
+
+	return formatDSN(setDSNValue(params, "dbname", dbName)), nil
+}
@@ -0,0 +1,117 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// effectiveSettings declare the validity period columns of an effective-dated table.
+//
+// A row is effective from ValidFrom (inclusive) to ValidTo (exclusive). A NULL ValidTo stands for
+// a row effective until further notice.
+type effectiveSettings struct {
+	ValidFrom string
+	ValidTo   string
+}
+
+type asOfKey struct{}
+
+// WithEffectiveDating declares an effective-dated table, with the columns holding the validity period of its rows.
+//
+// Empty column names default to "valid_from" and "valid_to".
+func WithEffectiveDating(table, validFrom, validTo string) DBOption {
+	return func(o *databaseSettings) {
+		tables := make(map[string]effectiveSettings, len(o.Effective)+1)
+		for k, v := range o.Effective {
+			tables[k] = v
+		}
+		tables[strings.ToLower(table)] = effectiveSettings{ValidFrom: validFrom, ValidTo: validTo}
+		o.Effective = tables
+	}
+}
+
+// AsOf scopes queries on effective-dated tables to the rows effective at time t.
+//
+// See Repository.SelectEffective.
+func AsOf(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, asOfKey{}, t)
+}
+
+// AsOfTime returns the time set by AsOf, or the current time.
+func AsOfTime(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(asOfKey{}).(time.Time); ok {
+		return t
+	}
+
+	return time.Now()
+}
+
+func (e effectiveSettings) validFrom() string {
+	if e.ValidFrom == "" {
+		return defaultHistoryValidFrom
+	}
+
+	return e.ValidFrom
+}
+
+func (e effectiveSettings) validTo() string {
+	if e.ValidTo == "" {
+		return defaultHistoryValidTo
+	}
+
+	return e.ValidTo
+}
+
+// effectiveQuery builds a query over the rows of a table effective at the time passed as the first parameter.
+func (e effectiveSettings) effectiveQuery(table string) string {
+	qFrom := quoteIdentifier(e.validFrom())
+	qTo := quoteIdentifier(e.validTo())
+
+	return fmt.Sprintf(
+		`SELECT * FROM %s WHERE %s <= $1 AND (%s IS NULL OR %s > $1)`,
+		quoteQualifiedIdentifier(table), qFrom, qTo, qTo,
+	)
+}
+
+// EffectiveQuery returns a SELECT statement that yields the rows of an effective-dated table which are effective
+// at the point in time passed as the first parameter ($1) of the query.
+//
+// The table must be declared in the "effective" config section.
+//
+// Example:
+//
+//	q, _ := r.EffectiveQuery("prices")
+//	err := r.DB().SelectContext(ctx, &prices, `SELECT * FROM (`+q+`) AS p WHERE p.sku = $2`, AsOfTime(ctx), sku)
+func (r *Repository) EffectiveQuery(table string) (string, error) {
+	e, ok := r.Effective[strings.ToLower(table)]
+	if !ok {
+		return "", fmt.Errorf("table %q is not declared as effective-dated: %w", table, ErrInvalidConfig)
+	}
+
+	return e.effectiveQuery(table), nil
+}
+
+// SelectEffective selects the rows of an effective-dated table which are effective at the time set by AsOf
+// (the current time by default), and which match an optional condition.
+//
+// Parameters of the condition are numbered from $2, e.g.:
+//
+//	ctx = AsOf(ctx, closingDate)
+//	err := r.SelectEffective(ctx, r.DB(), &prices, "prices", `sku = $2`, sku)
+func (r *Repository) SelectEffective(ctx context.Context, db sqlx.QueryerContext, dest any, table, where string, args ...any) error {
+	q, err := r.EffectiveQuery(table)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT * FROM (` + q + `) AS t`
+	if where != "" {
+		query += ` WHERE ` + where
+	}
+
+	return sqlx.SelectContext(ctx, db, dest, query, append([]any{AsOfTime(ctx)}, args...)...)
+}
@@ -0,0 +1,91 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveQuery(t *testing.T) {
+	r := &Repository{
+		databaseSettings: databaseSettingsFromOptions([]DBOption{
+			WithEffectiveDating("public.prices", "", ""),
+			WithEffectiveDating("Rates", "effective_from", "effective_to"),
+		}),
+	}
+
+	t.Run("with default columns", func(t *testing.T) {
+		q, err := r.EffectiveQuery("public.prices")
+		require.NoError(t, err)
+		require.Equal(t,
+			`SELECT * FROM "public"."prices" WHERE "valid_from" <= $1 AND ("valid_to" IS NULL OR "valid_to" > $1)`,
+			q,
+		)
+	})
+
+	t.Run("with custom columns", func(t *testing.T) {
+		q, err := r.EffectiveQuery("rates")
+		require.NoError(t, err)
+		require.Contains(t, q, `"effective_from" <= $1 AND ("effective_to" IS NULL OR "effective_to" > $1)`)
+	})
+
+	t.Run("requires a declared table", func(t *testing.T) {
+		_, err := r.EffectiveQuery("orders")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+func TestAsOf(t *testing.T) {
+	at := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	require.Equal(t, at, AsOfTime(AsOf(context.Background(), at)))
+	require.WithinDuration(t, time.Now(), AsOfTime(context.Background()), time.Second)
+}
+
+func TestSelectEffective(t *testing.T) {
+	r := &Repository{
+		databaseSettings: databaseSettingsFromOptions([]DBOption{WithEffectiveDating("prices", "", "")}),
+	}
+	at := time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)
+	db := &recordingQueryer{}
+
+	var prices []struct{}
+	err := r.SelectEffective(AsOf(context.Background(), at), db, &prices, "prices", `sku = $2`, "ABC")
+	require.ErrorIs(t, err, errRecorded)
+	require.Equal(t,
+		`SELECT * FROM (SELECT * FROM "prices" WHERE "valid_from" <= $1 AND ("valid_to" IS NULL OR "valid_to" > $1)) AS t WHERE sku = $2`,
+		db.query,
+	)
+	require.Equal(t, []any{at, "ABC"}, db.args)
+}
+
+var errRecorded = errors.New("recorded")
+
+// recordingQueryer records the last query, then fails.
+type recordingQueryer struct {
+	query string
+	args  []any
+}
+
+func (q *recordingQueryer) QueryContext(_ context.Context, query string, args ...any) (*sql.Rows, error) {
+	q.query, q.args = query, args
+
+	return nil, errRecorded
+}
+
+func (q *recordingQueryer) QueryxContext(_ context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	q.query, q.args = query, args
+
+	return nil, errRecorded
+}
+
+func (q *recordingQueryer) QueryRowxContext(_ context.Context, query string, args ...any) *sqlx.Row {
+	q.query, q.args = query, args
+
+	return &sqlx.Row{}
+}
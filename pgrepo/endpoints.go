@@ -0,0 +1,290 @@
+package pgrepo
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultEndpointsCheckInterval = 5 * time.Second
+	minEndpointsCheckInterval     = 100 * time.Millisecond
+)
+
+type (
+	// Endpoint is the network address of a database server.
+	Endpoint struct {
+		Host string
+		Port uint16
+	}
+
+	// Endpoints are the servers of a high-availability cluster, as known by a cluster manager.
+	Endpoints struct {
+		Primary  Endpoint
+		Replicas []Endpoint
+	}
+
+	// EndpointResolver discovers the current primary and replicas of a cluster, e.g. from Patroni (see NewPatroniResolver).
+	EndpointResolver interface {
+		ResolveEndpoints(context.Context) (Endpoints, error)
+	}
+
+	// EndpointResolverFunc is a function that implements EndpointResolver.
+	EndpointResolverFunc func(context.Context) (Endpoints, error)
+
+	endpointResolverSettings struct {
+		resolver EndpointResolver
+		interval time.Duration
+	}
+
+	// endpointState holds the endpoints last resolved for a repository.
+	//
+	// Once detached (e.g. after the standby has been promoted), resolved endpoints no longer apply.
+	endpointState struct {
+		mx        sync.RWMutex
+		current   Endpoints
+		detached  bool
+		unwatched chan struct{}
+		once      sync.Once
+	}
+)
+
+func (fn EndpointResolverFunc) ResolveEndpoints(ctx context.Context) (Endpoints, error) {
+	return fn(ctx)
+}
+
+// String yields the endpoint as "host:port".
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(int(e.Port)))
+}
+
+func (e Endpoint) isSet() bool {
+	return e.Host != ""
+}
+
+// apply points a driver configuration to the endpoint, instead of the hosts of the URL.
+func (e Endpoint) apply(dcfg *pgx.ConnConfig) {
+	if !e.isSet() {
+		return
+	}
+
+	dcfg.Host = e.Host
+	if e.Port > 0 {
+		dcfg.Port = e.Port
+	}
+	dcfg.Fallbacks = nil
+
+	if dcfg.TLSConfig != nil && dcfg.TLSConfig.ServerName != "" {
+		// verify the certificate of the resolved host
+		tlsConfig := dcfg.TLSConfig.Clone()
+		tlsConfig.ServerName = e.Host
+		dcfg.TLSConfig = tlsConfig
+	}
+}
+
+// WithEndpointResolver discovers the primary and the replicas of a high-availability cluster with a resolver,
+// e.g. from the Patroni REST API (see NewPatroniResolver), from DNS (see NewDNSResolver), or from the monitor
+// of pg_auto_failover with an EndpointResolverFunc.
+//
+// The resolved primary takes precedence over the hosts of the URL. The other connection parameters
+// (user, database, TLS...) are taken from the URL. Whenever the resolver reports replicas, they replace
+// the configured replicas: connection pools are opened for new replicas, and closed for replicas which are gone.
+//
+// Endpoints are resolved again at the specified interval (defaults to 5s). When the primary changes,
+// e.g. after a failover, connections to the former primary are renewed right away, instead of waiting for
+// them to fail.
+func WithEndpointResolver(resolver EndpointResolver, interval time.Duration) Option {
+	return func(o *settings) {
+		if resolver == nil {
+			o.resolver = nil
+
+			return
+		}
+
+		o.resolver = &endpointResolverSettings{
+			resolver: resolver,
+			interval: interval,
+		}
+	}
+}
+
+func (s *endpointResolverSettings) checkInterval() time.Duration {
+	if s.interval <= 0 {
+		return defaultEndpointsCheckInterval
+	}
+
+	return max(s.interval, minEndpointsCheckInterval)
+}
+
+// resolve the endpoints, within the maximum wait time allotted to connections.
+func (r databaseSettings) resolveEndpoints(ctx context.Context) (Endpoints, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.maxWait())
+	defer cancel()
+
+	return r.resolver.resolver.ResolveEndpoints(ctx)
+}
+
+func (s *endpointState) get() Endpoints {
+	if s == nil {
+		return Endpoints{}
+	}
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.current
+}
+
+// set the current endpoints and returns the former ones.
+func (s *endpointState) set(endpoints Endpoints) Endpoints {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	previous := s.current
+	s.current = endpoints
+
+	return previous
+}
+
+func newEndpointState() *endpointState {
+	return &endpointState{unwatched: make(chan struct{})}
+}
+
+// apply points a driver configuration to the resolved primary, if any.
+func (s *endpointState) apply(dcfg *pgx.ConnConfig) {
+	if s == nil || dcfg == nil || s.isDetached() {
+		return
+	}
+
+	s.get().Primary.apply(dcfg)
+}
+
+func (s *endpointState) isDetached() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	return s.detached
+}
+
+// setDetached stops (or resumes) applying the resolved endpoints.
+func (s *endpointState) setDetached(detached bool) {
+	if s == nil {
+		return
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	s.detached = detached
+}
+
+// unwatch stops watching the endpoints for good.
+func (s *endpointState) unwatch() {
+	if s == nil {
+		return
+	}
+
+	s.once.Do(func() {
+		close(s.unwatched)
+	})
+}
+
+// sameEndpoints tells if two lists of endpoints are the same, regardless of their order.
+func sameEndpoints(a, b []Endpoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, e := range a {
+		if !slices.Contains(b, e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// startEndpointsWatch periodically resolves the endpoints of the cluster.
+//
+// It returns a function to stop watching.
+func (r *Repository) startEndpointsWatch(l log.Logger) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	interval := r.resolver.checkInterval()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.endpoints.unwatched:
+				return
+			case <-ticker.C:
+				r.refreshEndpoints(ctx, l)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// refreshEndpoints resolves the endpoints of the cluster, and follows the primary and the replicas when they change.
+func (r *Repository) refreshEndpoints(ctx context.Context, l log.Logger) {
+	if r.endpoints.isDetached() {
+		return
+	}
+
+	endpoints, err := r.resolveEndpoints(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			l.Warn("could not resolve the endpoints of the cluster", zap.Error(err))
+		}
+
+		return
+	}
+
+	if !endpoints.Primary.isSet() {
+		// keep the former primary, e.g. while a failover is in progress
+		endpoints.Primary = r.endpoints.get().Primary
+	}
+	previous := r.endpoints.set(endpoints)
+
+	if endpoints.Primary != previous.Primary {
+		l.Warn("the primary has changed: connections will be renewed",
+			zap.Stringer("previous_primary", previous.Primary),
+			zap.Stringer("primary", endpoints.Primary),
+		)
+
+		if err := r.reload(r.connector.appliedSettings(), true); err != nil {
+			l.Error("could not switch to the new primary", zap.Error(err))
+		} else {
+			// idle connections to the former primary are closed right away
+			r.db.SetMaxIdleConns(0)
+			r.connector.appliedSettings().restoreIdleConns(r.db.DB)
+		}
+	}
+
+	if len(endpoints.Replicas) > 0 && !sameEndpoints(endpoints.Replicas, previous.Replicas) && r.replicas != nil {
+		l.Info("the replicas have changed", zap.Stringers("replicas", endpoints.Replicas))
+		r.replicas.update(ctx, endpoints.Replicas, r.openReplicaEndpoints, l)
+	}
+}
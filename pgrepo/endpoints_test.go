@@ -0,0 +1,223 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeResolver struct {
+	mx        sync.Mutex
+	endpoints Endpoints
+	err       error
+}
+
+func (f *fakeResolver) ResolveEndpoints(context.Context) (Endpoints, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	return f.endpoints, f.err
+}
+
+func (f *fakeResolver) set(endpoints Endpoints, err error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	f.endpoints, f.err = endpoints, err
+}
+
+func TestEndpointApply(t *testing.T) {
+	t.Run("should replace the hosts of the URL", func(t *testing.T) {
+		dcfg, err := pgx.ParseConfig("postgres://h1:5432,h2:5433/testdb?sslmode=disable")
+		require.NoError(t, err)
+		require.Len(t, dcfg.Fallbacks, 1)
+
+		Endpoint{Host: "pg-2", Port: 6432}.apply(dcfg)
+		require.Equal(t, "pg-2", dcfg.Host)
+		require.Equal(t, uint16(6432), dcfg.Port)
+		require.Empty(t, dcfg.Fallbacks)
+		require.Equal(t, "testdb", dcfg.Database)
+	})
+
+	t.Run("should keep the port of the URL", func(t *testing.T) {
+		dcfg, err := pgx.ParseConfig("postgres://h1:5433/testdb?sslmode=disable")
+		require.NoError(t, err)
+
+		Endpoint{Host: "pg-2"}.apply(dcfg)
+		require.Equal(t, "pg-2", dcfg.Host)
+		require.Equal(t, uint16(5433), dcfg.Port)
+	})
+
+	t.Run("should verify the certificate of the resolved host", func(t *testing.T) {
+		dcfg, err := pgx.ParseConfig("postgres://h1:5432/testdb?sslmode=verify-full")
+		require.NoError(t, err)
+		original := dcfg.TLSConfig
+
+		Endpoint{Host: "pg-2", Port: 5432}.apply(dcfg)
+		require.Equal(t, "pg-2", dcfg.TLSConfig.ServerName)
+		require.Equal(t, "h1", original.ServerName)
+	})
+
+	t.Run("should not apply an unresolved endpoint", func(t *testing.T) {
+		dcfg, err := pgx.ParseConfig("postgres://h1:5432/testdb?sslmode=disable")
+		require.NoError(t, err)
+
+		Endpoint{}.apply(dcfg)
+		require.Equal(t, "h1", dcfg.Host)
+	})
+}
+
+func TestEndpointResolverSettings(t *testing.T) {
+	require.Equal(t, "pg-1:5432", Endpoint{Host: "pg-1", Port: 5432}.String())
+	require.True(t, sameEndpoints(
+		[]Endpoint{{Host: "a", Port: 1}, {Host: "b", Port: 2}},
+		[]Endpoint{{Host: "b", Port: 2}, {Host: "a", Port: 1}},
+	))
+	require.False(t, sameEndpoints([]Endpoint{{Host: "a", Port: 1}}, []Endpoint{{Host: "a", Port: 2}}))
+
+	s := settingsFromOptions([]Option{WithEndpointResolver(&fakeResolver{}, 0)})
+	dbs := s.DBSettingsFor(DefaultDBAlias)
+	require.NotNil(t, dbs.resolver)
+	require.Equal(t, defaultEndpointsCheckInterval, dbs.resolver.checkInterval())
+	require.Contains(t, dbs.features(), "endpoint-resolver")
+
+	s = settingsFromOptions([]Option{WithEndpointResolver(&fakeResolver{}, time.Millisecond)})
+	require.Equal(t, minEndpointsCheckInterval, s.DBSettingsFor(DefaultDBAlias).resolver.checkInterval())
+}
+
+func TestRefreshEndpoints(t *testing.T) {
+	resolver := &fakeResolver{}
+	r := New(DefaultDBAlias,
+		WithDatabaseSettings(DefaultDBAlias, WithURL("postgres://h1:5432,h2:5432/testdb?sslmode=disable")),
+		WithEndpointResolver(resolver, time.Hour),
+		WithLogger(zap.NewNop()),
+	)
+	r.endpoints = newEndpointState()
+	r.endpoints.set(Endpoints{Primary: Endpoint{Host: "h1", Port: 5432}})
+
+	connCfg := r.ConnConfig(r.DBURL(), r.log, r.app)
+	require.NotNil(t, connCfg)
+	r.endpoints.apply(connCfg)
+
+	db, connector, err := r.openPool(connCfg, nil) // no connection established
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	r.db = db
+	r.connector = connector
+	r.replicas = &replicaSet{timeout: 100 * time.Millisecond}
+	t.Cleanup(func() { _ = r.replicas.close() })
+
+	ctx := context.Background()
+	l := r.log.Bg()
+	_, generation := connector.current()
+
+	currentHost := func() string {
+		cfg, err := connector.connectConfig(ctx)
+		require.NoError(t, err)
+
+		return cfg.Host
+	}
+	require.Equal(t, "h1", currentHost())
+
+	t.Run("should keep connections when the primary is unchanged", func(t *testing.T) {
+		resolver.set(Endpoints{Primary: Endpoint{Host: "h1", Port: 5432}}, nil)
+		r.refreshEndpoints(ctx, l)
+
+		_, current := connector.current()
+		require.Equal(t, generation, current)
+	})
+
+	t.Run("should renew connections after a failover", func(t *testing.T) {
+		resolver.set(Endpoints{Primary: Endpoint{Host: "h2", Port: 5432}}, nil)
+		r.refreshEndpoints(ctx, l)
+
+		_, current := connector.current()
+		require.Equal(t, generation+1, current)
+		require.Equal(t, "h2", currentHost())
+	})
+
+	t.Run("should keep the primary when the resolver fails", func(t *testing.T) {
+		resolver.set(Endpoints{}, errors.New("unavailable"))
+		r.refreshEndpoints(ctx, l)
+
+		resolver.set(Endpoints{}, nil)
+		r.refreshEndpoints(ctx, l)
+
+		_, current := connector.current()
+		require.Equal(t, generation+1, current)
+		require.Equal(t, "h2", currentHost())
+	})
+
+	t.Run("should follow discovered replicas", func(t *testing.T) {
+		resolver.set(Endpoints{
+			Primary:  Endpoint{Host: "h2", Port: 5432},
+			Replicas: []Endpoint{{Host: "127.0.0.1", Port: 1}, {Host: "127.0.0.1", Port: 2}},
+		}, nil)
+		r.refreshEndpoints(ctx, l)
+
+		r.replicas.mx.RLock()
+		names := make([]string, 0, len(r.replicas.members))
+		for _, member := range r.replicas.members {
+			names = append(names, member.name)
+		}
+		r.replicas.mx.RUnlock()
+
+		require.ElementsMatch(t, []string{"127.0.0.1:1", "127.0.0.1:2"}, names)
+		require.Nil(t, r.replicas.next(), "expected unreachable replicas to be unhealthy")
+
+		_, current := connector.current()
+		require.Equal(t, generation+1, current)
+	})
+
+	t.Run("should only renew the pools of replicas which have changed", func(t *testing.T) {
+		r.replicas.mx.RLock()
+		var kept *replica
+		for _, member := range r.replicas.members {
+			if member.name == "127.0.0.1:1" {
+				kept = member
+			}
+		}
+		r.replicas.mx.RUnlock()
+		require.NotNil(t, kept)
+
+		resolver.set(Endpoints{
+			Primary:  Endpoint{Host: "h2", Port: 5432},
+			Replicas: []Endpoint{{Host: "127.0.0.1", Port: 1}, {Host: "127.0.0.1", Port: 3}},
+		}, nil)
+		r.refreshEndpoints(ctx, l)
+
+		r.replicas.mx.RLock()
+		defer r.replicas.mx.RUnlock()
+		require.Len(t, r.replicas.members, 2)
+		require.Same(t, kept, r.replicas.members[0])
+		require.Equal(t, "127.0.0.1:3", r.replicas.members[1].name)
+	})
+
+	t.Run("should stop following the cluster once the standby is promoted", func(t *testing.T) {
+		stop := r.startEndpointsWatch(l)
+		t.Cleanup(stop)
+
+		r.standby = &standby{db: sqlx.NewDb(sql.OpenDB(&fakeReplica{}), driverName), url: "postgres://dr-cluster:5432/testdb", name: "dr"}
+		require.NoError(t, r.PromoteStandby(ctx))
+		require.Equal(t, "dr-cluster", currentHost())
+
+		select {
+		case <-r.endpoints.unwatched:
+		default:
+			require.Fail(t, "expected the endpoint watch to be stopped")
+		}
+
+		resolver.set(Endpoints{Primary: Endpoint{Host: "h1", Port: 5432}}, nil)
+		r.refreshEndpoints(ctx, l)
+		require.NoError(t, r.reload(r.connector.appliedSettings(), true))
+		require.Equal(t, "dr-cluster", currentHost())
+	})
+}
@@ -0,0 +1,169 @@
+package pgrepo
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Sentinel errors for common postgres error conditions.
+//
+// Errors returned by the driver are matched against these with errors.Is, once classified by ClassifyError.
+var (
+	ErrUniqueViolation      = errors.New("unique violation")
+	ErrForeignKeyViolation  = errors.New("foreign key violation")
+	ErrNotNullViolation     = errors.New("not null violation")
+	ErrCheckViolation       = errors.New("check violation")
+	ErrSerializationFailure = errors.New("serialization failure")
+	ErrDeadlock             = errors.New("deadlock detected")
+	ErrQueryCanceled        = errors.New("query canceled")
+	ErrInvalidDatabase      = errors.New("invalid database")
+	ErrConnectionFailure    = errors.New("connection failure")
+)
+
+// SQLSTATE codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	codeUniqueViolation      = "23505"
+	codeForeignKeyViolation  = "23503"
+	codeNotNullViolation     = "23502"
+	codeCheckViolation       = "23514"
+	codeSerializationFailure = "40001"
+	codeDeadlock             = "40P01"
+	codeQueryCanceled        = "57014"
+	codeUndefinedTable       = "42P01"
+
+	classConnection      = "08"
+	classAuth            = "28"
+	classInvalidDatabase = "3D"
+)
+
+var (
+	sqlStateErrors = map[string]error{
+		codeUniqueViolation:      ErrUniqueViolation,
+		codeForeignKeyViolation:  ErrForeignKeyViolation,
+		codeNotNullViolation:     ErrNotNullViolation,
+		codeCheckViolation:       ErrCheckViolation,
+		codeSerializationFailure: ErrSerializationFailure,
+		codeDeadlock:             ErrDeadlock,
+		codeQueryCanceled:        ErrQueryCanceled,
+	}
+
+	sqlStateClassErrors = map[string]error{
+		classConnection:      ErrConnectionFailure,
+		classAuth:            ErrPGAuth,
+		classInvalidDatabase: ErrInvalidDatabase,
+	}
+)
+
+// classifiedError decorates a postgres error with a sentinel error, retaining the original message.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// ClassifyError decorates a postgres error so it can be matched against the sentinel errors of this package
+// with errors.Is, e.g. errors.Is(err, ErrUniqueViolation).
+//
+// The original error is retained and may still be inspected with errors.As (e.g. as a *pgconn.PgError).
+// Errors which are not postgres errors, or which are not classified, are returned unchanged.
+func ClassifyError(err error) error {
+	class := sentinelFor(err)
+	if class == nil || errors.Is(err, class) {
+		return err
+	}
+
+	return &classifiedError{class: class, err: err}
+}
+
+func sentinelFor(err error) error {
+	code := SQLState(err)
+	if code == "" {
+		return nil
+	}
+
+	if class, ok := sqlStateErrors[code]; ok {
+		return class
+	}
+
+	if len(code) < 2 {
+		return nil
+	}
+
+	return sqlStateClassErrors[code[:2]]
+}
+
+// SQLState returns the SQLSTATE code of a postgres error, or the empty string if err is not a postgres error.
+func SQLState(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return ""
+	}
+
+	return pgErr.Code
+}
+
+// IsUniqueViolation tells if err is a unique constraint violation (SQLSTATE 23505).
+func IsUniqueViolation(err error) bool {
+	return SQLState(err) == codeUniqueViolation
+}
+
+// IsForeignKeyViolation tells if err is a foreign key constraint violation (SQLSTATE 23503).
+func IsForeignKeyViolation(err error) bool {
+	return SQLState(err) == codeForeignKeyViolation
+}
+
+// IsNotNullViolation tells if err is a not null constraint violation (SQLSTATE 23502).
+func IsNotNullViolation(err error) bool {
+	return SQLState(err) == codeNotNullViolation
+}
+
+// IsCheckViolation tells if err is a check constraint violation (SQLSTATE 23514).
+func IsCheckViolation(err error) bool {
+	return SQLState(err) == codeCheckViolation
+}
+
+// IsSerializationFailure tells if err is a serialization failure, which may be retried (SQLSTATE 40001).
+func IsSerializationFailure(err error) bool {
+	return SQLState(err) == codeSerializationFailure
+}
+
+// IsDeadlock tells if err is a detected deadlock, which may be retried (SQLSTATE 40P01).
+func IsDeadlock(err error) bool {
+	return SQLState(err) == codeDeadlock
+}
+
+// IsQueryCanceled tells if err is a query canceled by the server, e.g. on statement timeout (SQLSTATE 57014).
+func IsQueryCanceled(err error) bool {
+	return SQLState(err) == codeQueryCanceled
+}
+
+// IsAuthError tells if err is an authentication or authorization failure (SQLSTATE class 28).
+func IsAuthError(err error) bool {
+	return strings.HasPrefix(SQLState(err), classAuth)
+}
+
+// IsInvalidDatabase tells if err reports a database which does not exist (SQLSTATE class 3D).
+func IsInvalidDatabase(err error) bool {
+	return strings.HasPrefix(SQLState(err), classInvalidDatabase)
+}
+
+// IsConnectionError tells if err is a failure to connect or a broken connection (SQLSTATE class 08,
+// or a network error).
+func IsConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return strings.HasPrefix(SQLState(err), classConnection)
+}
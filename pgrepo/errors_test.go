@@ -0,0 +1,77 @@
+package pgrepo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	for _, toPin := range []struct {
+		Code      string
+		Sentinel  error
+		Predicate func(error) bool
+	}{
+		{Code: "23505", Sentinel: ErrUniqueViolation, Predicate: IsUniqueViolation},
+		{Code: "23503", Sentinel: ErrForeignKeyViolation, Predicate: IsForeignKeyViolation},
+		{Code: "23502", Sentinel: ErrNotNullViolation, Predicate: IsNotNullViolation},
+		{Code: "23514", Sentinel: ErrCheckViolation, Predicate: IsCheckViolation},
+		{Code: "40001", Sentinel: ErrSerializationFailure, Predicate: IsSerializationFailure},
+		{Code: "40P01", Sentinel: ErrDeadlock, Predicate: IsDeadlock},
+		{Code: "57014", Sentinel: ErrQueryCanceled, Predicate: IsQueryCanceled},
+		{Code: "28P01", Sentinel: ErrPGAuth, Predicate: IsAuthError},
+		{Code: "3D000", Sentinel: ErrInvalidDatabase, Predicate: IsInvalidDatabase},
+		{Code: "08006", Sentinel: ErrConnectionFailure, Predicate: IsConnectionError},
+	} {
+		fixture := toPin
+
+		t.Run(fmt.Sprintf("with SQLSTATE %s", fixture.Code), func(t *testing.T) {
+			pgErr := &pgconn.PgError{Code: fixture.Code, Message: "test"}
+			wrapped := fmt.Errorf("query failed: %w", pgErr)
+
+			require.True(t, fixture.Predicate(wrapped))
+			require.Equal(t, fixture.Code, SQLState(wrapped))
+
+			err := ClassifyError(wrapped)
+			require.ErrorIs(t, err, fixture.Sentinel)
+			require.Equal(t, wrapped.Error(), err.Error())
+
+			var asPgErr *pgconn.PgError
+			require.ErrorAs(t, err, &asPgErr)
+			require.Equal(t, fixture.Code, asPgErr.Code)
+		})
+	}
+
+	t.Run("should not classify unknown SQLSTATE", func(t *testing.T) {
+		pgErr := &pgconn.PgError{Code: "42P01"}
+
+		require.Equal(t, error(pgErr), ClassifyError(pgErr))
+		require.False(t, IsUniqueViolation(pgErr))
+	})
+
+	t.Run("should not classify other errors", func(t *testing.T) {
+		err := errors.New("unique violation")
+
+		require.Equal(t, err, ClassifyError(err))
+		require.Empty(t, SQLState(err))
+		require.False(t, IsUniqueViolation(err))
+		require.NotErrorIs(t, ClassifyError(err), ErrUniqueViolation)
+	})
+
+	t.Run("should not classify twice", func(t *testing.T) {
+		err := ClassifyError(&pgconn.PgError{Code: "23505"})
+
+		require.Equal(t, err, ClassifyError(err))
+	})
+
+	t.Run("should recognize network errors", func(t *testing.T) {
+		err := fmt.Errorf("failed to connect: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+
+		require.True(t, IsConnectionError(err))
+		require.False(t, IsConnectionError(errors.New("other")))
+	})
+}
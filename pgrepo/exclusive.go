@@ -0,0 +1,318 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultTaskLocksTable = "task_locks"
+
+// terminateTaskHolder terminates the backend holding the advisory lock of a task, provided it is the recorded holder.
+//
+// The advisory lock on a bigint key is reported in pg_locks with the high and low halves of the key
+// as classid and objid, and objsubid 1.
+const terminateTaskHolder = `SELECT pg_terminate_backend(l.pid)
+FROM pg_locks l, (SELECT hashtext($1)::bigint AS k) AS lock
+WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+  AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+  AND l.classid = ((lock.k >> 32) & 4294967295)::oid
+  AND l.objid = (lock.k & 4294967295)::oid
+  AND l.pid = $2`
+
+// ErrTaskLocked is returned by RunExclusive when a task is already run by another instance,
+// or when the lock on a running task is lost.
+var ErrTaskLocked = errors.New("task locked by another instance")
+
+// TaskLock describes the holder of a task run with RunExclusive.
+type TaskLock struct {
+	Name        string    `db:"name"`
+	Holder      string    `db:"holder"` // app@host:pid
+	PID         int       `db:"pid"`    // backend pid of the session holding the lock
+	AcquiredAt  time.Time `db:"acquired_at"`
+	HeartbeatAt time.Time `db:"heartbeat_at"`
+}
+
+// WithTaskLocks configures the locks of tasks run with RunExclusive.
+//
+// The holder of a task updates its heartbeat at every interval. A holder which heartbeat is older
+// than staleAfter is considered hung, and another instance may take over the task.
+func WithTaskLocks(heartbeat, staleAfter time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Tasks.Heartbeat = heartbeat
+		o.Tasks.StaleAfter = staleAfter
+	}
+}
+
+// RunExclusive runs a named task, ensuring that only one instance across the fleet runs it at a time.
+//
+// This is intended for cron-like singleton tasks: when another instance already runs the task,
+// RunExclusive returns immediately with ErrTaskLocked.
+//
+// Mutual exclusion relies on a session-level advisory lock. The holder is recorded in the "task_locks" table
+// (see Repository.TaskLocks), with a heartbeat updated while the task is running. If the heartbeat of
+// the holder is stale (e.g. the holder is hung but its session is still alive), its session is terminated
+// and the task is taken over.
+//
+// The context passed to fn is canceled if the lock is lost while running.
+//
+// Behind a transaction-pooling proxy (see WithCompat), RunExclusive returns ErrSessionPinningDisabled.
+func (r *Repository) RunExclusive(ctx context.Context, name string, fn func(context.Context) error) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	if r.PGConfig.transactionPooling() {
+		return ErrSessionPinningDisabled
+	}
+
+	ts := r.taskSettings()
+	table := quoteQualifiedIdentifier(r.taskLocksTable(ts))
+	l := r.logger(ctx).With(zap.String("task", name))
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	defer r.markAdminSession(ctx, conn)()
+
+	if err = ensureTaskLocksTable(ctx, conn, table); err != nil {
+		return err
+	}
+
+	if err = r.acquireTask(ctx, conn, table, name, ts); err != nil {
+		return err
+	}
+	l.Info("task lock acquired", zap.String("holder", taskHolder(r.app)))
+
+	defer func() {
+		releaseCtx := context.Background()
+		_, _ = conn.ExecContext(releaseCtx, fmt.Sprintf(`DELETE FROM %s WHERE name = $1 AND pid = pg_backend_pid()`, table), name)
+		_, _ = conn.ExecContext(releaseCtx, `SELECT pg_advisory_unlock(hashtext($1))`, taskLockKey(name))
+		l.Info("task lock released")
+	}()
+
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(ts.Heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-taskCtx.Done():
+				return
+			case <-ticker.C:
+				if err := heartbeatTask(taskCtx, conn, table, name); err != nil {
+					l.Error("task lock lost", zap.Error(err))
+					cancel(err)
+
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(taskCtx)
+	close(done)
+	wg.Wait()
+
+	if cause := context.Cause(taskCtx); err == nil && errors.Is(cause, ErrTaskLocked) {
+		// the task completed, but possibly concurrently with another holder
+		err = cause
+	}
+
+	return err
+}
+
+// TaskLocks returns the holders of the tasks currently run with RunExclusive.
+func (r *Repository) TaskLocks(ctx context.Context) ([]TaskLock, error) {
+	if r.db == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	table := quoteQualifiedIdentifier(r.taskLocksTable(r.taskSettings()))
+	var locks []TaskLock
+	err := r.db.SelectContext(ctx, &locks, fmt.Sprintf(`SELECT name, holder, pid, acquired_at, heartbeat_at
+FROM %s t
+WHERE EXISTS (SELECT 1 FROM pg_stat_activity a WHERE a.pid = t.pid)
+ORDER BY name`, table))
+	if err != nil {
+		if SQLState(err) == codeUndefinedTable {
+			// no task was ever run
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return locks, nil
+}
+
+// acquireTask acquires the advisory lock of a task, taking over a stale holder.
+func (r *Repository) acquireTask(ctx context.Context, conn *sql.Conn, table, name string, ts taskSettings) error {
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, taskLockKey(name)).Scan(&locked); err != nil {
+		return fmt.Errorf("could not acquire the lock of task %s: %w", name, err)
+	}
+
+	if !locked {
+		var (
+			holder TaskLock
+			stale  bool
+		)
+		err := conn.QueryRowContext(ctx, fmt.Sprintf(
+			`SELECT holder, pid, heartbeat_at, heartbeat_at < now() - make_interval(secs => $2) FROM %s WHERE name = $1`, table),
+			name, ts.StaleAfter.Seconds(),
+		).Scan(&holder.Holder, &holder.PID, &holder.HeartbeatAt, &stale)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: %s", ErrTaskLocked, name)
+			}
+
+			return err
+		}
+
+		if !stale {
+			return fmt.Errorf("%w: %s is held by %s", ErrTaskLocked, name, holder.Holder)
+		}
+
+		r.logger(ctx).Warn("taking over stale task lock",
+			zap.String("task", name),
+			zap.String("holder", holder.Holder),
+			zap.Time("heartbeat_at", holder.HeartbeatAt),
+		)
+
+		// the recorded holder may be outdated (e.g. the actual holder has not recorded itself yet, or the pid
+		// has been reused): only the backend which actually holds the lock is terminated
+		var terminated bool
+		err = conn.QueryRowContext(ctx, terminateTaskHolder, taskLockKey(name), holder.PID).Scan(&terminated)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: %s is not held by %s", ErrTaskLocked, name, holder.Holder)
+			}
+
+			return fmt.Errorf("could not take over task %s from %s: %w", name, holder.Holder, err)
+		}
+
+		// the lock is released when the terminated backend exits
+		lockCtx, cancel := context.WithTimeout(ctx, ts.Heartbeat)
+		defer cancel()
+
+		if _, err = conn.ExecContext(lockCtx, `SELECT pg_advisory_lock(hashtext($1))`, taskLockKey(name)); err != nil {
+			return fmt.Errorf("could not take over task %s from %s: %w", name, holder.Holder, err)
+		}
+	}
+
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (name, holder, pid, acquired_at, heartbeat_at)
+VALUES ($1, $2, pg_backend_pid(), now(), now())
+ON CONFLICT (name) DO UPDATE SET
+  holder = EXCLUDED.holder, pid = EXCLUDED.pid, acquired_at = EXCLUDED.acquired_at, heartbeat_at = EXCLUDED.heartbeat_at`, table),
+		name, taskHolder(r.app),
+	)
+	if err != nil {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, taskLockKey(name))
+
+		return fmt.Errorf("could not record the holder of task %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func heartbeatTask(ctx context.Context, conn *sql.Conn, table, name string) error {
+	res, err := conn.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET heartbeat_at = now() WHERE name = $1 AND pid = pg_backend_pid()`, table), name)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s was taken over", ErrTaskLocked, name)
+	}
+
+	return nil
+}
+
+func ensureTaskLocksTable(ctx context.Context, conn *sql.Conn, table string) error {
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	name text PRIMARY KEY,
+	holder text NOT NULL,
+	pid int NOT NULL,
+	acquired_at timestamptz NOT NULL,
+	heartbeat_at timestamptz NOT NULL
+)`, table))
+	if err != nil {
+		return fmt.Errorf("could not create task locks table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+func taskLockKey(name string) string {
+	return "pgrepo_task:" + name
+}
+
+// taskHolder identifies this instance as the holder of a task.
+func taskHolder(app string) string {
+	if app == "" {
+		app = "pgrepo"
+	}
+
+	host, _ := os.Hostname()
+
+	return fmt.Sprintf("%s@%s:%d", app, host, os.Getpid())
+}
+
+func (r databaseSettings) taskSettings() taskSettings {
+	ts := defaultSettings.PGConfig.Tasks
+	if r.PGConfig == nil {
+		return ts
+	}
+
+	if r.PGConfig.Tasks.Table != "" {
+		ts.Table = r.PGConfig.Tasks.Table
+	}
+	if r.PGConfig.Tasks.Heartbeat > 0 {
+		ts.Heartbeat = r.PGConfig.Tasks.Heartbeat
+	}
+	if r.PGConfig.Tasks.StaleAfter > 0 {
+		ts.StaleAfter = r.PGConfig.Tasks.StaleAfter
+	}
+
+	return ts
+}
+
+func (r databaseSettings) taskLocksTable(ts taskSettings) string {
+	table := ts.Table
+	if table == "" {
+		table = defaultTaskLocksTable
+	}
+
+	if r.namespace != "" && !strings.Contains(table, ".") {
+		return r.namespace + "." + table
+	}
+
+	return table
+}
@@ -0,0 +1,51 @@
+package pgrepo
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunExclusive(t *testing.T) {
+	t.Run("should require a started repository", func(t *testing.T) {
+		r := New(DefaultDBAlias)
+
+		require.ErrorIs(t, r.RunExclusive(context.Background(), "nightly", func(context.Context) error {
+			t.Fatal("task should not run")
+
+			return nil
+		}), ErrDBNotInitialized)
+
+		_, err := r.TaskLocks(context.Background())
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("should resolve task settings", func(t *testing.T) {
+		ts := databaseSettings{}.taskSettings()
+		require.Equal(t, 10*time.Second, ts.Heartbeat)
+		require.Equal(t, time.Minute, ts.StaleAfter)
+		require.Equal(t, "task_locks", databaseSettings{}.taskLocksTable(ts))
+
+		dbs := settingsFromOptions([]Option{
+			WithDefaultPoolOptions(WithTaskLocks(time.Second, 5*time.Second)),
+		}).DBSettingsFor(DefaultDBAlias)
+		ts = dbs.taskSettings()
+		require.Equal(t, time.Second, ts.Heartbeat)
+		require.Equal(t, 5*time.Second, ts.StaleAfter)
+
+		dbs.namespace = "tenant"
+		require.Equal(t, "tenant.task_locks", dbs.taskLocksTable(ts))
+	})
+
+	t.Run("should identify the holder", func(t *testing.T) {
+		holder := taskHolder("billing")
+		require.True(t, strings.HasPrefix(holder, "billing@"))
+		require.True(t, strings.HasSuffix(holder, ":"+strconv.Itoa(os.Getpid())))
+		require.True(t, strings.HasPrefix(taskHolder(""), "pgrepo@"))
+	})
+}
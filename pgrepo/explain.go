@@ -0,0 +1,197 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidPlan is returned when the output of EXPLAIN cannot be parsed.
+var ErrInvalidPlan = errors.New("invalid query plan")
+
+type (
+	// QueryPlan is the plan of a statement, as returned by EXPLAIN (FORMAT JSON).
+	//
+	// Execution statistics are only available with ExplainAnalyze.
+	QueryPlan struct {
+		Plan          PlanNode `json:"Plan"`
+		PlanningTime  float64  `json:"Planning Time,omitempty"`  // in milliseconds
+		ExecutionTime float64  `json:"Execution Time,omitempty"` // in milliseconds
+	}
+
+	// PlanNode is a node of a query plan.
+	PlanNode struct {
+		NodeType            string     `json:"Node Type"`
+		ParentRelationship  string     `json:"Parent Relationship,omitempty"`
+		RelationName        string     `json:"Relation Name,omitempty"`
+		Schema              string     `json:"Schema,omitempty"`
+		Alias               string     `json:"Alias,omitempty"`
+		IndexName           string     `json:"Index Name,omitempty"`
+		JoinType            string     `json:"Join Type,omitempty"`
+		Strategy            string     `json:"Strategy,omitempty"`
+		Filter              string     `json:"Filter,omitempty"`
+		IndexCond           string     `json:"Index Cond,omitempty"`
+		HashCond            string     `json:"Hash Cond,omitempty"`
+		SortKey             []string   `json:"Sort Key,omitempty"`
+		StartupCost         float64    `json:"Startup Cost"`
+		TotalCost           float64    `json:"Total Cost"`
+		PlanRows            float64    `json:"Plan Rows"`
+		PlanWidth           int        `json:"Plan Width"`
+		ActualStartupTime   float64    `json:"Actual Startup Time,omitempty"` // in milliseconds
+		ActualTotalTime     float64    `json:"Actual Total Time,omitempty"`   // in milliseconds
+		ActualRows          float64    `json:"Actual Rows,omitempty"`
+		ActualLoops         float64    `json:"Actual Loops,omitempty"`
+		RowsRemovedByFilter float64    `json:"Rows Removed by Filter,omitempty"`
+		Plans               []PlanNode `json:"Plans,omitempty"`
+	}
+)
+
+// Walk visits the nodes of a plan, depth-first.
+func (n PlanNode) Walk(visit func(PlanNode)) {
+	visit(n)
+	for _, child := range n.Plans {
+		child.Walk(visit)
+	}
+}
+
+// Explain returns the plan of a statement, without executing it.
+//
+// The default deadline of read operations applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) Explain(ctx context.Context, query string, args ...any) (*QueryPlan, error) {
+	if r.db == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationRead)
+	defer cancel()
+
+	var output string
+	if err := r.db.QueryRowxContext(ctx, `EXPLAIN (FORMAT JSON) `+query, args...).Scan(&output); err != nil {
+		return nil, fmt.Errorf("could not explain query: %w", err)
+	}
+
+	return parseQueryPlan(output)
+}
+
+// ExplainAnalyze executes a statement and returns its plan, with execution statistics.
+//
+// The statement is executed within a transaction which is rolled back, so statements that modify data may be analyzed
+// safely. Side effects outside of the transaction (e.g. sequences) are not rolled back.
+//
+// The default deadline of read operations applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) ExplainAnalyze(ctx context.Context, query string, args ...any) (*QueryPlan, error) {
+	if r.db == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationRead)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(withManagedTx(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var output string
+	if err = tx.QueryRowxContext(ctx, `EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) `+query, args...).Scan(&output); err != nil {
+		return nil, fmt.Errorf("could not explain query: %w", err)
+	}
+
+	return parseQueryPlan(output)
+}
+
+func parseQueryPlan(output string) (*QueryPlan, error) {
+	var plans []QueryPlan
+	if err := json.Unmarshal([]byte(output), &plans); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPlan, err)
+	}
+
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("%w: empty plan", ErrInvalidPlan)
+	}
+
+	return &plans[0], nil
+}
+
+// WithExplainSlowQueries attaches the output of EXPLAIN to the trace spans of read-only queries slower
+// than the threshold. This requires the "otel" trace provider (see WithTraceProvider).
+//
+// Slow queries are explained on the same connection, right after they complete. They are not executed again:
+// the plan comes without execution statistics (see ExplainAnalyze).
+//
+// Since plans may include the values of query parameters, plans are only attached with WithSensitiveParams.
+//
+// Queries within a transaction are not explained, and neither are queries whose span is not sampled.
+func WithExplainSlowQueries(threshold time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Trace.ExplainThreshold = threshold
+	}
+}
+
+type (
+	// explainTracer explains slow read-only queries, and attaches their plan to the current trace span.
+	//
+	// Queries are not executed again.
+	explainTracer struct {
+		threshold time.Duration
+	}
+
+	explainQueryKey struct{}
+
+	explainingKey struct{}
+
+	explainQuery struct {
+		sql   string
+		args  []any
+		start time.Time
+	}
+)
+
+var _ pgx.QueryTracer = &explainTracer{}
+
+func newExplainTracer(ts traceSettings, sensitiveParams bool) *explainTracer {
+	if ts.ExplainThreshold <= 0 || !sensitiveParams {
+		return nil
+	}
+
+	return &explainTracer{threshold: ts.ExplainThreshold}
+}
+
+func (t *explainTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if ctx.Value(explainingKey{}) != nil || !isReadOnlyQuery(data.SQL) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, explainQueryKey{}, &explainQuery{sql: data.SQL, args: data.Args, start: time.Now()})
+}
+
+func (t *explainTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	q, ok := ctx.Value(explainQueryKey{}).(*explainQuery)
+	if !ok || data.Err != nil || time.Since(q.start) < t.threshold {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() || conn == nil || conn.PgConn().TxStatus() != 'I' {
+		return
+	}
+
+	var output string
+	ctx = context.WithValue(ctx, explainingKey{}, true)
+	if err := conn.QueryRow(ctx, `EXPLAIN (FORMAT JSON) `+q.sql, q.args...).Scan(&output); err != nil {
+		span.AddEvent("explain failed", trace.WithAttributes(attribute.String("error", err.Error())))
+
+		return
+	}
+
+	span.SetAttributes(attribute.String("db.postgresql.plan", output))
+}
@@ -0,0 +1,140 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const samplePlan = `[
+  {
+    "Plan": {
+      "Node Type": "Hash Join",
+      "Join Type": "Inner",
+      "Startup Cost": 1.09,
+      "Total Cost": 25.34,
+      "Plan Rows": 10,
+      "Plan Width": 36,
+      "Actual Total Time": 0.123,
+      "Actual Rows": 8,
+      "Actual Loops": 1,
+      "Hash Cond": "(o.customer_id = c.id)",
+      "Plans": [
+        {
+          "Node Type": "Seq Scan",
+          "Parent Relationship": "Outer",
+          "Relation Name": "orders",
+          "Alias": "o",
+          "Filter": "(status = 'open'::text)",
+          "Rows Removed by Filter": 42,
+          "Startup Cost": 0,
+          "Total Cost": 22.7,
+          "Plan Rows": 6,
+          "Plan Width": 20
+        },
+        {
+          "Node Type": "Index Scan",
+          "Parent Relationship": "Inner",
+          "Relation Name": "customers",
+          "Index Name": "customers_pkey",
+          "Alias": "c",
+          "Startup Cost": 0.15,
+          "Total Cost": 1.05,
+          "Plan Rows": 1,
+          "Plan Width": 20
+        }
+      ]
+    },
+    "Planning Time": 0.2,
+    "Execution Time": 0.31
+  }
+]`
+
+func TestParseQueryPlan(t *testing.T) {
+	t.Run("should parse a plan", func(t *testing.T) {
+		plan, err := parseQueryPlan(samplePlan)
+		require.NoError(t, err)
+		require.Equal(t, "Hash Join", plan.Plan.NodeType)
+		require.Equal(t, "(o.customer_id = c.id)", plan.Plan.HashCond)
+		require.InDelta(t, 25.34, plan.Plan.TotalCost, 1e-9)
+		require.InDelta(t, 0.31, plan.ExecutionTime, 1e-9)
+		require.Len(t, plan.Plan.Plans, 2)
+		require.Equal(t, "orders", plan.Plan.Plans[0].RelationName)
+		require.InDelta(t, 42, plan.Plan.Plans[0].RowsRemovedByFilter, 1e-9)
+
+		var scans []string
+		plan.Plan.Walk(func(n PlanNode) {
+			if n.RelationName != "" {
+				scans = append(scans, n.NodeType+" on "+n.RelationName)
+			}
+		})
+		require.Equal(t, []string{"Seq Scan on orders", "Index Scan on customers"}, scans)
+	})
+
+	t.Run("should not parse an invalid plan", func(t *testing.T) {
+		_, err := parseQueryPlan(`{"Plan":`)
+		require.ErrorIs(t, err, ErrInvalidPlan)
+
+		_, err = parseQueryPlan(`[]`)
+		require.ErrorIs(t, err, ErrInvalidPlan)
+	})
+}
+
+func TestExplain(t *testing.T) {
+	r := &Repository{}
+
+	_, err := r.Explain(context.Background(), `SELECT 1`)
+	require.ErrorIs(t, err, ErrDBNotInitialized)
+
+	_, err = r.ExplainAnalyze(context.Background(), `SELECT 1`)
+	require.ErrorIs(t, err, ErrDBNotInitialized)
+}
+
+func TestExplainTracer(t *testing.T) {
+	t.Run("should be disabled by default", func(t *testing.T) {
+		require.Nil(t, newExplainTracer(defaultSettings.PGConfig.Trace, true))
+
+		ps := poolSettingsFromOptions([]PoolOption{WithExplainSlowQueries(time.Second)})
+		require.Equal(t, time.Second, ps.Trace.ExplainThreshold)
+		require.NotNil(t, newExplainTracer(ps.Trace, true))
+	})
+
+	t.Run("should require sensitive params", func(t *testing.T) {
+		ps := poolSettingsFromOptions([]PoolOption{WithExplainSlowQueries(time.Second)})
+		require.Nil(t, newExplainTracer(ps.Trace, ps.sensitiveParams()))
+
+		ps = poolSettingsFromOptions([]PoolOption{WithExplainSlowQueries(time.Second), WithSensitiveParams(true)})
+		require.NotNil(t, newExplainTracer(ps.Trace, ps.sensitiveParams()))
+	})
+
+	tracer := newExplainTracer(traceSettings{ExplainThreshold: time.Millisecond}, true)
+	ctx := context.Background()
+
+	t.Run("should only track read-only queries", func(t *testing.T) {
+		traced := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: `SELECT * FROM orders WHERE id = $1`, Args: []any{1}})
+		q, ok := traced.Value(explainQueryKey{}).(*explainQuery)
+		require.True(t, ok)
+		require.Equal(t, []any{1}, q.args)
+
+		traced = tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: `UPDATE orders SET status = 'closed'`})
+		require.Nil(t, traced.Value(explainQueryKey{}))
+	})
+
+	t.Run("should not explain its own queries", func(t *testing.T) {
+		explaining := context.WithValue(ctx, explainingKey{}, true)
+		traced := tracer.TraceQueryStart(explaining, nil, pgx.TraceQueryStartData{SQL: `SELECT 1`})
+		require.Nil(t, traced.Value(explainQueryKey{}))
+	})
+
+	t.Run("should skip queries without a recording span", func(t *testing.T) {
+		traced := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: `SELECT pg_sleep(1)`})
+		time.Sleep(2 * time.Millisecond)
+
+		require.NotPanics(t, func() {
+			tracer.TraceQueryEnd(traced, nil, pgx.TraceQueryEndData{})
+		})
+	})
+}
@@ -0,0 +1,159 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// fakeServer is a scripted database/sql connector, standing in for a postgres server.
+	//
+	// Queries are answered by a script, and statements are recorded with their arguments.
+	// Statements fail when the fake server is down, or with a scripted error.
+	fakeServer struct {
+		mx       sync.Mutex
+		script   fakeScript
+		down     atomic.Bool
+		err      error
+		executed []string
+		args     []driver.Value   // arguments of the last query
+		execArgs [][]driver.Value // arguments of executed statements
+	}
+
+	// fakeScript answers a query. A nil result returns no rows.
+	fakeScript func(query string, args []driver.Value) (*fakeRows, error)
+
+	fakeConn struct {
+		f      *fakeServer
+		script fakeScript
+	}
+
+	fakeTx fakeConn
+
+	fakeRows struct {
+		columns []string
+		rows    [][]driver.Value
+	}
+)
+
+func (f *fakeServer) Connect(context.Context) (driver.Conn, error) {
+	return f.connect(f.script)
+}
+
+func (f *fakeServer) Driver() driver.Driver { return nil }
+
+// connect establishes a connection answering queries with a script.
+func (f *fakeServer) connect(script fakeScript) (driver.Conn, error) {
+	if f.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+
+	return fakeConn{f: f, script: script}, nil
+}
+
+// queries returns the statements executed so far.
+func (f *fakeServer) queries() []string {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	return append([]string(nil), f.executed...)
+}
+
+// record a statement, and return its arguments.
+func (f *fakeServer) record(query string, args []driver.NamedValue, exec bool) ([]driver.Value, error) {
+	if f.down.Load() {
+		return nil, driver.ErrBadConn
+	}
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	f.executed = append(f.executed, query)
+	values := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+
+	if exec {
+		f.execArgs = append(f.execArgs, values)
+	} else {
+		f.args = values
+	}
+
+	return values, f.err
+}
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+
+// Begin a transaction, recorded as "BEGIN", then "COMMIT" or "ROLLBACK".
+func (c fakeConn) Begin() (driver.Tx, error) {
+	if _, err := c.f.record("BEGIN", nil, true); err != nil {
+		return nil, err
+	}
+
+	return fakeTx(c), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, err := c.f.record(query, args, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows *fakeRows
+	if c.script != nil {
+		if rows, err = c.script(query, values); err != nil {
+			return nil, err
+		}
+	}
+
+	if rows == nil {
+		return &fakeRows{}, nil
+	}
+
+	return &fakeRows{columns: rows.columns, rows: rows.rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, err := c.f.record(query, args, true); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (t fakeTx) Commit() error {
+	_, err := t.f.record("COMMIT", nil, true)
+
+	return err
+}
+
+func (t fakeTx) Rollback() error {
+	_, err := t.f.record("ROLLBACK", nil, true)
+
+	return err
+}
+
+// singleValue returns a single row with a single column.
+func singleValue(column string, value driver.Value) *fakeRows {
+	return &fakeRows{columns: []string{column}, rows: [][]driver.Value{{value}}}
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
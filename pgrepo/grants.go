@@ -0,0 +1,305 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Actions of grant changes.
+const (
+	GrantActionGrant  = "GRANT"
+	GrantActionRevoke = "REVOKE"
+)
+
+// tablePrivileges are the privileges which may be granted on tables and views.
+var tablePrivileges = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"}
+
+type (
+	// GrantSpec declares the desired privileges of roles on tables.
+	//
+	// Example (YAML):
+	//
+	//	grants:
+	//	  - role: reporting
+	//	    schema: public
+	//	    privileges: [SELECT] # on all tables of the schema
+	//	  - role: app
+	//	    schema: public
+	//	    tables: [orders, order_items]
+	//	    privileges: [SELECT, INSERT, UPDATE, DELETE]
+	GrantSpec struct {
+		Grants []Grant `yaml:"grants" json:"grants" mapstructure:"grants"`
+	}
+
+	// Grant declares privileges of a role on tables of a schema.
+	Grant struct {
+		Role       string   `yaml:"role" json:"role" mapstructure:"role"`
+		Schema     string   `yaml:"schema" json:"schema" mapstructure:"schema"`             // defaults to "public"
+		Tables     []string `yaml:"tables" json:"tables" mapstructure:"tables"`             // all tables and views of the schema if empty or "*"
+		Privileges []string `yaml:"privileges" json:"privileges" mapstructure:"privileges"` // table privileges, or ALL
+	}
+
+	// GrantChange is a privilege granted or revoked by SyncGrants.
+	GrantChange struct {
+		Action    string // GRANT or REVOKE
+		Role      string
+		Schema    string
+		Table     string
+		Privilege string
+	}
+
+	grantKey struct {
+		role, schema, table, privilege string
+	}
+
+	roleSchema struct {
+		role, schema string
+	}
+)
+
+// ParseGrantSpec parses a grant specification from YAML.
+func ParseGrantSpec(data []byte) (GrantSpec, error) {
+	var spec GrantSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("invalid grant specification: %w: %w", ErrInvalidConfig, err)
+	}
+
+	return spec, spec.Validate()
+}
+
+// Validate the grant specification.
+func (s GrantSpec) Validate() error {
+	for i, grant := range s.Grants {
+		if grant.Role == "" {
+			return fmt.Errorf("grant #%d: a role is required: %w", i, ErrInvalidConfig)
+		}
+
+		if len(grant.Privileges) == 0 {
+			return fmt.Errorf("grant #%d: privileges are required for role %s: %w", i, grant.Role, ErrInvalidConfig)
+		}
+
+		if _, err := grant.privileges(); err != nil {
+			return fmt.Errorf("grant #%d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Statement returns the SQL statement which applies the change.
+func (c GrantChange) Statement() string {
+	target := quoteIdentifier(c.Schema) + "." + quoteIdentifier(c.Table)
+	if c.Action == GrantActionRevoke {
+		return fmt.Sprintf(`REVOKE %s ON %s FROM %s`, c.Privilege, target, quoteIdentifier(c.Role))
+	}
+
+	return fmt.Sprintf(`GRANT %s ON %s TO %s`, c.Privilege, target, quoteIdentifier(c.Role))
+}
+
+func (c GrantChange) String() string {
+	return c.Statement()
+}
+
+// SyncGrants synchronizes the privileges of roles on tables with a specification.
+//
+// The privileges declared in the specification are compared with the actual privileges found in information_schema.
+// Missing privileges are granted and privileges which are not declared are revoked, so least-privilege setups
+// don't drift. Only the roles and schemas declared in the specification are considered: privileges of table
+// owners are left untouched.
+//
+// All changes are applied in a single transaction. In dry-run mode (see WithDryRun), changes are only logged.
+//
+// It returns the changes applied.
+func (r *Repository) SyncGrants(ctx context.Context, spec GrantSpec) ([]GrantChange, error) {
+	if r.db == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationAdmin)
+	defer cancel()
+
+	tx, err := r.db.BeginTxx(withManagedTx(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	desired, scope, err := desiredGrants(ctx, tx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := actualGrants(ctx, tx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := diffGrants(desired, actual)
+	l := r.logger(ctx).Zap()
+	for _, change := range changes {
+		if err = execDestructive(ctx, tx, r.dryRun, l, change.Statement()); err != nil {
+			return nil, fmt.Errorf("could not apply %q: %w", change.Statement(), err)
+		}
+
+		if !r.dryRun {
+			l.Info("grants synchronized", zap.Stringer("change", change))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// desiredGrants expands the specification into privileges on tables, and returns the roles and schemas it covers.
+func desiredGrants(ctx context.Context, tx *sqlx.Tx, spec GrantSpec) (map[grantKey]struct{}, map[roleSchema]struct{}, error) {
+	desired := make(map[grantKey]struct{})
+	scope := make(map[roleSchema]struct{})
+	schemaTables := make(map[string][]string)
+
+	for _, grant := range spec.Grants {
+		schema := grant.schema()
+		scope[roleSchema{role: grant.Role, schema: schema}] = struct{}{}
+
+		tables := grant.Tables
+		if grant.allTables() {
+			if _, ok := schemaTables[schema]; !ok {
+				var all []string
+				err := tx.SelectContext(ctx, &all,
+					`SELECT table_name FROM information_schema.tables WHERE table_schema = $1 ORDER BY table_name`, schema,
+				)
+				if err != nil {
+					return nil, nil, fmt.Errorf("could not list the tables of schema %s: %w", schema, err)
+				}
+				schemaTables[schema] = all
+			}
+
+			tables = schemaTables[schema]
+		}
+
+		privileges, _ := grant.privileges()
+		for _, table := range tables {
+			for _, privilege := range privileges {
+				desired[grantKey{role: grant.Role, schema: schema, table: table, privilege: privilege}] = struct{}{}
+			}
+		}
+	}
+
+	return desired, scope, nil
+}
+
+// actualGrants returns the privileges granted to roles on tables, for the roles and schemas in scope.
+func actualGrants(ctx context.Context, tx *sqlx.Tx, scope map[roleSchema]struct{}) (map[grantKey]struct{}, error) {
+	actual := make(map[grantKey]struct{})
+
+	for rs := range scope {
+		rows, err := tx.QueryContext(ctx, `SELECT table_name, privilege_type
+FROM information_schema.table_privileges
+WHERE grantee = $1 AND table_schema = $2 AND grantor <> grantee`, rs.role, rs.schema)
+		if err != nil {
+			return nil, fmt.Errorf("could not list the privileges of role %s: %w", rs.role, err)
+		}
+
+		for rows.Next() {
+			key := grantKey{role: rs.role, schema: rs.schema}
+			if err = rows.Scan(&key.table, &key.privilege); err != nil {
+				_ = rows.Close()
+
+				return nil, err
+			}
+			actual[key] = struct{}{}
+		}
+
+		if err = errors.Join(rows.Err(), rows.Close()); err != nil {
+			return nil, err
+		}
+	}
+
+	return actual, nil
+}
+
+// diffGrants returns the changes to apply to get the desired privileges, in a stable order.
+func diffGrants(desired, actual map[grantKey]struct{}) []GrantChange {
+	var changes []GrantChange
+	for key := range desired {
+		if _, ok := actual[key]; !ok {
+			changes = append(changes, key.change(GrantActionGrant))
+		}
+	}
+
+	for key := range actual {
+		if _, ok := desired[key]; !ok {
+			changes = append(changes, key.change(GrantActionRevoke))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Action != b.Action {
+			// revoke first
+			return a.Action == GrantActionRevoke
+		}
+
+		return strings.Join([]string{a.Role, a.Schema, a.Table, a.Privilege}, "\x00") <
+			strings.Join([]string{b.Role, b.Schema, b.Table, b.Privilege}, "\x00")
+	})
+
+	return changes
+}
+
+func (k grantKey) change(action string) GrantChange {
+	return GrantChange{
+		Action:    action,
+		Role:      k.role,
+		Schema:    k.schema,
+		Table:     k.table,
+		Privilege: k.privilege,
+	}
+}
+
+func (g Grant) schema() string {
+	if g.Schema == "" {
+		return "public"
+	}
+
+	return g.Schema
+}
+
+func (g Grant) allTables() bool {
+	return len(g.Tables) == 0 || (len(g.Tables) == 1 && g.Tables[0] == "*")
+}
+
+// privileges returns the normalized privileges of a grant, with ALL expanded.
+func (g Grant) privileges() ([]string, error) {
+	privileges := make([]string, 0, len(g.Privileges))
+	for _, privilege := range g.Privileges {
+		privilege = strings.ToUpper(strings.TrimSpace(privilege))
+		if privilege == "ALL" || privilege == "ALL PRIVILEGES" {
+			return tablePrivileges, nil
+		}
+
+		if !slices.Contains(tablePrivileges, privilege) {
+			return nil, fmt.Errorf("unsupported privilege %q for role %s: %w", privilege, g.Role, ErrInvalidConfig)
+		}
+
+		privileges = append(privileges, privilege)
+	}
+
+	return privileges, nil
+}
@@ -0,0 +1,82 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrantSpec(t *testing.T) {
+	t.Run("should parse a YAML specification", func(t *testing.T) {
+		spec, err := ParseGrantSpec([]byte(`
+grants:
+  - role: reporting
+    privileges: [select]
+  - role: app
+    schema: sales
+    tables: [orders]
+    privileges: [ALL]
+`))
+		require.NoError(t, err)
+		require.Len(t, spec.Grants, 2)
+
+		reporting := spec.Grants[0]
+		require.Equal(t, "public", reporting.schema())
+		require.True(t, reporting.allTables())
+		privileges, err := reporting.privileges()
+		require.NoError(t, err)
+		require.Equal(t, []string{"SELECT"}, privileges)
+
+		app := spec.Grants[1]
+		require.False(t, app.allTables())
+		privileges, err = app.privileges()
+		require.NoError(t, err)
+		require.Equal(t, tablePrivileges, privileges)
+	})
+
+	t.Run("should reject invalid specifications", func(t *testing.T) {
+		for _, spec := range []string{
+			`grants: [{privileges: [SELECT]}]`,
+			`grants: [{role: app}]`,
+			`grants: [{role: app, privileges: [EXECUTE]}]`,
+			`grants: {role: app}`,
+		} {
+			_, err := ParseGrantSpec([]byte(spec))
+			require.ErrorIs(t, err, ErrInvalidConfig, spec)
+		}
+	})
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		_, err := New(DefaultDBAlias).SyncGrants(context.Background(), GrantSpec{})
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+}
+
+func TestDiffGrants(t *testing.T) {
+	key := func(role, table, privilege string) grantKey {
+		return grantKey{role: role, schema: "public", table: table, privilege: privilege}
+	}
+
+	desired := map[grantKey]struct{}{
+		key("app", "orders", "SELECT"): {},
+		key("app", "orders", "INSERT"): {},
+		key("app", "users", "SELECT"):  {},
+	}
+	actual := map[grantKey]struct{}{
+		key("app", "orders", "SELECT"): {},
+		key("app", "orders", "DELETE"): {},
+	}
+
+	changes := diffGrants(desired, actual)
+	require.Equal(t, []GrantChange{
+		{Action: GrantActionRevoke, Role: "app", Schema: "public", Table: "orders", Privilege: "DELETE"},
+		{Action: GrantActionGrant, Role: "app", Schema: "public", Table: "orders", Privilege: "INSERT"},
+		{Action: GrantActionGrant, Role: "app", Schema: "public", Table: "users", Privilege: "SELECT"},
+	}, changes)
+
+	require.Equal(t, `REVOKE DELETE ON "public"."orders" FROM "app"`, changes[0].Statement())
+	require.Equal(t, `GRANT INSERT ON "public"."orders" TO "app"`, changes[1].String())
+
+	require.Empty(t, diffGrants(desired, desired))
+}
@@ -0,0 +1,108 @@
+package pgrepo
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// GSSAPI encryption modes (libpq "gssencmode").
+const (
+	GSSEncModeDisable = "disable"
+	GSSEncModePrefer  = "prefer"
+	GSSEncModeRequire = "require"
+)
+
+// SCRAM channel binding modes (libpq "channel_binding").
+const (
+	ChannelBindingDisable = "disable"
+	ChannelBindingPrefer  = "prefer"
+	ChannelBindingRequire = "require"
+)
+
+// kerberosSettings configure GSSAPI (Kerberos) authentication, with precedence over the "krbsrvname" and "krbspn"
+// parameters of the URL.
+type kerberosSettings struct {
+	SrvName string // the Kerberos service name, e.g. "postgres"
+	SPN     string // the Kerberos service principal name, e.g. "postgres/db.example.com"
+}
+
+// WithKerberos authenticates with GSSAPI (Kerberos) when requested by the server, with a service name and
+// an optional service principal name.
+//
+// NOTE: a GSSAPI provider must be registered with pgconn.RegisterGSSProvider, e.g. using github.com/otan/gopgkrb5.
+func WithKerberos(srvName, spn string) DBOption {
+	return func(o *databaseSettings) {
+		o.Kerberos.SrvName = srvName
+		o.Kerberos.SPN = spn
+	}
+}
+
+// WithGSSEncMode sets the GSSAPI encryption mode, with precedence over the "gssencmode" parameter of the URL.
+//
+// The pgx driver does not support GSSAPI encryption: only "disable" and "prefer" (which falls back to
+// an unencrypted or TLS connection) are accepted.
+func WithGSSEncMode(mode string) DBOption {
+	return func(o *databaseSettings) {
+		o.GSSEncMode = mode
+	}
+}
+
+// WithChannelBinding sets the SCRAM channel binding mode, with precedence over the "channel_binding" parameter
+// of the URL.
+//
+// The pgx driver does not support channel binding: only "disable" and "prefer" (which falls back to
+// SCRAM without channel binding) are accepted.
+func WithChannelBinding(mode string) DBOption {
+	return func(o *databaseSettings) {
+		o.ChannelBinding = mode
+	}
+}
+
+// validateAuthMethods checks the authentication method preferences, set by the settings or by the URL.
+//
+// Modes requiring a feature that the driver does not support are rejected, rather than silently ignored.
+func (r databaseSettings) validateAuthMethods(dcfg *pgx.ConnConfig) error {
+	gssEncMode, channelBinding := r.GSSEncMode, r.ChannelBinding
+	if gssEncMode == "" {
+		gssEncMode = dcfg.RuntimeParams["gssencmode"]
+	}
+	if channelBinding == "" {
+		channelBinding = dcfg.RuntimeParams["channel_binding"]
+	}
+
+	switch gssEncMode {
+	case "", GSSEncModeDisable, GSSEncModePrefer:
+	case GSSEncModeRequire:
+		return fmt.Errorf("gssencmode %q is not supported by the pgx driver: %w", gssEncMode, ErrInvalidConfig)
+	default:
+		return fmt.Errorf("invalid gssencmode %q: expected one of disable, prefer or require: %w", gssEncMode, ErrInvalidConfig)
+	}
+
+	switch channelBinding {
+	case "", ChannelBindingDisable, ChannelBindingPrefer:
+	case ChannelBindingRequire:
+		return fmt.Errorf("channel_binding %q is not supported by the pgx driver: %w", channelBinding, ErrInvalidConfig)
+	default:
+		return fmt.Errorf("invalid channel_binding %q: expected one of disable, prefer or require: %w", channelBinding, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// applyAuthMethods applies the Kerberos settings onto a driver configuration.
+//
+// The pgx driver neither encrypts with GSSAPI nor binds channels, which is what the accepted modes ("disable" and "prefer")
+// fall back to. The "gssencmode" and "channel_binding" parameters of the URL are removed, since the driver would
+// otherwise send them to the server as run-time parameters, which are rejected.
+func (r databaseSettings) applyAuthMethods(dcfg *pgx.ConnConfig) {
+	delete(dcfg.RuntimeParams, "gssencmode")
+	delete(dcfg.RuntimeParams, "channel_binding")
+
+	if r.Kerberos.SrvName != "" {
+		dcfg.KerberosSrvName = r.Kerberos.SrvName
+	}
+	if r.Kerberos.SPN != "" {
+		dcfg.KerberosSpn = r.Kerberos.SPN
+	}
+}
@@ -0,0 +1,70 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAuthMethods(t *testing.T) {
+	t.Run("should apply Kerberos settings", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithURL(DefaultURL + "&krbsrvname=pg"),
+			WithKerberos("postgres", "postgres/db.example.com"),
+			WithGSSEncMode(GSSEncModePrefer),
+			WithChannelBinding(ChannelBindingPrefer),
+		})
+		require.NoError(t, dbs.Validate())
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "")
+		require.NotNil(t, dcfg)
+		require.Equal(t, "postgres", dcfg.KerberosSrvName)
+		require.Equal(t, "postgres/db.example.com", dcfg.KerberosSpn)
+		require.NotContains(t, dcfg.RuntimeParams, "gssencmode")
+		require.NotContains(t, dcfg.RuntimeParams, "channel_binding")
+	})
+
+	t.Run("should not send libpq-only parameters of the URL to the server", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithURL(DefaultURL + "&gssencmode=prefer&channel_binding=disable"),
+		})
+		require.NoError(t, dbs.Validate())
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "")
+		require.NotNil(t, dcfg)
+		require.NotContains(t, dcfg.RuntimeParams, "gssencmode")
+		require.NotContains(t, dcfg.RuntimeParams, "channel_binding")
+	})
+
+	t.Run("should retain Kerberos parameters of the URL", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithURL(DefaultURL + "&krbsrvname=pg")})
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "")
+		require.NotNil(t, dcfg)
+		require.Equal(t, "pg", dcfg.KerberosSrvName)
+	})
+
+	t.Run("should reject modes not supported by the driver", func(t *testing.T) {
+		for _, opt := range []DBOption{
+			WithGSSEncMode(GSSEncModeRequire),
+			WithChannelBinding(ChannelBindingRequire),
+			WithGSSEncMode("allow"),
+			WithChannelBinding("always"),
+			WithURL(DefaultURL + "&channel_binding=require"),
+			WithURL(DefaultURL + "&gssencmode=require"),
+		} {
+			dbs := databaseSettingsFromOptions([]DBOption{WithURL(DefaultURL), opt})
+			require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+		}
+	})
+
+	t.Run("settings should take precedence over the URL", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithURL(DefaultURL + "&channel_binding=require"),
+			WithChannelBinding(ChannelBindingPrefer),
+		})
+		require.NoError(t, dbs.Validate())
+	})
+}
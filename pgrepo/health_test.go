@@ -0,0 +1,41 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthCheckContext(t *testing.T) {
+	t.Run("repository is not started", func(t *testing.T) {
+		r := &Repository{}
+		require.ErrorIs(t, r.HealthCheckContext(context.Background()), ErrDBNotInitialized)
+	})
+
+	t.Run("health check is bounded by the context", func(t *testing.T) {
+		db, err := sql.Open(driverName, DefaultURL) // no connection established
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		for _, opts := range [][]PoolOption{
+			nil,
+			{WithHealthCheckQuery(`SELECT 1`)},
+		} {
+			r := &Repository{
+				db:               sqlx.NewDb(db, driverName),
+				databaseSettings: databaseSettings{PGConfig: poolSettingsFromOptions(opts)},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			start := time.Now()
+			require.ErrorIs(t, r.HealthCheckContext(ctx), context.Canceled)
+			require.Less(t, time.Since(start), time.Second)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// WithQueryHint declares pg_hint_plan planner hints for a named query, e.g.:
+//
+//	WithQueryHint("orders_by_customer", "IndexScan(o orders_customer_idx) Leading(c o)")
+//
+// See Repository.Hint.
+func WithQueryHint(name, hint string) DBOption {
+	return func(o *databaseSettings) {
+		hints := make(map[string]string, len(o.Hints)+1)
+		for k, v := range o.Hints {
+			hints[k] = v
+		}
+		hints[strings.ToLower(name)] = hint
+		o.Hints = hints
+	}
+}
+
+// Hint injects the planner hints configured for a named query, as a leading comment interpreted
+// by the pg_hint_plan extension, e.g.:
+//
+//	err := db.SelectContext(ctx, &orders, repo.Hint("orders_by_customer", query), customerID)
+//
+// Hints are configured with the "hints" setting of the database, so hints may be applied or changed
+// without code changes. Query names are case-insensitive.
+//
+// The query is returned unchanged if no hint is configured for this name, or if it already starts with hints.
+//
+// NOTE: postgres ignores hints unless pg_hint_plan is loaded (e.g. with shared_preload_libraries).
+// This is checked when the repository starts.
+func (r *Repository) Hint(name, query string) string {
+	hint, ok := r.Hints[strings.ToLower(name)]
+	if !ok || strings.TrimSpace(hint) == "" || strings.HasPrefix(strings.TrimLeft(query, " \t\r\n"), "/*+") {
+		return query
+	}
+
+	return "/*+ " + strings.TrimSpace(hint) + " */ " + query
+}
+
+func (r databaseSettings) validateHints() error {
+	for name, hint := range r.Hints {
+		if strings.Contains(hint, "*/") || strings.Contains(hint, "/*") {
+			return fmt.Errorf("invalid hint for query %q: hints must not contain comment delimiters: %w", name, ErrInvalidConfig)
+		}
+	}
+
+	return nil
+}
+
+// checkHintPlan warns when hints are configured, but pg_hint_plan is not loaded: hints would be silently ignored.
+func (r databaseSettings) checkHintPlan(ctx context.Context, db sqlx.QueryerContext, l log.Logger) {
+	if len(r.Hints) == 0 {
+		return
+	}
+
+	// this parameter is defined only when the pg_hint_plan module is loaded
+	var enabled sql.NullString
+	if err := db.QueryRowxContext(ctx, `SELECT current_setting('pg_hint_plan.enable_hint', true)`).Scan(&enabled); err != nil {
+		l.Warn("could not check that pg_hint_plan is loaded", zap.Error(err))
+
+		return
+	}
+
+	switch {
+	case !enabled.Valid || enabled.String == "":
+		l.Warn("query hints are configured, but pg_hint_plan is not loaded: hints are ignored",
+			zap.Int("hints", len(r.Hints)),
+		)
+	case enabled.String != "on":
+		l.Warn("query hints are configured, but pg_hint_plan.enable_hint is off: hints are ignored",
+			zap.Int("hints", len(r.Hints)),
+		)
+	}
+}
@@ -0,0 +1,39 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHint(t *testing.T) {
+	const query = `SELECT * FROM orders o JOIN customers c ON c.id = o.customer_id WHERE c.id = $1`
+
+	r := &Repository{databaseSettings: databaseSettingsFromOptions([]DBOption{
+		WithQueryHint("Orders_By_Customer", "IndexScan(o orders_customer_idx) Leading(c o)"),
+	})}
+
+	t.Run("should inject hints as a leading comment", func(t *testing.T) {
+		require.Equal(t,
+			"/*+ IndexScan(o orders_customer_idx) Leading(c o) */ "+query,
+			r.Hint("orders_by_customer", query),
+		)
+	})
+
+	t.Run("should leave query without hints unchanged", func(t *testing.T) {
+		require.Equal(t, query, r.Hint("other", query))
+	})
+
+	t.Run("should not override explicit hints", func(t *testing.T) {
+		hinted := "/*+ SeqScan(o) */ " + query
+		require.Equal(t, hinted, r.Hint("orders_by_customer", hinted))
+	})
+}
+
+func TestValidateHints(t *testing.T) {
+	require.NoError(t, databaseSettings{Hints: map[string]string{"q": "SeqScan(o)"}}.validateHints())
+	require.ErrorIs(t,
+		databaseSettings{Hints: map[string]string{"q": "SeqScan(o) */ DROP TABLE orders; /*"}}.validateHints(),
+		ErrInvalidConfig,
+	)
+}
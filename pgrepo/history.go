@@ -0,0 +1,164 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHistorySuffix    = "_history"
+	defaultHistoryValidFrom = "valid_from"
+	defaultHistoryValidTo   = "valid_to"
+
+	historyTriggerFunction = "pgrepo_history_versioning"
+	historyTriggerName     = "pgrepo_history"
+)
+
+func (h historySettings) suffix() string {
+	if h.Suffix == "" {
+		return defaultHistorySuffix
+	}
+
+	return h.Suffix
+}
+
+func (h historySettings) validFrom() string {
+	if h.ValidFrom == "" {
+		return defaultHistoryValidFrom
+	}
+
+	return h.ValidFrom
+}
+
+func (h historySettings) validTo() string {
+	if h.ValidTo == "" {
+		return defaultHistoryValidTo
+	}
+
+	return h.ValidTo
+}
+
+func (h historySettings) historyTable(table string) string {
+	return table + h.suffix()
+}
+
+// installStatements returns the DDL statements to version a table.
+//
+// The versioned table is altered to carry a period start column. Every UPDATE or DELETE
+// appends the previous version of the row to the history table, with its period end set.
+func (h historySettings) installStatements(table string) []string {
+	qTable := quoteQualifiedIdentifier(table)
+	qHistory := quoteQualifiedIdentifier(h.historyTable(table))
+	qFrom := quoteIdentifier(h.validFrom())
+	qTo := quoteIdentifier(h.validTo())
+
+	return []string{
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s timestamptz NOT NULL DEFAULT now()`, qTable, qFrom),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (LIKE %s)`, qHistory, qTable),
+		fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s timestamptz NOT NULL`, qHistory, qTo),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS %s ON %s`, historyTriggerName, qTable),
+		fmt.Sprintf(
+			`CREATE TRIGGER %s BEFORE UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION %s(%s, %s)`,
+			historyTriggerName, qTable, historyTriggerFunction, quoteLiteral(qHistory), quoteLiteral(h.validFrom()),
+		),
+	}
+}
+
+func historyFunctionStatement() string {
+	return `CREATE OR REPLACE FUNCTION ` + historyTriggerFunction + `() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+  EXECUTE format('INSERT INTO %s SELECT ($1).*, now()', TG_ARGV[0]) USING OLD;
+
+  IF TG_OP = 'DELETE' THEN
+    RETURN OLD;
+  END IF;
+
+  RETURN jsonb_populate_record(NEW, jsonb_build_object(TG_ARGV[1], now()));
+END
+$$`
+}
+
+// asOfQuery builds a query over the current and historical versions of the rows of a table,
+// as they were at the time passed as the first parameter.
+func (h historySettings) asOfQuery(table string) string {
+	qTable := quoteQualifiedIdentifier(table)
+	qHistory := quoteQualifiedIdentifier(h.historyTable(table))
+	qFrom := quoteIdentifier(h.validFrom())
+	qTo := quoteIdentifier(h.validTo())
+
+	return fmt.Sprintf(
+		`SELECT t.*, NULL::timestamptz AS %[4]s FROM %[1]s t WHERE t.%[3]s <= $1 `+
+			`UNION ALL `+
+			`SELECT h.* FROM %[2]s h WHERE h.%[3]s <= $1 AND h.%[4]s > $1`,
+		qTable, qHistory, qFrom, qTo,
+	)
+}
+
+// InstallHistory installs the history versioning pattern on all tables declared in the "history" config section.
+//
+// Every versioned table is given a period start column (default: "valid_from") and an append-only
+// history table (default suffix: "_history") with a period end column (default: "valid_to").
+// Previous versions of rows are appended to the history table by a trigger upon UPDATE or DELETE.
+//
+// Installing is idempotent.
+//
+// The default deadline of DDL operations applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) InstallHistory(ctx context.Context) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationDDL)
+	defer cancel()
+
+	h := r.databaseSettings.History
+	if len(h.Tables) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(withManagedTx(ctx), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err = tx.ExecContext(ctx, historyFunctionStatement()); err != nil {
+		return fmt.Errorf("could not install history trigger function: %w", err)
+	}
+
+	for _, table := range h.Tables {
+		for _, stmt := range h.installStatements(table) {
+			if _, err = tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("could not install history for table %s: %w", table, err)
+			}
+		}
+
+		r.logger(ctx).Info("history installed", zap.String("table", table), zap.String("history_table", h.historyTable(table)))
+	}
+
+	return tx.Commit()
+}
+
+// AsOfQuery returns a SELECT statement that yields the rows of a versioned table as they were
+// at the point in time passed as the first parameter ($1) of the query.
+//
+// The table must be declared in the "history" config section.
+//
+// Example:
+//
+//	q, _ := r.AsOfQuery("orders")
+//	err := r.DB().SelectContext(ctx, &orders, `SELECT * FROM (`+q+`) AS o WHERE o.customer_id = $2`, at, customerID)
+func (r *Repository) AsOfQuery(table string) (string, error) {
+	h := r.databaseSettings.History
+	if !slices.Contains(h.Tables, table) {
+		return "", fmt.Errorf("table %q is not declared with history: %w", table, ErrInvalidConfig)
+	}
+
+	return h.asOfQuery(table), nil
+}
@@ -0,0 +1,48 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistorySettings(t *testing.T) {
+	t.Run("with defaults", func(t *testing.T) {
+		h := historySettings{Tables: []string{"public.orders"}}
+
+		stmts := h.installStatements("public.orders")
+		require.Len(t, stmts, 5)
+		require.Equal(t,
+			`ALTER TABLE "public"."orders" ADD COLUMN IF NOT EXISTS "valid_from" timestamptz NOT NULL DEFAULT now()`,
+			stmts[0],
+		)
+		require.Equal(t, `CREATE TABLE IF NOT EXISTS "public"."orders_history" (LIKE "public"."orders")`, stmts[1])
+		require.Contains(t, stmts[4], `pgrepo_history_versioning('"public"."orders_history"', 'valid_from')`)
+
+		require.Equal(t,
+			`SELECT t.*, NULL::timestamptz AS "valid_to" FROM "public"."orders" t WHERE t."valid_from" <= $1 `+
+				`UNION ALL SELECT h.* FROM "public"."orders_history" h WHERE h."valid_from" <= $1 AND h."valid_to" > $1`,
+			h.asOfQuery("public.orders"),
+		)
+	})
+
+	t.Run("with custom columns", func(t *testing.T) {
+		h := historySettings{Suffix: "_audit", ValidFrom: "sys_from", ValidTo: "sys_to"}
+
+		require.Equal(t, "orders_audit", h.historyTable("orders"))
+		require.Contains(t, h.asOfQuery("orders"), `"sys_to" > $1`)
+	})
+
+	t.Run("as of query requires a declared table", func(t *testing.T) {
+		r := &Repository{
+			databaseSettings: databaseSettingsFromOptions([]DBOption{WithHistory("orders")}),
+		}
+
+		_, err := r.AsOfQuery("customers")
+		require.ErrorIs(t, err, ErrInvalidConfig)
+
+		q, err := r.AsOfQuery("orders")
+		require.NoError(t, err)
+		require.NotEmpty(t, q)
+	})
+}
@@ -0,0 +1,22 @@
+package pgrepo
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// quoteIdentifier quotes a single identifier, such as a column name.
+func quoteIdentifier(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// quoteQualifiedIdentifier quotes a possibly schema-qualified identifier such as "public.users".
+func quoteQualifiedIdentifier(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// quoteLiteral quotes a string as a SQL literal.
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,13 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuoteQualifiedIdentifier(t *testing.T) {
+	require.Equal(t, `"users"`, quoteQualifiedIdentifier("users"))
+	require.Equal(t, `"public"."users"`, quoteQualifiedIdentifier("public.users"))
+	require.Equal(t, `"we""ird"`, quoteQualifiedIdentifier(`we"ird`))
+}
@@ -0,0 +1,45 @@
+package pgrepo
+
+import (
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	microsecondsPerDay   = int64(24 * time.Hour / time.Microsecond)
+	microsecondsPerMonth = 30 * microsecondsPerDay
+)
+
+// Interval is a postgres interval, which may be used as a query argument and scanned from query results.
+//
+// Like in postgres, months and days are kept apart from the time part, as their duration varies.
+// A zero value is NULL.
+type Interval struct{ pgtype.Interval }
+
+// NewInterval builds an interval from a duration, with a time part only.
+func NewInterval(d time.Duration) Interval {
+	return Interval{Interval: pgtype.Interval{
+		Microseconds: d.Microseconds(),
+		Valid:        true,
+	}}
+}
+
+// NewCalendarInterval builds an interval of months, days and a time part, e.g. NewCalendarInterval(1, 15, 0)
+// for "1 mon 15 days".
+func NewCalendarInterval(months, days int32, d time.Duration) Interval {
+	return Interval{Interval: pgtype.Interval{
+		Months:       months,
+		Days:         days,
+		Microseconds: d.Microseconds(),
+		Valid:        true,
+	}}
+}
+
+// Duration converts the interval to a duration, assuming 30-day months and 24-hour days,
+// like postgres does to justify intervals (see justify_interval).
+func (i Interval) Duration() time.Duration {
+	us := i.Microseconds + int64(i.Days)*microsecondsPerDay + int64(i.Months)*microsecondsPerMonth
+
+	return time.Duration(us) * time.Microsecond
+}
@@ -0,0 +1,35 @@
+package pgrepo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterval(t *testing.T) {
+	t.Run("should convert to a duration", func(t *testing.T) {
+		require.Equal(t, 90*time.Minute, NewInterval(90*time.Minute).Duration())
+		require.Equal(t, (31*24+1)*time.Hour, NewCalendarInterval(1, 1, time.Hour).Duration())
+	})
+
+	t.Run("should round trip with the text format", func(t *testing.T) {
+		value, err := NewCalendarInterval(1, 15, 90*time.Minute).Value()
+		require.NoError(t, err)
+		require.Equal(t, "1 mon 15 day 01:30:00.000000", value)
+
+		var scanned Interval
+		require.NoError(t, scanned.Scan("1 mon 15 days 01:30:00"))
+		require.Equal(t, NewCalendarInterval(1, 15, 90*time.Minute), scanned)
+	})
+
+	t.Run("zero value should be NULL", func(t *testing.T) {
+		value, err := Interval{}.Value()
+		require.NoError(t, err)
+		require.Nil(t, value)
+
+		scanned := NewInterval(time.Second)
+		require.NoError(t, scanned.Scan(nil))
+		require.False(t, scanned.Valid)
+	})
+}
@@ -0,0 +1,225 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultLeaderCampaignInterval = 5 * time.Second
+	defaultLeaderCheckInterval    = time.Second
+)
+
+// ErrLeadershipLost is returned by RunWhenLeader when the lock on the leadership is lost, e.g. when the
+// connection holding the lock is broken.
+var ErrLeadershipLost = errors.New("leadership lost")
+
+type (
+	// LeaderOption tunes the campaigns of a Leader.
+	LeaderOption func(*leaderOptions)
+
+	leaderOptions struct {
+		campaign time.Duration
+		check    time.Duration
+	}
+
+	// Leader elects a single leader among instances sharing a database, e.g. for cron-like workers
+	// (see Repository.Leader).
+	//
+	// The leadership of a key is held with a session-level advisory lock, on a connection pinned for the leader.
+	Leader struct {
+		r    *Repository
+		opts leaderOptions
+
+		mx      sync.Mutex
+		leading map[string]bool
+	}
+)
+
+// WithCampaignInterval sets the interval between two attempts to acquire the leadership (defaults to 5s).
+func WithCampaignInterval(d time.Duration) LeaderOption {
+	return func(o *leaderOptions) {
+		o.campaign = d
+	}
+}
+
+// WithLeaderCheckInterval sets the interval between two checks of the connection holding the leadership
+// (defaults to 1s).
+func WithLeaderCheckInterval(d time.Duration) LeaderOption {
+	return func(o *leaderOptions) {
+		o.check = d
+	}
+}
+
+func leaderOptionsWithDefaults(opts []LeaderOption) leaderOptions {
+	o := leaderOptions{
+		campaign: defaultLeaderCampaignInterval,
+		check:    defaultLeaderCheckInterval,
+	}
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	if o.campaign <= 0 {
+		o.campaign = defaultLeaderCampaignInterval
+	}
+	if o.check <= 0 {
+		o.check = defaultLeaderCheckInterval
+	}
+
+	return o
+}
+
+// Leader returns a Leader to elect a single instance to run some work.
+//
+// Unlike RunExclusive, which gives up when the task is already run elsewhere, a Leader campaigns
+// until it is elected.
+func (r *Repository) Leader(opts ...LeaderOption) *Leader {
+	return &Leader{
+		r:       r,
+		opts:    leaderOptionsWithDefaults(opts),
+		leading: make(map[string]bool),
+	}
+}
+
+// IsLeader tells if this instance currently holds the leadership of a key.
+func (l *Leader) IsLeader(key string) bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	return l.leading[key]
+}
+
+// RunWhenLeader campaigns for the leadership of a key, then runs fn while holding the leadership.
+//
+// RunWhenLeader blocks until it is elected, or the context is cancelled. The leadership is resigned when fn returns.
+//
+// The connection holding the leadership is checked while fn runs. When it is broken, the leadership is lost:
+// the context passed to fn is cancelled, the connection is closed so that the lock is released on the server,
+// and the returned error wraps ErrLeadershipLost. Callers may then campaign again.
+func (l *Leader) RunWhenLeader(ctx context.Context, key string, fn func(context.Context) error) error {
+	if l.r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	lg := l.r.logger(ctx).With(zap.String("leader_key", key))
+
+	session, err := l.campaign(ctx, key)
+	if err != nil {
+		return err
+	}
+	lg.Info("elected leader", zap.String("holder", taskHolder(l.r.app)))
+	l.setLeading(key, true)
+
+	leaderCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	done := make(chan struct{})
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+
+		ticker := time.NewTicker(l.opts.check)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-leaderCtx.Done():
+				return
+			case <-ticker.C:
+				if err := pingSession(leaderCtx, session, l.opts.check); err != nil && leaderCtx.Err() == nil {
+					lg.Error("leadership lost", zap.Error(err))
+					cancel(fmt.Errorf("%w: %s: %w", ErrLeadershipLost, key, err))
+
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(leaderCtx)
+	close(done)
+	<-checked
+	l.setLeading(key, false)
+
+	if cause := context.Cause(leaderCtx); errors.Is(cause, ErrLeadershipLost) {
+		_ = session.Close()
+
+		return errors.Join(cause, err)
+	}
+
+	_, _ = session.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, leaderLockKey(key))
+	if closeErr := session.Close(); closeErr != nil {
+		lg.Warn("could not resign cleanly", zap.Error(closeErr))
+	}
+	lg.Info("resigned leadership")
+
+	return err
+}
+
+// campaign tries to acquire the leadership of a key at every campaign interval, until it succeeds.
+//
+// It returns the session holding the leadership.
+func (l *Leader) campaign(ctx context.Context, key string) (Session, error) {
+	for {
+		session, err := l.r.AcquireSession(ctx)
+		if errors.Is(err, ErrSessionPinningDisabled) {
+			return nil, err
+		}
+
+		if err == nil {
+			var elected bool
+			err = session.QueryRowxContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, leaderLockKey(key)).Scan(&elected)
+			if err == nil && elected {
+				return session, nil
+			}
+
+			_ = session.Close()
+		}
+
+		if err != nil && ctx.Err() == nil {
+			l.r.logger(ctx).Warn("could not campaign for leadership", zap.String("leader_key", key), zap.Error(err))
+		}
+
+		timer := time.NewTimer(l.opts.campaign)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *Leader) setLeading(key string, leading bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if leading {
+		l.leading[key] = true
+	} else {
+		delete(l.leading, key)
+	}
+}
+
+// pingSession checks that the connection of a session is alive.
+func pingSession(ctx context.Context, session Session, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var alive bool
+
+	return session.QueryRowxContext(ctx, `SELECT true`).Scan(&alive)
+}
+
+func leaderLockKey(key string) string {
+	return "pgrepo_leader:" + key
+}
@@ -0,0 +1,91 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLeaderOptions(t *testing.T) {
+	o := leaderOptionsWithDefaults(nil)
+	require.Equal(t, defaultLeaderCampaignInterval, o.campaign)
+	require.Equal(t, defaultLeaderCheckInterval, o.check)
+
+	o = leaderOptionsWithDefaults([]LeaderOption{WithCampaignInterval(time.Minute), WithLeaderCheckInterval(-1)})
+	require.Equal(t, time.Minute, o.campaign)
+	require.Equal(t, defaultLeaderCheckInterval, o.check)
+}
+
+func TestRunWhenLeader(t *testing.T) {
+	// the fake server answers pg_try_advisory_lock() with its recovery status
+	server := &fakeReplica{}
+	db := sqlx.NewDb(sql.OpenDB(server), driverName)
+	t.Cleanup(func() { _ = db.Close() })
+
+	r := New(DefaultDBAlias, WithLogger(zap.NewNop()))
+	r.db = db
+	leader := r.Leader(WithCampaignInterval(10*time.Millisecond), WithLeaderCheckInterval(10*time.Millisecond))
+	ctx := context.Background()
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		err := (&Repository{}).Leader().RunWhenLeader(ctx, "cron", func(context.Context) error { return nil })
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("should campaign until elected", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			server.inRecovery.Store(true)
+		}()
+
+		var ran atomic.Bool
+		errWork := errors.New("work failed")
+		err := leader.RunWhenLeader(ctx, "cron", func(context.Context) error {
+			require.True(t, leader.IsLeader("cron"))
+			ran.Store(true)
+
+			return errWork
+		})
+		require.ErrorIs(t, err, errWork)
+		require.True(t, ran.Load())
+		require.False(t, leader.IsLeader("cron"))
+		require.Equal(t, 0, db.Stats().InUse, "expected the session to be released")
+	})
+
+	t.Run("should give up campaigning when the context is cancelled", func(t *testing.T) {
+		server.inRecovery.Store(false)
+		defer server.inRecovery.Store(true)
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		err := leader.RunWhenLeader(ctx, "cron", func(context.Context) error {
+			t.Error("expected the callback not to run")
+
+			return nil
+		})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should detect a lost connection", func(t *testing.T) {
+		defer server.down.Store(false)
+
+		err := leader.RunWhenLeader(ctx, "cron", func(ctx context.Context) error {
+			server.down.Store(true)
+			<-ctx.Done()
+
+			return ctx.Err()
+		})
+		require.ErrorIs(t, err, ErrLeadershipLost)
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, leader.IsLeader("cron"))
+		require.Equal(t, 0, db.Stats().OpenConnections, "expected the broken connection to be closed")
+	})
+}
@@ -0,0 +1,47 @@
+package pgrepo
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// WithConnMaxLifetimeJitter spreads out the expiration of connections: every connection expires after connMaxLifetime
+// plus a random duration up to jitter.
+//
+// This avoids reconnecting all connections at once behind a connection proxy (e.g. PgBouncer, RDS Proxy),
+// when connections have been established at about the same time.
+//
+// The jitter has no effect without connMaxLifetime.
+func WithConnMaxLifetimeJitter(jitter time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.ConnMaxLifetimeJitter = jitter
+	}
+}
+
+func (p *poolSettings) validateLifetimeJitter() error {
+	if p.ConnMaxLifetimeJitter < 0 {
+		return fmt.Errorf("connMaxLifetimeJitter must not be negative, but got %v: %w", p.ConnMaxLifetimeJitter, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// maxLifetime is the upper bound of the lifetime of connections, enforced by the database/sql pool.
+func (p *poolSettings) maxLifetime() time.Duration {
+	if p.ConnMaxLifeTime <= 0 {
+		return 0
+	}
+
+	return p.ConnMaxLifeTime + max(p.ConnMaxLifetimeJitter, 0)
+}
+
+// connLifetime draws the lifetime of a new connection, or returns 0 when connections don't expire
+// before the database/sql pool closes them.
+func (r databaseSettings) connLifetime() time.Duration {
+	if r.PGConfig == nil || r.PGConfig.ConnMaxLifeTime <= 0 || r.PGConfig.ConnMaxLifetimeJitter <= 0 {
+		return 0
+	}
+
+	return r.PGConfig.ConnMaxLifeTime + time.Duration(rand.Int63n(int64(r.PGConfig.ConnMaxLifetimeJitter)))
+}
@@ -0,0 +1,72 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestConnMaxLifetimeJitter(t *testing.T) {
+	t.Run("should draw lifetimes within the jitter", func(t *testing.T) {
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{
+			WithConnMaxLifeTime(time.Minute),
+			WithConnMaxLifetimeJitter(10 * time.Second),
+		})}
+
+		for i := 0; i < 100; i++ {
+			lifetime := dbs.connLifetime()
+			require.GreaterOrEqual(t, lifetime, time.Minute)
+			require.Less(t, lifetime, time.Minute+10*time.Second)
+		}
+		require.Equal(t, time.Minute+10*time.Second, dbs.PGConfig.maxLifetime())
+	})
+
+	t.Run("should not apply without a max lifetime", func(t *testing.T) {
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithConnMaxLifetimeJitter(time.Second)})}
+		require.Zero(t, dbs.connLifetime())
+		require.Zero(t, dbs.PGConfig.maxLifetime())
+		require.Zero(t, databaseSettings{}.connLifetime())
+
+		issues := dbs.PGConfig.lint("pgconfig")
+		require.Len(t, issues, 1)
+		require.Equal(t, "pgconfig.connmaxlifetimejitter", issues[0].Path)
+	})
+
+	t.Run("should reject a negative jitter", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{WithConnMaxLifetimeJitter(-time.Second)})}
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should discard expired connections", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL}
+		connCfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "")
+		require.NotNil(t, connCfg)
+
+		connector := newReloadableConnector(connCfg, dbs, nil)
+		_, generation := connector.current()
+		live, expired := &pgx.Conn{}, &pgx.Conn{}
+		connector.conns.Store(live, connState{generation: generation, expires: time.Now().Add(time.Hour)})
+		connector.conns.Store(expired, connState{generation: generation, expires: time.Now().Add(-time.Second)})
+
+		require.NoError(t, connector.resetSession(context.Background(), live))
+		require.ErrorIs(t, connector.resetSession(context.Background(), expired), driver.ErrBadConn)
+	})
+
+	t.Run("should configure pgxpool", func(t *testing.T) {
+		pcfg, err := PoolConfigFor(DefaultDBAlias,
+			WithDatabaseSettings(DefaultDBAlias,
+				WithURL(DefaultURL),
+				WithPoolSettings(WithConnMaxLifeTime(time.Hour), WithConnMaxLifetimeJitter(5*time.Minute)),
+			),
+		)
+		require.NoError(t, err)
+		require.Equal(t, time.Hour, pcfg.MaxConnLifetime)
+		require.Equal(t, 5*time.Minute, pcfg.MaxConnLifetimeJitter)
+	})
+}
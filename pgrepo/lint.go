@@ -0,0 +1,284 @@
+package pgrepo
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Severity levels of configuration issues.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is a configuration mistake detected by LintConfig.
+type LintIssue struct {
+	Severity string
+	Path     string // the configuration key, e.g. "databases.postgres.default.pgconfig.maxopenconns"
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// LintConfig detects common mistakes in the "databases" section of a configuration, without connecting to any database:
+//
+//   - unknown keys (e.g. typos such as "maxIdelConns")
+//   - invalid settings
+//   - suspicious pool settings (e.g. maxIdleConns greater than maxOpenConns)
+//   - passwords in URLs, when user or password fields are also specified
+//   - remote hosts without TLS (missing sslmode or sslmode=disable)
+//
+// Issues are sorted by configuration key.
+func LintConfig(cfg *viper.Viper) []LintIssue {
+	if cfg == nil {
+		return nil
+	}
+
+	section, ok := cfg.AllSettings()["databases"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var issues []LintIssue
+	issues = append(issues, lintKeys("databases", section, reflect.TypeOf(settings{}))...)
+
+	s, err := makeSettingsFromViper(cfg, zap.NewNop())
+	if err != nil {
+		issues = append(issues, LintIssue{Severity: LintError, Path: "databases", Message: err.Error()})
+	}
+
+	if s.PGConfig != nil {
+		issues = append(issues, s.PGConfig.lint("databases.pgconfig")...)
+	}
+
+	for alias, dbs := range s.Databases {
+		path := "databases.postgres." + alias
+		if dbs.PGConfig == nil {
+			dbs.PGConfig = s.PGConfig
+		} else {
+			issues = append(issues, dbs.PGConfig.lint(path+".pgconfig")...)
+		}
+
+		for _, name := range dbs.poolProfileNames() {
+			if ps := dbs.Pools[name]; ps != nil {
+				issues = append(issues, ps.lint(path+".pools."+name)...)
+			}
+		}
+
+		issues = append(issues, dbs.lint(path)...)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Path < issues[j].Path
+	})
+
+	return issues
+}
+
+// lintKeys reports keys which don't match any field of the settings structure.
+func lintKeys(path string, values map[string]interface{}, typ reflect.Type) []LintIssue {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make(map[string]reflect.Type, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+
+	var issues []LintIssue
+	for key, value := range values {
+		keyPath := path + "." + key
+		fieldType, ok := fields[strings.ToLower(key)]
+		if !ok {
+			msg := "unknown key"
+			if suggestion := closestKey(key, fields); suggestion != "" {
+				msg = fmt.Sprintf("unknown key: did you mean %q?", suggestion)
+			}
+			issues = append(issues, LintIssue{Severity: LintWarning, Path: keyPath, Message: msg})
+
+			continue
+		}
+
+		nested, isMap := value.(map[string]interface{})
+		if !isMap {
+			continue
+		}
+
+		if fieldType.Kind() == reflect.Map {
+			elemType := fieldType.Elem()
+			if elemType.Kind() != reflect.Struct {
+				// free-form maps, e.g. SET parameters or tags
+				continue
+			}
+
+			for k, v := range nested {
+				if entry, ok := v.(map[string]interface{}); ok {
+					issues = append(issues, lintKeys(keyPath+"."+k, entry, elemType)...)
+				}
+			}
+
+			continue
+		}
+
+		issues = append(issues, lintKeys(keyPath, nested, fieldType)...)
+	}
+
+	return issues
+}
+
+// closestKey suggests a known key at an edit distance of at most 2 from an unknown key.
+func closestKey(key string, fields map[string]reflect.Type) string {
+	const maxDistance = 2
+
+	best, bestDistance := "", maxDistance+1
+	for candidate := range fields {
+		if d := editDistance(strings.ToLower(key), candidate); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+
+	if bestDistance > maxDistance {
+		return ""
+	}
+
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func (p *poolSettings) lint(path string) []LintIssue {
+	var issues []LintIssue
+	warn := func(key, msg string, args ...interface{}) {
+		issues = append(issues, LintIssue{Severity: LintWarning, Path: path + "." + key, Message: fmt.Sprintf(msg, args...)})
+	}
+
+	if p.MaxOpenConns > 0 && p.MaxIdleConns > p.MaxOpenConns {
+		warn("maxidleconns", "maxIdleConns (%d) is greater than maxOpenConns (%d): extra idle connections are never kept", p.MaxIdleConns, p.MaxOpenConns)
+	}
+	if p.MaxOpenConns < 0 || p.MaxIdleConns < 0 {
+		warn("maxopenconns", "negative pool sizes are ignored")
+	}
+	if p.ConnMaxLifeTime > 0 && p.ConnMaxIdleTime > p.ConnMaxLifeTime {
+		warn("connmaxidletime", "connMaxIdleTime (%v) is greater than connMaxLifetime (%v) and has no effect", p.ConnMaxIdleTime, p.ConnMaxLifeTime)
+	}
+	if p.ConnMaxLifeTime <= 0 && p.ConnMaxLifetimeJitter > 0 {
+		warn("connmaxlifetimejitter", "connMaxLifetimeJitter (%v) has no effect without connMaxLifetime", p.ConnMaxLifetimeJitter)
+	}
+	if p.ParallelShare < 0 || p.ParallelShare > 1 {
+		warn("parallelshare", "parallelShare (%v) should be within ]0, 1]: the default is used", p.ParallelShare)
+	}
+	if p.Partition.Enabled {
+		if p.Partition.ReadShare <= 0 || p.Partition.ReadShare >= 1 {
+			warn("partition.readshare", "readShare (%v) should be within ]0, 1[: the default is used", p.Partition.ReadShare)
+		}
+		if p.MaxOpenConns < 2 {
+			warn("partition.enabled", "partitioning requires maxOpenConns to be at least 2: the pool is not partitioned")
+		}
+	}
+	if p.WaitMonitor.Enabled && p.MaxOpenConns == 0 {
+		warn("waitmonitor.enabled", "the pool size is not bounded: callers never wait for a connection")
+	}
+	if p.Trace.ExplainThreshold > 0 && !p.Trace.usesTraceProvider(TraceProviderOTel) {
+		warn("trace.explainthreshold", "slow queries are only explained with the otel trace provider")
+	}
+
+	return issues
+}
+
+func (r databaseSettings) lint(path string) []LintIssue {
+	var issues []LintIssue
+	warn := func(key, msg string) {
+		issues = append(issues, LintIssue{Severity: LintWarning, Path: path + "." + key, Message: msg})
+	}
+
+	if err := r.Validate(); err != nil {
+		issues = append(issues, LintIssue{Severity: LintError, Path: path, Message: err.Error()})
+	}
+
+	cp, err := parseConnString(r.DBURL())
+	if err != nil || len(cp.Hosts) == 0 {
+		return issues
+	}
+
+	if cp.HasPassword && (r.User != "" || r.Password != "" || r.PasswordFrom.isSet()) {
+		warn("url", "the URL contains a plaintext password, while user or password fields are also specified")
+	}
+
+	if r.Auth == AuthAWSIAM && (r.Password != "" || r.PasswordFrom.isSet()) {
+		warn("password", "the password is ignored with aws-iam authentication")
+	}
+
+	if !allLocalHosts(cp.Hosts) && !r.TLS.isSet() {
+		switch sslmode := cp.SSLMode; sslmode {
+		case "":
+			warn("url", "sslmode is not specified for a remote host")
+		case "disable", "allow":
+			warn("url", fmt.Sprintf("TLS is not enforced for a remote host (sslmode=%s)", sslmode))
+		}
+	}
+
+	return issues
+}
+
+func allLocalHosts(hosts []string) bool {
+	for _, host := range hosts {
+		if !isLocalHost(host) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isLocalHost(host string) bool {
+	if host == "localhost" || host == "" || isUnixSocket(host) {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
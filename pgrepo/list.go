@@ -0,0 +1,201 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrInvalidFilter is returned when a filter or a sort of a list query is not valid.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// FilterOp is the comparison operator of a Filter.
+type FilterOp string
+
+// Filter operators.
+const (
+	OpEq      FilterOp = "eq"
+	OpNe      FilterOp = "ne"
+	OpLt      FilterOp = "lt"
+	OpLte     FilterOp = "lte"
+	OpGt      FilterOp = "gt"
+	OpGte     FilterOp = "gte"
+	OpIn      FilterOp = "in" // the value is a slice
+	OpLike    FilterOp = "like"
+	OpILike   FilterOp = "ilike"
+	OpIsNull  FilterOp = "is_null" // the value is ignored
+	OpNotNull FilterOp = "not_null"
+)
+
+var filterOperators = map[FilterOp]string{
+	OpEq:    "=",
+	OpNe:    "<>",
+	OpLt:    "<",
+	OpLte:   "<=",
+	OpGt:    ">",
+	OpGte:   ">=",
+	OpLike:  "LIKE",
+	OpILike: "ILIKE",
+}
+
+// SortDir is the direction of a Sort.
+type SortDir string
+
+// Sort directions.
+const (
+	SortAsc  SortDir = "asc"
+	SortDesc SortDir = "desc"
+)
+
+// Filter is a condition on a field, e.g. Filter{Field: "status", Op: OpEq, Value: "active"}.
+type Filter struct {
+	Field string
+	Op    FilterOp
+	Value any
+}
+
+// Sort orders by a field. The default direction is ascending.
+type Sort struct {
+	Field string
+	Dir   SortDir
+}
+
+// ListQuery describes a page of a list, as typically requested by the caller of a list API.
+//
+// A zero Limit returns all rows.
+type ListQuery struct {
+	Filters []Filter
+	Sorts   []Sort
+	Limit   int
+	Offset  int
+}
+
+// Where returns a WHERE clause combining filters with AND, and its arguments.
+//
+// Fields must be allowed columns. Values are passed as arguments, numbered from $start: the clause may be combined
+// with other conditions. The clause is empty when no filter is given.
+func (c *Columns) Where(start int, filters ...Filter) (string, []any, error) {
+	if len(filters) == 0 {
+		return "", nil, nil
+	}
+
+	conditions := make([]string, 0, len(filters))
+	args := make([]any, 0, len(filters))
+	for _, filter := range filters {
+		column, err := c.Quote(filter.Field)
+		if err != nil {
+			return "", nil, err
+		}
+
+		op := FilterOp(strings.ToLower(string(filter.Op)))
+		switch op {
+		case OpIsNull:
+			conditions = append(conditions, column+" IS NULL")
+
+			continue
+		case OpNotNull:
+			conditions = append(conditions, column+" IS NOT NULL")
+
+			continue
+		case OpIn:
+			if v := reflect.ValueOf(filter.Value); !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+				return "", nil, fmt.Errorf("%w: operator %q on %q expects a slice, but got %T", ErrInvalidFilter, op, filter.Field, filter.Value)
+			}
+			conditions = append(conditions, fmt.Sprintf("%s = ANY($%d)", column, start+len(args)))
+		default:
+			operator, ok := filterOperators[op]
+			if !ok {
+				return "", nil, fmt.Errorf("%w: unsupported operator %q on %q", ErrInvalidFilter, filter.Op, filter.Field)
+			}
+			conditions = append(conditions, fmt.Sprintf("%s %s $%d", column, operator, start+len(args)))
+		}
+		args = append(args, filter.Value)
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// Sort returns an ORDER BY clause. Fields must be allowed columns.
+//
+// The clause is empty when no sort is given.
+func (c *Columns) Sort(sorts ...Sort) (string, error) {
+	specs := make([]string, 0, len(sorts))
+	for _, sort := range sorts {
+		dir := SortDir(strings.ToLower(string(sort.Dir)))
+		switch dir {
+		case "", SortAsc, SortDesc:
+		default:
+			return "", fmt.Errorf("%w: invalid sort direction %q on %q", ErrInvalidFilter, sort.Dir, sort.Field)
+		}
+
+		if strings.ContainsAny(sort.Field, " \t\r\n") {
+			return "", fmt.Errorf("%w: %q", ErrColumnNotAllowed, sort.Field)
+		}
+		specs = append(specs, strings.TrimSpace(sort.Field+" "+string(dir)))
+	}
+
+	return c.OrderBy(specs...)
+}
+
+// List returns a query selecting the allowed columns of a table, with the filters, sort and page of a list query.
+//
+// The table name is quoted. Limit and offset are passed as arguments.
+func (c *Columns) List(table string, q ListQuery) (string, []any, error) {
+	if q.Limit < 0 || q.Offset < 0 {
+		return "", nil, fmt.Errorf("%w: limit and offset must not be negative, but got %d and %d", ErrInvalidFilter, q.Limit, q.Offset)
+	}
+
+	projection, err := c.Select()
+	if err != nil {
+		return "", nil, err
+	}
+
+	where, args, err := c.Where(1, q.Filters...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	orderBy, err := c.Sort(q.Sorts...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	clauses := []string{fmt.Sprintf("SELECT %s FROM %s", projection, quoteQualifiedIdentifier(table))}
+	for _, clause := range []string{where, orderBy} {
+		if clause != "" {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		clauses = append(clauses, fmt.Sprintf("LIMIT $%d", len(args)))
+	}
+	if q.Offset > 0 {
+		args = append(args, q.Offset)
+		clauses = append(clauses, fmt.Sprintf("OFFSET $%d", len(args)))
+	}
+
+	return strings.Join(clauses, " "), args, nil
+}
+
+// SelectList retrieves a page of rows of a table into a slice of models.
+//
+// Columns are those of the model (see ColumnsOf): only these may be filtered and sorted.
+func SelectList[T any](ctx context.Context, db sqlx.QueryerContext, table string, q ListQuery) ([]T, error) {
+	query, args, err := ColumnsOf[T]().List(table, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []T
+	if err := sqlx.SelectContext(ctx, db, &rows, query, args...); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
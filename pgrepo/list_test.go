@@ -0,0 +1,86 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListQuery(t *testing.T) {
+	c := NewColumns("id", "name", "status", "deleted_at")
+
+	t.Run("should build parameterized conditions", func(t *testing.T) {
+		where, args, err := c.Where(2,
+			Filter{Field: "status", Op: OpIn, Value: []string{"active", "pending"}},
+			Filter{Field: "name", Op: "ILIKE", Value: "a%"},
+			Filter{Field: "id", Op: OpGte, Value: 10},
+			Filter{Field: "deleted_at", Op: OpIsNull},
+		)
+		require.NoError(t, err)
+		require.Equal(t, `WHERE "status" = ANY($2) AND "name" ILIKE $3 AND "id" >= $4 AND "deleted_at" IS NULL`, where)
+		require.Equal(t, []any{[]string{"active", "pending"}, "a%", 10}, args)
+
+		where, args, err = c.Where(1)
+		require.NoError(t, err)
+		require.Empty(t, where)
+		require.Empty(t, args)
+	})
+
+	t.Run("should reject invalid filters", func(t *testing.T) {
+		_, _, err := c.Where(1, Filter{Field: "password", Op: OpEq, Value: "x"})
+		require.ErrorIs(t, err, ErrColumnNotAllowed)
+
+		_, _, err = c.Where(1, Filter{Field: "name", Op: "; DROP TABLE users", Value: "x"})
+		require.ErrorIs(t, err, ErrInvalidFilter)
+
+		_, _, err = c.Where(1, Filter{Field: "id", Op: OpIn, Value: 1})
+		require.ErrorIs(t, err, ErrInvalidFilter)
+	})
+
+	t.Run("should sort", func(t *testing.T) {
+		orderBy, err := c.Sort(Sort{Field: "name"}, Sort{Field: "id", Dir: "DESC"})
+		require.NoError(t, err)
+		require.Equal(t, `ORDER BY "name" ASC, "id" DESC`, orderBy)
+
+		_, err = c.Sort(Sort{Field: "id", Dir: "sideways"})
+		require.ErrorIs(t, err, ErrInvalidFilter)
+
+		_, err = c.Sort(Sort{Field: "id desc"})
+		require.ErrorIs(t, err, ErrColumnNotAllowed)
+	})
+
+	t.Run("should build a page of a list", func(t *testing.T) {
+		query, args, err := c.List("app.users", ListQuery{
+			Filters: []Filter{{Field: "status", Op: OpEq, Value: "active"}},
+			Sorts:   []Sort{{Field: "name", Dir: SortAsc}},
+			Limit:   20,
+			Offset:  40,
+		})
+		require.NoError(t, err)
+		require.Equal(t,
+			`SELECT "id", "name", "status", "deleted_at" FROM "app"."users" WHERE "status" = $1 ORDER BY "name" ASC LIMIT $2 OFFSET $3`,
+			query,
+		)
+		require.Equal(t, []any{"active", 20, 40}, args)
+
+		query, args, err = c.List("users", ListQuery{})
+		require.NoError(t, err)
+		require.Equal(t, `SELECT "id", "name", "status", "deleted_at" FROM "users"`, query)
+		require.Empty(t, args)
+
+		_, _, err = c.List("users", ListQuery{Limit: -1})
+		require.ErrorIs(t, err, ErrInvalidFilter)
+	})
+
+	t.Run("should select a list of models", func(t *testing.T) {
+		db := &recordingQueryer{}
+		_, err := SelectList[columnsModel](context.Background(), db, "users", ListQuery{
+			Filters: []Filter{{Field: "email", Op: OpLike, Value: "%@example.com"}},
+			Limit:   10,
+		})
+		require.ErrorIs(t, err, errRecorded)
+		require.Equal(t, `SELECT "name", "email", "address", "id" FROM "users" WHERE "email" LIKE $1 LIMIT $2`, db.query)
+		require.Equal(t, []any{"%@example.com", 10}, db.args)
+	})
+}
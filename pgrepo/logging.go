@@ -0,0 +1,172 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/tracelog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// contextFieldsLogger adds fields extracted from the context to all driver logs.
+type contextFieldsLogger struct {
+	tracelog.Logger
+	fields func(context.Context) []zap.Field
+}
+
+func (l contextFieldsLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]interface{}) {
+	fields := l.fields(ctx)
+	if len(fields) == 0 {
+		l.Logger.Log(ctx, level, msg, data)
+
+		return
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+
+	merged := make(map[string]interface{}, len(data)+len(enc.Fields))
+	for k, v := range data {
+		merged[k] = v
+	}
+	for k, v := range enc.Fields {
+		merged[k] = v
+	}
+
+	l.Logger.Log(ctx, level, msg, merged)
+}
+
+// statementClassLogger logs SQL statements at a level which depends on their class (e.g. DDL statements at info
+// and reads at debug).
+//
+// Failed statements are logged at their original level.
+type statementClassLogger struct {
+	tracelog.Logger
+	levels map[string]tracelog.LogLevel
+}
+
+func (l statementClassLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]interface{}) {
+	var class string
+	if query, ok := data["sql"].(string); ok {
+		class = statementClass(query)
+	} else if msg == "CopyFrom" {
+		class = StatementClassWrite
+	}
+
+	if class != "" {
+		// the data belong to the caller
+		classified := make(map[string]interface{}, len(data)+1)
+		for k, v := range data {
+			classified[k] = v
+		}
+		classified["statement_class"] = class
+		data = classified
+
+		if _, failed := data["err"]; !failed {
+			if classLevel, ok := l.levels[class]; ok {
+				level = classLevel
+			}
+		}
+	}
+
+	l.Logger.Log(ctx, level, msg, data)
+}
+
+// maxLoggedStatementLength truncates the statements of slow query logs.
+const maxLoggedStatementLength = 1024
+
+// slowQueryLogger logs queries slower than a threshold as warnings, with a logger which is not limited by
+// the log level of the driver.
+//
+// Other logs are passed on.
+type slowQueryLogger struct {
+	tracelog.Logger
+	slow      tracelog.Logger
+	threshold time.Duration
+}
+
+func (l slowQueryLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]interface{}) {
+	elapsed, _ := data["time"].(time.Duration)
+	query, isQuery := data["sql"].(string)
+	if msg != "Query" || !isQuery || elapsed < l.threshold {
+		l.Logger.Log(ctx, level, msg, data)
+
+		return
+	}
+
+	if _, failed := data["err"]; failed {
+		l.Logger.Log(ctx, level, msg, data)
+
+		return
+	}
+
+	if len(query) > maxLoggedStatementLength {
+		query = truncateApplicationName(query, maxLoggedStatementLength) + "..."
+	}
+
+	slow := map[string]interface{}{
+		"sql":             query,
+		"time":            elapsed,
+		"threshold":       l.threshold,
+		"statement_class": statementClass(query),
+	}
+	if tag, ok := data["commandTag"].(string); ok {
+		slow["rows"] = pgconn.NewCommandTag(tag).RowsAffected()
+	}
+	if pid, ok := data["pid"]; ok {
+		slow["pid"] = pid
+	}
+
+	l.slow.Log(ctx, tracelog.LogLevelWarn, "slow query", slow)
+}
+
+func (p *poolSettings) slowQueryThreshold() time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	return p.Log.SlowQueryThreshold
+}
+
+// classLevels returns the log levels configured for statement classes.
+func (s logSettings) classLevels() (map[string]tracelog.LogLevel, error) {
+	if len(s.Classes) == 0 {
+		return nil, nil
+	}
+
+	levels := make(map[string]tracelog.LogLevel, len(s.Classes))
+	for class, lvl := range s.Classes {
+		class = strings.ToLower(class)
+		switch class {
+		case StatementClassRead, StatementClassWrite, StatementClassFunction, StatementClassRole, StatementClassDDL, StatementClassMisc:
+		default:
+			return nil, fmt.Errorf("invalid statement class for pgx driver logs [%q]: %w", class, ErrInvalidConfig)
+		}
+
+		level, err := tracelog.LogLevelFromString(strings.ToLower(lvl))
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level for %s statements [%q]: %w", class, lvl, errors.Join(ErrInvalidConfig, err))
+		}
+		levels[class] = level
+	}
+
+	return levels, nil
+}
+
+// logger returns a logger for the context, with the fields extracted from the context.
+func (r *Repository) logger(ctx context.Context) log.Logger {
+	lg := r.log.For(ctx)
+	if r.logFields == nil {
+		return lg
+	}
+
+	return lg.With(r.logFields(ctx)...)
+}
@@ -0,0 +1,161 @@
+package pgrepo
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type ctxKey struct{}
+
+type capturedLog struct {
+	level tracelog.LogLevel
+	msg   string
+	data  map[string]interface{}
+}
+
+type captureLogger struct {
+	logs []capturedLog
+}
+
+func (c *captureLogger) Log(_ context.Context, level tracelog.LogLevel, msg string, data map[string]interface{}) {
+	c.logs = append(c.logs, capturedLog{level: level, msg: msg, data: data})
+}
+
+func TestContextFieldsLogger(t *testing.T) {
+	capture := &captureLogger{}
+	lg := contextFieldsLogger{
+		Logger: capture,
+		fields: func(ctx context.Context) []zap.Field {
+			tenant, ok := ctx.Value(ctxKey{}).(string)
+			if !ok {
+				return nil
+			}
+
+			return []zap.Field{zap.String("tenant", tenant), zap.Int("shard", 3)}
+		},
+	}
+
+	lg.Log(context.Background(), tracelog.LogLevelInfo, "query", map[string]interface{}{"sql": "SELECT 1"})
+	ctx := context.WithValue(context.Background(), ctxKey{}, "acme")
+	lg.Log(ctx, tracelog.LogLevelInfo, "query", map[string]interface{}{"sql": "SELECT 2"})
+
+	require.Len(t, capture.logs, 2)
+	require.Equal(t, map[string]interface{}{"sql": "SELECT 1"}, capture.logs[0].data)
+	require.Equal(t, map[string]interface{}{"sql": "SELECT 2", "tenant": "acme", "shard": int64(3)}, capture.logs[1].data)
+}
+
+func TestRuntimeSettingsPreserved(t *testing.T) {
+	fields := func(context.Context) []zap.Field { return nil }
+	s := settingsFromOptions([]Option{
+		WithName("app"),
+		WithLogFieldsFromContext(fields),
+		WithViper(DefaultSettings()),
+	})
+
+	require.Equal(t, "app", s.app)
+	require.NotNil(t, s.logFields)
+	require.NotNil(t, s.DBSettingsFor(DefaultDBAlias).logFields)
+}
+
+func TestStatementClassLogger(t *testing.T) {
+	levels, err := logSettings{Classes: map[string]string{"READ": "debug", "write": "debug", "ddl": "info", "role": "info"}}.classLevels()
+	require.NoError(t, err)
+
+	capture := &captureLogger{}
+	lg := statementClassLogger{Logger: capture, levels: levels}
+	ctx := context.Background()
+
+	lg.Log(ctx, tracelog.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 1"})
+	lg.Log(ctx, tracelog.LogLevelInfo, "Query", map[string]interface{}{"sql": "ALTER TABLE users ADD COLUMN x int"})
+	lg.Log(ctx, tracelog.LogLevelError, "Query", map[string]interface{}{"sql": "INSERT INTO users VALUES (1)", "err": "duplicate key"})
+	lg.Log(ctx, tracelog.LogLevelInfo, "CopyFrom", map[string]interface{}{"tableName": "users"})
+	lg.Log(ctx, tracelog.LogLevelInfo, "Query", map[string]interface{}{"sql": "BEGIN"})
+	lg.Log(ctx, tracelog.LogLevelInfo, "Connect", map[string]interface{}{"host": "localhost"})
+
+	require.Len(t, capture.logs, 6)
+	require.Equal(t, tracelog.LogLevelDebug, capture.logs[0].level)
+	require.Equal(t, StatementClassRead, capture.logs[0].data["statement_class"])
+	require.Equal(t, tracelog.LogLevelInfo, capture.logs[1].level)
+	require.Equal(t, StatementClassDDL, capture.logs[1].data["statement_class"])
+	require.Equal(t, tracelog.LogLevelError, capture.logs[2].level, "failed statements keep their level")
+	require.Equal(t, tracelog.LogLevelDebug, capture.logs[3].level)
+	require.Equal(t, tracelog.LogLevelInfo, capture.logs[4].level, "unconfigured classes keep their level")
+	require.Equal(t, StatementClassMisc, capture.logs[4].data["statement_class"])
+	require.NotContains(t, capture.logs[5].data, "statement_class")
+
+	t.Run("should not alter the data of the caller", func(t *testing.T) {
+		data := map[string]interface{}{"sql": "SELECT 1"}
+		lg.Log(ctx, tracelog.LogLevelInfo, "Query", data)
+
+		require.NotContains(t, data, "statement_class")
+		require.Equal(t, StatementClassRead, capture.logs[len(capture.logs)-1].data["statement_class"])
+	})
+
+	t.Run("invalid class settings", func(t *testing.T) {
+		_, err := logSettings{Classes: map[string]string{"select": "debug"}}.classLevels()
+		require.ErrorIs(t, err, ErrInvalidConfig)
+
+		_, err = logSettings{Classes: map[string]string{"read": "verbose"}}.classLevels()
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+func TestSlowQueryLogger(t *testing.T) {
+	capture, slow := &captureLogger{}, &captureLogger{}
+	lg := slowQueryLogger{Logger: capture, slow: slow, threshold: 500 * time.Millisecond}
+	ctx := context.Background()
+	long := "SELECT * FROM orders WHERE id IN (" + strings.Repeat("1,", maxLoggedStatementLength) + "1)"
+
+	lg.Log(ctx, tracelog.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 1", "time": time.Millisecond, "commandTag": "SELECT 1"})
+	lg.Log(ctx, tracelog.LogLevelInfo, "Query", map[string]interface{}{"sql": long, "args": []any{}, "time": time.Second, "commandTag": "SELECT 12", "pid": uint32(42)})
+	lg.Log(ctx, tracelog.LogLevelError, "Query", map[string]interface{}{"sql": "SELECT 1", "time": time.Second, "err": "canceled"})
+	lg.Log(ctx, tracelog.LogLevelInfo, "Connect", map[string]interface{}{"host": "localhost", "time": time.Second})
+
+	require.Len(t, capture.logs, 3, "fast queries, failed queries and other logs should be passed on")
+	require.Equal(t, "Query", capture.logs[0].msg)
+	require.Equal(t, tracelog.LogLevelError, capture.logs[1].level)
+	require.Equal(t, "Connect", capture.logs[2].msg)
+
+	require.Len(t, slow.logs, 1)
+	logged := slow.logs[0]
+	require.Equal(t, tracelog.LogLevelWarn, logged.level)
+	require.Equal(t, "slow query", logged.msg)
+	require.Equal(t, time.Second, logged.data["time"])
+	require.Equal(t, int64(12), logged.data["rows"])
+	require.Equal(t, uint32(42), logged.data["pid"])
+	require.Equal(t, StatementClassRead, logged.data["statement_class"])
+	require.Len(t, logged.data["sql"], maxLoggedStatementLength+3)
+	require.NotContains(t, logged.data, "args")
+
+	t.Run("should log slow queries whatever the driver log level", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithPoolSettings(WithLogLevel("none"), WithSlowQueryThreshold(500*time.Millisecond)),
+		})
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "app")
+		tr, ok := dcfg.Tracer.(*tracelog.TraceLog)
+		require.True(t, ok)
+		require.Equal(t, tracelog.LogLevelInfo, tr.LogLevel)
+		require.IsType(t, slowQueryLogger{}, tr.Logger)
+	})
+
+	t.Run("should truncate statements without splitting multi-byte characters", func(t *testing.T) {
+		slow.logs = nil
+		query := "SELECT 'a" + strings.Repeat("é", maxLoggedStatementLength) + "'"
+		lg.Log(ctx, tracelog.LogLevelInfo, "Query", map[string]interface{}{"sql": query, "time": time.Second})
+
+		require.Len(t, slow.logs, 1)
+		logged, ok := slow.logs[0].data["sql"].(string)
+		require.True(t, ok)
+		require.True(t, utf8.ValidString(logged))
+		require.Len(t, logged, maxLoggedStatementLength-1+3)
+	})
+}
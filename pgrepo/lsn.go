@@ -0,0 +1,149 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	minLSNPollInterval = 5 * time.Millisecond
+	maxLSNPollInterval = 200 * time.Millisecond
+)
+
+var (
+	// ErrInvalidLSN is returned when a WAL location cannot be parsed.
+	ErrInvalidLSN = errors.New("invalid LSN")
+
+	// ErrReplicaLag is returned when no replica has replayed the WAL up to some location in time.
+	ErrReplicaLag = errors.New("replicas have not caught up")
+)
+
+// LSN is a location in the write-ahead log (WAL) of a postgres cluster, e.g. "16/B374D848".
+//
+// An LSN captured after a write on the master is a consistency token: a replica which has replayed the WAL
+// up to this location observes the write (see Repository.WaitForLSN).
+type LSN uint64
+
+// ParseLSN parses the textual representation of an LSN, e.g. "16/B374D848".
+func ParseLSN(s string) (LSN, error) {
+	hi, lo, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLSN, s)
+	}
+
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLSN, s)
+	}
+
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLSN, s)
+	}
+
+	return LSN(h<<32 | l), nil
+}
+
+// String yields the textual representation of the LSN, as postgres does.
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+// Scan implements sql.Scanner. A NULL location scans as 0.
+func (l *LSN) Scan(src any) error {
+	var s string
+
+	switch v := src.(type) {
+	case nil:
+		*l = 0
+
+		return nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("%w: cannot scan %T", ErrInvalidLSN, src)
+	}
+
+	lsn, err := ParseLSN(s)
+	if err != nil {
+		return err
+	}
+	*l = lsn
+
+	return nil
+}
+
+// CurrentLSN returns the current WAL insert location of the master, e.g. right after a write.
+func (r *Repository) CurrentLSN(ctx context.Context) (LSN, error) {
+	if r.db == nil {
+		return 0, ErrDBNotInitialized
+	}
+
+	var lsn LSN
+	err := r.db.QueryRowContext(ctx, `SELECT pg_current_wal_insert_lsn()::text`).Scan(&lsn)
+
+	return lsn, err
+}
+
+// WaitForLSN waits until a healthy replica has replayed the WAL up to some location (see CurrentLSN),
+// and returns this replica: reads on this replica observe all writes up to this location.
+//
+// Without healthy replicas, the master is returned right away. Replicas which are not in recovery
+// (e.g. promoted replicas) never catch up.
+//
+// When the context of the caller has no deadline, the default deadline of read operations applies.
+// The returned error wraps ErrReplicaLag when no replica has caught up in time.
+func (r *Repository) WaitForLSN(ctx context.Context, lsn LSN) (*sqlx.DB, error) {
+	if r.db == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationRead)
+	defer cancel()
+
+	for wait := minLSNPollInterval; ; wait = min(2*wait, maxLSNPollInterval) {
+		members := r.replicas.healthy()
+		if len(members) == 0 {
+			return r.db, nil
+		}
+
+		for _, member := range members {
+			var location sql.NullString
+			if err := member.db.QueryRowContext(ctx, `SELECT pg_last_wal_replay_lsn()::text`).Scan(&location); err != nil {
+				continue
+			}
+
+			if !location.Valid {
+				// the member is not in recovery (e.g. it has been promoted): it does not replay the WAL of the master
+				continue
+			}
+
+			replayed, err := ParseLSN(location.String)
+			if err != nil {
+				continue
+			}
+
+			if replayed >= lsn {
+				return member.db, nil
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, fmt.Errorf("%w with LSN %s: %w", ErrReplicaLag, lsn, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
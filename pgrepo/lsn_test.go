@@ -0,0 +1,124 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLSN(t *testing.T) {
+	t.Run("should parse and format", func(t *testing.T) {
+		lsn, err := ParseLSN("16/B374D848")
+		require.NoError(t, err)
+		require.Equal(t, LSN(0x16B374D848), lsn)
+		require.Equal(t, "16/B374D848", lsn.String())
+		require.Equal(t, "0/0", LSN(0).String())
+
+		previous, err := ParseLSN("16/B374D7FF")
+		require.NoError(t, err)
+		require.Less(t, previous, lsn)
+	})
+
+	t.Run("should reject invalid locations", func(t *testing.T) {
+		for _, s := range []string{"", "16", "16/", "G/1", "1/100000000"} {
+			_, err := ParseLSN(s)
+			require.ErrorIsf(t, err, ErrInvalidLSN, "expected %q to be invalid", s)
+		}
+	})
+
+	t.Run("should scan", func(t *testing.T) {
+		var lsn LSN
+		require.NoError(t, lsn.Scan([]byte("0/3000060")))
+		require.Equal(t, LSN(0x3000060), lsn)
+		require.NoError(t, lsn.Scan(nil))
+		require.Zero(t, lsn)
+		require.ErrorIs(t, lsn.Scan(42), ErrInvalidLSN)
+	})
+}
+
+func TestWaitForLSN(t *testing.T) {
+	newServer := func() (*fakeReplica, *sqlx.DB) {
+		server := &fakeReplica{}
+		db := sqlx.NewDb(sql.OpenDB(server), driverName)
+		t.Cleanup(func() { _ = db.Close() })
+
+		return server, db
+	}
+
+	master, masterDB := newServer()
+	lagging, laggingDB := newServer()
+	catching, catchingDB := newServer()
+
+	members := []*replica{{db: laggingDB, name: "lagging"}, {db: catchingDB, name: "catching"}}
+	for _, member := range members {
+		member.healthy.Store(true)
+	}
+	r := &Repository{db: masterDB, replicas: &replicaSet{members: members}}
+
+	master.lsn.Store(0x3000060)
+	lagging.lsn.Store(0x3000000)
+	catching.lsn.Store(0x3000010)
+
+	ctx := context.Background()
+	lsn, err := r.CurrentLSN(ctx)
+	require.NoError(t, err)
+	require.Equal(t, LSN(0x3000060), lsn)
+
+	t.Run("should wait for a replica to catch up", func(t *testing.T) {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			catching.lsn.Store(0x3000080)
+		}()
+
+		db, err := r.WaitForLSN(ctx, lsn)
+		require.NoError(t, err)
+		require.Same(t, catchingDB, db)
+	})
+
+	t.Run("should time out when replicas lag behind", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		_, err := r.WaitForLSN(ctx, LSN(0x4000000))
+		require.ErrorIs(t, err, ErrReplicaLag)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("should not wait for replicas which are not in recovery", func(t *testing.T) {
+		promoted := &fakeServer{script: func(string, []driver.Value) (*fakeRows, error) {
+			return singleValue("pg_last_wal_replay_lsn", nil), nil
+		}}
+		promotedDB := sqlx.NewDb(sql.OpenDB(promoted), driverName)
+		t.Cleanup(func() { _ = promotedDB.Close() })
+
+		member := &replica{db: promotedDB, name: "promoted"}
+		member.healthy.Store(true)
+		r := &Repository{db: masterDB, replicas: &replicaSet{members: []*replica{member}}}
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+
+		_, err := r.WaitForLSN(ctx, 0)
+		require.ErrorIs(t, err, ErrReplicaLag)
+	})
+
+	t.Run("should return the master without healthy replicas", func(t *testing.T) {
+		for _, member := range members {
+			member.healthy.Store(false)
+		}
+
+		db, err := r.WaitForLSN(ctx, LSN(0x4000000))
+		require.NoError(t, err)
+		require.Same(t, masterDB, db)
+	})
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		_, err := (&Repository{}).WaitForLSN(ctx, lsn)
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+}
@@ -0,0 +1,204 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const defaultMaintenanceChannel = "pg_maintenance"
+
+// WithMaintenanceChannel enables draining the pool before a planned maintenance of the database server
+// (e.g. a failover), when a notification is received on a channel.
+//
+// When notified, connections are drained and new acquisitions are paused for the maintenance window,
+// then new connections are established. The payload of the notification may specify another window,
+// e.g.:
+//
+//	NOTIFY pg_maintenance, '2m'
+//
+// Windows specified by notifications are capped (see WithMaintenanceMaxWindow).
+func WithMaintenanceChannel(channel string, window time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Maintenance.Enabled = true
+		o.Maintenance.Channel = channel
+		o.Maintenance.Window = window
+	}
+}
+
+// WithMaintenanceMaxWindow sets the longest maintenance window a notification may specify (default: 10m).
+//
+// The cap is never shorter than the configured window.
+func WithMaintenanceMaxWindow(window time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Maintenance.MaxWindow = window
+	}
+}
+
+func (r databaseSettings) maintenanceSettings() maintenanceSettings {
+	var ms maintenanceSettings
+	if r.PGConfig != nil {
+		ms = r.PGConfig.Maintenance
+	}
+
+	defaults := defaultSettings.PGConfig.Maintenance
+	if ms.Channel == "" {
+		ms.Channel = defaults.Channel
+	}
+	if ms.Window <= 0 {
+		ms.Window = defaults.Window
+	}
+	if ms.MaxWindow <= 0 {
+		ms.MaxWindow = defaults.MaxWindow
+	}
+	if ms.MaxWindow < ms.Window {
+		ms.MaxWindow = ms.Window
+	}
+
+	return ms
+}
+
+// startMaintenanceListener listens to maintenance notifications on a dedicated connection to the master database.
+//
+// The listener reconnects with the backoff of the retry policy whenever its connection is lost,
+// e.g. during the maintenance.
+//
+// It returns a function to stop the listener.
+func (r *Repository) startMaintenanceListener(ms maintenanceSettings) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := r.log.Bg().With(zap.String("db_alias", r.alias), zap.String("channel", ms.Channel))
+	policy := r.retryPolicy()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for attempts := 1; ; attempts++ {
+			err := r.listenMaintenance(ctx, ms, l, func() { attempts = 0 })
+			if ctx.Err() != nil {
+				return
+			}
+
+			delay := policy.backoff(max(attempts, 1))
+			l.Warn("maintenance listener disconnected: retrying", zap.Duration("delay", delay), zap.Error(err))
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+		r.connector.resume()
+	}
+}
+
+// listenMaintenance waits for maintenance notifications, until the connection fails or the context is cancelled.
+func (r *Repository) listenMaintenance(ctx context.Context, ms maintenanceSettings, l log.Logger, connected func()) error {
+	cfg, err := r.connector.connectConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	if _, err = conn.Exec(ctx, `LISTEN `+quoteIdentifier(ms.Channel)); err != nil {
+		return err
+	}
+	connected()
+	l.Debug("listening to maintenance notifications")
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		window, err := maintenanceWindow(notification.Payload, ms)
+		if err != nil {
+			l.Warn("unexpected maintenance window in notification", zap.String("payload", notification.Payload), zap.Error(err))
+		}
+		l.Warn("planned maintenance: draining connections", zap.Duration("window", window))
+
+		if err = r.drain(ctx, window); err != nil {
+			return err
+		}
+		l.Info("maintenance window elapsed: reconnecting")
+	}
+}
+
+// drain closes idle connections and pauses new acquisitions for a while.
+//
+// Connections in use are discarded when released.
+func (r *Repository) drain(ctx context.Context, window time.Duration) error {
+	r.connector.pause()
+	defer r.connector.resume()
+
+	r.db.SetMaxIdleConns(0)
+	defer r.connector.appliedSettings().restoreIdleConns(r.db.DB)
+
+	timer := time.NewTimer(window)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// restoreIdleConns restores the maximum number of idle connections after a drain.
+func (r databaseSettings) restoreIdleConns(db *sql.DB) {
+	idle := defaultSettings.PGConfig.MaxIdleConns
+	if r.PGConfig != nil && r.PGConfig.idleConns() > 0 {
+		idle = r.PGConfig.idleConns()
+	}
+
+	db.SetMaxIdleConns(idle)
+}
+
+// maintenanceWindow returns the window specified by the payload of a maintenance notification, or the configured window.
+//
+// Windows longer than the maximum are capped. An error is returned along with the applied window when the payload
+// is invalid or capped.
+func maintenanceWindow(payload string, ms maintenanceSettings) (time.Duration, error) {
+	payload = strings.TrimSpace(payload)
+	if payload == "" {
+		return ms.Window, nil
+	}
+
+	window, err := time.ParseDuration(payload)
+	if err != nil {
+		return ms.Window, err
+	}
+
+	if window <= 0 {
+		return ms.Window, fmt.Errorf("maintenance window must be positive: %v", window)
+	}
+
+	if window > ms.MaxWindow {
+		return ms.MaxWindow, fmt.Errorf("maintenance window %v is capped to %v", window, ms.MaxWindow)
+	}
+
+	return window, nil
+}
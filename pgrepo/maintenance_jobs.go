@@ -0,0 +1,204 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMaintenanceRunsTable = "maintenance_runs"
+	minMaintenanceJobInterval   = time.Second
+)
+
+// ErrUnknownMaintenanceJob is returned when running a maintenance job which is not configured.
+var ErrUnknownMaintenanceJob = errors.New("unknown maintenance job")
+
+// maintenanceJobSettings declare a periodic maintenance statement.
+type maintenanceJobSettings struct {
+	Statement string        // e.g. ANALYZE orders, REFRESH MATERIALIZED VIEW CONCURRENTLY daily_sales
+	Interval  time.Duration // the statement is run at most once per interval, across all instances
+}
+
+// WithMaintenanceJob schedules a maintenance statement to run periodically, e.g. an ANALYZE of hot tables,
+// a REFRESH MATERIALIZED VIEW CONCURRENTLY, or the cleanup of old partitions.
+//
+// Across all instances sharing the database, a job is run by a single instance at a time (with an advisory lock),
+// and at most once per interval: the last run of jobs is recorded in the "maintenance_runs" table.
+//
+// Statements are not run within a transaction, so VACUUM or REINDEX CONCURRENTLY are supported.
+// In dry-run mode (see WithDryRun), statements are only logged and runs are not recorded.
+// The default deadline of admin operations applies to each run (see WithDefaultDeadline).
+func WithMaintenanceJob(name, statement string, interval time.Duration) DBOption {
+	return func(o *databaseSettings) {
+		jobs := make(map[string]maintenanceJobSettings, len(o.MaintenanceJobs)+1)
+		for k, v := range o.MaintenanceJobs {
+			jobs[k] = v
+		}
+		jobs[strings.ToLower(name)] = maintenanceJobSettings{Statement: statement, Interval: interval}
+		o.MaintenanceJobs = jobs
+	}
+}
+
+func (r databaseSettings) validateMaintenanceJobs() error {
+	for name, job := range r.MaintenanceJobs {
+		if strings.TrimSpace(job.Statement) == "" {
+			return fmt.Errorf("maintenance job %q has no statement: %w", name, ErrInvalidConfig)
+		}
+
+		if job.Interval < minMaintenanceJobInterval {
+			return fmt.Errorf("the interval of maintenance job %q must be at least %v: %w", name, minMaintenanceJobInterval, ErrInvalidConfig)
+		}
+	}
+
+	return nil
+}
+
+func (r databaseSettings) maintenanceRunsTable() string {
+	if r.namespace != "" {
+		return r.namespace + "." + defaultMaintenanceRunsTable
+	}
+
+	return defaultMaintenanceRunsTable
+}
+
+// maintenanceCheckInterval is the interval between two checks of a job being due.
+//
+// Jobs are checked several times per interval, so that a job is run close to its interval whatever
+// the instance which gets to run it.
+func maintenanceCheckInterval(interval time.Duration) time.Duration {
+	return max(interval/4, minMaintenanceJobInterval)
+}
+
+// RunMaintenanceJob runs a configured maintenance job right away, regardless of its interval (see WithMaintenanceJob).
+//
+// It returns false if the job is being run by another instance.
+func (r *Repository) RunMaintenanceJob(ctx context.Context, name string) (bool, error) {
+	if r.db == nil {
+		return false, ErrDBNotInitialized
+	}
+
+	name = strings.ToLower(name)
+	job, ok := r.MaintenanceJobs[name]
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownMaintenanceJob, name)
+	}
+
+	return r.runMaintenanceJob(ctx, name, job, true)
+}
+
+// runMaintenanceJob runs a maintenance job when it is due (or when forced), unless another instance runs it.
+func (r *Repository) runMaintenanceJob(ctx context.Context, name string, job maintenanceJobSettings, force bool) (bool, error) {
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationAdmin)
+	defer cancel()
+
+	table := quoteQualifiedIdentifier(r.maintenanceRunsTable())
+	session, err := r.AcquireSession(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		// the advisory lock is released with the session
+		_ = session.Close()
+	}()
+	defer r.markAdminSession(ctx, session)()
+
+	var locked bool
+	if err = session.QueryRowxContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, maintenanceLockKey(name)).Scan(&locked); err != nil {
+		return false, fmt.Errorf("could not lock maintenance job %s: %w", name, err)
+	}
+	if !locked {
+		return false, nil
+	}
+
+	if _, err = session.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	name text PRIMARY KEY,
+	last_run_at timestamptz NOT NULL,
+	duration interval NOT NULL
+)`, table)); err != nil {
+		return false, fmt.Errorf("could not create maintenance runs table %s: %w", table, err)
+	}
+
+	if !force {
+		var due bool
+		if err = session.QueryRowxContext(ctx, fmt.Sprintf(
+			`SELECT NOT EXISTS (SELECT 1 FROM %s WHERE name = $1 AND last_run_at > now() - make_interval(secs => $2))`, table),
+			name, job.Interval.Seconds(),
+		).Scan(&due); err != nil {
+			return false, fmt.Errorf("could not check the last run of maintenance job %s: %w", name, err)
+		}
+
+		if !due {
+			return false, nil
+		}
+	}
+
+	l := r.logger(ctx).With(zap.String("job", name))
+	start := time.Now()
+	if err = execDestructive(ctx, session, r.dryRun, l.Zap(), job.Statement); err != nil {
+		return true, fmt.Errorf("maintenance job %s failed: %w", name, err)
+	}
+	elapsed := time.Since(start)
+
+	if r.dryRun {
+		// the job remains due
+		return true, nil
+	}
+
+	if _, err = session.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (name, last_run_at, duration)
+VALUES ($1, now(), make_interval(secs => $2))
+ON CONFLICT (name) DO UPDATE SET last_run_at = EXCLUDED.last_run_at, duration = EXCLUDED.duration`, table),
+		name, elapsed.Seconds(),
+	); err != nil {
+		return true, fmt.Errorf("could not record the run of maintenance job %s: %w", name, err)
+	}
+
+	l.Info("maintenance job done", zap.Duration("duration", elapsed))
+
+	return true, nil
+}
+
+// startMaintenanceJobs periodically runs the maintenance jobs which are due.
+//
+// It returns a function to stop running jobs.
+func (r *Repository) startMaintenanceJobs(l log.Logger) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	for name, job := range r.MaintenanceJobs {
+		wg.Add(1)
+
+		go func(name string, job maintenanceJobSettings) {
+			defer wg.Done()
+
+			ticker := time.NewTicker(maintenanceCheckInterval(job.Interval))
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if _, err := r.runMaintenanceJob(ctx, name, job, false); err != nil && ctx.Err() == nil {
+						l.Warn("maintenance job failed", zap.String("job", name), zap.Error(err))
+					}
+				}
+			}
+		}(name, job)
+	}
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func maintenanceLockKey(name string) string {
+	return "pgrepo_maintenance:" + name
+}
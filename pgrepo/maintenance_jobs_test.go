@@ -0,0 +1,126 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMaintenanceJobSettings(t *testing.T) {
+	t.Run("should declare maintenance jobs", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithMaintenanceJob("Analyze_Orders", "ANALYZE orders", time.Hour),
+			WithMaintenanceJob("refresh_sales", "REFRESH MATERIALIZED VIEW CONCURRENTLY daily_sales", 10*time.Minute),
+		})
+		require.NoError(t, dbs.Validate())
+		require.Len(t, dbs.MaintenanceJobs, 2)
+		require.Equal(t, maintenanceJobSettings{Statement: "ANALYZE orders", Interval: time.Hour}, dbs.MaintenanceJobs["analyze_orders"])
+		require.Equal(t, "maintenance_runs", dbs.maintenanceRunsTable())
+
+		dbs.namespace = "tenant1"
+		require.Equal(t, "tenant1.maintenance_runs", dbs.maintenanceRunsTable())
+	})
+
+	t.Run("should not validate a job without a statement", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithMaintenanceJob("analyze", " ", time.Hour)})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should not validate a too short interval", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithMaintenanceJob("analyze", "ANALYZE orders", time.Millisecond)})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should check jobs several times per interval", func(t *testing.T) {
+		require.Equal(t, 15*time.Minute, maintenanceCheckInterval(time.Hour))
+		require.Equal(t, minMaintenanceJobInterval, maintenanceCheckInterval(2*time.Second))
+	})
+}
+
+func TestRunMaintenanceJob(t *testing.T) {
+	var locked atomic.Bool
+	server := &fakeServer{script: func(string, []driver.Value) (*fakeRows, error) {
+		return singleValue("pg_try_advisory_lock", locked.Load()), nil
+	}}
+	db := sqlx.NewDb(sql.OpenDB(server), driverName)
+	t.Cleanup(func() { _ = db.Close() })
+
+	r := New(DefaultDBAlias,
+		WithLogger(zap.NewNop()),
+		WithDatabaseSettings(DefaultDBAlias, WithMaintenanceJob("analyze", "ANALYZE orders", time.Hour)),
+	)
+	r.db = db
+	ctx := context.Background()
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		_, err := (&Repository{}).RunMaintenanceJob(ctx, "analyze")
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("should require a configured job", func(t *testing.T) {
+		_, err := r.RunMaintenanceJob(ctx, "vacuum")
+		require.ErrorIs(t, err, ErrUnknownMaintenanceJob)
+	})
+
+	t.Run("should skip a job run by another instance", func(t *testing.T) {
+		locked.Store(false)
+
+		ran, err := r.RunMaintenanceJob(ctx, "ANALYZE")
+		require.NoError(t, err)
+		require.False(t, ran)
+		require.Equal(t, 0, db.Stats().InUse, "expected the session to be released")
+	})
+
+	t.Run("should run a job when holding the lock", func(t *testing.T) {
+		locked.Store(true)
+
+		ran, err := r.RunMaintenanceJob(ctx, "analyze")
+		require.NoError(t, err)
+		require.True(t, ran)
+		require.Equal(t, 0, db.Stats().InUse, "expected the session to be released")
+
+		queries := server.queries()
+		require.GreaterOrEqual(t, len(queries), 3)
+		last := queries[len(queries)-3:]
+		require.Contains(t, last[0], `CREATE TABLE IF NOT EXISTS "maintenance_runs"`)
+		require.Equal(t, "ANALYZE orders", last[1])
+		require.Contains(t, last[2], `INSERT INTO "maintenance_runs"`)
+	})
+
+	t.Run("should only log a job in dry-run mode", func(t *testing.T) {
+		dryRun := New(DefaultDBAlias,
+			WithLogger(zap.NewNop()),
+			WithDryRun(),
+			WithDatabaseSettings(DefaultDBAlias, WithMaintenanceJob("analyze", "ANALYZE orders", time.Hour)),
+		)
+		dryRun.db = db
+		before := len(server.queries())
+
+		ran, err := dryRun.RunMaintenanceJob(ctx, "analyze")
+		require.NoError(t, err)
+		require.True(t, ran)
+
+		queries := server.queries()[before:]
+		require.NotContains(t, queries, "ANALYZE orders")
+		for _, query := range queries {
+			require.NotContains(t, query, "INSERT INTO")
+		}
+	})
+
+	t.Run("should report a failed job", func(t *testing.T) {
+		server.err = errors.New("permission denied")
+		defer func() { server.err = nil }()
+
+		_, err := r.RunMaintenanceJob(ctx, "analyze")
+		require.Error(t, err)
+		require.Equal(t, 0, db.Stats().InUse, "expected the session to be released")
+	})
+}
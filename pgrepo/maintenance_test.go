@@ -0,0 +1,116 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	ms := maintenanceSettings{Window: time.Second, MaxWindow: 10 * time.Minute}
+
+	for _, toPin := range []struct {
+		payload  string
+		expected time.Duration
+		rejected bool
+	}{
+		{payload: "2m", expected: 2 * time.Minute},
+		{payload: " 2m ", expected: 2 * time.Minute},
+		{payload: "", expected: time.Second},
+		{payload: "failover", expected: time.Second, rejected: true},
+		{payload: "-1m", expected: time.Second, rejected: true},
+		{payload: "10m", expected: 10 * time.Minute},
+		{payload: "2400h", expected: 10 * time.Minute, rejected: true},
+	} {
+		tc := toPin
+
+		window, err := maintenanceWindow(tc.payload, ms)
+		require.Equal(t, tc.expected, window, tc.payload)
+		if tc.rejected {
+			require.Error(t, err, tc.payload)
+		} else {
+			require.NoError(t, err, tc.payload)
+		}
+	}
+}
+
+func TestMaintenanceSettings(t *testing.T) {
+	t.Run("should apply defaults", func(t *testing.T) {
+		ms := databaseSettings{}.maintenanceSettings()
+		require.False(t, ms.Enabled)
+		require.Equal(t, defaultMaintenanceChannel, ms.Channel)
+		require.Equal(t, 30*time.Second, ms.Window)
+		require.Equal(t, 10*time.Minute, ms.MaxWindow)
+	})
+
+	t.Run("should apply option", func(t *testing.T) {
+		s := settingsFromOptions([]Option{
+			WithDefaultPoolOptions(WithMaintenanceChannel("ops", time.Minute)),
+		})
+
+		ms := s.DBSettingsFor(DefaultDBAlias).maintenanceSettings()
+		require.True(t, ms.Enabled)
+		require.Equal(t, "ops", ms.Channel)
+		require.Equal(t, time.Minute, ms.Window)
+	})
+
+	t.Run("should not cap windows below the configured window", func(t *testing.T) {
+		s := settingsFromOptions([]Option{
+			WithDefaultPoolOptions(WithMaintenanceChannel("ops", time.Hour), WithMaintenanceMaxWindow(time.Minute)),
+		})
+
+		ms := s.DBSettingsFor(DefaultDBAlias).maintenanceSettings()
+		require.Equal(t, time.Hour, ms.MaxWindow)
+	})
+}
+
+func TestDrain(t *testing.T) {
+	connector := &reloadableConnector{connector: &fakeReplica{}}
+	db := sqlx.NewDb(sql.OpenDB(connector), driverName)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	r := &Repository{db: db, connector: connector}
+
+	require.NoError(t, db.Ping())
+	require.Equal(t, 1, db.Stats().Idle)
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- r.drain(context.Background(), 200*time.Millisecond)
+	}()
+
+	require.Eventually(t, func() bool {
+		return db.Stats().OpenConnections == 0
+	}, time.Second, 10*time.Millisecond)
+
+	t.Run("new connections should wait for the end of the window", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		require.ErrorIs(t, db.PingContext(ctx), context.DeadlineExceeded)
+	})
+
+	require.NoError(t, <-drained)
+	require.NoError(t, db.Ping())
+	require.Equal(t, 1, db.Stats().Idle)
+}
+
+func TestDrainCancelled(t *testing.T) {
+	connector := &reloadableConnector{connector: &fakeReplica{}}
+	db := sqlx.NewDb(sql.OpenDB(connector), driverName)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	r := &Repository{db: db, connector: connector}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.ErrorIs(t, r.drain(ctx, time.Hour), context.Canceled)
+	require.NoError(t, db.Ping())
+}
@@ -0,0 +1,241 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const defaultMigrationsTable = "schema_migrations"
+
+// ErrInvalidMigration is returned when migration files cannot be resolved into an ordered list of versions.
+var ErrInvalidMigration = errors.New("invalid migration")
+
+// Migration is a versioned SQL script.
+type Migration struct {
+	Version int64
+	Name    string
+	Path    string
+}
+
+// Migrate applies the SQL migrations found in fsys to the database with alias "dbAlias".
+//
+// A connection pool is opened for the duration of the migration.
+//
+// See Repository.Migrate.
+func Migrate(ctx context.Context, dbAlias string, fsys fs.FS, opts ...Option) error {
+	r := New(dbAlias, opts...)
+	if err := r.StartContext(ctx); err != nil {
+		return err
+	}
+
+	err := r.Migrate(ctx, fsys)
+
+	return errors.Join(err, r.Stop())
+}
+
+// Migrate applies the SQL migrations found at the root of fsys (e.g. an embed.FS), which are not applied yet.
+//
+// Migration files are named after their version, e.g. "0001_create_users.sql" or "0001_create_users.up.sql".
+// Files with the ".down.sql" extension are ignored. Use fs.Sub to apply migrations located in a subdirectory.
+//
+// Applied versions are tracked in the "schema_migrations" table (see WithMigrationsTable).
+// Each migration runs in its own transaction.
+//
+// A session-level advisory lock is held for the duration of the migration, so concurrent instances of an
+// app don't race to apply the same migrations.
+//
+// In dry-run mode (see WithDryRun), pending migrations are only logged.
+//
+// The default deadline of DDL operations applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) Migrate(ctx context.Context, fsys fs.FS) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationDDL)
+	defer cancel()
+
+	migrations, err := parseMigrations(fsys)
+	if err != nil {
+		return err
+	}
+
+	table := r.migrationsTable()
+	l := r.logger(ctx).With(zap.String("migrations_table", table))
+
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	defer r.markAdminSession(ctx, conn)()
+
+	lockKey := "pgrepo_migrations:" + table
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext($1))`, lockKey); err != nil {
+		return fmt.Errorf("could not acquire migrations lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext($1))`, lockKey)
+	}()
+
+	if r.namespace != "" {
+		if _, err = conn.ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS `+quoteIdentifier(r.namespace)); err != nil {
+			return fmt.Errorf("could not create schema %s: %w", r.namespace, err)
+		}
+	}
+
+	qtable := quoteQualifiedIdentifier(table)
+	_, err = conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version bigint PRIMARY KEY,
+	name text NOT NULL,
+	applied_at timestamptz NOT NULL DEFAULT now()
+)`, qtable))
+	if err != nil {
+		return fmt.Errorf("could not create migrations table %s: %w", table, err)
+	}
+
+	applied, err := appliedVersions(ctx, conn, qtable)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		ml := l.With(zap.Int64("version", migration.Version), zap.String("migration", migration.Name))
+		if r.dryRun {
+			ml.Info("dry-run: migration not applied")
+
+			continue
+		}
+
+		if err = applyMigration(ctx, conn, fsys, qtable, migration); err != nil {
+			return err
+		}
+
+		ml.Info("migration applied")
+	}
+
+	return nil
+}
+
+func (r databaseSettings) migrationsTable() string {
+	table := r.migrations
+	if table == "" {
+		table = defaultMigrationsTable
+	}
+
+	if r.namespace != "" && !strings.Contains(table, ".") {
+		return r.namespace + "." + table
+	}
+
+	return table
+}
+
+// parseMigrations lists the migration files at the root of fsys, ordered by version.
+func parseMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	versions := make(map[int64]string, len(entries))
+
+	for _, entry := range entries {
+		file := entry.Name()
+		if entry.IsDir() || path.Ext(file) != ".sql" || strings.HasSuffix(file, ".down.sql") {
+			continue
+		}
+
+		base := strings.TrimSuffix(strings.TrimSuffix(file, ".sql"), ".up")
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: migration file %q should start with a version number: %w", ErrInvalidMigration, file, err)
+		}
+
+		if other, duplicate := versions[version]; duplicate {
+			return nil, fmt.Errorf("%w: files %q and %q have the same version %d", ErrInvalidMigration, other, file, version)
+		}
+		versions[version] = file
+
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    name,
+			Path:    file,
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn, qtable string) (map[int64]struct{}, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`SELECT version FROM %s`, qtable))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	applied := make(map[int64]struct{})
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = struct{}{}
+	}
+
+	return applied, rows.Err()
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, fsys fs.FS, qtable string, migration Migration) error {
+	script, err := fs.ReadFile(fsys, migration.Path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(withManagedTx(ctx), nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// statements without arguments are sent using the simple protocol, so a script may contain several statements
+	if _, err = tx.ExecContext(ctx, string(script)); err != nil {
+		return fmt.Errorf("migration %s failed: %w", migration.Path, err)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (version, name) VALUES ($1, $2)`, qtable), migration.Version, migration.Name)
+	if err != nil {
+		return fmt.Errorf("could not record migration %s: %w", migration.Path, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("migration %s failed: %w", migration.Path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package pgrepo
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMigrations(t *testing.T) {
+	t.Run("migrations are ordered by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"0010_add_index.up.sql":   {Data: []byte(`CREATE INDEX ...`)},
+			"0010_add_index.down.sql": {Data: []byte(`DROP INDEX ...`)},
+			"0002_create_users.sql":   {Data: []byte(`CREATE TABLE users ...`)},
+			"1_init.sql":              {Data: []byte(`CREATE SCHEMA app`)},
+			"README.md":               {Data: []byte(`not a migration`)},
+			"fixtures/0003_data.sql":  {Data: []byte(`INSERT ...`)},
+		}
+
+		migrations, err := parseMigrations(fsys)
+		require.NoError(t, err)
+		require.Equal(t, []Migration{
+			{Version: 1, Name: "init", Path: "1_init.sql"},
+			{Version: 2, Name: "create_users", Path: "0002_create_users.sql"},
+			{Version: 10, Name: "add_index", Path: "0010_add_index.up.sql"},
+		}, migrations)
+	})
+
+	t.Run("versions must be unique", func(t *testing.T) {
+		_, err := parseMigrations(fstest.MapFS{
+			"0001_init.sql": {},
+			"001_other.sql": {},
+		})
+		require.ErrorIs(t, err, ErrInvalidMigration)
+	})
+
+	t.Run("files must start with a version", func(t *testing.T) {
+		_, err := parseMigrations(fstest.MapFS{
+			"init.sql": {},
+		})
+		require.ErrorIs(t, err, ErrInvalidMigration)
+	})
+
+	t.Run("migrations table", func(t *testing.T) {
+		require.Equal(t, "schema_migrations", databaseSettings{}.migrationsTable())
+
+		dbs := settingsFromOptions([]Option{WithMigrationsTable("app.migrations")}).DBSettingsFor(DefaultDBAlias)
+		require.Equal(t, "app.migrations", dbs.migrationsTable())
+	})
+}
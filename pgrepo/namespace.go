@@ -0,0 +1,80 @@
+package pgrepo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fredbi/go-trace/log"
+	"go.uber.org/zap"
+)
+
+// Namespace derives a repository scoped to a schema, for modules sharing the same database.
+//
+// The derived repository:
+//
+//   - resolves unqualified names in the schema first, then in "public" (search_path)
+//   - records its migrations in the schema (e.g. "billing.schema_migrations"), which is created by Migrate if needed
+//   - labels its metrics with the namespace
+//
+// The derived repository has its own connection pool, configured like this one, and needs to be started with Start().
+//
+// With an empty schema, the derived repository is not scoped.
+func (r *Repository) Namespace(schema string) *Repository {
+	dbs := r.databaseSettings
+	dbs.namespace = schema
+
+	if schema == "" {
+		return &Repository{
+			log:              r.log,
+			app:              r.app,
+			alias:            r.alias,
+			opts:             r.opts,
+			databaseSettings: dbs,
+		}
+	}
+
+	return &Repository{
+		log:              log.NewFactory(r.log.Zap().With(zap.String("namespace", schema))),
+		app:              r.app,
+		alias:            r.alias,
+		opts:             r.opts,
+		databaseSettings: dbs,
+	}
+}
+
+// NamespaceName returns the schema this repository is scoped to, or the empty string if it is not scoped (see Namespace).
+func (r *Repository) NamespaceName() string {
+	return r.namespace
+}
+
+func (r databaseSettings) validateNamespace() error {
+	if r.namespace == "" {
+		return nil
+	}
+
+	if len(r.namespace) > maxIdentifierLength {
+		return fmt.Errorf("namespace %q is longer than %d characters: %w", r.namespace, maxIdentifierLength, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// searchPath returns the value of the search_path parameter for the schema setting.
+func (r databaseSettings) searchPath() string {
+	schemas := make([]string, 0, len(r.Schema))
+	for _, schema := range r.Schema {
+		schemas = append(schemas, quoteIdentifier(strings.TrimSpace(schema)))
+	}
+
+	return strings.Join(schemas, ", ")
+}
+
+func (r databaseSettings) validateSchema() error {
+	for _, schema := range r.Schema {
+		if strings.TrimSpace(schema) == "" {
+			return fmt.Errorf("schema names in the search path must not be empty: %w", ErrInvalidConfig)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package pgrepo
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNamespace(t *testing.T) {
+	r := New(DefaultDBAlias,
+		WithLogger(zap.NewNop()),
+		WithDefaultPoolOptions(WithSetClause("plan_cache_mode", "force_custom_plan")),
+	)
+
+	billing := r.Namespace("billing")
+
+	t.Run("should scope the search path", func(t *testing.T) {
+		require.Equal(t, "billing", billing.NamespaceName())
+		require.Equal(t, `"billing", public`, billing.setParams()["search_path"])
+		require.Equal(t, "force_custom_plan", billing.setParams()["plan_cache_mode"])
+		require.NoError(t, billing.Validate())
+
+		require.Empty(t, r.NamespaceName())
+		require.NotContains(t, r.setParams(), "search_path")
+	})
+
+	t.Run("should keep the search path when settings are reloaded", func(t *testing.T) {
+		require.NotContains(t, billing.PGConfig.Set, "search_path")
+
+		s := settingsFromOptions(nil)
+		s.runtimeSettings = billing.runtimeSettings
+		require.Equal(t, `"billing", public`, s.DBSettingsFor(DefaultDBAlias).setParams()["search_path"])
+	})
+
+	t.Run("should override the search path of the database", func(t *testing.T) {
+		rr := New(DefaultDBAlias,
+			WithLogger(zap.NewNop()),
+			WithDatabaseSettings(DefaultDBAlias, WithURL(DefaultURL), WithDBSetClause("search_path", "app, public")),
+		)
+
+		scoped := rr.Namespace("billing")
+		require.Equal(t, `"billing", public`, scoped.setParams()["search_path"])
+		require.Equal(t, "app, public", rr.setParams()["search_path"])
+	})
+
+	t.Run("should scope migrations", func(t *testing.T) {
+		require.Equal(t, "billing.schema_migrations", billing.migrationsTable())
+
+		dbs := billing.databaseSettings
+		dbs.migrations = "migrations"
+		require.Equal(t, "billing.migrations", dbs.migrationsTable())
+
+		dbs.migrations = "app.migrations"
+		require.Equal(t, "app.migrations", dbs.migrationsTable())
+	})
+
+	t.Run("should label metrics", func(t *testing.T) {
+		db, err := sql.Open(driverName, DefaultURL) // no connection established
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		root := r.Namespace("")
+		require.NotContains(t, root.setParams(), "search_path")
+		root.db = sqlx.NewDb(db, driverName)
+		billing.db = sqlx.NewDb(db, driverName)
+		t.Cleanup(func() { billing.db = nil })
+
+		registry := prometheus.NewPedanticRegistry()
+		require.NoError(t, registry.Register(billing.Collector()))
+		require.NoError(t, registry.Register(root.Collector()))
+
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		require.NotEmpty(t, families)
+
+		var namespaces []string
+		for _, metric := range families[0].GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "namespace" {
+					namespaces = append(namespaces, label.GetValue())
+				}
+			}
+		}
+		require.ElementsMatch(t, []string{"", "billing"}, namespaces)
+	})
+
+	t.Run("should reject invalid namespace", func(t *testing.T) {
+		require.ErrorIs(t, r.Namespace(strings.Repeat("x", 64)).Validate(), ErrInvalidConfig)
+	})
+}
+
+func TestSearchPath(t *testing.T) {
+	t.Run("should set the search path", func(t *testing.T) {
+		r := New(DefaultDBAlias,
+			WithLogger(zap.NewNop()),
+			WithDefaultPoolOptions(WithSetClause("search_path", "public")),
+			WithDatabaseSettings(DefaultDBAlias, WithURL(DefaultURL), WithSearchPath("billing", "public")),
+		)
+		require.NoError(t, r.Validate())
+		require.Equal(t, `"billing", "public"`, r.setParams()["search_path"])
+
+		t.Run("namespace should take precedence", func(t *testing.T) {
+			require.Equal(t, `"orders", public`, r.Namespace("orders").setParams()["search_path"])
+		})
+	})
+
+	t.Run("SET parameters of the database should take precedence", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithSearchPath("billing"),
+			WithDBSetClause("search_path", "legacy"),
+		})
+		require.Equal(t, "legacy", dbs.setParams()["search_path"])
+	})
+
+	t.Run("should not accept empty schemas", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithURL(DefaultURL), WithSearchPath("billing", " ")})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should load the schema from config", func(t *testing.T) {
+		for _, schema := range []string{"billing", "[billing]"} {
+			cfg := viper.New()
+			cfg.SetConfigType("yaml")
+			require.NoError(t, cfg.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    default:
+      url: 'postgresql://localhost:5432/testdb'
+      schema: `+schema+`
+`)))
+
+			s, err := makeSettingsFromViper(cfg, zap.NewNop())
+			require.NoError(t, err)
+			require.Equal(t, `"billing"`, s.DBSettingsFor(DefaultDBAlias).setParams()["search_path"])
+		}
+	})
+}
@@ -1,9 +1,15 @@
 package pgrepo
 
 import (
+	"context"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -19,7 +25,7 @@ type (
 )
 
 func settingsFromOptions(opts []Option) settings {
-	s := defaultSettings
+	s := defaultSettings.clone()
 	for _, apply := range opts {
 		apply(&s)
 	}
@@ -28,7 +34,7 @@ func settingsFromOptions(opts []Option) settings {
 }
 
 func databaseSettingsFromOptions(opts []DBOption) databaseSettings {
-	dbs := defaultSettings.Databases[DefaultDBAlias]
+	dbs := defaultSettings.Databases[DefaultDBAlias].clone()
 	for _, apply := range opts {
 		apply(&dbs)
 	}
@@ -37,12 +43,12 @@ func databaseSettingsFromOptions(opts []DBOption) databaseSettings {
 }
 
 func poolSettingsFromOptions(opts []PoolOption) *poolSettings {
-	ps := *defaultSettings.PGConfig
+	ps := defaultSettings.PGConfig.clone()
 	for _, apply := range opts {
-		apply(&ps)
+		apply(ps)
 	}
 
-	return &ps
+	return ps
 }
 
 // SettingsFromViper builds settings from a *viper.Viper configuration registry.
@@ -55,9 +61,7 @@ func SettingsFromViper(cfg *viper.Viper, opts ...Option) (Option, error) {
 	s, err := makeSettingsFromViper(cfg, s.logger)
 
 	return func(o *settings) {
-		app, logger := o.app, o.logger
-		*o = s
-		o.app, o.logger = app, logger
+		o.loadViper(s)
 	}, err
 }
 
@@ -75,16 +79,134 @@ func WithName(app string) Option {
 	}
 }
 
+// WithLogFieldsFromContext injects a function to extract log fields from the context (e.g. tenant, request ID).
+//
+// These fields are added to all logs produced by the pgx driver and by this package.
+func WithLogFieldsFromContext(fn func(context.Context) []zap.Field) Option {
+	return func(o *settings) {
+		o.logFields = fn
+	}
+}
+
+// WithDryRun enables a dry-run mode for destructive helpers such as DropDB.
+//
+// In dry-run mode, destructive statements are only logged and simulated results are returned.
+func WithDryRun() Option {
+	return func(o *settings) {
+		o.dryRun = true
+	}
+}
+
+// WithTracerProvider injects an OpenTelemetry tracer provider, used when the "otel" trace provider is enabled.
+//
+// The default is the global tracer provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *settings) {
+		o.tracerProvider = tp
+	}
+}
+
+// WithConnConfigHook adds a hook to customize the pgx driver configuration, e.g. to set fields which are not
+// modelled by the settings, such as DialFunc or LookupFunc.
+//
+// Use WithAfterConnect rather than overriding AfterConnect, which applies the SET parameters.
+//
+// Hooks are called in order, after the configuration is built from the settings, for the master database
+// and its replicas. They are called again when the configuration is reloaded.
+func WithConnConfigHook(hook func(*pgx.ConnConfig)) Option {
+	return func(o *settings) {
+		o.connConfigHooks = append(o.connConfigHooks[:len(o.connConfigHooks):len(o.connConfigHooks)], hook)
+	}
+}
+
+// WithAfterConnect adds a hook called on every new connection, e.g. to register types, create temporary objects
+// or prepare statements.
+//
+// Hooks are called in order, after the SET parameters are applied. The connection is discarded if a hook fails.
+func WithAfterConnect(hook func(context.Context, *pgconn.PgConn) error) Option {
+	return func(o *settings) {
+		o.afterConnect = append(o.afterConnect[:len(o.afterConnect):len(o.afterConnect)], hook)
+	}
+}
+
+func chainAfterConnect(hooks []func(context.Context, *pgconn.PgConn) error) func(context.Context, *pgconn.PgConn) error {
+	return func(ctx context.Context, conn *pgconn.PgConn) error {
+		for _, hook := range hooks {
+			if err := hook(ctx, conn); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// WithMetricsRegistry registers the connection pool metrics of repositories on a prometheus registry.
+//
+// Metrics are registered when the repository is started and unregistered when it is stopped.
+//
+// See Repository.Collector.
+func WithMetricsRegistry(registerer prometheus.Registerer) Option {
+	return func(o *settings) {
+		o.registerer = registerer
+	}
+}
+
+// WithMigrationsTable sets the table used to track applied migrations, optionally qualified by its schema.
+//
+// The default is "schema_migrations".
+func WithMigrationsTable(table string) Option {
+	return func(o *settings) {
+		o.migrations = table
+	}
+}
+
+// WithCreateOptions sets the options used by CreateDB and EnsureDB when creating a database.
+func WithCreateOptions(opts ...CreateOption) Option {
+	return func(o *settings) {
+		for _, apply := range opts {
+			apply(&o.create)
+		}
+	}
+}
+
+// WithForceDrop terminates active connections to a database when dropping it with DropDB,
+// so a lingering connection pool does not block the drop.
+func WithForceDrop() Option {
+	return func(o *settings) {
+		o.forceDrop = true
+	}
+}
+
+// WithDBNameValidator adds a validation hook for database names passed to CreateDB, EnsureDB and DropDB
+// (e.g. to enforce a naming convention).
+func WithDBNameValidator(fn func(string) error) Option {
+	return func(o *settings) {
+		o.dbNameValidator = fn
+	}
+}
+
 // WithViper is the same as SettingsFromViper, but it doesn't check for errors.
 func WithViper(cfg *viper.Viper) Option {
 	return func(o *settings) {
 		s, _ := makeSettingsFromViper(cfg, o.logger)
-		app, logger := o.app, o.logger
-		*o = s
-		o.app, o.logger = app, logger
+		o.loadViper(s)
 	}
 }
 
+// loadViper replaces settings by the settings loaded from a viper configuration, preserving runtime settings.
+//
+// When reloading, the settings from the reloaded configuration are used instead.
+func (o *settings) loadViper(s settings) {
+	rt := o.runtimeSettings
+	if rt.reloaded != nil {
+		s = rt.reloaded.clone()
+	}
+
+	*o = s
+	o.runtimeSettings = rt
+}
+
 func WithDatabaseSettings(alias string, opts ...DBOption) Option {
 	return func(o *settings) {
 		dbs := databaseSettingsFromOptions(opts)
@@ -93,6 +215,25 @@ func WithDatabaseSettings(alias string, opts ...DBOption) Option {
 	}
 }
 
+// WithDatabaseOptions applies options to the settings of a database alias, e.g. declared by WithViper or
+// WithDatabaseSettings.
+//
+// Unlike WithDatabaseSettings, the other settings of the database are preserved.
+func WithDatabaseOptions(alias string, opts ...DBOption) Option {
+	return func(o *settings) {
+		dbs, ok := o.Databases[alias]
+		if !ok {
+			dbs = defaultSettings.Databases[DefaultDBAlias]
+		}
+
+		for _, apply := range opts {
+			apply(&dbs)
+		}
+
+		o.Databases[alias] = dbs
+	}
+}
+
 func WithDefaultPoolOptions(opts ...PoolOption) Option {
 	return func(o *settings) {
 		ps := poolSettingsFromOptions(opts)
@@ -155,12 +296,53 @@ func WithLogLevel(level string) PoolOption {
 	}
 }
 
+// WithStatementClassLogLevel logs statements of a given class (read, write, function, role, ddl or misc)
+// at a specific level, e.g. to log DDL statements at info while keeping reads at debug.
+func WithStatementClassLogLevel(class, level string) PoolOption {
+	return func(o *poolSettings) {
+		classes := make(map[string]string, len(o.Log.Classes)+1)
+		for k, v := range o.Log.Classes {
+			classes[k] = v
+		}
+		classes[class] = level
+		o.Log.Classes = classes
+	}
+}
+
+// WithSlowQueryThreshold logs queries slower than the threshold as warnings, with their duration, the number
+// of rows and a truncated statement, independently of the log level of the driver.
+//
+// A zero threshold disables slow query logging (the default).
+func WithSlowQueryThreshold(threshold time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Log.SlowQueryThreshold = threshold
+	}
+}
+
 func WithTracing(enabled bool) PoolOption {
 	return func(o *poolSettings) {
 		o.Trace.Enabled = enabled
 	}
 }
 
+// WithTraceProvider selects the trace provider(s) used when tracing is enabled: "opencensus" (the default), "otel"
+// or a registered provider, e.g. "datadog" (see RegisterTraceProvider).
+func WithTraceProvider(providers ...string) PoolOption {
+	return func(o *poolSettings) {
+		o.Trace.Provider = strings.Join(providers, ",")
+	}
+}
+
+// WithTraceStatsInterval sets the sampling interval of the pool statistics, when tracing with opencensus.
+//
+// The statistics of the master pool are recorded for the views of ocsql (see ocsql.RegisterAllViews).
+// A negative interval disables sampling.
+func WithTraceStatsInterval(interval time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Trace.StatsInterval = interval
+	}
+}
+
 func WithPingTimeout(timeout time.Duration) PoolOption {
 	return func(o *poolSettings) {
 		o.PingTimeout = timeout
@@ -175,3 +357,111 @@ func WithSetClause(param, value string) PoolOption {
 		o.Set[param] = value
 	}
 }
+
+// WithDBSetClause executes a SET command on every new connection to this database.
+//
+// Parameters set for a database take precedence over the parameters of the pool settings (see WithSetClause).
+func WithDBSetClause(param, value string) DBOption {
+	return func(o *databaseSettings) {
+		if o.Set == nil {
+			o.Set = make(map[string]string)
+		}
+		o.Set[param] = value
+	}
+}
+
+// WithSearchPath sets the search path of the connections to this database, e.g. to the schema of a service
+// sharing a database with other services.
+//
+// Unqualified names are resolved in the schemas, in this order. Include "public" explicitly if needed.
+func WithSearchPath(schemas ...string) DBOption {
+	return func(o *databaseSettings) {
+		o.Schema = schemas
+	}
+}
+
+// WithHistory declares tables to be versioned with an append-only history table.
+//
+// See Repository.InstallHistory.
+func WithHistory(tables ...string) DBOption {
+	return func(o *databaseSettings) {
+		o.History.Tables = append(o.History.Tables, tables...)
+	}
+}
+
+// WithTag adds a key/value tag to a database, for cost attribution.
+//
+// Tags are propagated as a suffix to the application_name, as trace attributes and as log fields.
+func WithTag(key, value string) DBOption {
+	return func(o *databaseSettings) {
+		if o.Tags == nil {
+			o.Tags = make(map[string]string)
+		}
+		o.Tags[key] = value
+	}
+}
+
+// WithWaitMonitor enables the monitoring of the time spent by callers waiting for a connection from the pool.
+//
+// A warning is logged whenever the cumulated wait time over a sampling interval exceeds the threshold.
+func WithWaitMonitor(interval, threshold time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.WaitMonitor.Enabled = true
+		o.WaitMonitor.Interval = interval
+		o.WaitMonitor.Threshold = threshold
+	}
+}
+
+// WithHealthCheckQuery sets a query to run for health checks, instead of a ping.
+func WithHealthCheckQuery(query string) PoolOption {
+	return func(o *poolSettings) {
+		o.HealthCheckQuery = query
+	}
+}
+
+// WithPartition splits MaxOpenConns into a budget for reads ("readShare") and a budget for writes.
+//
+// Acquisitions wait at most acquireTimeout for the budget of their class. A zero timeout waits
+// for as long as the context allows.
+func WithPartition(readShare float64, acquireTimeout time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.Partition.Enabled = true
+		o.Partition.ReadShare = readShare
+		o.Partition.AcquireTimeout = acquireTimeout
+	}
+}
+
+// WithResetPolicy sets the policy applied to reset the session state of a pooled connection before it is reused.
+//
+// Supported policies are ResetPolicyNone (the default), ResetPolicyResetAll and ResetPolicyDiscardAll.
+func WithResetPolicy(policy string) PoolOption {
+	return func(o *poolSettings) {
+		o.ResetPolicy = policy
+	}
+}
+
+// WithParallelShare sets the maximum share of the pool (MaxOpenConns) that may be used by parallel queries.
+//
+// See Repository.Parallel.
+func WithParallelShare(share float64) PoolOption {
+	return func(o *poolSettings) {
+		o.ParallelShare = share
+	}
+}
+
+// WithReplicas declares read-only replicas for a database.
+//
+// Replicas are connected with the same credentials as the master instance.
+func WithReplicas(urls ...string) DBOption {
+	return func(o *databaseSettings) {
+		o.Replicas = append(o.Replicas, urls...)
+	}
+}
+
+// WithReplicaReadOnly sets default_transaction_read_only on replica connections, so writes sent
+// to a replica fail fast, even if a replica URL actually points to a primary. This is enabled by default.
+func WithReplicaReadOnly(enabled bool) PoolOption {
+	return func(o *poolSettings) {
+		o.ReplicaReadOnly = &enabled
+	}
+}
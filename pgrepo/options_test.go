@@ -0,0 +1,95 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWithConnConfigHook(t *testing.T) {
+	lookup := func(context.Context, string) ([]string, error) { return []string{"10.0.0.1"}, nil }
+	var calls []string
+
+	s := settingsFromOptions([]Option{
+		WithConnConfigHook(func(cfg *pgx.ConnConfig) {
+			calls = append(calls, "lookup")
+			cfg.LookupFunc = lookup
+		}),
+		WithConnConfigHook(func(cfg *pgx.ConnConfig) {
+			calls = append(calls, "runtime params")
+			cfg.RuntimeParams["application_name"] = "overridden"
+		}),
+	})
+	dbs := s.DBSettingsFor(DefaultDBAlias)
+
+	dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "app")
+	require.NotNil(t, dcfg)
+	require.Equal(t, []string{"lookup", "runtime params"}, calls)
+	require.Equal(t, "overridden", dcfg.RuntimeParams["application_name"])
+
+	addrs, err := dcfg.LookupFunc(context.Background(), "localhost")
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", net.ParseIP(addrs[0]).String())
+}
+
+func TestWithAfterConnect(t *testing.T) {
+	var calls []string
+	failure := errors.New("boom")
+
+	s := settingsFromOptions([]Option{
+		WithAfterConnect(func(context.Context, *pgconn.PgConn) error {
+			calls = append(calls, "register types")
+
+			return nil
+		}),
+		WithAfterConnect(func(context.Context, *pgconn.PgConn) error {
+			calls = append(calls, "prepare")
+
+			return failure
+		}),
+		WithAfterConnect(func(context.Context, *pgconn.PgConn) error {
+			calls = append(calls, "never called")
+
+			return nil
+		}),
+	})
+	dbs := s.DBSettingsFor(DefaultDBAlias)
+
+	dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "app")
+	require.NotNil(t, dcfg)
+	require.NotNil(t, dcfg.AfterConnect)
+
+	require.ErrorIs(t, dcfg.AfterConnect(context.Background(), nil), failure)
+	require.Equal(t, []string{"register types", "prepare"}, calls)
+}
+
+func TestWithDatabaseOptions(t *testing.T) {
+	t.Run("should preserve the other settings of the database", func(t *testing.T) {
+		s := settingsFromOptions([]Option{
+			WithDatabaseSettings(DefaultDBAlias, WithUser("app"), WithTag("team", "orders")),
+			WithDatabaseOptions(DefaultDBAlias, WithURL("postgres://localhost:5433/orders")),
+		})
+
+		dbs := s.DBSettingsFor(DefaultDBAlias)
+		require.Equal(t, "postgres://localhost:5433/orders", dbs.URL)
+		require.Equal(t, "app", dbs.User)
+		require.Equal(t, "orders", dbs.Tags["team"])
+	})
+
+	t.Run("should apply to an undeclared database", func(t *testing.T) {
+		s := settingsFromOptions([]Option{
+			WithDatabaseOptions("reporting", WithUser("reader")),
+		})
+
+		dbs := s.DBSettingsFor("reporting")
+		require.Equal(t, DefaultURL, dbs.URL)
+		require.Equal(t, "reader", dbs.User)
+	})
+}
@@ -0,0 +1,198 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceProviderOpenCensus instruments the sql driver with opencensus (the default).
+	TraceProviderOpenCensus = "opencensus"
+
+	// TraceProviderOTel instruments the pgx driver with OpenTelemetry.
+	TraceProviderOTel = "otel"
+
+	otelInstrumentationName = "github.com/fredbi/pgxutils/pgrepo"
+)
+
+type otelSpanKey struct{}
+
+// otelTracer is a pgx tracer emitting OpenTelemetry spans, with the standard semantic conventions for databases.
+//
+// Spans are children of the span found in the context of the query, if any.
+type otelTracer struct {
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+var (
+	_ pgx.QueryTracer    = &otelTracer{}
+	_ pgx.BatchTracer    = &otelTracer{}
+	_ pgx.CopyFromTracer = &otelTracer{}
+)
+
+func newOTelTracer(tp trace.TracerProvider, cfg *pgx.ConnConfig, tags map[string]string) *otelTracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBName(cfg.Database),
+		semconv.DBUser(cfg.User),
+		semconv.ServerAddress(cfg.Host),
+		semconv.ServerPort(int(cfg.Port)),
+	}
+	for k, v := range tags {
+		attrs = append(attrs, attribute.String("db.tag."+k, v))
+	}
+
+	return &otelTracer{
+		tracer: tp.Tracer(otelInstrumentationName),
+		attrs:  attrs,
+	}
+}
+
+func (t *otelTracer) start(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	ctx, span := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.attrs...),
+		trace.WithAttributes(attrs...),
+	)
+
+	return context.WithValue(ctx, otelSpanKey{}, span)
+}
+
+func (t *otelTracer) end(ctx context.Context, err error, attrs ...attribute.KeyValue) {
+	span, ok := ctx.Value(otelSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func (t *otelTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op := sqlOperation(data.SQL)
+
+	return t.start(ctx, spanName(op), semconv.DBStatement(data.SQL), semconv.DBOperation(op))
+}
+
+func (t *otelTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+func (t *otelTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	var size int
+	if data.Batch != nil {
+		size = data.Batch.Len()
+	}
+
+	return t.start(ctx, "batch", semconv.DBOperation("BATCH"), attribute.Int("db.batch.size", size))
+}
+
+func (t *otelTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	span, ok := ctx.Value(otelSpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.AddEvent("query", trace.WithAttributes(
+		semconv.DBStatement(data.SQL),
+		attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()),
+	))
+	if data.Err != nil {
+		span.RecordError(data.Err)
+	}
+}
+
+func (t *otelTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, data.Err)
+}
+
+func (t *otelTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return t.start(ctx, spanName("COPY"),
+		semconv.DBOperation("COPY"),
+		semconv.DBSQLTableKey.String(data.TableName.Sanitize()),
+	)
+}
+
+func (t *otelTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	t.end(ctx, data.Err, attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+}
+
+func spanName(op string) string {
+	if op == "" {
+		return "postgresql"
+	}
+
+	return "postgresql." + strings.ToLower(op)
+}
+
+// usesTraceProvider tells if a given trace provider is enabled.
+//
+// The trace provider setting may be a comma-separated list of providers. It defaults to opencensus.
+func (t traceSettings) usesTraceProvider(provider string) bool {
+	if !t.Enabled {
+		return false
+	}
+
+	if t.Provider == "" {
+		return provider == TraceProviderOpenCensus
+	}
+
+	for _, p := range strings.Split(t.Provider, ",") {
+		if strings.TrimSpace(strings.ToLower(p)) == provider {
+			return true
+		}
+	}
+
+	return false
+}
+
+// statsInterval returns the sampling interval of the pool statistics recorded by opencensus, or 0 if disabled.
+func (t traceSettings) statsInterval() time.Duration {
+	if !t.usesTraceProvider(TraceProviderOpenCensus) || t.StatsInterval < 0 {
+		return 0
+	}
+
+	if t.StatsInterval == 0 {
+		return defaultSettings.PGConfig.Trace.StatsInterval
+	}
+
+	return t.StatsInterval
+}
+
+func (t traceSettings) validate() error {
+	if t.Provider == "" {
+		return nil
+	}
+
+	for _, p := range strings.Split(t.Provider, ",") {
+		name := strings.TrimSpace(strings.ToLower(p))
+		switch name {
+		case TraceProviderOpenCensus, TraceProviderOTel:
+		default:
+			if _, ok := registeredTraceProvider(name); !ok {
+				return fmt.Errorf("unsupported trace provider %q (is the package providing it imported?): %w", p, ErrInvalidConfig)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	_ pgx.QueryTracer    = &otelMetrics{}
+	_ pgx.BatchTracer    = &otelMetrics{}
+	_ pgx.CopyFromTracer = &otelMetrics{}
+)
+
+// WithMeterProvider emits OpenTelemetry metrics with a meter provider: the duration of statements,
+// the statistics of the connection pools and errors by SQLSTATE class.
+//
+// Metrics are labeled by database alias and by operation (e.g. SELECT, INSERT).
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(o *settings) {
+		o.meterProvider = mp
+	}
+}
+
+type (
+	// otelMetrics is a pgx tracer which records OpenTelemetry metrics about statements.
+	otelMetrics struct {
+		attrs    []attribute.KeyValue
+		duration metric.Float64Histogram
+		errors   metric.Int64Counter
+	}
+
+	otelMetricsKey struct{}
+
+	// otelMetricsQuery is the statement being measured.
+	otelMetricsQuery struct {
+		operation string
+		start     time.Time
+	}
+)
+
+func newOTelMetrics(mp metric.MeterProvider, attrs []attribute.KeyValue) (*otelMetrics, error) {
+	meter := mp.Meter(otelInstrumentationName)
+
+	duration, err := meter.Float64Histogram("db.client.operation.duration",
+		metric.WithDescription("The duration of statements."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	errCount, err := meter.Int64Counter("db.client.errors",
+		metric.WithDescription("The number of failed statements, by SQLSTATE class."),
+		metric.WithUnit("{error}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &otelMetrics{
+		attrs:    attrs,
+		duration: duration,
+		errors:   errCount,
+	}, nil
+}
+
+// otelMetricsAttributes are the attributes of the OpenTelemetry metrics of a repository.
+func (r *Repository) otelMetricsAttributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.alias", r.alias),
+	}
+	if r.app != "" {
+		attrs = append(attrs, attribute.String("app", r.app))
+	}
+	if r.namespace != "" {
+		attrs = append(attrs, attribute.String("namespace", r.namespace))
+	}
+	for _, k := range r.sortedTagKeys() {
+		attrs = append(attrs, attribute.String("db.tag."+k, r.Tags[k]))
+	}
+
+	return attrs
+}
+
+// registerPoolMetrics observes the statistics of the connection pools of a repository with a meter provider.
+//
+// It returns a function to stop observing the pools.
+func (r *Repository) registerPoolMetrics(mp metric.MeterProvider) (func(), error) {
+	meter := mp.Meter(otelInstrumentationName)
+
+	usage, err := meter.Int64ObservableGauge("db.client.connections.usage",
+		metric.WithDescription("The number of connections, by state (idle or used)."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	maxConns, err := meter.Int64ObservableGauge("db.client.connections.max",
+		metric.WithDescription("The maximum number of open connections."),
+		metric.WithUnit("{connection}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	waitCount, err := meter.Int64ObservableCounter("db.client.connections.wait_count",
+		metric.WithDescription("The total number of connections waited for."),
+		metric.WithUnit("{wait}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	waitTime, err := meter.Float64ObservableCounter("db.client.connections.wait_time",
+		metric.WithDescription("The total time blocked waiting for a new connection."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	baseAttrs := r.otelMetricsAttributes()
+	observe := func(o metric.Observer, role string, stats sql.DBStats) {
+		attrs := append(baseAttrs[:len(baseAttrs):len(baseAttrs)], attribute.String("role", role))
+		o.ObserveInt64(usage, int64(stats.Idle), metric.WithAttributes(append(attrs, attribute.String("state", "idle"))...))
+		o.ObserveInt64(usage, int64(stats.InUse), metric.WithAttributes(append(attrs, attribute.String("state", "used"))...))
+		o.ObserveInt64(maxConns, int64(stats.MaxOpenConnections), metric.WithAttributes(attrs...))
+		o.ObserveInt64(waitCount, stats.WaitCount, metric.WithAttributes(attrs...))
+		o.ObserveFloat64(waitTime, stats.WaitDuration.Seconds(), metric.WithAttributes(attrs...))
+	}
+
+	registration, err := meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		if r.db != nil {
+			observe(o, "master", r.db.Stats())
+		}
+
+		if replicaStats, ok := r.replicas.stats(); ok {
+			observe(o, "replica", replicaStats)
+		}
+
+		return nil
+	}, usage, maxConns, waitCount, waitTime)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = registration.Unregister()
+	}, nil
+}
+
+func (m *otelMetrics) start(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, otelMetricsKey{}, otelMetricsQuery{operation: operation, start: time.Now()})
+}
+
+func (m *otelMetrics) end(ctx context.Context, err error) {
+	q, ok := ctx.Value(otelMetricsKey{}).(otelMetricsQuery)
+	if !ok {
+		return
+	}
+
+	attrs := append(m.attrs[:len(m.attrs):len(m.attrs)], attribute.String("db.operation", q.operation))
+	m.duration.Record(ctx, time.Since(q.start).Seconds(), metric.WithAttributes(attrs...))
+
+	if err != nil {
+		m.errors.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("db.sqlstate_class", sqlStateClass(err)))...))
+	}
+}
+
+// sqlStateClass returns the class of the SQLSTATE code of an error, e.g. "23" for integrity constraint violations.
+//
+// Errors which do not come from the server are of class "unknown".
+func sqlStateClass(err error) string {
+	if state := SQLState(err); len(state) >= 2 {
+		return state[:2]
+	}
+
+	return "unknown"
+}
+
+func (m *otelMetrics) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return m.start(ctx, sqlOperation(data.SQL))
+}
+
+func (m *otelMetrics) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	m.end(ctx, data.Err)
+}
+
+func (m *otelMetrics) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return m.start(ctx, "BATCH")
+}
+
+func (m *otelMetrics) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (m *otelMetrics) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	m.end(ctx, data.Err)
+}
+
+func (m *otelMetrics) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceCopyFromStartData) context.Context {
+	return m.start(ctx, "COPY")
+}
+
+func (m *otelMetrics) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	m.end(ctx, data.Err)
+}
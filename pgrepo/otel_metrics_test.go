@@ -0,0 +1,91 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestOTelMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	r := New("orders", WithLogger(zap.NewNop()), WithName("billing"), WithMeterProvider(mp))
+	db := sqlx.NewDb(sql.OpenDB(&fakeReplica{}), driverName)
+	t.Cleanup(func() { _ = db.Close() })
+	r.db = db
+
+	m, err := newOTelMetrics(mp, r.otelMetricsAttributes())
+	require.NoError(t, err)
+	unregister, err := r.registerPoolMetrics(mp)
+	require.NoError(t, err)
+	defer unregister()
+
+	ctx := context.Background()
+	qctx := m.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "SELECT * FROM users"})
+	m.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+	qctx = m.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: "INSERT INTO users VALUES (1)"})
+	m.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: &pgconn.PgError{Code: "23505"}})
+	qctx = m.TraceCopyFromStart(ctx, nil, pgx.TraceCopyFromStartData{})
+	m.TraceCopyFromEnd(qctx, nil, pgx.TraceCopyFromEndData{Err: errors.New("broken pipe")})
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric.Data
+	}
+
+	t.Run("should record the duration of statements by operation", func(t *testing.T) {
+		histogram, ok := metrics["db.client.operation.duration"].(metricdata.Histogram[float64])
+		require.True(t, ok)
+		require.Len(t, histogram.DataPoints, 3)
+
+		operations := make(map[string]uint64)
+		for _, point := range histogram.DataPoints {
+			alias, _ := point.Attributes.Value("db.alias")
+			require.Equal(t, "orders", alias.AsString())
+			app, _ := point.Attributes.Value("app")
+			require.Equal(t, "billing", app.AsString())
+
+			op, _ := point.Attributes.Value("db.operation")
+			operations[op.AsString()] = point.Count
+		}
+		require.Equal(t, map[string]uint64{"SELECT": 1, "INSERT": 1, "COPY": 1}, operations)
+	})
+
+	t.Run("should count errors by SQLSTATE class", func(t *testing.T) {
+		counter, ok := metrics["db.client.errors"].(metricdata.Sum[int64])
+		require.True(t, ok)
+		require.Len(t, counter.DataPoints, 2)
+
+		classes := make(map[string]int64)
+		for _, point := range counter.DataPoints {
+			class, _ := point.Attributes.Value(attribute.Key("db.sqlstate_class"))
+			classes[class.AsString()] = point.Value
+		}
+		require.Equal(t, map[string]int64{"23": 1, "unknown": 1}, classes)
+	})
+
+	t.Run("should observe the connection pool", func(t *testing.T) {
+		usage, ok := metrics["db.client.connections.usage"].(metricdata.Gauge[int64])
+		require.True(t, ok)
+		require.Len(t, usage.DataPoints, 2)
+
+		for _, name := range []string{"db.client.connections.max", "db.client.connections.wait_count", "db.client.connections.wait_time"} {
+			require.Contains(t, metrics, name)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOTelTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cfg, err := pgx.ParseConfig(DefaultURL)
+	require.NoError(t, err)
+
+	tracer := composeTracers(&captureTracer{}, newOTelTracer(tp, cfg, map[string]string{"team": "payments"}))
+
+	parentCtx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	ctx := tracer.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: "/* hint */ select * from users"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 3")})
+
+	ctx = tracer.TraceQueryStart(parentCtx, nil, pgx.TraceQueryStartData{SQL: "DELETE FROM users"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: errors.New("boom")})
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	selectSpan := spans[0]
+	require.Equal(t, "postgresql.select", selectSpan.Name())
+	require.Equal(t, parent.SpanContext().SpanID(), selectSpan.Parent().SpanID())
+	attrs := make(map[string]interface{})
+	for _, attr := range selectSpan.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	require.Equal(t, "postgresql", attrs["db.system"])
+	require.Equal(t, "testdb", attrs["db.name"])
+	require.Equal(t, "SELECT", attrs["db.operation"])
+	require.Equal(t, "payments", attrs["db.tag.team"])
+	require.Equal(t, int64(3), attrs["db.rows_affected"])
+
+	deleteSpan := spans[1]
+	require.Equal(t, "postgresql.delete", deleteSpan.Name())
+	require.Equal(t, codes.Error, deleteSpan.Status().Code)
+}
+
+func TestTraceProviders(t *testing.T) {
+	require.True(t, traceSettings{Enabled: true}.usesTraceProvider(TraceProviderOpenCensus))
+	require.False(t, traceSettings{Enabled: true}.usesTraceProvider(TraceProviderOTel))
+	require.False(t, traceSettings{Provider: TraceProviderOTel}.usesTraceProvider(TraceProviderOTel))
+
+	both := traceSettings{Enabled: true, Provider: "opencensus, OTel"}
+	require.True(t, both.usesTraceProvider(TraceProviderOpenCensus))
+	require.True(t, both.usesTraceProvider(TraceProviderOTel))
+	require.NoError(t, both.validate())
+
+	require.ErrorIs(t, traceSettings{Provider: "zipkin"}.validate(), ErrInvalidConfig)
+}
+
+func TestTraceStatsInterval(t *testing.T) {
+	require.Equal(t, 10*time.Second, defaultSettings.PGConfig.Trace.StatsInterval)
+	require.Zero(t, defaultSettings.PGConfig.Trace.statsInterval(), "opencensus is disabled by default")
+
+	require.Equal(t, 10*time.Second, traceSettings{Enabled: true}.statsInterval())
+	require.Zero(t, traceSettings{Provider: TraceProviderOTel, StatsInterval: time.Second}.statsInterval())
+
+	ps := poolSettingsFromOptions([]PoolOption{WithTracing(true), WithTraceStatsInterval(time.Minute)})
+	require.Equal(t, time.Minute, ps.Trace.statsInterval())
+
+	ps = poolSettingsFromOptions([]PoolOption{WithTracing(true), WithTraceStatsInterval(-1)})
+	require.Zero(t, ps.Trace.statsInterval())
+}
+
+func TestSQLOperation(t *testing.T) {
+	require.Equal(t, "SELECT", sqlOperation("select 1"))
+	require.Equal(t, "INSERT", sqlOperation("  -- comment\n /* block */ insert into t values(1)"))
+	require.Equal(t, "WITH", sqlOperation("WITH x AS (SELECT 1) SELECT * FROM x"))
+	require.Equal(t, "", sqlOperation("-- only a comment"))
+}
+
+func TestStatementClass(t *testing.T) {
+	for query, class := range map[string]string{
+		"select 1":                                StatementClassRead,
+		"WITH x AS (SELECT 1) SELECT * FROM x":    StatementClassRead,
+		"/* hint */ insert into users values (1)": StatementClassWrite,
+		"TRUNCATE users":                          StatementClassWrite,
+		"CALL refresh()":                          StatementClassFunction,
+		"GRANT SELECT ON users TO app":            StatementClassRole,
+		"create role app":                         StatementClassRole,
+		"ALTER USER app WITH PASSWORD 'x'":        StatementClassRole,
+		"CREATE TABLE users (id int)":             StatementClassDDL,
+		"drop index users_idx":                    StatementClassDDL,
+		"COMMENT ON TABLE users IS 'x'":           StatementClassDDL,
+		"SET search_path = app":                   StatementClassMisc,
+		"":                                        StatementClassMisc,
+	} {
+		require.Equalf(t, class, statementClass(query), "unexpected class for %q", query)
+	}
+}
@@ -0,0 +1,90 @@
+package pgrepo
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// Group runs independent queries concurrently, with a bounded concurrency.
+//
+// The first error returned cancels the context passed to all other queries in the group.
+type Group struct {
+	g     *errgroup.Group
+	ctx   context.Context
+	db    *sqlx.DB
+	slots *semaphore.Weighted
+}
+
+// Parallel returns a Group to fan-out queries concurrently.
+//
+// The concurrency is limited to n. Across all groups of the repository, the queries run concurrently
+// never exceed the share of the pool configured with "parallelShare" (the default is half of MaxOpenConns,
+// as configured when the repository starts). This prevents fan-out code from starving the pool.
+func (r *Repository) Parallel(ctx context.Context, n int) *Group {
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(r.parallelLimit(n))
+
+	return &Group{
+		g:     g,
+		ctx:   gctx,
+		db:    r.db,
+		slots: r.parallel,
+	}
+}
+
+// Go runs a query function in a new goroutine, blocking as long as the concurrency limit is reached.
+func (g *Group) Go(fn func(context.Context, *sqlx.DB) error) {
+	g.g.Go(func() error {
+		if g.db == nil {
+			return ErrDBNotInitialized
+		}
+
+		if g.slots != nil {
+			if err := g.slots.Acquire(g.ctx, 1); err != nil {
+				return err
+			}
+			defer g.slots.Release(1)
+		}
+
+		return fn(g.ctx, g.db)
+	})
+}
+
+// Wait blocks until all query functions have returned, then returns the first error, if any.
+func (g *Group) Wait() error {
+	return g.g.Wait()
+}
+
+func (r databaseSettings) parallelLimit(n int) int {
+	if n < 1 {
+		n = 1
+	}
+
+	if capacity := r.parallelCapacity(); capacity > 0 {
+		return min(n, capacity)
+	}
+
+	return n
+}
+
+// parallelCapacity is the number of connections which may be used by Parallel groups, or 0 when the pool is unbounded.
+func (r databaseSettings) parallelCapacity() int {
+	if r.PGConfig == nil || r.PGConfig.MaxOpenConns <= 0 {
+		return 0
+	}
+
+	share := r.PGConfig.ParallelShare
+	if share <= 0 || share > 1 {
+		share = defaultSettings.PGConfig.ParallelShare
+	}
+
+	maxAllowed := int(float64(r.PGConfig.MaxOpenConns) * share)
+	if maxAllowed < 1 {
+		maxAllowed = 1
+	}
+
+	return maxAllowed
+}
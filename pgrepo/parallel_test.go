@@ -0,0 +1,121 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/semaphore"
+)
+
+func TestParallel(t *testing.T) {
+	t.Run("limit is bounded by the pool share", func(t *testing.T) {
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(10)})}
+		require.Equal(t, 5, dbs.parallelLimit(20))
+		require.Equal(t, 3, dbs.parallelLimit(3))
+		require.Equal(t, 1, dbs.parallelLimit(0))
+
+		dbs.PGConfig.ParallelShare = 0.8
+		require.Equal(t, 8, dbs.parallelLimit(20))
+
+		dbs.PGConfig.MaxOpenConns = 0
+		require.Equal(t, 20, dbs.parallelLimit(20))
+	})
+
+	t.Run("concurrency is bounded", func(t *testing.T) {
+		db, err := sql.Open(driverName, DefaultURL) // no connection established
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		r := &Repository{
+			db:               sqlx.NewDb(db, driverName),
+			databaseSettings: databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(4)})},
+		}
+
+		var running, peak atomic.Int32
+		g := r.Parallel(context.Background(), 10)
+		for i := 0; i < 10; i++ {
+			g.Go(func(_ context.Context, _ *sqlx.DB) error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+
+				return nil
+			})
+		}
+
+		require.NoError(t, g.Wait())
+		require.LessOrEqual(t, peak.Load(), int32(2))
+	})
+
+	t.Run("concurrency is bounded across groups", func(t *testing.T) {
+		db, err := sql.Open(driverName, DefaultURL) // no connection established
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(4)})}
+		r := &Repository{
+			db:               sqlx.NewDb(db, driverName),
+			parallel:         semaphore.NewWeighted(int64(dbs.parallelCapacity())),
+			databaseSettings: dbs,
+		}
+
+		var running, peak atomic.Int32
+		groups := []*Group{r.Parallel(context.Background(), 2), r.Parallel(context.Background(), 2)}
+		for i := 0; i < 10; i++ {
+			groups[i%2].Go(func(_ context.Context, _ *sqlx.DB) error {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+
+				return nil
+			})
+		}
+
+		for _, g := range groups {
+			require.NoError(t, g.Wait())
+		}
+		require.LessOrEqual(t, peak.Load(), int32(2))
+	})
+
+	t.Run("first error cancels the group", func(t *testing.T) {
+		db, err := sql.Open(driverName, DefaultURL)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		errQuery := errors.New("query failed")
+		r := &Repository{db: sqlx.NewDb(db, driverName)}
+
+		g := r.Parallel(context.Background(), 2)
+		g.Go(func(_ context.Context, _ *sqlx.DB) error { return errQuery })
+		g.Go(func(ctx context.Context, _ *sqlx.DB) error {
+			<-ctx.Done()
+
+			return ctx.Err()
+		})
+		require.ErrorIs(t, g.Wait(), errQuery)
+	})
+
+	t.Run("uninitialized repository", func(t *testing.T) {
+		g := (&Repository{}).Parallel(context.Background(), 1)
+		g.Go(func(_ context.Context, _ *sqlx.DB) error { return nil })
+		require.ErrorIs(t, g.Wait(), ErrDBNotInitialized)
+	})
+}
@@ -0,0 +1,186 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrPoolBudgetExceeded is returned when no connection is available in the budget of a statement class.
+var ErrPoolBudgetExceeded = errors.New("connection budget exceeded")
+
+// StatementClass partitions the connection pool budget.
+type StatementClass string
+
+const (
+	// StatementRead is the class of read-only statements.
+	StatementRead StatementClass = "read"
+
+	// StatementWrite is the class of statements that modify data.
+	StatementWrite StatementClass = "write"
+)
+
+type (
+	// PartitionStats reports the usage of the connection budget of a statement class.
+	PartitionStats struct {
+		Class    StatementClass
+		Budget   int
+		InUse    int
+		Acquired int64 // total number of acquisitions
+		Queued   int64 // total number of acquisitions that had to wait for the budget
+		Rejected int64 // total number of acquisitions that gave up waiting
+	}
+
+	// partition is a client-side budget of connections for a class of statements.
+	partition struct {
+		class    StatementClass
+		slots    chan struct{}
+		acquired atomic.Int64
+		queued   atomic.Int64
+		rejected atomic.Int64
+	}
+
+	// partitionSet splits MaxOpenConns into read and write budgets.
+	partitionSet struct {
+		read    *partition
+		write   *partition
+		timeout time.Duration
+	}
+)
+
+// newPartitionSet builds read and write budgets from the pool settings.
+//
+// It returns nil if partitioning is disabled, or if the pool size is not bounded.
+func newPartitionSet(p *poolSettings) *partitionSet {
+	if p == nil || !p.Partition.Enabled || p.MaxOpenConns < 2 {
+		return nil
+	}
+
+	share := p.Partition.ReadShare
+	if share <= 0 || share >= 1 {
+		share = defaultSettings.PGConfig.Partition.ReadShare
+	}
+
+	readBudget := min(max(int(float64(p.MaxOpenConns)*share), 1), p.MaxOpenConns-1)
+
+	return &partitionSet{
+		read:    newPartition(StatementRead, readBudget),
+		write:   newPartition(StatementWrite, p.MaxOpenConns-readBudget),
+		timeout: p.Partition.AcquireTimeout,
+	}
+}
+
+func newPartition(class StatementClass, budget int) *partition {
+	return &partition{
+		class: class,
+		slots: make(chan struct{}, budget),
+	}
+}
+
+func (s *partitionSet) get(class StatementClass) *partition {
+	if class == StatementWrite {
+		return s.write
+	}
+
+	return s.read
+}
+
+// acquire a slot in the budget, waiting at most for the acquisition timeout, if any.
+func (p *partition) acquire(ctx context.Context, timeout time.Duration) error {
+	select {
+	case p.slots <- struct{}{}:
+		p.acquired.Add(1)
+
+		return nil
+	default:
+	}
+
+	p.queued.Add(1)
+	if timeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		p.acquired.Add(1)
+
+		return nil
+	case <-ctx.Done():
+		p.rejected.Add(1)
+
+		return fmt.Errorf("%w for %s statements (budget: %d): %w", ErrPoolBudgetExceeded, p.class, cap(p.slots), ctx.Err())
+	}
+}
+
+func (p *partition) release() {
+	<-p.slots
+}
+
+func (p *partition) stats() PartitionStats {
+	return PartitionStats{
+		Class:    p.class,
+		Budget:   cap(p.slots),
+		InUse:    len(p.slots),
+		Acquired: p.acquired.Load(),
+		Queued:   p.queued.Load(),
+		Rejected: p.rejected.Load(),
+	}
+}
+
+// Read runs a function issuing read statements, within the connection budget allotted to reads.
+//
+// When the pool is not partitioned (see WithPartition), the function is called immediately.
+//
+// The default deadline of reads applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) Read(ctx context.Context, fn func(context.Context, *sqlx.DB) error) error {
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationRead)
+	defer cancel()
+
+	return r.withClass(ctx, StatementRead, fn)
+}
+
+// Write runs a function issuing write statements, within the connection budget allotted to writes.
+//
+// When the pool is not partitioned (see WithPartition), the function is called immediately.
+//
+// The default deadline of writes applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) Write(ctx context.Context, fn func(context.Context, *sqlx.DB) error) error {
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationWrite)
+	defer cancel()
+
+	return r.withClass(ctx, StatementWrite, fn)
+}
+
+// PartitionStats returns the usage of the read and write connection budgets,
+// or nil if the pool is not partitioned.
+func (r *Repository) PartitionStats() []PartitionStats {
+	if r.partitions == nil {
+		return nil
+	}
+
+	return []PartitionStats{r.partitions.read.stats(), r.partitions.write.stats()}
+}
+
+func (r *Repository) withClass(ctx context.Context, class StatementClass, fn func(context.Context, *sqlx.DB) error) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	if r.partitions == nil {
+		return fn(ctx, r.db)
+	}
+
+	p := r.partitions.get(class)
+	if err := p.acquire(ctx, r.partitions.timeout); err != nil {
+		return err
+	}
+	defer p.release()
+
+	return fn(ctx, r.db)
+}
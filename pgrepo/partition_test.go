@@ -0,0 +1,77 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartition(t *testing.T) {
+	t.Run("budgets are split from MaxOpenConns", func(t *testing.T) {
+		require.Nil(t, newPartitionSet(poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(10)})))
+		require.Nil(t, newPartitionSet(poolSettingsFromOptions([]PoolOption{WithPartition(0.7, 0)})))
+
+		set := newPartitionSet(poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(10), WithPartition(0.7, 0)}))
+		require.NotNil(t, set)
+		require.Equal(t, 7, cap(set.read.slots))
+		require.Equal(t, 3, cap(set.write.slots))
+
+		set = newPartitionSet(poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(2), WithPartition(0.99, 0)}))
+		require.Equal(t, 1, cap(set.read.slots))
+		require.Equal(t, 1, cap(set.write.slots))
+	})
+
+	t.Run("writes cannot starve reads", func(t *testing.T) {
+		db, err := sql.Open(driverName, DefaultURL) // no connection established
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = db.Close() })
+
+		r := &Repository{
+			db:         sqlx.NewDb(db, driverName),
+			partitions: newPartitionSet(poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(4), WithPartition(0.5, 10*time.Millisecond)})),
+		}
+		ctx := context.Background()
+
+		blocked := make(chan struct{})
+		done := make(chan error)
+		for i := 0; i < 2; i++ {
+			go func() {
+				done <- r.Write(ctx, func(context.Context, *sqlx.DB) error {
+					<-blocked
+
+					return nil
+				})
+			}()
+		}
+
+		require.Eventually(t, func() bool {
+			return r.PartitionStats()[1].InUse == 2
+		}, time.Second, time.Millisecond)
+
+		// the write budget is exhausted
+		err = r.Write(ctx, func(context.Context, *sqlx.DB) error { return nil })
+		require.ErrorIs(t, err, ErrPoolBudgetExceeded)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// reads are still served
+		var called bool
+		require.NoError(t, r.Read(ctx, func(context.Context, *sqlx.DB) error {
+			called = true
+
+			return nil
+		}))
+		require.True(t, called)
+
+		close(blocked)
+		require.NoError(t, <-done)
+		require.NoError(t, <-done)
+
+		stats := r.PartitionStats()
+		require.Equal(t, PartitionStats{Class: StatementRead, Budget: 2, Acquired: 1}, stats[0])
+		require.Equal(t, PartitionStats{Class: StatementWrite, Budget: 2, Acquired: 2, Queued: 1, Rejected: 1}, stats[1])
+	})
+}
@@ -0,0 +1,133 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrPatroni is returned when the endpoints of a cluster cannot be retrieved from Patroni.
+var ErrPatroni = errors.New("patroni error")
+
+// PatroniConfig configures a resolver of endpoints from the REST API of Patroni.
+type PatroniConfig struct {
+	URLs       []string     // the addresses of the Patroni REST API, e.g. "http://pg-1:8008". Addresses are tried in turn
+	HTTPClient *http.Client // defaults to http.DefaultClient
+}
+
+// PatroniResolver discovers the primary and the replicas of a cluster managed by Patroni.
+//
+// The leader is the primary. Running replicas are reported, except those tagged with "noloadbalance".
+type PatroniResolver struct {
+	cfg PatroniConfig
+}
+
+// patroniCluster is the part of the response of the "/cluster" endpoint of the Patroni REST API about members.
+type patroniCluster struct {
+	Members []struct {
+		Name  string                 `json:"name"`
+		Role  string                 `json:"role"`
+		State string                 `json:"state"`
+		Host  string                 `json:"host"`
+		Port  uint16                 `json:"port"`
+		Tags  map[string]interface{} `json:"tags"`
+	} `json:"members"`
+}
+
+// NewPatroniResolver builds an endpoint resolver for a cluster managed by Patroni (see WithEndpointResolver).
+func NewPatroniResolver(cfg PatroniConfig) (*PatroniResolver, error) {
+	if len(cfg.URLs) == 0 {
+		return nil, fmt.Errorf("at least one address of the Patroni REST API is required: %w", ErrInvalidConfig)
+	}
+
+	for _, u := range cfg.URLs {
+		if _, err := url.Parse(u); err != nil {
+			return nil, fmt.Errorf("invalid Patroni address: %w: %w", ErrInvalidConfig, err)
+		}
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return &PatroniResolver{cfg: cfg}, nil
+}
+
+// ResolveEndpoints retrieves the members of the cluster from the first Patroni node which responds.
+func (p *PatroniResolver) ResolveEndpoints(ctx context.Context) (Endpoints, error) {
+	var errs error
+
+	for _, u := range p.cfg.URLs {
+		cluster, err := p.cluster(ctx, u)
+		if err != nil {
+			errs = errors.Join(errs, err)
+
+			if ctx.Err() != nil {
+				break
+			}
+
+			continue
+		}
+
+		return cluster.endpoints(), nil
+	}
+
+	return Endpoints{}, errs
+}
+
+func (p *PatroniResolver) cluster(ctx context.Context, u string) (patroniCluster, error) {
+	var cluster patroniCluster
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(u, "/")+"/cluster", nil)
+	if err != nil {
+		return cluster, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return cluster, fmt.Errorf("%w: %w", ErrPatroni, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return cluster, fmt.Errorf("%w: GET %s/cluster: %s: %s", ErrPatroni, u, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&cluster); err != nil {
+		return cluster, fmt.Errorf("%w: invalid response: %w", ErrPatroni, err)
+	}
+
+	return cluster, nil
+}
+
+func (c patroniCluster) endpoints() Endpoints {
+	var endpoints Endpoints
+
+	for _, member := range c.Members {
+		if member.Host == "" {
+			continue
+		}
+		endpoint := Endpoint{Host: member.Host, Port: member.Port}
+
+		switch member.Role {
+		case "leader", "master", "primary":
+			endpoints.Primary = endpoint
+		case "replica", "sync_standby", "quorum_standby":
+			if (member.State == "running" || member.State == "streaming") && member.Tags["noloadbalance"] != true {
+				endpoints.Replicas = append(endpoints.Replicas, endpoint)
+			}
+		}
+	}
+
+	return endpoints
+}
@@ -0,0 +1,64 @@
+package pgrepo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const patroniClusterResponse = `{
+  "members": [
+    {"name": "pg-1", "role": "replica", "state": "streaming", "host": "10.0.0.1", "port": 5432, "lag": 0},
+    {"name": "pg-2", "role": "leader", "state": "running", "host": "10.0.0.2", "port": 5432, "timeline": 3},
+    {"name": "pg-3", "role": "sync_standby", "state": "running", "host": "10.0.0.3", "port": 5433},
+    {"name": "pg-4", "role": "replica", "state": "stopped", "host": "10.0.0.4", "port": 5432},
+    {"name": "pg-5", "role": "replica", "state": "streaming", "host": "10.0.0.5", "port": 5432,
+     "tags": {"noloadbalance": true, "replicatefrom": "pg-1"}}
+  ]
+}`
+
+func TestPatroniResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet || req.URL.Path != "/cluster" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(patroniClusterResponse))
+	}))
+	t.Cleanup(server.Close)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+
+	t.Run("should require an address", func(t *testing.T) {
+		_, err := NewPatroniResolver(PatroniConfig{})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("should discover the leader and running replicas", func(t *testing.T) {
+		resolver, err := NewPatroniResolver(PatroniConfig{URLs: []string{down.URL, server.URL + "/"}})
+		require.NoError(t, err)
+
+		endpoints, err := resolver.ResolveEndpoints(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, Endpoint{Host: "10.0.0.2", Port: 5432}, endpoints.Primary)
+		require.Equal(t, []Endpoint{{Host: "10.0.0.1", Port: 5432}, {Host: "10.0.0.3", Port: 5433}}, endpoints.Replicas)
+	})
+
+	t.Run("should fail when no node responds", func(t *testing.T) {
+		resolver, err := NewPatroniResolver(PatroniConfig{URLs: []string{down.URL}})
+		require.NoError(t, err)
+
+		_, err = resolver.ResolveEndpoints(context.Background())
+		require.ErrorIs(t, err, ErrPatroni)
+		require.Contains(t, err.Error(), "503")
+	})
+}
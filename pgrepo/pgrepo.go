@@ -3,35 +3,61 @@ package pgrepo
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/fredbi/go-trace/log"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 	"github.com/opencensus-integrations/ocsql"
 	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
 )
 
-const driverName = "pgx"
+const (
+	driverName = "pgx"
+
+	// minPingTimeout is the minimum time allotted to a single ping at startup
+	minPingTimeout = 500 * time.Millisecond
+)
 
 var (
 	ErrDBNotInitialized = errors.New("db not initialized")
 	ErrInvalidConfig    = errors.New("invalid config")
 	ErrPGAuth           = errors.New("postgres authentication error")
 	ErrInvalidPGURL     = errors.New("DB URL is invalid")
+	ErrStartupCancelled = errors.New("startup cancelled")
+	ErrStartupTimeout   = errors.New("startup timed out")
 )
 
 // Repository knows how to handle a postgres backend database.
 //
 // The database driver is instrumented for tracing.
 type Repository struct {
-	db  *sqlx.DB // master instance
-	log log.Factory
-	app string
+	db          *sqlx.DB // master instance
+	replicas    *replicaSet
+	standby     *standby
+	partitions  *partitionSet
+	connector   *reloadableConnector
+	stmts       *stmtCache
+	orphans     *orphanedXacts
+	report      *StartupReport
+	endpoints   *endpointState
+	activity    *activityMonitor
+	recent      *queryRing
+	breaker     *circuitBreaker
+	txWatch     *txWatch
+	jobMetrics  *jobMetrics
+	liveness    *liveness
+	otelMetrics *otelMetrics
+	parallel    *semaphore.Weighted // bounds the queries run by all Parallel groups
+	log         log.Factory
+	app         string
+	alias       string
+	opts        []Option // the options the repository was built with, applied again by Reload
+	stop        []func()
 
 	databaseSettings
 }
@@ -46,6 +72,8 @@ func New(dbAlias string, opts ...Option) *Repository {
 	return &Repository{
 		log:              log.NewFactory(s.logger),
 		app:              s.app,
+		alias:            dbAlias,
+		opts:             opts,
 		databaseSettings: dbSettings,
 	}
 }
@@ -55,6 +83,18 @@ func (r *Repository) DB() *sqlx.DB {
 	return r.db
 }
 
+// ReplicaDB returns a read-only replica instance.
+//
+// Replicas are load-balanced in a round-robin fashion, skipping unhealthy ones.
+// ReplicaDB falls back to the master instance when no healthy replica is available.
+func (r *Repository) ReplicaDB() *sqlx.DB {
+	if db := r.replicas.next(); db != nil {
+		return db
+	}
+
+	return r.db
+}
+
 // Logger returns a logger factory
 func (r Repository) Logger() log.Factory {
 	return r.log
@@ -62,21 +102,168 @@ func (r Repository) Logger() log.Factory {
 
 // Start a connection pool to a database, plus possibly another one to the read-only version of it
 func (r *Repository) Start() error {
+	return r.StartContext(context.Background())
+}
+
+// StartContext starts the repository like Start.
+//
+// Waiting for the database to be available is aborted when the context is cancelled (e.g. upon SIGTERM),
+// with an error wrapping ErrStartupCancelled.
+func (r *Repository) StartContext(ctx context.Context) error {
 	l := r.log.Bg()
+	if r.PGConfig != nil {
+		r.recent = newQueryRing(r.PGConfig.RecentQueries)
+		r.breaker = newCircuitBreaker(r.PGConfig.Breaker, l)
+		r.txWatch = newTxWatch(r.PGConfig.TxCheck)
+	}
+	if capacity := r.parallelCapacity(); capacity > 0 {
+		r.parallel = semaphore.NewWeighted(int64(capacity))
+	}
+	if r.pushGateway != nil {
+		r.jobMetrics = newJobMetrics(r.metricsLabels())
+	}
+	r.liveness = newLiveness()
+	if r.meterProvider != nil {
+		m, err := newOTelMetrics(r.meterProvider, r.otelMetricsAttributes())
+		if err != nil {
+			l.Warn("could not create OpenTelemetry metrics", zap.Error(err))
+		}
+		r.otelMetrics = m
+	}
 	s := r.databaseSettings
 
 	if err := s.Validate(); err != nil {
 		return err
 	}
+	s.warnPoolCoherence(l)
 
-	connCfg := s.ConnConfig(s.DBURL(), r.log, r.app)
-	db, err := r.open(context.Background(), connCfg)
+	connCfg := s.ConnConfig(s.DBURL(), r.log, r.app, r.connTracers(roleMaster)...)
+	if s.resolver != nil {
+		r.endpoints = newEndpointState()
+		endpoints, err := s.resolveEndpoints(ctx)
+		if err != nil {
+			l.Warn("could not resolve the endpoints of the cluster: connecting to the configured hosts", zap.Error(err))
+		} else {
+			r.endpoints.set(endpoints)
+			r.endpoints.apply(connCfg)
+		}
+	}
+
+	db, connector, err := r.open(ctx, connCfg)
 	if err != nil {
+		return s.reportSetParams(ctx, connCfg, err)
+	}
+	if err = s.checkCollation(ctx, db, l); err != nil {
+		_ = db.Close()
+
 		return err
 	}
+	s.checkHintPlan(ctx, db, l)
+	r.orphans = s.checkPreparedXacts(ctx, db, r.app, l)
+	report, err := r.startupReport(ctx, db)
+	if err != nil {
+		l.Warn("startup report not available", zap.Error(err))
+	}
+	r.report = report
+	s.warmup(ctx, db.DB, l)
 	r.db = db
+	r.connector = connector
+	r.breaker.arm()
+	r.stmts = newStmtCache(db)
+	r.partitions = newPartitionSet(s.PGConfig)
+
+	if len(s.Replicas) > 0 || s.resolver != nil {
+		// with a resolver, replicas may be discovered later on
+		r.replicas = r.openReplicas(ctx)
+		r.stop = append(r.stop, r.replicas.startHealthCheck(s.replicaCheckInterval(), l))
+	}
 
-	l.Info("connection pool ok", zap.String("db", connCfg.Database))
+	if s.Standby.URL != "" {
+		sb, err := r.openStandby(ctx)
+		if err != nil {
+			l.Error("could not configure standby", zap.Error(err))
+		} else {
+			r.standby = sb
+			r.stop = append(r.stop, sb.startCheck(s.Standby.checkInterval(), s.maxWait(), l))
+		}
+	}
+
+	if s.PGConfig != nil && s.PGConfig.ActivityMonitor.Enabled {
+		r.stop = append(r.stop, r.startActivityMonitor(s.PGConfig.ActivityMonitor, l))
+	}
+
+	if s.registerer != nil {
+		collector := r.Collector()
+		if err := s.registerer.Register(collector); err != nil {
+			l.Warn("could not register pool metrics", zap.Error(err))
+		} else {
+			r.stop = append(r.stop, func() { s.registerer.Unregister(collector) })
+		}
+	}
+
+	if s.PGConfig != nil {
+		if interval := s.PGConfig.Trace.statsInterval(); interval > 0 {
+			// populates the connection pool views of ocsql
+			r.stop = append(r.stop, ocsql.RecordStats(db.DB, interval))
+		}
+	}
+
+	if s.meterProvider != nil {
+		unregister, err := r.registerPoolMetrics(s.meterProvider)
+		if err != nil {
+			l.Warn("could not register OpenTelemetry pool metrics", zap.Error(err))
+		} else {
+			r.stop = append(r.stop, unregister)
+		}
+	}
+
+	if s.PGConfig != nil && s.PGConfig.WaitMonitor.Enabled {
+		r.stop = append(r.stop, r.startWaitMonitor(s.PGConfig.WaitMonitor))
+	}
+
+	if len(s.MaintenanceJobs) > 0 {
+		r.stop = append(r.stop, r.startMaintenanceJobs(l))
+	}
+
+	if r.txWatch != nil {
+		r.stop = append(r.stop, r.txWatch.start(l))
+	}
+
+	if s.PGConfig != nil && s.PGConfig.Maintenance.Enabled {
+		r.stop = append(r.stop, r.startMaintenanceListener(s.maintenanceSettings()))
+	}
+
+	if s.credentials != nil {
+		r.stop = append(r.stop, s.startCredentialsWatch(l, func() {
+			if err := r.reload(r.connector.appliedSettings(), true); err != nil {
+				l.Error("could not apply new credentials", zap.Error(err))
+			}
+		}))
+	}
+
+	if s.resolver != nil {
+		r.stop = append(r.stop, r.startEndpointsWatch(l))
+	}
+
+	if s.URLFrom.isSet() {
+		r.stop = append(r.stop, s.startURLWatch(defaultSecretWatchInterval, l, func(string) {
+			if err := r.reload(r.connector.appliedSettings(), true); err != nil {
+				l.Error("could not apply the new connection URL", zap.Error(err))
+			}
+		}))
+	}
+
+	build := r.BuildInfo()
+	fields := []zap.Field{
+		zap.String("db", connCfg.Database),
+		zap.String("pgxutils_version", build.Version),
+		zap.String("pgx_version", build.PGXVersion),
+		zap.Strings("features", build.Features),
+	}
+	if r.report != nil {
+		fields = append(fields, zap.Object("startup_report", r.report))
+	}
+	l.Info("connection pool ok", fields...)
 
 	return nil
 }
@@ -84,74 +271,115 @@ func (r *Repository) Start() error {
 // Stop the repository and close all connection pools.
 //
 // Stop may be called safely even if the database connection failed to start properly.
+//
+// See Shutdown to let in-flight work complete.
 func (r *Repository) Stop() error {
+	if r.db != nil && r.pushGateway != nil {
+		if err := r.PushMetrics(context.Background()); err != nil {
+			r.log.Bg().Warn("could not push metrics", zap.Error(err))
+		}
+	}
+
+	r.stopBackground()
+
+	err := errors.Join(r.stmts.reset(), r.replicas.close(), r.standby.close())
+	r.replicas = nil
+	r.standby = nil
+
 	if r.db == nil {
-		return nil
+		return err
 	}
 
-	return r.db.Close()
+	return errors.Join(err, r.db.Close())
+}
+
+// stopBackground stops the background workers started with the repository.
+func (r *Repository) stopBackground() {
+	for _, stop := range r.stop {
+		stop()
+	}
+	r.stop = nil
 }
 
 // HealthCheck pings the database
+//
+// Deprecated: use HealthCheckContext.
 func (r *Repository) HealthCheck() error {
+	return r.HealthCheckContext(context.Background())
+}
+
+// HealthCheckContext checks that the database is available, within the deadline of the context and at most
+// for the configured ping timeout.
+//
+// The health check pings the database, or runs the health check query if configured (e.g. "SELECT 1").
+func (r *Repository) HealthCheckContext(ctx context.Context) error {
 	if r.db == nil {
 		return ErrDBNotInitialized
 	}
 
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), r.databaseSettings.PGConfig.PingTimeout)
-	defer cancel()
-
-	return r.db.PingContext(ctxTimeout)
-}
-
-func (r Repository) open(ctx context.Context, dcfg *pgx.ConnConfig) (*sqlx.DB, error) {
-	if dcfg == nil {
-		return nil, ErrInvalidConfig
+	if r.PGConfig != nil && r.PGConfig.PingTimeout > 0 {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, r.PGConfig.PingTimeout)
+		defer cancel()
 	}
 
-	addr := stdlib.RegisterConnConfig(dcfg)
-	lg := r.log.Bg()
-	lg.Debug("registered driver",
-		zap.String("driver", driverName),
-		zap.String("driver_config", dcfg.ConnString()),
-		zap.String("db", dcfg.Database),
-	)
-
-	s := r.databaseSettings
-	opts := s.TraceOptions(dcfg.ConnString())
-	instrumentedDriver := driverName
+	if r.PGConfig == nil || r.PGConfig.HealthCheckQuery == "" {
+		return r.db.PingContext(ctx)
+	}
 
-	if len(opts) > 0 {
-		lg.Info("trace enabled for sql driver", zap.String("db", dcfg.Database))
+	_, err := r.db.ExecContext(ctx, r.PGConfig.HealthCheckQuery)
 
-		// opencensus tracing registered in the sql driver
-		// (this wraps the sql driver with an instrumented version)
-		var err error
-		instrumentedDriver, err = ocsql.RegisterWithSource(driverName, addr, opts...)
-		if err != nil {
-			lg.Error("failed to register trace driver", zap.Error(err))
+	return err
+}
 
-			return nil, err
-		}
+// HealthStatus is the detailed outcome of a health check (see Repository.HealthCheckVerbose).
+type HealthStatus struct {
+	Alias   string        `json:"alias"`
+	Healthy bool          `json:"healthy"`
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"latency"`
+	Build   VersionInfo   `json:"build"`
+}
 
-		lg.Debug("registered instrumented driver", zap.String("driver", instrumentedDriver))
+// HealthCheckVerbose checks that the database is available like HealthCheckContext, and reports
+// the latency of the check and the versions of the data layer.
+func (r *Repository) HealthCheckVerbose(ctx context.Context) HealthStatus {
+	start := time.Now()
+	err := r.HealthCheckContext(ctx)
+
+	status := HealthStatus{
+		Alias:   r.alias,
+		Healthy: err == nil,
+		Latency: time.Since(start),
+		Build:   r.BuildInfo(),
+	}
+	if err != nil {
+		status.Error = err.Error()
 	}
 
-	db, err := sql.Open(instrumentedDriver, addr)
+	return status
+}
+
+func (r Repository) open(ctx context.Context, dcfg *pgx.ConnConfig) (*sqlx.DB, *reloadableConnector, error) {
+	db, connector, err := r.openPool(dcfg, r.breaker)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	s := r.databaseSettings
+	lg := r.log.Bg()
 	lg.Debug("trying to ping the database",
 		zap.Duration("max_wait", s.maxWait()),
 		zap.String("db", dcfg.Database),
 	)
-	if err = waitPing(ctx, db, s.maxWait()); err != nil {
-		return nil, err
+	if err = waitPing(ctx, db, s.maxWait(), s.retryPolicy()); err != nil {
+		_ = db.Close()
+
+		return nil, nil, err
 	}
 
 	// connection pool settings
-	s.SetPool(db)
+	s.SetPool(db.DB)
 
 	if s.PGConfig != nil {
 		lg.Info("db pool settings",
@@ -160,57 +388,115 @@ func (r Repository) open(ctx context.Context, dcfg *pgx.ConnConfig) (*sqlx.DB, e
 			zap.Int("maxOpenConns", s.PGConfig.MaxOpenConns),
 			zap.Duration("connMaxIdleTime", s.PGConfig.ConnMaxIdleTime),
 			zap.Duration("connMaxLifetime", s.PGConfig.ConnMaxLifeTime),
+			zap.Duration("connMaxLifetimeJitter", s.PGConfig.ConnMaxLifetimeJitter),
 		)
 	}
 
-	return sqlx.NewDb(db, driverName), nil
+	return db, connector, nil
 }
 
-// waitPing checks for the availability of the database connection for maxWait.
+// openPool configures the (possibly instrumented) driver and opens a connection pool, without connecting.
 //
-// If the database is not immediately available, it tries every second up to maxWait.
+// The driver configuration may be replaced later on with the returned connector. New connections are guarded
+// by the circuit breaker, if any.
+func (r Repository) openPool(dcfg *pgx.ConnConfig, breaker *circuitBreaker) (*sqlx.DB, *reloadableConnector, error) {
+	if dcfg == nil {
+		return nil, nil, ErrInvalidConfig
+	}
+
+	s := r.databaseSettings
+	reloadable := newReloadableConnector(dcfg, s, breaker)
+	var connector driver.Connector = reloadable
+	lg := r.log.Bg()
+	lg.Debug("configured driver",
+		zap.String("driver", driverName),
+		zap.String("driver_config", dcfg.ConnString()),
+		zap.String("db", dcfg.Database),
+	)
+
+	opts := s.TraceOptions(dcfg.ConnString())
+
+	if len(opts) > 0 {
+		lg.Info("trace enabled for sql driver", zap.String("db", dcfg.Database))
+
+		// opencensus tracing in the sql driver
+		// (this wraps the driver connector with an instrumented version)
+		connector = ocsql.WrapConnector(connector, opts...)
+	}
+
+	db := sql.OpenDB(connector)
+
+	return sqlx.NewDb(db, driverName), reloadable, nil
+}
+
+// waitPing pings the database until it is available.
+//
+// If the database is not immediately available, it retries with the backoff of the retry policy, up to maxWait
+// or the maximum number of attempts of the policy.
 //
 // This avoids a hard container restart when the database is not immediatly available
 // (e.g. when a db proxy container is not ready yet).
-func waitPing(parentCtx context.Context, db interface{ PingContext(context.Context) error }, maxWait time.Duration) error {
+//
+// When the parent context is cancelled, the returned error wraps ErrStartupCancelled.
+// When maxWait elapses or attempts are exhausted, the returned error wraps ErrStartupTimeout and the last ping error.
+func waitPing(parentCtx context.Context, db interface{ PingContext(context.Context) error }, maxWait time.Duration, policy RetryPolicy) error {
 	if maxWait < time.Second {
 		maxWait = time.Second
 	}
-	pollInterval := time.Second
-	singlePingTimeout := pollInterval / 2
+	policy = policy.withDefaults()
+	var attempts int
 
-	ping := func() (bool, error) {
-		ctxTimeout, cancel := context.WithTimeout(parentCtx, singlePingTimeout)
+	ping := func(timeout time.Duration) (bool, error) {
+		ctxTimeout, cancel := context.WithTimeout(parentCtx, max(timeout, minPingTimeout))
 		defer cancel()
+		attempts++
 
 		return errShouldReturn(db.PingContext(ctxTimeout))
 	}
 
-	if shouldBail, err := ping(); shouldBail {
-		return err
+	cancelled := func(err error) error {
+		return fmt.Errorf("%w after %d attempts: %w", ErrStartupCancelled, attempts, errors.Join(parentCtx.Err(), err))
+	}
+
+	delay := policy.backoff(1)
+	shouldBail, lastErr := ping(delay / 2)
+	if shouldBail {
+		return lastErr
 	}
 
-	timer := time.NewTimer(maxWait)
-	defer timer.Stop()
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
 
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
+	retry := time.NewTimer(delay)
+	defer retry.Stop()
 
 	for {
 		select {
 		case <-parentCtx.Done():
-			return fmt.Errorf("parent context cancelled: %w", parentCtx.Err())
+			return cancelled(lastErr)
+
+		case <-retry.C:
+			if policy.MaxAttempts > 0 && attempts >= policy.MaxAttempts {
+				return fmt.Errorf("%w after %d attempts (max attempts): %w", ErrStartupTimeout, attempts, lastErr)
+			}
 
-		case <-ticker.C:
-			if shouldBail, err := ping(); shouldBail {
-				return err
+			delay = policy.backoff(attempts + 1)
+			if shouldBail, lastErr = ping(delay / 2); shouldBail {
+				return lastErr
 			}
+			retry.Reset(delay)
 
-		case <-timer.C:
+		case <-deadline.C:
 			// last attempt
-			_, err := ping()
+			if shouldBail, lastErr = ping(delay / 2); shouldBail {
+				return lastErr
+			}
 
-			return err
+			if parentCtx.Err() != nil {
+				return cancelled(lastErr)
+			}
+
+			return fmt.Errorf("%w after %d attempts (max wait: %v): %w", ErrStartupTimeout, attempts, maxWait, lastErr)
 		}
 	}
 }
@@ -220,12 +506,12 @@ func errShouldReturn(err error) (bool, error) {
 		return true, nil
 	}
 
-	if strings.Contains(err.Error(), "SQLSTATE 28") {
-		return true, errors.Join(ErrPGAuth, err)
-	}
-	if strings.Contains(err.Error(), "SQLSTATE 3D") {
+	if errors.Is(err, ErrInvalidConfig) {
 		return true, err
 	}
+	if IsAuthError(err) || IsInvalidDatabase(err) {
+		return true, ClassifyError(err)
+	}
 
 	return false, err
 }
@@ -236,7 +522,7 @@ func sqlDefaultTraceOptions() []ocsql.TraceOption {
 		ocsql.WithAllowRoot(true),
 		ocsql.WithLastInsertID(true),
 		ocsql.WithQuery(true),
-		ocsql.WithQueryParams(true),
+		ocsql.WithQueryParams(false), // parameters are only traced with sensitive params (see WithSensitiveParams)
 		ocsql.WithRowsAffected(true),
 		ocsql.WithRowsClose(true),
 	}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
@@ -84,3 +85,21 @@ func testWithViper(dbName string, cfg *viper.Viper) func(*testing.T) {
 		})
 	}
 }
+
+func TestStartContext(t *testing.T) {
+	r := New(DefaultDBAlias,
+		WithDatabaseSettings(DefaultDBAlias,
+			WithURL("postgresql://postgres@127.0.0.1:1/testdb?sslmode=disable"), // nothing listens there
+			WithPoolSettings(WithPingTimeout(time.Minute)),
+		),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := r.StartContext(ctx)
+	require.ErrorIs(t, err, ErrStartupCancelled)
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.NoError(t, r.Stop())
+}
@@ -0,0 +1,79 @@
+package pgrepo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"go.uber.org/zap"
+)
+
+// WithStrictPoolSettings fails the validation of incoherent pool settings (e.g. maxIdleConns greater than maxOpenConns),
+// instead of correcting them with a warning.
+func WithStrictPoolSettings(enabled bool) PoolOption {
+	return func(o *poolSettings) {
+		o.Strict = enabled
+	}
+}
+
+// poolIncoherences lists the incoherent combinations of pool settings.
+func (r databaseSettings) poolIncoherences() []string {
+	p := r.PGConfig
+	if p == nil {
+		return nil
+	}
+
+	var issues []string
+	if p.MaxOpenConns > 0 && p.MaxIdleConns > p.MaxOpenConns {
+		issues = append(issues, fmt.Sprintf("maxIdleConns (%d) is greater than maxOpenConns (%d): idle connections are capped to maxOpenConns", p.MaxIdleConns, p.MaxOpenConns))
+	}
+	if p.MaxOpenConns == 0 && len(r.Replicas) > 0 {
+		issues = append(issues, fmt.Sprintf("maxOpenConns is unlimited with %d replicas: every pool may open any number of connections", len(r.Replicas)))
+	}
+	if p.ConnMaxLifeTime > 0 && p.ConnMaxIdleTime > p.ConnMaxLifeTime {
+		issues = append(issues, fmt.Sprintf("connMaxIdleTime (%v) is greater than connMaxLifetime (%v): the idle time is capped to connMaxLifetime", p.ConnMaxIdleTime, p.ConnMaxLifeTime))
+	}
+
+	return issues
+}
+
+// validatePoolCoherence fails on incoherent pool settings, in strict mode.
+func (r databaseSettings) validatePoolCoherence() error {
+	if r.PGConfig == nil || !r.PGConfig.Strict {
+		return nil
+	}
+
+	var err error
+	for _, issue := range r.poolIncoherences() {
+		err = errors.Join(err, fmt.Errorf("incoherent pool settings: %s: %w", issue, ErrInvalidConfig))
+	}
+
+	return err
+}
+
+// warnPoolCoherence warns about incoherent pool settings. Pool sizes and idle time are corrected when applied (see SetPool).
+func (r databaseSettings) warnPoolCoherence(l log.Logger) {
+	for _, issue := range r.poolIncoherences() {
+		l.Warn("incoherent pool settings", zap.String("issue", issue))
+	}
+}
+
+// idleConns returns the maximum number of idle connections, raised to minConns and capped by maxOpenConns.
+func (p *poolSettings) idleConns() int {
+	idle := max(p.MaxIdleConns, p.MinConns)
+	if p.MaxOpenConns > 0 {
+		idle = min(idle, p.MaxOpenConns)
+	}
+
+	return idle
+}
+
+// idleTime returns the maximum idle time of connections, capped by connMaxLifetime.
+func (p *poolSettings) idleTime() time.Duration {
+	if p.ConnMaxLifeTime > 0 && p.ConnMaxIdleTime > p.ConnMaxLifeTime {
+		return p.ConnMaxLifeTime
+	}
+
+	return p.ConnMaxIdleTime
+}
@@ -0,0 +1,64 @@
+package pgrepo
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPoolCoherence(t *testing.T) {
+	incoherent := []PoolOption{
+		WithMaxOpenConns(5),
+		WithMaxIdleConns(10),
+		WithConnMaxLifeTime(time.Minute),
+		WithConnMaxIdleTime(time.Hour),
+	}
+
+	t.Run("should accept coherent settings", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL, Replicas: []string{DefaultURL}, PGConfig: poolSettingsFromOptions([]PoolOption{
+			WithStrictPoolSettings(true),
+			WithMaxOpenConns(10),
+			WithMaxIdleConns(5),
+		})}
+		require.Empty(t, dbs.poolIncoherences())
+		require.NoError(t, dbs.Validate())
+	})
+
+	t.Run("should fail in strict mode", func(t *testing.T) {
+		dbs := databaseSettings{
+			URL:      DefaultURL,
+			Replicas: []string{DefaultURL},
+			PGConfig: poolSettingsFromOptions(append([]PoolOption{WithStrictPoolSettings(true)}, incoherent...)),
+		}
+		require.Len(t, dbs.poolIncoherences(), 2)
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+
+		dbs.PGConfig = poolSettingsFromOptions([]PoolOption{WithStrictPoolSettings(true)})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig, "unlimited connections with replicas")
+	})
+
+	t.Run("should correct and warn otherwise", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions(incoherent)}
+		require.NoError(t, dbs.Validate())
+
+		core, logs := observer.New(zap.WarnLevel)
+		dbs.warnPoolCoherence(log.NewFactory(zap.New(core)).Bg())
+		require.Equal(t, 2, logs.FilterMessage("incoherent pool settings").Len())
+
+		require.Equal(t, 5, dbs.PGConfig.idleConns())
+		require.Equal(t, time.Minute, dbs.PGConfig.idleTime())
+
+		db := sqlx.NewDb(sql.OpenDB(&fakeReplica{}), driverName)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+		dbs.SetPool(db.DB)
+		require.Equal(t, 5, db.Stats().MaxOpenConnections)
+	})
+}
@@ -0,0 +1,99 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// PoolConfigFor builds a native pgxpool configuration for a database alias, for users who manage their own pgxpool.
+//
+// The configuration reuses the settings of this package: URL and credentials with env expansion, SET parameters,
+// logging and tracing of the pgx driver, session reset policy and pool limits.
+//
+// Pool parameters specified in the URL (e.g. "pool_max_conns") take precedence over the pool settings.
+//
+// NOTE: opencensus tracing is not available with pgxpool, as it instruments the database/sql driver.
+func PoolConfigFor(alias string, opts ...Option) (*pgxpool.Config, error) {
+	return settingsFromOptions(opts).PoolConfigFor(alias)
+}
+
+// PoolConfigFor builds a native pgxpool configuration for a database alias.
+//
+// See PoolConfigFor.
+func (s settings) PoolConfigFor(alias string) (*pgxpool.Config, error) {
+	dbs := s.DBSettingsFor(alias)
+	if err := dbs.Validate(); err != nil {
+		return nil, err
+	}
+
+	u := dbs.DBURL()
+	pcfg, err := pgxpool.ParseConfig(u)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPGURL, err)
+	}
+
+	connCfg := dbs.ConnConfig(u, log.NewFactory(s.logger), s.app)
+	if connCfg == nil {
+		return nil, ErrInvalidPGURL
+	}
+	pcfg.ConnConfig = connCfg
+	pcfg.BeforeConnect = dbs.beforeConnect()
+
+	dbs.setPoolConfig(pcfg, u)
+
+	return pcfg, nil
+}
+
+// setPoolConfig applies pool settings to a pgxpool configuration, unless already specified in the URL.
+func (r databaseSettings) setPoolConfig(pcfg *pgxpool.Config, u string) {
+	if r.PGConfig == nil {
+		return
+	}
+
+	params := poolParams(u)
+
+	if _, isSet := params["pool_max_conns"]; r.PGConfig.MaxOpenConns > 0 && !isSet {
+		pcfg.MaxConns = int32(r.PGConfig.MaxOpenConns)
+	}
+	if _, isSet := params["pool_min_conns"]; r.PGConfig.MinConns > 0 && !isSet {
+		pcfg.MinConns = int32(r.PGConfig.MinConns)
+	}
+	if _, isSet := params["pool_max_conn_lifetime"]; r.PGConfig.ConnMaxLifeTime > 0 && !isSet {
+		pcfg.MaxConnLifetime = r.PGConfig.ConnMaxLifeTime
+	}
+	if _, isSet := params["pool_max_conn_lifetime_jitter"]; r.PGConfig.ConnMaxLifetimeJitter > 0 && !isSet {
+		pcfg.MaxConnLifetimeJitter = r.PGConfig.ConnMaxLifetimeJitter
+	}
+	if _, isSet := params["pool_max_conn_idle_time"]; r.PGConfig.ConnMaxIdleTime > 0 && !isSet {
+		pcfg.MaxConnIdleTime = r.PGConfig.ConnMaxIdleTime
+	}
+
+	if reset := r.resetSession(); reset != nil {
+		// the session is reset before the connection is handed over. A connection which fails to reset is destroyed.
+		pcfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+			if err := reset(ctx, conn); err != nil {
+				r.logger.Warn("could not reset session", zap.String("db", pcfg.ConnConfig.Database), zap.Error(err))
+
+				return false
+			}
+
+			return true
+		}
+	}
+}
+
+// poolParams returns the parameters specified in a connection string (URL or DSN), e.g. "pool_max_conns".
+func poolParams(u string) map[string]string {
+	cfg, err := pgconn.ParseConfig(u)
+	if err != nil {
+		return nil
+	}
+
+	return cfg.RuntimeParams
+}
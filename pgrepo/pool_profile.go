@@ -0,0 +1,68 @@
+package pgrepo
+
+import (
+	"fmt"
+	"sort"
+)
+
+// WithPoolProfile selects a named pool profile of the databases, e.g. "web", "worker" or "cron",
+// so different process types of an application use appropriately sized pools from the same configuration.
+//
+// Profiles are declared for each database with the "pools" setting, or with WithPoolProfileSettings.
+// A database without this profile uses its pool settings.
+func WithPoolProfile(name string) Option {
+	return func(o *settings) {
+		o.poolProfile = name
+	}
+}
+
+// WithPoolProfileSettings declares the pool settings of a named pool profile for a database (see WithPoolProfile).
+//
+// Like pool settings for a database, the pool settings of a profile replace the default pool settings.
+func WithPoolProfileSettings(name string, opts ...PoolOption) DBOption {
+	return func(o *databaseSettings) {
+		pools := make(map[string]*poolSettings, len(o.Pools)+1)
+		for k, v := range o.Pools {
+			pools[k] = v
+		}
+		pools[name] = poolSettingsFromOptions(opts)
+		o.Pools = pools
+	}
+}
+
+// poolProfile returns the pool settings of a named profile, if declared for this database.
+func (r databaseSettings) poolProfile(name string) (*poolSettings, bool) {
+	if name == "" {
+		return nil, false
+	}
+
+	ps, ok := r.Pools[name]
+
+	return ps, ok && ps != nil
+}
+
+// poolProfileNames returns the names of the declared pool profiles, in alphabetical order.
+func (r databaseSettings) poolProfileNames() []string {
+	names := make([]string, 0, len(r.Pools))
+	for name := range r.Pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (r databaseSettings) validatePoolProfiles() error {
+	for _, name := range r.poolProfileNames() {
+		ps := r.Pools[name]
+		if ps == nil {
+			continue
+		}
+
+		if err := validateSetParams(ps.Set); err != nil {
+			return fmt.Errorf("pool profile %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
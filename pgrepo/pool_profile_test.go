@@ -0,0 +1,86 @@
+package pgrepo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPoolProfile(t *testing.T) {
+	cfg := viper.New()
+	cfg.SetConfigType("yaml")
+	require.NoError(t, cfg.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    default:
+      url: 'postgresql://localhost:5432/testdb'
+      pgconfig:
+        maxOpenConns: 20
+      pools:
+        web:
+          maxOpenConns: 50
+          maxIdleConns: 25
+        worker:
+          maxOpenConns: 5
+    other:
+      url: 'postgresql://localhost:5432/other'
+`)))
+
+	s, err := makeSettingsFromViper(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	t.Run("should use the pool settings of the database without profile", func(t *testing.T) {
+		require.Equal(t, 20, s.DBSettingsFor(DefaultDBAlias).PGConfig.MaxOpenConns)
+	})
+
+	t.Run("should use the pool settings of a profile", func(t *testing.T) {
+		WithPoolProfile("web")(&s)
+		dbs := s.DBSettingsFor(DefaultDBAlias)
+		require.Equal(t, 50, dbs.PGConfig.MaxOpenConns)
+		require.Equal(t, 25, dbs.PGConfig.MaxIdleConns)
+
+		WithPoolProfile("worker")(&s)
+		require.Equal(t, 5, s.DBSettingsFor(DefaultDBAlias).PGConfig.MaxOpenConns)
+	})
+
+	t.Run("should fall back to the pool settings of the database for other profiles", func(t *testing.T) {
+		WithPoolProfile("cron")(&s)
+		require.Equal(t, 20, s.DBSettingsFor(DefaultDBAlias).PGConfig.MaxOpenConns)
+		require.Equal(t, s.PGConfig, s.DBSettingsFor("other").PGConfig)
+	})
+
+	t.Run("should declare profiles with options", func(t *testing.T) {
+		r := New(DefaultDBAlias,
+			WithPoolProfile("worker"),
+			WithDatabaseSettings(DefaultDBAlias,
+				WithURL(DefaultURL),
+				WithPoolSettings(WithMaxOpenConns(20)),
+				WithPoolProfileSettings("web", WithMaxOpenConns(50)),
+				WithPoolProfileSettings("worker", WithMaxOpenConns(5)),
+			),
+		)
+		require.Equal(t, 5, r.PGConfig.MaxOpenConns)
+		require.Equal(t, []string{"web", "worker"}, r.poolProfileNames())
+	})
+
+	t.Run("should validate profiles", func(t *testing.T) {
+		invalid := viper.New()
+		invalid.SetConfigType("yaml")
+		require.NoError(t, invalid.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    default:
+      url: 'postgresql://localhost:5432/testdb'
+      pools:
+        worker:
+          set:
+            'statement timeout': 10s
+`)))
+
+		_, err := makeSettingsFromViper(invalid, zap.NewNop())
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,123 @@
+package pgrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// PreparedXact is a transaction prepared for a two-phase commit, and not yet committed or rolled back.
+type PreparedXact struct {
+	GID      string    `db:"gid"`
+	Prepared time.Time `db:"prepared"`
+	Owner    string    `db:"owner"`
+}
+
+// WithPreparedXactCheck looks for orphaned prepared transactions when the repository is started,
+// i.e. transactions prepared for a two-phase commit for longer than maxAge.
+//
+// Orphaned prepared transactions hold their locks and prevent vacuum from cleaning up dead rows,
+// until they are explicitly committed or rolled back. They are logged and exposed as metrics.
+//
+// Prepared transactions are attributed to this application by the prefix of their global identifier,
+// which defaults to the application name (see WithPreparedXactPrefix).
+//
+// When rollback is true, orphaned prepared transactions of this application are rolled back.
+func WithPreparedXactCheck(maxAge time.Duration, rollback bool) PoolOption {
+	return func(o *poolSettings) {
+		o.PreparedXacts.Enabled = true
+		o.PreparedXacts.MaxAge = maxAge
+		o.PreparedXacts.Rollback = rollback
+	}
+}
+
+// WithPreparedXactPrefix sets the prefix of the global identifiers of the transactions prepared by this application.
+func WithPreparedXactPrefix(prefix string) PoolOption {
+	return func(o *poolSettings) {
+		o.PreparedXacts.Prefix = prefix
+	}
+}
+
+// orphanedXacts counts the orphaned prepared transactions found at startup.
+type orphanedXacts struct {
+	found      int
+	rolledBack int
+}
+
+func (r databaseSettings) preparedXactSettings() preparedXactSettings {
+	var ps preparedXactSettings
+	if r.PGConfig != nil {
+		ps = r.PGConfig.PreparedXacts
+	}
+
+	if ps.MaxAge <= 0 {
+		ps.MaxAge = defaultSettings.PGConfig.PreparedXacts.MaxAge
+	}
+
+	return ps
+}
+
+// checkPreparedXacts logs the orphaned prepared transactions of this application, and possibly rolls them back.
+//
+// Without a prefix to attribute prepared transactions to this application, all orphaned prepared transactions
+// are reported, and none is rolled back.
+//
+// Failures are logged: they don't prevent the repository from starting.
+func (r databaseSettings) checkPreparedXacts(ctx context.Context, db sqlx.ExtContext, app string, l log.Logger) *orphanedXacts {
+	ps := r.preparedXactSettings()
+	if !ps.Enabled {
+		return nil
+	}
+
+	prefix := ps.Prefix
+	if prefix == "" {
+		prefix = app
+	}
+	l = l.With(zap.String("gid_prefix", prefix), zap.Duration("max_age", ps.MaxAge))
+
+	var xacts []PreparedXact
+	if err := sqlx.SelectContext(ctx, db, &xacts,
+		`SELECT gid, prepared, owner FROM pg_prepared_xacts
+		WHERE database = current_database() AND starts_with(gid, $1) AND prepared < now() - make_interval(secs => $2)
+		ORDER BY prepared`,
+		prefix, ps.MaxAge.Seconds(),
+	); err != nil {
+		l.Warn("could not check orphaned prepared transactions", zap.Error(err))
+
+		return nil
+	}
+
+	orphans := &orphanedXacts{found: len(xacts)}
+	if len(xacts) == 0 {
+		return orphans
+	}
+
+	rollback := ps.Rollback
+	if rollback && prefix == "" {
+		l.Warn("orphaned prepared transactions are not rolled back without a prefix to identify those of this application")
+		rollback = false
+	}
+
+	for _, xact := range xacts {
+		xl := l.With(zap.String("gid", xact.GID), zap.Time("prepared", xact.Prepared), zap.String("owner", xact.Owner))
+
+		if !rollback {
+			xl.Warn("orphaned prepared transaction: it holds locks until committed or rolled back")
+
+			continue
+		}
+
+		if _, err := db.ExecContext(ctx, `ROLLBACK PREPARED `+quoteLiteral(xact.GID)); err != nil {
+			xl.Error("could not roll back orphaned prepared transaction", zap.Error(err))
+
+			continue
+		}
+		orphans.rolledBack++
+		xl.Warn("orphaned prepared transaction rolled back")
+	}
+
+	return orphans
+}
@@ -0,0 +1,94 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCheckPreparedXacts(t *testing.T) {
+	prepared := time.Now().Add(-time.Hour)
+	newFake := func() (*fakePreparedXacts, *sqlx.DB) {
+		fake := &fakePreparedXacts{xacts: [][]driver.Value{
+			{"billing-1", prepared, "app"},
+			{"billing-2", prepared, "app"},
+		}}
+		db := sqlx.NewDb(sql.OpenDB(fake), driverName)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		return fake, db
+	}
+
+	t.Run("should be disabled by default", func(t *testing.T) {
+		fake, db := newFake()
+		require.Nil(t, databaseSettings{}.checkPreparedXacts(context.Background(), db, "billing", log.NewFactory(zap.NewNop()).Bg()))
+		require.Empty(t, fake.queries())
+	})
+
+	t.Run("should report orphaned prepared transactions", func(t *testing.T) {
+		fake, db := newFake()
+		core, logs := observer.New(zap.WarnLevel)
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithPreparedXactCheck(0, false)})}
+
+		orphans := dbs.checkPreparedXacts(context.Background(), db, "billing", log.NewFactory(zap.New(core)).Bg())
+		require.Equal(t, &orphanedXacts{found: 2}, orphans)
+		require.Equal(t, 2, logs.FilterMessageSnippet("orphaned prepared transaction").Len())
+		require.Len(t, fake.queries(), 1)
+		require.Equal(t, []driver.Value{"billing", (10 * time.Minute).Seconds()}, fake.args)
+	})
+
+	t.Run("should roll back orphaned prepared transactions", func(t *testing.T) {
+		fake, db := newFake()
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{
+			WithPreparedXactCheck(time.Minute, true),
+			WithPreparedXactPrefix("billing-"),
+		})}
+
+		orphans := dbs.checkPreparedXacts(context.Background(), db, "app", log.NewFactory(zap.NewNop()).Bg())
+		require.Equal(t, &orphanedXacts{found: 2, rolledBack: 2}, orphans)
+		require.Equal(t, []driver.Value{"billing-", time.Minute.Seconds()}, fake.args)
+		require.Equal(t, []string{`ROLLBACK PREPARED 'billing-1'`, `ROLLBACK PREPARED 'billing-2'`}, fake.queries()[1:])
+	})
+
+	t.Run("should not roll back without a prefix", func(t *testing.T) {
+		fake, db := newFake()
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithPreparedXactCheck(time.Minute, true)})}
+
+		orphans := dbs.checkPreparedXacts(context.Background(), db, "", log.NewFactory(zap.NewNop()).Bg())
+		require.Equal(t, &orphanedXacts{found: 2}, orphans)
+		require.Len(t, fake.queries(), 1)
+	})
+
+	t.Run("should tolerate failures", func(t *testing.T) {
+		fake, db := newFake()
+		fake.err = errors.New("permission denied")
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithPreparedXactCheck(time.Minute, false)})}
+
+		require.Nil(t, dbs.checkPreparedXacts(context.Background(), db, "billing", log.NewFactory(zap.NewNop()).Bg()))
+	})
+}
+
+// fakePreparedXacts is a fake server which lists prepared transactions.
+type fakePreparedXacts struct {
+	fakeServer
+	xacts [][]driver.Value
+}
+
+func (f *fakePreparedXacts) Connect(context.Context) (driver.Conn, error) {
+	return f.connect(f.answer)
+}
+
+func (f *fakePreparedXacts) answer(string, []driver.Value) (*fakeRows, error) {
+	return &fakeRows{columns: []string{"gid", "prepared", "owner"}, rows: f.xacts}, nil
+}
@@ -0,0 +1,145 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrNotLive is returned by Repository.Live when the database has been failing for longer than the liveness window.
+var ErrNotLive = errors.New("database not live")
+
+var _ pgx.QueryTracer = &liveness{}
+
+// WithLivenessWindow sets how long the database may keep failing before Repository.Live reports it as not live.
+func WithLivenessWindow(window time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.LivenessWindow = window
+	}
+}
+
+func (r databaseSettings) livenessWindow() time.Duration {
+	if r.PGConfig == nil || r.PGConfig.LivenessWindow <= 0 {
+		return defaultSettings.PGConfig.LivenessWindow
+	}
+
+	return r.PGConfig.LivenessWindow
+}
+
+// liveness is a pgx tracer which records the outcome of the last queries.
+//
+// Only failures of the database (connection failures or timeouts) are recorded as failures:
+// other errors (e.g. constraint violations) tell that the database is responding.
+type liveness struct {
+	mx          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastErr     error
+}
+
+func newLiveness() *liveness {
+	return &liveness{lastSuccess: time.Now()}
+}
+
+func (v *liveness) record(err error) {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	if isBreakerFailure(err) {
+		v.lastFailure = time.Now()
+		v.lastErr = err
+
+		return
+	}
+
+	if errors.Is(err, context.Canceled) {
+		// no outcome
+		return
+	}
+
+	v.lastSuccess = time.Now()
+}
+
+// check fails when queries have been failing, without any success for longer than the window.
+func (v *liveness) check(window time.Duration) error {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	if v.lastFailure.Before(v.lastSuccess) || time.Since(v.lastSuccess) <= window {
+		return nil
+	}
+
+	return fmt.Errorf("%w: no successful query for %v: %w", ErrNotLive, time.Since(v.lastSuccess).Round(time.Second), v.lastErr)
+}
+
+func (v *liveness) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (v *liveness) TraceQueryEnd(_ context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	v.record(data.Err)
+}
+
+// Live is a cheap liveness check, which does not query the database.
+//
+// It fails when the repository is not started, when the circuit breaker is open, or when queries have been failing
+// without any success for longer than the liveness window (see WithLivenessWindow).
+func (r *Repository) Live() error {
+	if r.db == nil || r.liveness == nil {
+		return ErrDBNotInitialized
+	}
+
+	if r.breaker.isOpen() {
+		return fmt.Errorf("%w: %w", ErrNotLive, ErrCircuitOpen)
+	}
+
+	return r.liveness.check(r.livenessWindow())
+}
+
+// Ready is a readiness check, which performs a round trip to the database: a ping, or the configured
+// health check query (see WithHealthCheckQuery).
+//
+// See HealthCheckContext.
+func (r *Repository) Ready(ctx context.Context) error {
+	err := r.HealthCheckContext(ctx)
+	if r.liveness != nil {
+		r.liveness.record(err)
+	}
+
+	return err
+}
+
+// LiveHandler serves the liveness check, e.g. on a "/livez" endpoint.
+//
+// It responds with status 200, or 503 with the error when the check fails.
+func (r *Repository) LiveHandler() http.Handler {
+	return probeHandler(func(context.Context) error { return r.Live() })
+}
+
+// ReadyHandler serves the readiness check, e.g. on a "/readyz" endpoint.
+//
+// It responds with status 200, or 503 with the error when the check fails.
+func (r *Repository) ReadyHandler() http.Handler {
+	return probeHandler(r.Ready)
+}
+
+func probeHandler(check func(context.Context) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		if err := check(req.Context()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintln(w, err.Error())
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,93 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLiveness(t *testing.T) {
+	t.Run("should be live while failures are recent", func(t *testing.T) {
+		v := newLiveness()
+		v.record(driver.ErrBadConn)
+		require.NoError(t, v.check(time.Minute))
+	})
+
+	t.Run("should not be live after failing for longer than the window", func(t *testing.T) {
+		v := newLiveness()
+		v.lastSuccess = time.Now().Add(-2 * time.Minute)
+		v.record(driver.ErrBadConn)
+
+		err := v.check(time.Minute)
+		require.ErrorIs(t, err, ErrNotLive)
+		require.ErrorIs(t, err, driver.ErrBadConn)
+
+		v.record(nil)
+		require.NoError(t, v.check(time.Minute))
+	})
+
+	t.Run("should be live when idle", func(t *testing.T) {
+		v := newLiveness()
+		v.lastSuccess = time.Now().Add(-time.Hour)
+		require.NoError(t, v.check(time.Minute))
+	})
+
+	t.Run("should not count errors of queries as failures", func(t *testing.T) {
+		v := newLiveness()
+		v.lastSuccess = time.Now().Add(-time.Hour)
+		v.record(ErrUniqueViolation)
+		require.NoError(t, v.check(time.Minute))
+	})
+}
+
+func TestProbes(t *testing.T) {
+	t.Run("repository is not started", func(t *testing.T) {
+		r := &Repository{}
+		require.ErrorIs(t, r.Live(), ErrDBNotInitialized)
+		require.ErrorIs(t, r.Ready(context.Background()), ErrDBNotInitialized)
+
+		rec := httptest.NewRecorder()
+		r.LiveHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+		require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		require.Contains(t, rec.Body.String(), ErrDBNotInitialized.Error())
+	})
+
+	t.Run("repository is started", func(t *testing.T) {
+		db := sqlx.NewDb(sql.OpenDB(&reloadableConnector{connector: &fakeReplica{}}), driverName)
+		t.Cleanup(func() { _ = db.Close() })
+
+		r := &Repository{db: db}
+		r.liveness = newLiveness()
+		r.liveness.lastSuccess = time.Now().Add(-time.Hour)
+		r.liveness.record(driver.ErrBadConn)
+		require.ErrorIs(t, r.Live(), ErrNotLive)
+
+		rec := httptest.NewRecorder()
+		r.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "ok\n", rec.Body.String())
+
+		// a successful round trip tells that the database is live again
+		require.NoError(t, r.Live())
+	})
+
+	t.Run("circuit breaker is open", func(t *testing.T) {
+		b := newCircuitBreaker(breakerSettings{Enabled: true, Threshold: 1}, log.NewFactory(zap.NewNop()).Bg())
+		b.arm()
+		b.record(driver.ErrBadConn)
+
+		r := &Repository{db: &sqlx.DB{}}
+		r.liveness = newLiveness()
+		r.breaker = b
+		require.ErrorIs(t, r.Live(), ErrCircuitOpen)
+	})
+}
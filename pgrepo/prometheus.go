@@ -0,0 +1,161 @@
+package pgrepo
+
+import (
+	"database/sql"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "pgrepo"
+
+var rexInvalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// poolCollector is a prometheus collector for the statistics of the connection pools of a repository.
+//
+// Statistics are sampled whenever metrics are collected.
+type poolCollector struct {
+	r *Repository
+
+	openConns         *prometheus.Desc
+	inUseConns        *prometheus.Desc
+	idleConns         *prometheus.Desc
+	maxOpenConns      *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxIdleTimeClosed *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+
+	standbyUp *prometheus.Desc
+
+	orphanedXacts   *prometheus.Desc
+	rolledBackXacts *prometheus.Desc
+
+	activeConns      *prometheus.Desc
+	idleInTxConns    *prometheus.Desc
+	cancelledQueries *prometheus.Desc
+
+	partitionBudget   *prometheus.Desc
+	partitionInUse    *prometheus.Desc
+	partitionAcquired *prometheus.Desc
+	partitionQueued   *prometheus.Desc
+	partitionRejected *prometheus.Desc
+}
+
+// Collector returns a prometheus collector for the connection pool statistics of this repository.
+//
+// Metrics are labeled by database alias, namespace (see Namespace), application name and role (master or replica).
+// Configured tags are added as constant labels, prefixed by "tag_".
+func (r *Repository) Collector() prometheus.Collector {
+	constLabels := r.metricsLabels()
+
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "pool", name), help, []string{"role"}, constLabels)
+	}
+	activityDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "activity", name), help, []string{"role"}, constLabels)
+	}
+	partitionDesc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "partition", name), help, []string{"class"}, constLabels)
+	}
+
+	return &poolCollector{
+		r:                 r,
+		openConns:         desc("open_connections", "The number of established connections, both in use and idle."),
+		inUseConns:        desc("in_use_connections", "The number of connections currently in use."),
+		idleConns:         desc("idle_connections", "The number of idle connections."),
+		maxOpenConns:      desc("max_open_connections", "The maximum number of open connections to the database."),
+		waitCount:         desc("wait_count_total", "The total number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "The total time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "The total number of connections closed due to SetMaxIdleConns."),
+		maxIdleTimeClosed: desc("max_idle_time_closed_total", "The total number of connections closed due to SetConnMaxIdleTime."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "The total number of connections closed due to SetConnMaxLifetime."),
+		standbyUp:         desc("standby_up", "Whether the standby was reachable when last probed (1) or not (0)."),
+		orphanedXacts:     desc("orphaned_prepared_xacts", "The number of orphaned prepared transactions found at startup."),
+		rolledBackXacts:   desc("orphaned_prepared_xacts_rolled_back", "The number of orphaned prepared transactions rolled back at startup."),
+		activeConns:       activityDesc("active_connections", "The number of connections of this application running a query, as sampled from pg_stat_activity."),
+		idleInTxConns:     activityDesc("idle_in_transaction_connections", "The number of connections of this application idle in a transaction, as sampled from pg_stat_activity."),
+		cancelledQueries:  activityDesc("cancelled_queries_total", "The total number of long running queries of this application cancelled by the activity monitor."),
+		partitionBudget:   partitionDesc("budget_connections", "The number of connections allotted to a statement class."),
+		partitionInUse:    partitionDesc("in_use_connections", "The number of connections currently in use by a statement class."),
+		partitionAcquired: partitionDesc("acquired_total", "The total number of connections acquired by a statement class."),
+		partitionQueued:   partitionDesc("queued_total", "The total number of acquisitions that waited for the budget of a statement class."),
+		partitionRejected: partitionDesc("rejected_total", "The total number of acquisitions that gave up waiting for the budget of a statement class."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.openConns
+	ch <- c.inUseConns
+	ch <- c.idleConns
+	ch <- c.maxOpenConns
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxIdleTimeClosed
+	ch <- c.maxLifetimeClosed
+	ch <- c.standbyUp
+	ch <- c.orphanedXacts
+	ch <- c.rolledBackXacts
+	ch <- c.activeConns
+	ch <- c.idleInTxConns
+	ch <- c.cancelledQueries
+	ch <- c.partitionBudget
+	ch <- c.partitionInUse
+	ch <- c.partitionAcquired
+	ch <- c.partitionQueued
+	ch <- c.partitionRejected
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.r.db != nil {
+		c.collect(ch, "master", c.r.db.Stats())
+	}
+
+	if replicaStats, ok := c.r.replicas.stats(); ok {
+		c.collect(ch, "replica", replicaStats)
+	}
+
+	if sb := c.r.standby; sb != nil && !sb.promoted.Load() {
+		c.collect(ch, "standby", sb.db.Stats())
+
+		var up float64
+		if sb.reachable.Load() {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.standbyUp, prometheus.GaugeValue, up, "standby")
+	}
+
+	if orphans := c.r.orphans; orphans != nil {
+		ch <- prometheus.MustNewConstMetric(c.orphanedXacts, prometheus.GaugeValue, float64(orphans.found), "master")
+		ch <- prometheus.MustNewConstMetric(c.rolledBackXacts, prometheus.GaugeValue, float64(orphans.rolledBack), "master")
+	}
+
+	if activity := c.r.activity; activity != nil {
+		ch <- prometheus.MustNewConstMetric(c.activeConns, prometheus.GaugeValue, float64(activity.active.Load()), "master")
+		ch <- prometheus.MustNewConstMetric(c.idleInTxConns, prometheus.GaugeValue, float64(activity.idleInTransaction.Load()), "master")
+		ch <- prometheus.MustNewConstMetric(c.cancelledQueries, prometheus.CounterValue, float64(activity.cancelled.Load()), "master")
+	}
+
+	for _, stats := range c.r.PartitionStats() {
+		class := string(stats.Class)
+		ch <- prometheus.MustNewConstMetric(c.partitionBudget, prometheus.GaugeValue, float64(stats.Budget), class)
+		ch <- prometheus.MustNewConstMetric(c.partitionInUse, prometheus.GaugeValue, float64(stats.InUse), class)
+		ch <- prometheus.MustNewConstMetric(c.partitionAcquired, prometheus.CounterValue, float64(stats.Acquired), class)
+		ch <- prometheus.MustNewConstMetric(c.partitionQueued, prometheus.CounterValue, float64(stats.Queued), class)
+		ch <- prometheus.MustNewConstMetric(c.partitionRejected, prometheus.CounterValue, float64(stats.Rejected), class)
+	}
+}
+
+func (c *poolCollector) collect(ch chan<- prometheus.Metric, role string, stats sql.DBStats) {
+	ch <- prometheus.MustNewConstMetric(c.openConns, prometheus.GaugeValue, float64(stats.OpenConnections), role)
+	ch <- prometheus.MustNewConstMetric(c.inUseConns, prometheus.GaugeValue, float64(stats.InUse), role)
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.Idle), role)
+	ch <- prometheus.MustNewConstMetric(c.maxOpenConns, prometheus.GaugeValue, float64(stats.MaxOpenConnections), role)
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), role)
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds(), role)
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed), role)
+	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed), role)
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), role)
+}
@@ -0,0 +1,45 @@
+package pgrepo
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	db, err := sql.Open(driverName, DefaultURL) // no connection established
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	db.SetMaxOpenConns(7)
+
+	r := &Repository{
+		db:               sqlx.NewDb(db, driverName),
+		app:              "test-app",
+		alias:            "main",
+		databaseSettings: databaseSettings{Tags: map[string]string{"team-name": "core"}},
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(r.Collector()))
+
+	expected := `
+# HELP pgrepo_pool_max_open_connections The maximum number of open connections to the database.
+# TYPE pgrepo_pool_max_open_connections gauge
+pgrepo_pool_max_open_connections{app="test-app",db_alias="main",namespace="",role="master",tag_team_name="core"} 7
+# HELP pgrepo_pool_open_connections The number of established connections, both in use and idle.
+# TYPE pgrepo_pool_open_connections gauge
+pgrepo_pool_open_connections{app="test-app",db_alias="main",namespace="",role="master",tag_team_name="core"} 0
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"pgrepo_pool_max_open_connections", "pgrepo_pool_open_connections",
+	))
+
+	count, err := testutil.GatherAndCount(registry)
+	require.NoError(t, err)
+	require.Equal(t, 9, count)
+}
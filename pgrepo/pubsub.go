@@ -0,0 +1,335 @@
+package pgrepo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultPubSubOverflowTable = "pubsub_overflow"
+
+	// maxNotifyPayload is the largest payload accepted by NOTIFY (payloads must be shorter than 8000 bytes).
+	maxNotifyPayload = 7999
+
+	// overflowMarker prefixes the notification of a payload stored in the overflow table.
+	//
+	// A JSON payload never starts with "@". The reference to the stored payload comes with a random token,
+	// so it can't be forged by other notifications on the channel.
+	overflowMarker = "@overflow:"
+
+	overflowTokenLength = 16
+
+	subscriptionBuffer = 64
+)
+
+// WithPubSubOverflow sets the table holding the payloads of Publish which exceed the limit of NOTIFY,
+// and how long they are kept.
+//
+// The table is created by InstallPubSub.
+func WithPubSubOverflow(table string, retention time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.PubSub.OverflowTable = table
+		o.PubSub.Retention = retention
+	}
+}
+
+func (r databaseSettings) pubSubSettings() pubSubSettings {
+	ps := defaultSettings.PGConfig.PubSub
+	if r.PGConfig != nil {
+		if r.PGConfig.PubSub.OverflowTable != "" {
+			ps.OverflowTable = r.PGConfig.PubSub.OverflowTable
+		}
+		if r.PGConfig.PubSub.Retention > 0 {
+			ps.Retention = r.PGConfig.PubSub.Retention
+		}
+	}
+
+	if r.namespace != "" && !strings.Contains(ps.OverflowTable, ".") {
+		ps.OverflowTable = r.namespace + "." + ps.OverflowTable
+	}
+
+	return ps
+}
+
+// Publish notifies subscribers of a channel with a message, marshaled as JSON (see Subscribe).
+//
+// Payloads exceeding the 8000 bytes limit of NOTIFY are stored in an overflow table (see WithPubSubOverflow),
+// and subscribers are notified with a reference to the stored payload. The overflow table must be created
+// beforehand (see InstallPubSub).
+//
+// Use PublishTx to deliver a message only when a transaction commits.
+func (r *Repository) Publish(ctx context.Context, channel string, v any) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	return r.publish(ctx, r.db, channel, v)
+}
+
+// PublishTx notifies subscribers of a channel with a message, like Publish, within a transaction.
+//
+// Like NOTIFY, the message is delivered when the transaction commits, and discarded if it is rolled back.
+// Large payloads are stored in the overflow table within the transaction as well.
+func (r *Repository) PublishTx(ctx context.Context, tx *sqlx.Tx, channel string, v any) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	return r.publish(ctx, tx, channel, v)
+}
+
+func (r *Repository) publish(ctx context.Context, db sqlx.ExecerContext, channel string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("could not marshal a message for channel %s: %w", channel, err)
+	}
+
+	if len(payload) <= maxNotifyPayload {
+		_, err = db.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, string(payload))
+
+		return err
+	}
+
+	ps := r.pubSubSettings()
+	table := quoteQualifiedIdentifier(ps.OverflowTable)
+	token, err := overflowToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf(`WITH overflow AS (
+  INSERT INTO %s (channel, payload, token) VALUES ($1, $2::jsonb, $4) RETURNING id
+)
+SELECT pg_notify($1, $3 || id || ':' || $4) FROM overflow`, table),
+		channel, string(payload), overflowMarker, token,
+	)
+	if err != nil {
+		return fmt.Errorf("could not publish a large message on channel %s (see InstallPubSub): %w", channel, err)
+	}
+
+	// expired payloads are deleted outside of any transaction: a failure must not abort the transaction
+	if _, err = r.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE created_at < now() - make_interval(secs => $1)`, table),
+		ps.Retention.Seconds(),
+	); err != nil {
+		r.logger(ctx).Warn("could not delete expired overflow payloads", zap.String("table", ps.OverflowTable), zap.Error(err))
+	}
+
+	return nil
+}
+
+// Subscribe listens to a channel, and delivers the messages published with Publish, unmarshaled from JSON.
+//
+// Messages are listened to on a dedicated connection, which is reestablished with the backoff of the retry
+// policy whenever it is lost. Messages published while the connection is lost are not delivered.
+// Messages that cannot be unmarshaled are skipped.
+//
+// The returned channel is closed when the context is cancelled.
+//
+// Behind a transaction-pooling proxy (see WithCompat), Subscribe returns ErrSessionPinningDisabled.
+func Subscribe[T any](ctx context.Context, r *Repository, channel string) (<-chan T, error) {
+	if r.db == nil || r.connector == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	if r.PGConfig.transactionPooling() {
+		return nil, ErrSessionPinningDisabled
+	}
+
+	conn, err := r.listen(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	l := r.log.Bg().With(zap.String("db_alias", r.alias), zap.String("channel", channel))
+	out := make(chan T, subscriptionBuffer)
+
+	go func() {
+		defer close(out)
+
+		r.runSubscription(ctx, conn, channel, l, func(payload []byte) bool {
+			var v T
+			if err := json.Unmarshal(payload, &v); err != nil {
+				l.Warn("could not unmarshal a message: skipped", zap.Error(err))
+
+				return true
+			}
+
+			select {
+			case out <- v:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+
+	return out, nil
+}
+
+// listen establishes a dedicated connection listening to a channel.
+func (r *Repository) listen(ctx context.Context, channel string) (*pgx.Conn, error) {
+	cfg, err := r.connector.connectConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = conn.Exec(ctx, `LISTEN `+quoteIdentifier(channel)); err != nil {
+		_ = conn.Close(context.Background())
+
+		return nil, fmt.Errorf("could not listen to channel %s: %w", channel, err)
+	}
+
+	return conn, nil
+}
+
+// runSubscription delivers notifications until the context is cancelled or deliver returns false,
+// reconnecting whenever the connection is lost.
+func (r *Repository) runSubscription(ctx context.Context, conn *pgx.Conn, channel string, l log.Logger, deliver func([]byte) bool) {
+	policy := r.retryPolicy()
+	table := quoteQualifiedIdentifier(r.pubSubSettings().OverflowTable)
+
+	for attempts := 0; ; {
+		if conn != nil {
+			err := receiveNotifications(ctx, conn, table, l, deliver)
+			_ = conn.Close(context.Background())
+			conn = nil
+
+			if err == nil || ctx.Err() != nil {
+				return
+			}
+			l.Warn("subscription disconnected: messages may be lost until reconnected", zap.Error(err))
+		}
+
+		attempts++
+		timer := time.NewTimer(policy.backoff(attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+
+		var err error
+		if conn, err = r.listen(ctx, channel); err != nil {
+			l.Warn("could not resubscribe: retrying", zap.Int("attempts", attempts), zap.Error(err))
+
+			continue
+		}
+
+		attempts = 0
+		l.Info("subscription reconnected")
+	}
+}
+
+// receiveNotifications waits for notifications, until the connection fails, the context is cancelled or deliver returns false.
+func receiveNotifications(ctx context.Context, conn *pgx.Conn, table string, l log.Logger, deliver func([]byte) bool) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		payload := notification.Payload
+		if id, token, ok := overflowRef(payload); ok {
+			err = conn.QueryRow(ctx, fmt.Sprintf(`SELECT payload::text FROM %s WHERE id = $1 AND channel = $2 AND token = $3`, table),
+				id, notification.Channel, token,
+			).Scan(&payload)
+			if err != nil {
+				if conn.IsClosed() {
+					return err
+				}
+
+				l.Warn("could not retrieve an overflow payload: skipped", zap.Int64("id", id), zap.Error(err))
+
+				continue
+			}
+		}
+
+		if !deliver([]byte(payload)) {
+			return nil
+		}
+	}
+}
+
+// overflowRef returns the identifier and the token of a payload stored in the overflow table,
+// if the notification refers to one.
+func overflowRef(payload string) (int64, string, bool) {
+	ref, ok := strings.CutPrefix(payload, overflowMarker)
+	if !ok {
+		return 0, "", false
+	}
+
+	ref, token, ok := strings.Cut(ref, ":")
+	if !ok || token == "" {
+		return 0, "", false
+	}
+
+	id, err := strconv.ParseInt(ref, 10, 64)
+
+	return id, token, err == nil
+}
+
+// overflowToken generates the random token which authenticates a reference to an overflow payload.
+func overflowToken() (string, error) {
+	token := make([]byte, overflowTokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
+
+// InstallPubSub creates the overflow table of Publish (see WithPubSubOverflow), e.g. at startup.
+//
+// The table may be created by migrations instead:
+//
+//	CREATE TABLE pubsub_overflow (
+//	  id bigserial PRIMARY KEY,
+//	  channel text NOT NULL,
+//	  payload jsonb NOT NULL,
+//	  token text NOT NULL,
+//	  created_at timestamptz NOT NULL DEFAULT now()
+//	)
+//
+// Installing is idempotent.
+//
+// The default deadline of DDL operations applies if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) InstallPubSub(ctx context.Context) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	ctx, cancel := r.withDefaultDeadline(ctx, OperationDDL)
+	defer cancel()
+
+	table := quoteQualifiedIdentifier(r.pubSubSettings().OverflowTable)
+	_, err := r.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id bigserial PRIMARY KEY,
+	channel text NOT NULL,
+	payload jsonb NOT NULL,
+	token text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+)`, table))
+	if err != nil {
+		return fmt.Errorf("could not create overflow table %s: %w", table, err)
+	}
+
+	return nil
+}
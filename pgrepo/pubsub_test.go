@@ -0,0 +1,172 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPubSubSettings(t *testing.T) {
+	t.Run("should apply defaults", func(t *testing.T) {
+		ps := databaseSettings{}.pubSubSettings()
+		require.Equal(t, defaultPubSubOverflowTable, ps.OverflowTable)
+		require.Equal(t, time.Hour, ps.Retention)
+	})
+
+	t.Run("should apply option and namespace", func(t *testing.T) {
+		s := settingsFromOptions([]Option{
+			WithDefaultPoolOptions(WithPubSubOverflow("events_overflow", time.Minute)),
+		})
+		dbs := s.DBSettingsFor(DefaultDBAlias)
+		dbs.namespace = "billing"
+
+		ps := dbs.pubSubSettings()
+		require.Equal(t, "billing.events_overflow", ps.OverflowTable)
+		require.Equal(t, time.Minute, ps.Retention)
+	})
+}
+
+func TestOverflowRef(t *testing.T) {
+	id, token, ok := overflowRef(overflowMarker + "42:c0ffee")
+	require.True(t, ok)
+	require.Equal(t, int64(42), id)
+	require.Equal(t, "c0ffee", token)
+
+	for _, payload := range []string{
+		`{"id":42}`, `"@overflow:42:c0ffee"`, overflowMarker, overflowMarker + "x:c0ffee", overflowMarker + "42", overflowMarker + "42:",
+	} {
+		_, _, ok = overflowRef(payload)
+		require.Falsef(t, ok, "expected %q not to refer to an overflow payload", payload)
+	}
+
+	t.Run("tokens are random", func(t *testing.T) {
+		token, err := overflowToken()
+		require.NoError(t, err)
+		require.Len(t, token, 2*overflowTokenLength)
+
+		other, err := overflowToken()
+		require.NoError(t, err)
+		require.NotEqual(t, token, other)
+	})
+}
+
+func TestPublish(t *testing.T) {
+	type event struct {
+		Kind string `json:"kind"`
+		Data string `json:"data"`
+	}
+
+	ctx := context.Background()
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		require.ErrorIs(t, (&Repository{}).Publish(ctx, "events", event{}), ErrDBNotInitialized)
+		require.ErrorIs(t, (&Repository{}).PublishTx(ctx, nil, "events", event{}), ErrDBNotInitialized)
+	})
+
+	newRepository := func(t *testing.T) (*Repository, *fakeServer) {
+		fake := &fakeServer{}
+		db := sqlx.NewDb(sql.OpenDB(fake), driverName)
+		t.Cleanup(func() { _ = db.Close() })
+
+		r := New(DefaultDBAlias, WithLogger(zap.NewNop()))
+		r.db = db
+
+		return r, fake
+	}
+
+	t.Run("should notify a small payload", func(t *testing.T) {
+		r, fake := newRepository(t)
+
+		require.NoError(t, r.Publish(ctx, "events", event{Kind: "created", Data: "x"}))
+		require.Equal(t, []string{`SELECT pg_notify($1, $2)`}, fake.queries())
+		require.Equal(t, [][]driver.Value{{"events", `{"kind":"created","data":"x"}`}}, fake.execArgs)
+	})
+
+	t.Run("should store a large payload in the overflow table", func(t *testing.T) {
+		r, fake := newRepository(t)
+		large := event{Kind: "created", Data: strings.Repeat("x", maxNotifyPayload)}
+
+		require.NoError(t, r.Publish(ctx, "events", large))
+
+		queries := fake.queries()
+		require.Len(t, queries, 2)
+		require.Contains(t, queries[0], `INSERT INTO "pubsub_overflow" (channel, payload, token)`)
+		require.Contains(t, queries[0], `pg_notify($1, $3 || id || ':' || $4)`)
+		require.Equal(t, "events", fake.execArgs[0][0])
+		require.Equal(t, overflowMarker, fake.execArgs[0][2])
+		require.Len(t, fake.execArgs[0][3], 2*overflowTokenLength)
+		require.Contains(t, queries[1], `DELETE FROM "pubsub_overflow"`)
+		require.Equal(t, []driver.Value{time.Hour.Seconds()}, fake.execArgs[1])
+	})
+
+	t.Run("should reject payloads which cannot be marshaled", func(t *testing.T) {
+		r, _ := newRepository(t)
+
+		require.Error(t, r.Publish(ctx, "events", make(chan int)))
+	})
+
+	t.Run("should notify within a transaction", func(t *testing.T) {
+		r, fake := newRepository(t)
+
+		tx, err := r.db.BeginTxx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, r.PublishTx(ctx, tx, "events", event{Kind: "created", Data: "x"}))
+		require.NoError(t, tx.Commit())
+
+		require.Equal(t, []string{"BEGIN", `SELECT pg_notify($1, $2)`, "COMMIT"}, fake.queries())
+	})
+
+	t.Run("should store a large payload within a transaction", func(t *testing.T) {
+		r, fake := newRepository(t)
+		large := event{Kind: "created", Data: strings.Repeat("x", maxNotifyPayload)}
+
+		tx, err := r.db.BeginTxx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, r.PublishTx(ctx, tx, "events", large))
+		require.NoError(t, tx.Rollback())
+
+		queries := fake.queries()
+		require.Len(t, queries, 4)
+		require.Equal(t, "BEGIN", queries[0])
+		require.Contains(t, queries[1], `INSERT INTO "pubsub_overflow" (channel, payload, token)`)
+		require.Contains(t, queries[2], `DELETE FROM "pubsub_overflow"`)
+		require.Equal(t, "ROLLBACK", queries[3])
+	})
+
+	t.Run("should install the overflow table", func(t *testing.T) {
+		r, fake := newRepository(t)
+
+		require.NoError(t, r.InstallPubSub(ctx))
+		require.ErrorIs(t, (&Repository{}).InstallPubSub(ctx), ErrDBNotInitialized)
+
+		queries := fake.queries()
+		require.Len(t, queries, 1)
+		require.Contains(t, queries[0], `CREATE TABLE IF NOT EXISTS "pubsub_overflow"`)
+		require.Contains(t, queries[0], `token text NOT NULL`)
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		_, err := Subscribe[map[string]any](ctx, &Repository{}, "events")
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("should be disabled behind a transaction-pooling proxy", func(t *testing.T) {
+		r := &Repository{db: &sqlx.DB{}, connector: &reloadableConnector{}, databaseSettings: databaseSettings{
+			PGConfig: poolSettingsFromOptions([]PoolOption{WithCompat(CompatPgBouncer)}),
+		}}
+
+		_, err := Subscribe[map[string]any](ctx, r, "events")
+		require.ErrorIs(t, err, ErrSessionPinningDisabled)
+	})
+}
@@ -0,0 +1,202 @@
+package pgrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const defaultPushTimeout = 10 * time.Second
+
+var (
+	_ pgx.QueryTracer    = &jobMetrics{}
+	_ pgx.BatchTracer    = &jobMetrics{}
+	_ pgx.CopyFromTracer = &jobMetrics{}
+)
+
+type pushSettings struct {
+	URL string
+	Job string
+}
+
+// WithPushGateway pushes the metrics of a run to a prometheus pushgateway, for short-lived jobs (e.g. batch jobs
+// loading data) which live too briefly to be scraped.
+//
+// The metrics of a repository (pool statistics, statements executed, their duration, errors and rows affected)
+// are pushed when it is stopped, or explicitly with Repository.PushMetrics. EnsureDB pushes the duration and
+// the outcome of the creation of the database.
+//
+// Metrics are grouped by job and by database alias, with the "db" grouping label.
+func WithPushGateway(url, job string) Option {
+	return func(o *settings) {
+		o.pushGateway = &pushSettings{URL: url, Job: job}
+	}
+}
+
+// PushMetrics pushes the metrics of this repository to the pushgateway configured with WithPushGateway.
+//
+// The metrics previously pushed for this job and database alias are replaced.
+func (r *Repository) PushMetrics(ctx context.Context) error {
+	if r.pushGateway == nil {
+		return nil
+	}
+
+	collectors := []prometheus.Collector{r.Collector()}
+	if r.jobMetrics != nil {
+		collectors = append(collectors, r.jobMetrics)
+	}
+
+	return r.pushGateway.push(ctx, r.alias, collectors...)
+}
+
+func (p *pushSettings) push(ctx context.Context, alias string, collectors ...prometheus.Collector) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultPushTimeout)
+	defer cancel()
+
+	pusher := push.New(p.URL, p.Job).Grouping("db", alias)
+	for _, collector := range collectors {
+		pusher = pusher.Collector(collector)
+	}
+
+	return pusher.PushContext(ctx)
+}
+
+// metricsLabels are the constant labels of the metrics of a repository.
+func (r *Repository) metricsLabels() prometheus.Labels {
+	constLabels := prometheus.Labels{
+		"db_alias":  r.alias,
+		"namespace": r.namespace,
+		"app":       r.app,
+	}
+	for k, v := range r.Tags {
+		constLabels["tag_"+rexInvalidLabelChars.ReplaceAllString(k, "_")] = v
+	}
+
+	return constLabels
+}
+
+// jobMetrics is a pgx tracer which accounts for the statements executed during the run of a job.
+//
+// It is a prometheus collector for these metrics.
+type jobMetrics struct {
+	since time.Time
+
+	runDuration prometheus.GaugeFunc
+	statements  prometheus.Counter
+	errors      prometheus.Counter
+	duration    prometheus.Counter
+	rows        prometheus.Counter
+}
+
+type jobMetricsKey struct{}
+
+func newJobMetrics(constLabels prometheus.Labels) *jobMetrics {
+	opts := func(name, help string) prometheus.Opts {
+		return prometheus.Opts{
+			Namespace:   metricsNamespace,
+			Subsystem:   "job",
+			Name:        name,
+			Help:        help,
+			ConstLabels: constLabels,
+		}
+	}
+
+	m := &jobMetrics{
+		since:      time.Now(),
+		statements: prometheus.NewCounter(prometheus.CounterOpts(opts("statements_total", "The total number of statements executed."))),
+		errors:     prometheus.NewCounter(prometheus.CounterOpts(opts("errors_total", "The total number of failed statements."))),
+		duration:   prometheus.NewCounter(prometheus.CounterOpts(opts("statement_duration_seconds_total", "The total time spent executing statements."))),
+		rows:       prometheus.NewCounter(prometheus.CounterOpts(opts("rows_total", "The total number of rows affected or loaded by statements."))),
+	}
+	m.runDuration = prometheus.NewGaugeFunc(prometheus.GaugeOpts(opts("run_duration_seconds", "The duration of the run.")), func() float64 {
+		return time.Since(m.since).Seconds()
+	})
+
+	return m
+}
+
+func (m *jobMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.runDuration.Describe(ch)
+	m.statements.Describe(ch)
+	m.errors.Describe(ch)
+	m.duration.Describe(ch)
+	m.rows.Describe(ch)
+}
+
+func (m *jobMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.runDuration.Collect(ch)
+	m.statements.Collect(ch)
+	m.errors.Collect(ch)
+	m.duration.Collect(ch)
+	m.rows.Collect(ch)
+}
+
+// observe accounts for a statement, or for an operation of the job when started is zero.
+func (m *jobMetrics) observe(started time.Time, rows int64, err error) {
+	if !started.IsZero() {
+		m.statements.Inc()
+		m.duration.Add(time.Since(started).Seconds())
+	}
+
+	if err != nil {
+		m.errors.Inc()
+
+		return
+	}
+
+	m.rows.Add(float64(max(rows, 0)))
+}
+
+func (m *jobMetrics) start(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jobMetricsKey{}, time.Now())
+}
+
+func (m *jobMetrics) started(ctx context.Context) time.Time {
+	started, _ := ctx.Value(jobMetricsKey{}).(time.Time)
+
+	return started
+}
+
+func (m *jobMetrics) TraceQueryStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryStartData) context.Context {
+	return m.start(ctx)
+}
+
+func (m *jobMetrics) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	m.observe(m.started(ctx), data.CommandTag.RowsAffected(), data.Err)
+}
+
+func (m *jobMetrics) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return m.start(ctx)
+}
+
+func (m *jobMetrics) TraceBatchQuery(_ context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	m.statements.Inc()
+	if data.Err != nil {
+		m.errors.Inc()
+
+		return
+	}
+
+	m.rows.Add(float64(max(data.CommandTag.RowsAffected(), 0)))
+}
+
+func (m *jobMetrics) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	if started := m.started(ctx); !started.IsZero() {
+		m.duration.Add(time.Since(started).Seconds())
+	}
+
+	if data.Err != nil {
+		m.errors.Inc()
+	}
+}
+
+func (m *jobMetrics) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceCopyFromStartData) context.Context {
+	return m.start(ctx)
+}
+
+func (m *jobMetrics) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	m.observe(m.started(ctx), data.CommandTag.RowsAffected(), data.Err)
+}
@@ -0,0 +1,94 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobMetrics(t *testing.T) {
+	m := newJobMetrics(nil)
+	ctx := context.Background()
+
+	qctx := m.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: `UPDATE t SET x = 1`})
+	m.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 3")})
+
+	qctx = m.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: `SELECT 1/0`})
+	m.TraceQueryEnd(qctx, nil, pgx.TraceQueryEndData{Err: errors.New("division by zero")})
+
+	cctx := m.TraceCopyFromStart(ctx, nil, pgx.TraceCopyFromStartData{})
+	m.TraceCopyFromEnd(cctx, nil, pgx.TraceCopyFromEndData{CommandTag: pgconn.NewCommandTag("COPY 100")})
+
+	bctx := m.TraceBatchStart(ctx, nil, pgx.TraceBatchStartData{})
+	m.TraceBatchQuery(bctx, nil, pgx.TraceBatchQueryData{CommandTag: pgconn.NewCommandTag("INSERT 0 1")})
+	m.TraceBatchQuery(bctx, nil, pgx.TraceBatchQueryData{CommandTag: pgconn.NewCommandTag("INSERT 0 1")})
+	m.TraceBatchEnd(bctx, nil, pgx.TraceBatchEndData{})
+
+	require.Equal(t, float64(5), testutil.ToFloat64(m.statements))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.errors))
+	require.Equal(t, float64(105), testutil.ToFloat64(m.rows))
+	require.Greater(t, testutil.ToFloat64(m.duration), float64(0))
+	require.Equal(t, 5, testutil.CollectAndCount(m))
+}
+
+func TestPushMetrics(t *testing.T) {
+	var (
+		method, path, body string
+	)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		method, path = req.Method, req.URL.Path
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(gateway.Close)
+
+	r := New(DefaultDBAlias, WithPushGateway(gateway.URL, "loader"))
+	r.jobMetrics = newJobMetrics(r.metricsLabels())
+	r.jobMetrics.observe(time.Now(), 10, nil)
+
+	require.NoError(t, r.PushMetrics(context.Background()))
+	require.Equal(t, http.MethodPut, method)
+	require.Equal(t, "/metrics/job/loader/db/default", path)
+
+	for _, metric := range []string{
+		"pgrepo_job_statements_total",
+		"pgrepo_job_rows_total",
+		"pgrepo_job_run_duration_seconds",
+	} {
+		require.Truef(t, strings.Contains(body, metric), "expected %s to be pushed", metric)
+	}
+
+	t.Run("should not push without a pushgateway", func(t *testing.T) {
+		path = ""
+		require.NoError(t, New(DefaultDBAlias).PushMetrics(context.Background()))
+		require.Empty(t, path)
+	})
+}
+
+func ExampleWithPushGateway() {
+	// a batch job loading data reports its metrics when done
+	repo := New(DefaultDBAlias,
+		WithMetricsRegistry(prometheus.DefaultRegisterer), // scraped metrics, for long-running processes
+		WithPushGateway("http://pushgateway:9091", "nightly-load"),
+	)
+	if err := repo.Start(); err != nil {
+		return
+	}
+	defer func() {
+		_ = repo.Stop() // metrics are pushed when the repository is stopped
+	}()
+
+	_, _ = repo.DB().ExecContext(context.Background(), `DELETE FROM staging WHERE loaded_at < now() - interval '1 day'`)
+}
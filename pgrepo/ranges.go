@@ -0,0 +1,175 @@
+package pgrepo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Range types, which may be used as query arguments and scanned from query results.
+//
+// They implement the database/sql Scanner and Valuer interfaces, as well as the range interfaces of pgtype,
+// so they may be used with sqlx as well as with native pgx connections (e.g. Repository.SendBatch).
+//
+// A zero value is NULL. Use the Range field to build other bounds than the constructors do (e.g. unbounded ranges),
+// e.g.:
+//
+//	r := DateRange{Range: pgtype.Range[time.Time]{
+//		Lower:     from,
+//		LowerType: pgtype.Inclusive,
+//		UpperType: pgtype.Unbounded,
+//		Valid:     true,
+//	}}
+type (
+	// DateRange is a postgres daterange.
+	DateRange struct{ pgtype.Range[time.Time] }
+
+	// TimeRange is a postgres tstzrange.
+	TimeRange struct{ pgtype.Range[time.Time] }
+
+	// NumRange is a postgres numrange, with float64 bounds.
+	NumRange struct{ pgtype.Range[float64] }
+
+	// Int64Range is a postgres int8range.
+	Int64Range struct{ pgtype.Range[int64] }
+)
+
+var (
+	_ sql.Scanner   = &DateRange{}
+	_ driver.Valuer = DateRange{}
+	_ sql.Scanner   = &TimeRange{}
+	_ driver.Valuer = TimeRange{}
+	_ sql.Scanner   = &NumRange{}
+	_ driver.Valuer = NumRange{}
+	_ sql.Scanner   = &Int64Range{}
+	_ driver.Valuer = Int64Range{}
+)
+
+// typeMaps encode and decode values of postgres types in the text format.
+//
+// pgtype.Map is not safe for concurrent use: maps are pooled.
+var typeMaps = sync.Pool{
+	New: func() any {
+		return pgtype.NewMap()
+	},
+}
+
+// NewDateRange builds a daterange [lower, upper).
+func NewDateRange(lower, upper time.Time) DateRange {
+	return DateRange{Range: halfOpenRange(lower, upper)}
+}
+
+// NewTimeRange builds a tstzrange [lower, upper).
+func NewTimeRange(lower, upper time.Time) TimeRange {
+	return TimeRange{Range: halfOpenRange(lower, upper)}
+}
+
+// NewNumRange builds a numrange [lower, upper).
+func NewNumRange(lower, upper float64) NumRange {
+	return NumRange{Range: halfOpenRange(lower, upper)}
+}
+
+// NewInt64Range builds an int8range [lower, upper).
+func NewInt64Range(lower, upper int64) Int64Range {
+	return Int64Range{Range: halfOpenRange(lower, upper)}
+}
+
+// Scan implements the database/sql Scanner interface.
+func (r *DateRange) Scan(src any) error { return scanText(pgtype.DaterangeOID, src, &r.Range) }
+
+// Value implements the database/sql/driver Valuer interface.
+func (r DateRange) Value() (driver.Value, error) { return rangeValue(pgtype.DaterangeOID, r.Range) }
+
+// Scan implements the database/sql Scanner interface.
+func (r *TimeRange) Scan(src any) error { return scanText(pgtype.TstzrangeOID, src, &r.Range) }
+
+// Value implements the database/sql/driver Valuer interface.
+func (r TimeRange) Value() (driver.Value, error) { return rangeValue(pgtype.TstzrangeOID, r.Range) }
+
+// Scan implements the database/sql Scanner interface.
+func (r *NumRange) Scan(src any) error { return scanText(pgtype.NumrangeOID, src, &r.Range) }
+
+// Value implements the database/sql/driver Valuer interface.
+func (r NumRange) Value() (driver.Value, error) { return rangeValue(pgtype.NumrangeOID, r.Range) }
+
+// Scan implements the database/sql Scanner interface.
+func (r *Int64Range) Scan(src any) error { return scanText(pgtype.Int8rangeOID, src, &r.Range) }
+
+// Value implements the database/sql/driver Valuer interface.
+func (r Int64Range) Value() (driver.Value, error) { return rangeValue(pgtype.Int8rangeOID, r.Range) }
+
+// RangeOverlaps returns a predicate on a range column, true when the range overlaps the range argument,
+// e.g. RangeOverlaps("period", "$1") returns `"period" && $1`.
+func RangeOverlaps(column, placeholder string) string {
+	return quoteQualifiedIdentifier(column) + " && " + placeholder
+}
+
+// RangeContains returns a predicate on a range column, true when the range contains the range argument,
+// e.g. RangeContains("period", "$1") returns `"period" @> $1`.
+func RangeContains(column, placeholder string) string {
+	return quoteQualifiedIdentifier(column) + " @> " + placeholder
+}
+
+// RangeContainedBy returns a predicate on a range column, true when the range is contained by the range argument,
+// e.g. RangeContainedBy("period", "$1") returns `"period" <@ $1`.
+func RangeContainedBy(column, placeholder string) string {
+	return quoteQualifiedIdentifier(column) + " <@ " + placeholder
+}
+
+// RangeContainsElement returns a predicate on a range column, true when the range contains a single value
+// of the element type, e.g. RangeContainsElement("period", "$1", "date") returns `"period" @> $1::date`.
+//
+// The cast tells postgres that the argument is an element, not a range.
+func RangeContainsElement(column, placeholder, elementType string) string {
+	return quoteQualifiedIdentifier(column) + " @> " + placeholder + "::" + elementType
+}
+
+func halfOpenRange[T any](lower, upper T) pgtype.Range[T] {
+	return pgtype.Range[T]{
+		Lower:     lower,
+		Upper:     upper,
+		LowerType: pgtype.Inclusive,
+		UpperType: pgtype.Exclusive,
+		Valid:     true,
+	}
+}
+
+// scanText decodes a value of a postgres type in the text format, as returned by the database/sql driver.
+func scanText(oid uint32, src, dst any) error {
+	var text []byte
+	switch src := src.(type) {
+	case nil:
+	case string:
+		text = []byte(src)
+	case []byte:
+		text = src
+	default:
+		return fmt.Errorf("cannot scan %T", src)
+	}
+
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	return m.Scan(oid, pgtype.TextFormatCode, text, dst)
+}
+
+// rangeValue encodes a range in the text format.
+func rangeValue[T any](oid uint32, r pgtype.Range[T]) (driver.Value, error) {
+	if !r.Valid {
+		return nil, nil
+	}
+
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	buf, err := m.Encode(oid, pgtype.TextFormatCode, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(buf), nil
+}
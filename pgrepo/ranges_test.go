@@ -0,0 +1,82 @@
+package pgrepo
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRanges(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should encode ranges", func(t *testing.T) {
+		for _, tc := range []struct {
+			value    driver.Valuer
+			expected string
+		}{
+			{value: NewDateRange(from, to), expected: "[2024-01-01,2024-02-01)"},
+			{value: NewNumRange(1.5, 10), expected: "[1.5,10)"},
+			{value: NewInt64Range(1, 10), expected: "[1,10)"},
+		} {
+			value, err := tc.value.Value()
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		}
+
+		value, err := NewTimeRange(from, to).Value()
+		require.NoError(t, err)
+		require.Contains(t, value, "2024-01-01 00:00:00")
+	})
+
+	t.Run("should scan ranges", func(t *testing.T) {
+		var dates DateRange
+		require.NoError(t, dates.Scan("[2024-01-01,2024-02-01)"))
+		require.Equal(t, NewDateRange(from, to), dates)
+
+		var ids Int64Range
+		require.NoError(t, ids.Scan([]byte("[1,10)")))
+		require.Equal(t, NewInt64Range(1, 10), ids)
+
+		var amounts NumRange
+		require.NoError(t, amounts.Scan("(1.5,)"))
+		require.Equal(t, 1.5, amounts.Lower)
+		require.Equal(t, pgtype.Exclusive, amounts.LowerType)
+		require.Equal(t, pgtype.Unbounded, amounts.UpperType)
+
+		var period TimeRange
+		require.NoError(t, period.Scan("[2024-01-01 00:00:00+00,2024-02-01 00:00:00+00)"))
+		require.True(t, period.Lower.Equal(from))
+		require.True(t, period.Upper.Equal(to))
+
+		var empty DateRange
+		require.NoError(t, empty.Scan("empty"))
+		require.True(t, empty.Valid)
+		require.Equal(t, pgtype.Empty, empty.LowerType)
+	})
+
+	t.Run("zero value should be NULL", func(t *testing.T) {
+		value, err := DateRange{}.Value()
+		require.NoError(t, err)
+		require.Nil(t, value)
+
+		scanned := NewInt64Range(1, 2)
+		require.NoError(t, scanned.Scan(nil))
+		require.False(t, scanned.Valid)
+	})
+
+	t.Run("should not scan other types", func(t *testing.T) {
+		var dates DateRange
+		require.Error(t, dates.Scan(42))
+	})
+}
+
+func TestRangePredicates(t *testing.T) {
+	require.Equal(t, `"period" && $1`, RangeOverlaps("period", "$1"))
+	require.Equal(t, `"b"."period" @> $2`, RangeContains("b.period", "$2"))
+	require.Equal(t, `"period" <@ :window`, RangeContainedBy("period", ":window"))
+	require.Equal(t, `"period" @> $1::date`, RangeContainsElement("period", "$1", "date"))
+}
@@ -0,0 +1,61 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// errWrappedConn indicates that a pooled driver connection is not a native pgx connection.
+var errWrappedConn = errors.New("driver connection is wrapped")
+
+// withConn runs a function on a native pgx connection, e.g. to use the COPY protocol.
+//
+// The connection is borrowed from the pool, unless the driver connections are wrapped (e.g. when opencensus
+// tracing is enabled). In that case, a dedicated connection is established with the same configuration.
+func (r *Repository) withConn(ctx context.Context, fn func(context.Context, *pgx.Conn) error) error {
+	if r.db == nil || r.connector == nil {
+		return ErrDBNotInitialized
+	}
+
+	err := r.withPooledConn(ctx, fn)
+	if !errors.Is(err, errWrappedConn) {
+		return err
+	}
+
+	cfg, err := r.connector.connectConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	return fn(ctx, conn)
+}
+
+func (r *Repository) withPooledConn(ctx context.Context, fn func(context.Context, *pgx.Conn) error) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	return conn.Raw(func(driverConn any) error {
+		native, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errWrappedConn
+		}
+
+		return fn(ctx, native.Conn())
+	})
+}
@@ -0,0 +1,169 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const maxFingerprintLength = 1024
+
+var (
+	_ pgx.QueryTracer = &queryRing{}
+
+	rexStringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	rexNumericLiteral = regexp.MustCompile(`(^|[^$\w.])-?\d+(?:\.\d+)?\b`)
+	rexBlanks         = regexp.MustCompile(`\s+`)
+)
+
+// QueryRecord describes a recent query execution.
+type QueryRecord struct {
+	Fingerprint string        `json:"fingerprint"` // the query, with literals replaced by "?"
+	Start       time.Time     `json:"start"`
+	Duration    time.Duration `json:"duration_ns"`
+	Rows        int64         `json:"rows"`
+	Error       string        `json:"error,omitempty"`
+}
+
+// WithRecentQueries keeps the last n query executions in memory, for inspection with RecentQueries.
+func WithRecentQueries(n int) PoolOption {
+	return func(o *poolSettings) {
+		o.RecentQueries = n
+	}
+}
+
+// RecentQueries returns the last query executions, most recent first.
+//
+// It returns nil unless recent queries are kept, with the "recentQueries" pool setting or WithRecentQueries.
+func (r *Repository) RecentQueries() []QueryRecord {
+	return r.recent.snapshot()
+}
+
+// RecentQueriesHandler serves the last query executions as JSON, e.g. on a debug endpoint.
+//
+// Records are sorted with the most recent first, or with the slowest first with the query parameter "sort=duration".
+func (r *Repository) RecentQueriesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		records := r.RecentQueries()
+		if records == nil {
+			records = []QueryRecord{}
+		}
+
+		if req.URL.Query().Get("sort") == "duration" {
+			sort.SliceStable(records, func(i, j int) bool {
+				return records[i].Duration > records[j].Duration
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(records)
+	})
+}
+
+// queryRing is a pgx tracer which keeps the last query executions in a ring buffer.
+type queryRing struct {
+	mx      sync.Mutex
+	records []QueryRecord
+	next    int
+	full    bool
+}
+
+type queryRingKey struct{}
+
+type queryStart struct {
+	sql   string
+	start time.Time
+}
+
+func newQueryRing(size int) *queryRing {
+	if size <= 0 {
+		return nil
+	}
+
+	return &queryRing{records: make([]QueryRecord, size)}
+}
+
+func (q *queryRing) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, queryRingKey{}, queryStart{sql: data.SQL, start: time.Now()})
+}
+
+func (q *queryRing) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	started, ok := ctx.Value(queryRingKey{}).(queryStart)
+	if !ok {
+		return
+	}
+
+	record := QueryRecord{
+		Fingerprint: Fingerprint(started.sql),
+		Start:       started.start,
+		Duration:    time.Since(started.start),
+		Rows:        data.CommandTag.RowsAffected(),
+	}
+	if data.Err != nil {
+		record.Error = data.Err.Error()
+	}
+
+	q.add(record)
+}
+
+func (q *queryRing) add(record QueryRecord) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	q.records[q.next] = record
+	q.next = (q.next + 1) % len(q.records)
+	if q.next == 0 {
+		q.full = true
+	}
+}
+
+// snapshot returns a copy of the records, most recent first.
+func (q *queryRing) snapshot() []QueryRecord {
+	if q == nil {
+		return nil
+	}
+
+	q.mx.Lock()
+	defer q.mx.Unlock()
+
+	n := q.next
+	if q.full {
+		n = len(q.records)
+	}
+
+	records := make([]QueryRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		records = append(records, q.records[(q.next-i+len(q.records))%len(q.records)])
+	}
+
+	return records
+}
+
+// Fingerprint normalizes a query, so that executions with different literal values look alike:
+// literals are replaced by "?" and blanks are collapsed.
+//
+// Long fingerprints are truncated (see NormalizeQuery).
+func Fingerprint(query string) string {
+	query = NormalizeQuery(query)
+
+	if len(query) > maxFingerprintLength {
+		query = truncateApplicationName(query, maxFingerprintLength) + "..."
+	}
+
+	return query
+}
+
+// NormalizeQuery replaces the literals of a query by "?" and collapses blanks, like Fingerprint, without truncating it.
+func NormalizeQuery(query string) string {
+	query = rexStringLiteral.ReplaceAllString(query, "?")
+	query = rexNumericLiteral.ReplaceAllString(query, "${1}?")
+
+	return strings.TrimSpace(rexBlanks.ReplaceAllString(query, " "))
+}
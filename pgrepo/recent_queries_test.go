@@ -0,0 +1,87 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFingerprint(t *testing.T) {
+	require.Equal(t,
+		`SELECT * FROM users WHERE id = ? AND name = ? AND t1.x > ? AND y = $1`,
+		Fingerprint("SELECT *\n\tFROM users WHERE id = 42 AND name = 'O''Brien' AND t1.x > -1.5 AND y = $1"),
+	)
+
+	t.Run("should truncate long queries without splitting multi-byte characters", func(t *testing.T) {
+		fingerprint := Fingerprint("SELECT " + strings.Repeat("é", maxFingerprintLength))
+
+		require.True(t, utf8.ValidString(fingerprint))
+		require.Len(t, fingerprint, maxFingerprintLength-1+3)
+	})
+
+	t.Run("should not truncate normalized queries", func(t *testing.T) {
+		query := "SELECT " + strings.Repeat("a, ", maxFingerprintLength) + "b FROM t"
+
+		require.Equal(t, query, NormalizeQuery(query))
+	})
+}
+
+func TestRecentQueries(t *testing.T) {
+	t.Run("should be disabled by default", func(t *testing.T) {
+		r := &Repository{}
+		require.Nil(t, r.RecentQueries())
+		require.Nil(t, newQueryRing(0))
+	})
+
+	ring := newQueryRing(3)
+	for i, query := range []string{`SELECT 1`, `SELECT 2`, `DELETE FROM t`, `UPDATE t SET a = 'x'`} {
+		ctx := ring.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: query})
+		data := pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("UPDATE 3")}
+		if i == 2 {
+			data = pgx.TraceQueryEndData{Err: errors.New("canceled")}
+			time.Sleep(10 * time.Millisecond)
+		}
+		ring.TraceQueryEnd(ctx, nil, data)
+	}
+
+	r := &Repository{recent: ring, databaseSettings: databaseSettings{URL: DefaultURL}}
+	records := r.RecentQueries()
+	require.Len(t, records, 3)
+	require.Equal(t, `UPDATE t SET a = ?`, records[0].Fingerprint)
+	require.Equal(t, int64(3), records[0].Rows)
+	require.Equal(t, `DELETE FROM t`, records[1].Fingerprint)
+	require.Equal(t, "canceled", records[1].Error)
+	require.Equal(t, `SELECT ?`, records[2].Fingerprint)
+
+	t.Run("should serve recent queries", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.RecentQueriesHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/queries?sort=duration", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var served []QueryRecord
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&served))
+		require.Len(t, served, 3)
+		require.Equal(t, `DELETE FROM t`, served[0].Fingerprint, "expected the slowest query first")
+	})
+
+	t.Run("should trace queries of the driver", func(t *testing.T) {
+		dcfg := r.ConnConfig(r.DBURL(), log.NewFactory(zap.NewNop()), "", r.connTracers(roleMaster)...)
+		require.NotNil(t, dcfg)
+
+		multi, ok := dcfg.Tracer.(multiTracer)
+		require.True(t, ok)
+		require.Contains(t, multi.tracers, pgx.QueryTracer(ring))
+	})
+}
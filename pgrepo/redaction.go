@@ -0,0 +1,96 @@
+package pgrepo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+// redactedParam replaces the value of query parameters in logs.
+const redactedParam = "[redacted]"
+
+type (
+	// ParamSanitizer transforms the value of a query parameter before it is logged or traced,
+	// e.g. to mask personal data. The index of the parameter starts at 0.
+	ParamSanitizer func(query string, index int, value any) any
+
+	logParamsKey struct{}
+)
+
+// WithSensitiveParams logs and traces the parameters of queries. By default, parameters are redacted,
+// so that credentials or personal data never land in logs or trace spans.
+//
+// Query plans attached to trace spans may include the values of parameters: they require sensitive params as well
+// (see WithExplainSlowQueries).
+//
+// Logged parameters are transformed by the sanitizer, if any (see WithParamSanitizer).
+func WithSensitiveParams(enabled bool) PoolOption {
+	return func(o *poolSettings) {
+		o.SensitiveParams = enabled
+	}
+}
+
+// WithParamSanitizer transforms the parameters of queries before they are logged.
+//
+// The sanitizer applies whenever parameters are logged, i.e. with WithSensitiveParams or LogParams.
+// Since the opencensus driver wrapper cannot sanitize parameters, they are not traced with a sanitizer.
+func WithParamSanitizer(sanitizer ParamSanitizer) Option {
+	return func(o *settings) {
+		o.paramSanitizer = sanitizer
+	}
+}
+
+// LogParams opts in to the logging of the parameters of queries executed with this context (or a derived one),
+// e.g. to troubleshoot a specific query when parameters are redacted by default.
+//
+// The parameters are logged by the driver, transformed by the sanitizer, if any (see WithParamSanitizer).
+//
+// LogParams only applies to logs: parameters are still redacted from trace spans, which carry neither parameters
+// nor query plans unless WithSensitiveParams is enabled.
+func LogParams(ctx context.Context) context.Context {
+	return context.WithValue(ctx, logParamsKey{}, true)
+}
+
+func paramsLogged(ctx context.Context) bool {
+	logged, _ := ctx.Value(logParamsKey{}).(bool)
+
+	return logged
+}
+
+func (p *poolSettings) sensitiveParams() bool {
+	return p != nil && p.SensitiveParams
+}
+
+// paramsRedactor redacts or sanitizes the parameters of queries in driver logs.
+type paramsRedactor struct {
+	tracelog.Logger
+	sensitive bool
+	sanitize  ParamSanitizer
+}
+
+func (l paramsRedactor) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]interface{}) {
+	args, ok := data["args"].([]any)
+	if !ok || len(args) == 0 {
+		l.Logger.Log(ctx, level, msg, data)
+
+		return
+	}
+
+	logged := make([]any, len(args))
+	switch {
+	case !l.sensitive && !paramsLogged(ctx):
+		for i := range logged {
+			logged[i] = redactedParam
+		}
+	case l.sanitize != nil:
+		query, _ := data["sql"].(string)
+		for i, arg := range args {
+			logged[i] = l.sanitize(query, i, arg)
+		}
+	default:
+		copy(logged, args)
+	}
+	data["args"] = logged
+
+	l.Logger.Log(ctx, level, msg, data)
+}
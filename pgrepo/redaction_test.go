@@ -0,0 +1,83 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParamsRedactor(t *testing.T) {
+	ctx := context.Background()
+	query := `SELECT * FROM users WHERE email = $1 AND tenant = $2`
+	logQuery := func(lg paramsRedactor, ctx context.Context) []any {
+		capture := &captureLogger{}
+		lg.Logger = capture
+		lg.Log(ctx, tracelog.LogLevelInfo, "Query", map[string]interface{}{"sql": query, "args": []any{"jane@example.com", 42}})
+		require.Len(t, capture.logs, 1)
+		args, ok := capture.logs[0].data["args"].([]any)
+		require.True(t, ok)
+
+		return args
+	}
+
+	t.Run("should redact parameters by default", func(t *testing.T) {
+		require.Equal(t, []any{redactedParam, redactedParam}, logQuery(paramsRedactor{}, ctx))
+	})
+
+	t.Run("should log parameters of queries opted in", func(t *testing.T) {
+		require.Equal(t, []any{"jane@example.com", 42}, logQuery(paramsRedactor{}, LogParams(ctx)))
+	})
+
+	t.Run("should log sensitive parameters", func(t *testing.T) {
+		require.Equal(t, []any{"jane@example.com", 42}, logQuery(paramsRedactor{sensitive: true}, ctx))
+	})
+
+	t.Run("should sanitize logged parameters", func(t *testing.T) {
+		sanitize := func(q string, i int, v any) any {
+			require.Equal(t, query, q)
+			if i == 0 {
+				return "***"
+			}
+
+			return fmt.Sprint(v)
+		}
+
+		require.Equal(t, []any{"***", "42"}, logQuery(paramsRedactor{sensitive: true, sanitize: sanitize}, ctx))
+		require.Equal(t, []any{"***", "42"}, logQuery(paramsRedactor{sanitize: sanitize}, LogParams(ctx)))
+		require.Equal(t, []any{redactedParam, redactedParam}, logQuery(paramsRedactor{sanitize: sanitize}, ctx))
+	})
+
+	t.Run("should pass on logs without parameters", func(t *testing.T) {
+		capture := &captureLogger{}
+		lg := paramsRedactor{Logger: capture}
+		lg.Log(ctx, tracelog.LogLevelInfo, "Connect", map[string]interface{}{"host": "localhost"})
+		require.Len(t, capture.logs, 1)
+		require.NotContains(t, capture.logs[0].data, "args")
+	})
+}
+
+func TestSensitiveParamsSettings(t *testing.T) {
+	sanitizer := func(string, int, any) any { return redactedParam }
+
+	t.Run("should redact parameters in driver logs", func(t *testing.T) {
+		dbs := settingsFromOptions([]Option{WithParamSanitizer(sanitizer)}).DBSettingsFor(DefaultDBAlias)
+		require.False(t, dbs.PGConfig.sensitiveParams())
+		require.NotNil(t, dbs.paramSanitizer)
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "app")
+		tr, ok := dcfg.Tracer.(*tracelog.TraceLog)
+		require.True(t, ok)
+		require.IsType(t, paramsRedactor{}, tr.Logger)
+	})
+
+	t.Run("should enable sensitive parameters", func(t *testing.T) {
+		ps := poolSettingsFromOptions([]PoolOption{WithSensitiveParams(true)})
+		require.True(t, ps.sensitiveParams())
+		require.False(t, (*poolSettings)(nil).sensitiveParams())
+	})
+}
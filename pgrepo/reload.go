@@ -0,0 +1,371 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var _ driver.Connector = &reloadableConnector{}
+
+// reloadableConnector is a driver connector which configuration may be replaced at runtime, without closing the pool.
+//
+// Connections established with a former configuration are discarded when they are about to be reused.
+type reloadableConnector struct {
+	mx         sync.RWMutex
+	connector  driver.Connector
+	cfg        *pgx.ConnConfig
+	reset      func(context.Context, *pgx.Conn) error
+	before     func(context.Context, *pgx.ConnConfig) error
+	settings   databaseSettings
+	guard      *circuitBreaker // guards the connections to the master, if any
+	generation uint64
+	resumed    chan struct{} // closed when new connections are allowed again, after a drain
+	closing    bool          // new connections are refused, during a shutdown
+	conns      sync.Map      // *pgx.Conn -> connState
+}
+
+// connState tracks a connection established by the connector.
+type connState struct {
+	generation uint64    // the generation of the configuration used to establish the connection
+	expires    time.Time // when the connection is discarded, with a jittered lifetime (see WithConnMaxLifetimeJitter)
+}
+
+func newReloadableConnector(dcfg *pgx.ConnConfig, s databaseSettings, breaker *circuitBreaker) *reloadableConnector {
+	c := &reloadableConnector{guard: breaker}
+	c.swap(dcfg, s)
+
+	return c
+}
+
+// swap the configuration used to establish new connections.
+func (c *reloadableConnector) swap(dcfg *pgx.ConnConfig, s databaseSettings) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.before = s.beforeConnect()
+	opts := []stdlib.OptionOpenDB{stdlib.OptionResetSession(c.resetSession)}
+	if c.before != nil {
+		opts = append(opts, stdlib.OptionBeforeConnect(c.before))
+	}
+
+	c.connector = stdlib.GetConnector(*dcfg, opts...)
+	c.cfg = dcfg
+	c.reset = s.resetSession()
+	c.settings = s
+	c.generation++
+}
+
+func (c *reloadableConnector) current() (driver.Connector, uint64) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return c.connector, c.generation
+}
+
+// connectConfig returns the current driver configuration, with refreshed credentials.
+func (c *reloadableConnector) connectConfig(ctx context.Context) (*pgx.ConnConfig, error) {
+	c.mx.RLock()
+	cfg, before := c.cfg.Copy(), c.before
+	c.mx.RUnlock()
+
+	if before == nil {
+		return cfg, nil
+	}
+
+	return cfg, before(ctx, cfg)
+}
+
+// appliedSettings returns the settings of the current driver configuration.
+func (c *reloadableConnector) appliedSettings() databaseSettings {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return c.settings
+}
+
+// breaker returns the circuit breaker guarding connections, if any.
+func (c *reloadableConnector) breaker() *circuitBreaker {
+	return c.guard
+}
+
+// pause new connections, and discard the connections established so far when they are next reused.
+func (c *reloadableConnector) pause() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+	}
+	c.generation++
+}
+
+// resume new connections after a pause.
+func (c *reloadableConnector) resume() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+	}
+}
+
+// shutdown refuses new connections, and discards the connections established so far when they are next reused.
+func (c *reloadableConnector) shutdown() {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.closing = true
+	c.generation++
+}
+
+// forceClose closes the network connections of all connections still open, and returns how many were closed.
+//
+// Queries in flight fail, and their connections are discarded when released.
+func (c *reloadableConnector) forceClose() int {
+	var closed int
+	c.conns.Range(func(key, _ any) bool {
+		conn := key.(*pgx.Conn)
+		c.conns.Delete(conn)
+		if conn.IsClosed() {
+			return true
+		}
+
+		// closing the network connection is safe while the connection is in use
+		if err := conn.PgConn().Conn().Close(); err == nil {
+			closed++
+		}
+
+		return true
+	})
+
+	return closed
+}
+
+// waitResumed waits until new connections are allowed.
+func (c *reloadableConnector) waitResumed(ctx context.Context) error {
+	c.mx.RLock()
+	resumed, closing := c.resumed, c.closing
+	c.mx.RUnlock()
+
+	if closing {
+		return ErrShuttingDown
+	}
+
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-resumed:
+		return nil
+	}
+}
+
+func (c *reloadableConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.waitResumed(ctx); err != nil {
+		return nil, err
+	}
+
+	connector, generation := c.current()
+	breaker := c.breaker()
+	if err := breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	conn, err := connector.Connect(ctx)
+	breaker.record(err)
+	if err != nil {
+		return nil, err
+	}
+
+	if native, ok := conn.(*stdlib.Conn); ok {
+		state := connState{generation: generation}
+		if lifetime := c.appliedSettings().connLifetime(); lifetime > 0 {
+			state.expires = time.Now().Add(lifetime)
+		}
+
+		c.prune()
+		c.conns.Store(native.Conn(), state)
+	}
+
+	return conn, nil
+}
+
+func (c *reloadableConnector) Driver() driver.Driver {
+	connector, _ := c.current()
+
+	return connector.Driver()
+}
+
+// resetSession discards connections established with a former configuration or past their jittered lifetime,
+// then applies the session reset policy.
+//
+// Connections are discarded as well while the circuit breaker is open, so callers fail fast.
+func (c *reloadableConnector) resetSession(ctx context.Context, conn *pgx.Conn) error {
+	c.mx.RLock()
+	generation, reset := c.generation, c.reset
+	c.mx.RUnlock()
+	breaker := c.guard
+
+	if breaker.isOpen() {
+		return driver.ErrBadConn
+	}
+
+	if established, ok := c.conns.Load(conn); ok {
+		state := established.(connState)
+		if state.generation != generation || (!state.expires.IsZero() && time.Now().After(state.expires)) {
+			c.conns.Delete(conn)
+
+			return driver.ErrBadConn
+		}
+	}
+
+	if reset == nil {
+		return nil
+	}
+
+	return reset(ctx, conn)
+}
+
+// prune forgets about closed connections.
+func (c *reloadableConnector) prune() {
+	c.conns.Range(func(key, _ any) bool {
+		if conn := key.(*pgx.Conn); conn.IsClosed() {
+			c.conns.Delete(conn)
+		}
+
+		return true
+	})
+}
+
+// Reload applies the settings from a viper configuration to a started repository, without restarting it.
+//
+// Pool sizes are applied immediately.
+//
+// Changes to the connection URL, credentials, driver log level, SET parameters or session reset policy
+// apply to new connections: connections established with former settings are discarded when they are next reused.
+//
+// The options the repository was built with (e.g. WithTLSConfig, WithDSN) apply over the reloaded configuration,
+// just like they did over the initial one.
+//
+// Other settings (e.g. replicas, opencensus tracing, partitions or the wait monitor) require a restart.
+func (r *Repository) Reload(cfg *viper.Viper) error {
+	if r.db == nil || r.connector == nil {
+		return ErrDBNotInitialized
+	}
+
+	reloaded, err := makeSettingsFromViper(cfg, r.log.Zap())
+	if err != nil {
+		return err
+	}
+
+	s := reloaded.clone()
+	s.runtimeSettings = r.runtimeSettings
+	s.reloaded = &reloaded
+	for _, apply := range r.opts {
+		apply(&s)
+	}
+	s.runtimeSettings = r.runtimeSettings
+
+	return r.reload(s.DBSettingsFor(r.alias), false)
+}
+
+// reload applies new settings. When force is true, connections are renewed even if the driver settings are unchanged
+// (e.g. when the content of a secret file has changed).
+func (r *Repository) reload(dbs databaseSettings, force bool) error {
+	dbs.runtimeSettings = r.runtimeSettings
+	if err := dbs.Validate(); err != nil {
+		return err
+	}
+
+	l := r.log.Bg()
+	dbs.SetPool(r.db.DB)
+	r.replicas.setPool(dbs)
+
+	previous := r.connector.appliedSettings()
+	if !force && !driverSettingsChanged(previous, dbs) {
+		l.Info("pool settings reloaded")
+
+		return nil
+	}
+
+	connCfg := dbs.ConnConfig(dbs.DBURL(), r.log, r.app, r.connTracers(roleMaster)...)
+	if connCfg == nil {
+		return ErrInvalidConfig
+	}
+	r.endpoints.apply(connCfg)
+
+	r.connector.swap(connCfg, dbs)
+	l.Info("driver settings reloaded: connections will be renewed", zap.String("db_url", dbs.RedactedURL()))
+
+	return nil
+}
+
+// driverSettingsChanged tells if settings which apply to the driver connections have changed.
+func driverSettingsChanged(previous, current databaseSettings) bool {
+	type driverSettings struct {
+		URL, DSN, User, Password string
+		TargetSessionAttrs       string
+		URLFrom, PasswordFrom    secretSource
+		Auth                     string
+		AWS                      awsSettings
+		Kerberos                 kerberosSettings
+		GSSEncMode               string
+		ChannelBinding           string
+		TLS                      tlsSettings
+		Set                      map[string]string
+		Log                      logSettings
+		ResetPolicy              string
+		QueryExecMode            string
+		StatementCache           *int
+		DescriptionCache         *int
+		Timeouts                 map[string]string
+		Tags                     map[string]string
+		OTelEnabled              bool
+	}
+
+	extract := func(s databaseSettings) driverSettings {
+		d := driverSettings{
+			URL:                s.URL,
+			DSN:                s.DSN,
+			TargetSessionAttrs: s.TargetSessionAttrs,
+			User:               s.User,
+			Password:           s.Password,
+			URLFrom:            s.URLFrom,
+			PasswordFrom:       s.PasswordFrom,
+			Auth:               s.Auth,
+			AWS:                s.AWS,
+			Kerberos:           s.Kerberos,
+			GSSEncMode:         s.GSSEncMode,
+			ChannelBinding:     s.ChannelBinding,
+			TLS:                s.TLS,
+			Tags:               s.Tags,
+			Set:                s.setParams(),
+		}
+
+		if s.PGConfig != nil {
+			d.Log = s.PGConfig.Log
+			d.ResetPolicy = s.PGConfig.resetPolicy()
+			d.QueryExecMode = s.PGConfig.queryExecMode()
+			d.StatementCache = s.PGConfig.StatementCacheCapacity
+			d.DescriptionCache = s.PGConfig.DescriptionCacheCapacity
+			d.Timeouts = s.PGConfig.timeoutParams()
+			d.OTelEnabled = s.PGConfig.Trace.usesTraceProvider(TraceProviderOTel)
+		}
+
+		return d
+	}
+
+	return !reflect.DeepEqual(extract(previous), extract(current))
+}
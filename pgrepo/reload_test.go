@@ -0,0 +1,143 @@
+package pgrepo
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReload(t *testing.T) {
+	t.Run("repository is not started", func(t *testing.T) {
+		r := &Repository{}
+		require.ErrorIs(t, r.Reload(viper.New()), ErrDBNotInitialized)
+	})
+
+	const initial = `
+databases:
+  postgres:
+    default:
+      url: 'postgresql://localhost:5432/testdb'
+      pgconfig:
+        maxOpenConns: 10
+        log:
+          level: warn
+`
+
+	cfg := viper.New()
+	cfg.SetConfigType("yaml")
+	require.NoError(t, cfg.ReadConfig(strings.NewReader(initial)))
+
+	r := New(DefaultDBAlias, WithViper(cfg), WithLogger(zap.NewNop()))
+	connCfg := r.ConnConfig(r.DBURL(), r.log, r.app)
+	require.NotNil(t, connCfg)
+
+	db, connector, err := r.openPool(connCfg, nil) // no connection established
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	r.db = db
+	r.connector = connector
+
+	_, generation := connector.current()
+
+	t.Run("should apply pool settings in place", func(t *testing.T) {
+		require.NoError(t, cfg.ReadConfig(strings.NewReader(strings.Replace(initial, "maxOpenConns: 10", "maxOpenConns: 20", 1))))
+		require.NoError(t, r.Reload(cfg))
+
+		require.Equal(t, 20, db.Stats().MaxOpenConnections)
+		_, current := connector.current()
+		require.Equal(t, generation, current, "expected driver connections to be kept")
+	})
+
+	t.Run("should renew connections when driver settings change", func(t *testing.T) {
+		require.NoError(t, cfg.ReadConfig(strings.NewReader(strings.Replace(initial, "level: warn", "level: debug", 1))))
+		require.NoError(t, r.Reload(cfg))
+
+		require.Equal(t, 10, db.Stats().MaxOpenConnections)
+		_, current := connector.current()
+		require.Equal(t, generation+1, current)
+		require.Equal(t, "debug", connector.appliedSettings().PGConfig.Log.Level)
+	})
+
+	t.Run("should reject invalid settings", func(t *testing.T) {
+		require.NoError(t, cfg.ReadConfig(strings.NewReader(strings.Replace(initial, "level: warn", "level: verbose", 1))))
+		require.Error(t, r.Reload(cfg))
+
+		require.Equal(t, "debug", connector.appliedSettings().PGConfig.Log.Level)
+	})
+}
+
+func TestReloadWithOptions(t *testing.T) {
+	const initial = `
+databases:
+  pgconfig:
+    maxOpenConns: 10
+`
+
+	cfg := viper.New()
+	cfg.SetConfigType("yaml")
+	require.NoError(t, cfg.ReadConfig(strings.NewReader(initial)))
+
+	const dsn = "host=db.example.com port=5432 dbname=app user=app"
+	tlsConfig := &tls.Config{ServerName: "db.example.com", MinVersion: tls.VersionTLS12}
+	r := New(DefaultDBAlias,
+		WithViper(cfg),
+		WithLogger(zap.NewNop()),
+		WithDatabaseSettings(DefaultDBAlias, WithDSN(dsn), WithTLSConfig(tlsConfig)),
+	)
+	connCfg := r.ConnConfig(r.DBURL(), r.log, r.app)
+	require.NotNil(t, connCfg)
+
+	db, connector, err := r.openPool(connCfg, nil) // no connection established
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	r.db = db
+	r.connector = connector
+
+	_, generation := connector.current()
+
+	require.NoError(t, cfg.ReadConfig(strings.NewReader(strings.Replace(initial, "maxOpenConns: 10", "maxOpenConns: 20", 1))))
+	require.NoError(t, r.Reload(cfg))
+
+	require.Equal(t, 20, db.Stats().MaxOpenConnections)
+	_, current := connector.current()
+	require.Equal(t, generation, current, "expected driver connections to be kept")
+
+	applied := connector.appliedSettings()
+	require.Equal(t, dsn, applied.DSN)
+	require.Same(t, tlsConfig, applied.TLS.config)
+}
+
+func TestReloadableConnector(t *testing.T) {
+	dbs := databaseSettings{URL: DefaultURL}
+	connCfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "")
+	require.NotNil(t, connCfg)
+
+	connector := newReloadableConnector(connCfg, dbs, nil)
+	conn := &pgx.Conn{}
+	_, generation := connector.current()
+	connector.conns.Store(conn, connState{generation: generation})
+
+	require.NoError(t, connector.resetSession(context.Background(), conn))
+
+	connector.swap(connCfg, dbs)
+	require.ErrorIs(t, connector.resetSession(context.Background(), conn), driver.ErrBadConn)
+
+	t.Run("should detect changes of driver settings", func(t *testing.T) {
+		previous := databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions(nil)}
+
+		require.False(t, driverSettingsChanged(previous, databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(5)})}))
+		require.True(t, driverSettingsChanged(previous, databaseSettings{URL: DefaultURL, Password: "changed", PGConfig: poolSettingsFromOptions(nil)}))
+		require.True(t, driverSettingsChanged(previous, databaseSettings{URL: DefaultURL, PGConfig: poolSettingsFromOptions([]PoolOption{WithSetClause("work_mem", "'64MB'")})}))
+		require.True(t, driverSettingsChanged(previous, databaseSettings{URL: DefaultURL, Kerberos: kerberosSettings{SrvName: "pg"}, PGConfig: poolSettingsFromOptions(nil)}))
+		require.True(t, driverSettingsChanged(previous, databaseSettings{URL: DefaultURL, GSSEncMode: "disable", PGConfig: poolSettingsFromOptions(nil)}))
+		require.True(t, driverSettingsChanged(previous, databaseSettings{URL: DefaultURL, ChannelBinding: "disable", PGConfig: poolSettingsFromOptions(nil)}))
+	})
+}
@@ -0,0 +1,397 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type (
+	// replica is a connection pool to a read-only replica.
+	replica struct {
+		db      *sqlx.DB
+		name    string
+		healthy atomic.Bool
+		primary atomic.Bool // the replica URL actually points to a primary
+	}
+
+	// replicaSet load-balances over healthy replicas.
+	replicaSet struct {
+		mx      sync.RWMutex
+		members []*replica
+		counter atomic.Uint64
+		timeout time.Duration
+	}
+)
+
+func redactURL(u string) string {
+	return redactConnString(u)
+}
+
+// openReplicas opens a connection pool for all configured replicas, or for the replicas reported by
+// the endpoint resolver, if any.
+//
+// Replicas that fail to be configured are skipped. Replicas that are not reachable are
+// considered unhealthy until the next successful health check.
+func (r *Repository) openReplicas(ctx context.Context) *replicaSet {
+	set := &replicaSet{
+		timeout: r.maxWait(),
+	}
+
+	if discovered := r.endpoints.get().Replicas; len(discovered) > 0 {
+		set.members = r.openReplicaEndpoints(discovered)
+	} else {
+		set.members = r.openReplicaURLs(r.Replicas)
+	}
+
+	set.check(ctx, r.log.Bg())
+
+	return set
+}
+
+// openReplicaURLs opens a connection pool for each replica URL.
+func (r *Repository) openReplicaURLs(urls []string) []*replica {
+	members := make([]*replica, 0, len(urls))
+
+	for _, replicaURL := range urls {
+		u := os.ExpandEnv(replicaURL)
+		if member := r.openReplica(r.replicaConnConfig(u), redactURL(u)); member != nil {
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
+
+// openReplicaEndpoints opens a connection pool for each replica endpoint, with the connection parameters of the master.
+func (r *Repository) openReplicaEndpoints(endpoints []Endpoint) []*replica {
+	members := make([]*replica, 0, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		dcfg := r.replicaConnConfig(r.DBURL())
+		if dcfg != nil {
+			endpoint.apply(dcfg)
+		}
+
+		if member := r.openReplica(dcfg, endpoint.String()); member != nil {
+			members = append(members, member)
+		}
+	}
+
+	return members
+}
+
+func (r *Repository) openReplica(dcfg *pgx.ConnConfig, name string) *replica {
+	// the circuit breaker guards the master only: replicas are skipped when unhealthy
+	db, _, err := r.openPool(dcfg, nil)
+	if err != nil {
+		r.log.Bg().Error("could not configure replica", zap.String("replica", name), zap.Error(err))
+
+		return nil
+	}
+
+	r.SetPool(db.DB)
+
+	return &replica{db: db, name: name}
+}
+
+// replicaConnConfig builds the driver configuration for a replica.
+//
+// Unless disabled, transactions on replicas are read-only by default.
+func (r *Repository) replicaConnConfig(u string) *pgx.ConnConfig {
+	dcfg := r.ConnConfig(u, r.log, r.app, r.connTracers(roleReplica)...)
+	if dcfg == nil || !r.replicaReadOnly() {
+		return dcfg
+	}
+
+	if dcfg.RuntimeParams == nil {
+		dcfg.RuntimeParams = make(map[string]string, 1)
+	}
+	dcfg.RuntimeParams["default_transaction_read_only"] = "on"
+
+	return dcfg
+}
+
+// next returns the next healthy replica, or nil if none is available.
+func (s *replicaSet) next() *sqlx.DB {
+	if s == nil {
+		return nil
+	}
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	var healthy uint64
+	for _, member := range s.members {
+		if member.healthy.Load() {
+			healthy++
+		}
+	}
+
+	if healthy == 0 {
+		return nil
+	}
+
+	k := s.counter.Add(1) % healthy
+	for _, member := range s.members {
+		if !member.healthy.Load() {
+			continue
+		}
+
+		if k == 0 {
+			return member.db
+		}
+		k--
+	}
+
+	return nil
+}
+
+// healthy returns the healthy replicas, starting with the next one in the round-robin.
+func (s *replicaSet) healthy() []*replica {
+	if s == nil {
+		return nil
+	}
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	healthy := make([]*replica, 0, len(s.members))
+	for _, member := range s.members {
+		if member.healthy.Load() {
+			healthy = append(healthy, member)
+		}
+	}
+
+	if len(healthy) < 2 {
+		return healthy
+	}
+
+	k := int(s.counter.Add(1) % uint64(len(healthy)))
+	rotated := make([]*replica, 0, len(healthy))
+
+	return append(append(rotated, healthy[k:]...), healthy[:k]...)
+}
+
+// check pings all replicas and updates their health status.
+//
+// Replicas are expected to be in recovery mode: an error is logged whenever a replica actually
+// points to a primary server, which is a common misconfiguration.
+func (s *replicaSet) check(ctx context.Context, l log.Logger) {
+	s.mx.RLock()
+	members := s.members
+	s.mx.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, member := range members {
+		wg.Add(1)
+
+		go func(member *replica) {
+			defer wg.Done()
+
+			ctxTimeout, cancel := context.WithTimeout(ctx, s.timeout)
+			defer cancel()
+
+			var inRecovery bool
+			err := member.db.QueryRowContext(ctxTimeout, `SELECT pg_is_in_recovery()`).Scan(&inRecovery)
+			healthy := err == nil
+			if wasHealthy := member.healthy.Swap(healthy); wasHealthy != healthy {
+				if healthy {
+					l.Info("replica is available", zap.String("replica", member.name))
+				} else {
+					l.Warn("replica is unavailable", zap.String("replica", member.name), zap.Error(err))
+				}
+			}
+
+			if !healthy {
+				return
+			}
+
+			primary := !inRecovery
+			if wasPrimary := member.primary.Swap(primary); wasPrimary != primary {
+				if primary {
+					l.Error("replica is not in recovery: the replica URL points to a primary server", zap.String("replica", member.name))
+				} else {
+					l.Info("replica is in recovery", zap.String("replica", member.name))
+				}
+			}
+		}(member)
+	}
+
+	wg.Wait()
+}
+
+// startHealthCheck periodically checks the health of replicas.
+//
+// It returns a function to stop the health check.
+func (s *replicaSet) startHealthCheck(interval time.Duration, l log.Logger) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.check(ctx, l)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// stats returns the cumulated statistics of all replica connection pools.
+func (s *replicaSet) stats() (sql.DBStats, bool) {
+	var total sql.DBStats
+	if s == nil {
+		return total, false
+	}
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	for _, member := range s.members {
+		stats := member.db.Stats()
+		total.MaxOpenConnections += stats.MaxOpenConnections
+		total.OpenConnections += stats.OpenConnections
+		total.InUse += stats.InUse
+		total.Idle += stats.Idle
+		total.WaitCount += stats.WaitCount
+		total.WaitDuration += stats.WaitDuration
+		total.MaxIdleClosed += stats.MaxIdleClosed
+		total.MaxIdleTimeClosed += stats.MaxIdleTimeClosed
+		total.MaxLifetimeClosed += stats.MaxLifetimeClosed
+	}
+
+	return total, len(s.members) > 0
+}
+
+// setPool applies pool settings to all replica connection pools.
+func (s *replicaSet) setPool(dbs databaseSettings) {
+	if s == nil {
+		return
+	}
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	for _, member := range s.members {
+		dbs.SetPool(member.db.DB)
+	}
+}
+
+// update the members of the replica set to match a list of endpoints, e.g. when replicas are discovered.
+//
+// Connection pools are opened for new endpoints, and checked before being used. The connection pools
+// of endpoints which are gone are closed.
+func (s *replicaSet) update(ctx context.Context, endpoints []Endpoint, open func([]Endpoint) []*replica, l log.Logger) {
+	s.mx.RLock()
+	current := make(map[string]*replica, len(s.members))
+	for _, member := range s.members {
+		current[member.name] = member
+	}
+	s.mx.RUnlock()
+
+	members := make([]*replica, 0, len(endpoints))
+	kept := make(map[*replica]bool, len(endpoints))
+	var added []Endpoint
+	for _, endpoint := range endpoints {
+		if member, ok := current[endpoint.String()]; ok {
+			members = append(members, member)
+			kept[member] = true
+
+			continue
+		}
+
+		added = append(added, endpoint)
+	}
+
+	if len(added) > 0 {
+		candidates := &replicaSet{members: open(added), timeout: s.timeout}
+		candidates.check(ctx, l)
+		members = append(members, candidates.members...)
+	}
+
+	s.mx.Lock()
+	previous := s.members
+	s.members = members
+	s.mx.Unlock()
+
+	for _, member := range previous {
+		if kept[member] {
+			continue
+		}
+
+		l.Info("replica removed", zap.String("replica", member.name))
+		if err := member.db.Close(); err != nil {
+			l.Warn("could not close replica", zap.String("replica", member.name), zap.Error(err))
+		}
+	}
+}
+
+// drain closes the idle connections of all replica connection pools, and no longer keeps released connections idle.
+func (s *replicaSet) drain() {
+	if s == nil {
+		return
+	}
+
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+
+	for _, member := range s.members {
+		member.db.SetMaxIdleConns(0)
+	}
+}
+
+// close all replica connection pools.
+func (s *replicaSet) close() error {
+	if s == nil {
+		return nil
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	var err error
+	for _, member := range s.members {
+		err = errors.Join(err, member.db.Close())
+	}
+	s.members = nil
+
+	return err
+}
+
+func (r databaseSettings) replicaReadOnly() bool {
+	if r.PGConfig == nil || r.PGConfig.ReplicaReadOnly == nil {
+		return true
+	}
+
+	return *r.PGConfig.ReplicaReadOnly
+}
+
+func (r databaseSettings) replicaCheckInterval() time.Duration {
+	if r.PGConfig == nil || r.PGConfig.ReplicaCheck <= 0 {
+		return defaultSettings.PGConfig.ReplicaCheck
+	}
+
+	return r.PGConfig.ReplicaCheck
+}
@@ -0,0 +1,145 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestReplicaSet(t *testing.T) {
+	t.Run("nil set has no replica", func(t *testing.T) {
+		var set *replicaSet
+
+		require.Nil(t, set.next())
+		require.NoError(t, set.close())
+	})
+
+	t.Run("round robin over healthy replicas", func(t *testing.T) {
+		db1, db2, db3 := &sqlx.DB{}, &sqlx.DB{}, &sqlx.DB{}
+		set := &replicaSet{
+			members: []*replica{{db: db1}, {db: db2}, {db: db3}},
+		}
+		require.Nil(t, set.next())
+
+		set.members[0].healthy.Store(true)
+		set.members[2].healthy.Store(true)
+
+		seen := make(map[*sqlx.DB]int)
+		for i := 0; i < 10; i++ {
+			seen[set.next()]++
+		}
+
+		require.Len(t, seen, 2)
+		require.Equal(t, 5, seen[db1])
+		require.Equal(t, 5, seen[db3])
+	})
+
+	t.Run("falls back to master", func(t *testing.T) {
+		master := &sqlx.DB{}
+		r := &Repository{db: master, replicas: &replicaSet{members: []*replica{{db: &sqlx.DB{}}}}}
+
+		require.Same(t, master, r.ReplicaDB())
+	})
+}
+
+func TestReplicaReadOnly(t *testing.T) {
+	const replicaURL = "postgresql://replica1:5432/testdb"
+
+	t.Run("replica transactions are read-only by default", func(t *testing.T) {
+		r := New(DefaultDBAlias, WithDatabaseSettings(DefaultDBAlias, WithReplicas(replicaURL)))
+
+		dcfg := r.replicaConnConfig(replicaURL)
+		require.NotNil(t, dcfg)
+		require.Equal(t, "on", dcfg.RuntimeParams["default_transaction_read_only"])
+
+		master := r.ConnConfig(r.DBURL(), r.log, r.app)
+		require.NotContains(t, master.RuntimeParams, "default_transaction_read_only")
+	})
+
+	t.Run("read-only replicas may be disabled", func(t *testing.T) {
+		r := New(DefaultDBAlias, WithDatabaseSettings(DefaultDBAlias,
+			WithReplicas(replicaURL),
+			WithPoolSettings(WithReplicaReadOnly(false)),
+		))
+
+		dcfg := r.replicaConnConfig(replicaURL)
+		require.NotNil(t, dcfg)
+		require.NotContains(t, dcfg.RuntimeParams, "default_transaction_read_only")
+	})
+}
+
+func TestReplicaCheck(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := log.NewFactory(zap.New(core)).Bg()
+
+	standby := &fakeReplica{}
+	standby.inRecovery.Store(true)
+	primary := &fakeReplica{}
+	down := &fakeReplica{}
+	down.down.Store(true)
+
+	set := &replicaSet{
+		timeout: time.Second,
+		members: []*replica{
+			{db: sqlx.NewDb(sql.OpenDB(standby), driverName), name: "standby"},
+			{db: sqlx.NewDb(sql.OpenDB(primary), driverName), name: "primary"},
+			{db: sqlx.NewDb(sql.OpenDB(down), driverName), name: "down"},
+		},
+	}
+	t.Cleanup(func() {
+		_ = set.close()
+	})
+
+	set.check(context.Background(), l)
+
+	require.True(t, set.members[0].healthy.Load())
+	require.False(t, set.members[0].primary.Load())
+	require.True(t, set.members[1].healthy.Load())
+	require.True(t, set.members[1].primary.Load())
+	require.False(t, set.members[2].healthy.Load())
+
+	alerts := logs.FilterLevelExact(zap.ErrorLevel).All()
+	require.Len(t, alerts, 1)
+	require.Equal(t, "primary", alerts[0].ContextMap()["replica"])
+
+	t.Run("alerts are raised once", func(t *testing.T) {
+		set.check(context.Background(), l)
+		require.Len(t, logs.FilterLevelExact(zap.ErrorLevel).All(), 1)
+	})
+
+	t.Run("replica back in recovery", func(t *testing.T) {
+		primary.inRecovery.Store(true)
+		set.check(context.Background(), l)
+		require.False(t, set.members[1].primary.Load())
+	})
+}
+
+// fakeReplica is a fake server which answers queries with pg_is_in_recovery(), or with the current LSN
+// to queries about the WAL.
+type fakeReplica struct {
+	fakeServer
+	inRecovery atomic.Bool
+	lsn        atomic.Uint64
+}
+
+func (f *fakeReplica) Connect(context.Context) (driver.Conn, error) {
+	return f.connect(f.answer)
+}
+
+func (f *fakeReplica) answer(query string, _ []driver.Value) (*fakeRows, error) {
+	if strings.Contains(query, "_lsn()") {
+		return singleValue("lsn", LSN(f.lsn.Load()).String()), nil
+	}
+
+	return singleValue("pg_is_in_recovery", f.inRecovery.Load()), nil
+}
@@ -0,0 +1,43 @@
+package pgrepo
+
+import (
+	"context"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+)
+
+var _ Repo = &Repository{}
+
+// Repo is the interface of a repository used by data access code.
+//
+// Code which depends on a Repo rather than on a *Repository may be unit tested without a live database
+// (see package pgrepomock).
+type Repo interface {
+	// DB master instance
+	DB() *sqlx.DB
+
+	// ReplicaDB returns a read-only replica instance, or the master instance if no replica is available.
+	ReplicaDB() *sqlx.DB
+
+	// Logger returns a logger factory
+	Logger() log.Factory
+
+	// HealthCheck pings the database
+	HealthCheck() error
+
+	// HealthCheckContext checks that the database is available.
+	HealthCheckContext(ctx context.Context) error
+
+	// RunInTx runs a function within a transaction, which is committed if the function returns no error.
+	RunInTx(ctx context.Context, fn func(context.Context, *sqlx.Tx) error, opts ...TxOption) error
+
+	// Read runs a function issuing read statements.
+	Read(ctx context.Context, fn func(context.Context, *sqlx.DB) error) error
+
+	// Write runs a function issuing write statements.
+	Write(ctx context.Context, fn func(context.Context, *sqlx.DB) error) error
+
+	// Stop the connection pools.
+	Stop() error
+}
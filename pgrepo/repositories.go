@@ -0,0 +1,164 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Repositories manages the lifecycle of one Repository for each database alias declared in the settings.
+type Repositories struct {
+	repos   map[string]*Repository
+	aliases []string
+	log     log.Factory
+}
+
+// NewRepositories creates a Repository for each database alias declared in the "databases.postgres"
+// section of a viper configuration.
+//
+// If no database is declared in the configuration, a single repository is created for the default alias.
+//
+// Extra options (e.g. WithLogger, ...) can be added.
+//
+// The repositories need to be started with StartAll() in order to create the connection pools.
+func NewRepositories(cfg *viper.Viper, opts ...Option) (*Repositories, error) {
+	withViper, err := SettingsFromViper(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]Option{withViper}, opts...)
+	s := settingsFromOptions(opts)
+
+	var aliases []string
+	if cfg != nil {
+		for alias := range cfg.GetStringMap("databases.postgres") {
+			aliases = append(aliases, alias)
+		}
+	}
+	if len(aliases) == 0 {
+		aliases = []string{DefaultDBAlias}
+	}
+	sort.Strings(aliases)
+
+	repos := make(map[string]*Repository, len(aliases))
+	for _, alias := range aliases {
+		repos[alias] = &Repository{
+			log:              log.NewFactory(s.logger),
+			app:              s.app,
+			alias:            alias,
+			opts:             opts,
+			databaseSettings: s.DBSettingsFor(alias),
+		}
+	}
+
+	return &Repositories{
+		repos:   repos,
+		aliases: aliases,
+		log:     log.NewFactory(s.logger),
+	}, nil
+}
+
+// Get the Repository for a database alias.
+func (rs *Repositories) Get(alias string) (*Repository, bool) {
+	r, ok := rs.repos[alias]
+
+	return r, ok
+}
+
+// Aliases returns the sorted list of managed database aliases.
+func (rs *Repositories) Aliases() []string {
+	return append([]string(nil), rs.aliases...)
+}
+
+// StartAll starts all repositories, in the order of their aliases.
+//
+// If any repository fails to start, the repositories already started are stopped.
+func (rs *Repositories) StartAll() error {
+	return rs.StartAllContext(context.Background())
+}
+
+// StartAllContext starts all repositories like StartAll, and aborts when the context is cancelled.
+//
+// See Repository.StartContext.
+func (rs *Repositories) StartAllContext(ctx context.Context) error {
+	for i, alias := range rs.aliases {
+		if err := rs.repos[alias].StartContext(ctx); err != nil {
+			err = fmt.Errorf("database %q: %w", alias, err)
+
+			return errors.Join(err, rs.stop(rs.aliases[:i]))
+		}
+	}
+
+	return nil
+}
+
+// StopAll stops all repositories, in the reverse order of StartAll.
+func (rs *Repositories) StopAll() error {
+	return rs.stop(rs.aliases)
+}
+
+// ShutdownAll shuts down all repositories gracefully and concurrently, within the deadline of the context.
+//
+// See Repository.Shutdown.
+func (rs *Repositories) ShutdownAll(ctx context.Context) error {
+	errs := make([]error, len(rs.aliases))
+	var wg sync.WaitGroup
+	for i, alias := range rs.aliases {
+		wg.Add(1)
+		go func(i int, alias string) {
+			defer wg.Done()
+
+			if e := rs.repos[alias].Shutdown(ctx); e != nil {
+				errs[i] = fmt.Errorf("database %q: %w", alias, e)
+			}
+		}(i, alias)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// HealthCheckAll checks the health of all repositories and reports all failures.
+func (rs *Repositories) HealthCheckAll(ctx context.Context) error {
+	var err error
+	for _, alias := range rs.aliases {
+		if e := rs.repos[alias].HealthCheckContext(ctx); e != nil {
+			err = errors.Join(err, fmt.Errorf("database %q: %w", alias, e))
+		}
+	}
+
+	return err
+}
+
+// ReloadAll applies the settings from a viper configuration to all repositories and reports all failures.
+//
+// See Repository.Reload.
+func (rs *Repositories) ReloadAll(cfg *viper.Viper) error {
+	var err error
+	for _, alias := range rs.aliases {
+		if e := rs.repos[alias].Reload(cfg); e != nil {
+			err = errors.Join(err, fmt.Errorf("database %q: %w", alias, e))
+		}
+	}
+
+	return err
+}
+
+func (rs *Repositories) stop(aliases []string) error {
+	var err error
+	for i := len(aliases) - 1; i >= 0; i-- {
+		alias := aliases[i]
+		if e := rs.repos[alias].Stop(); e != nil {
+			rs.log.Bg().Warn("could not stop repository", zap.String("db_alias", alias), zap.Error(e))
+			err = errors.Join(err, fmt.Errorf("database %q: %w", alias, e))
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,99 @@
+package pgrepo
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositories(t *testing.T) {
+	cfg := viper.New()
+	cfg.SetConfigType("yaml")
+	require.NoError(t, cfg.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    users:
+      url: 'postgresql://localhost:5432/users'
+    orders:
+      url: 'postgresql://localhost:5432/orders'
+      pgconfig:
+        maxOpenConns: 7
+`)))
+
+	rs, err := NewRepositories(cfg, WithName("test-app"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders", "users"}, rs.Aliases())
+
+	orders, ok := rs.Get("orders")
+	require.True(t, ok)
+	require.Equal(t, "orders", orders.alias)
+	require.Equal(t, "test-app", orders.app)
+	require.Equal(t, "postgresql://localhost:5432/orders", orders.URL)
+	require.Equal(t, 7, orders.PGConfig.MaxOpenConns)
+
+	_, ok = rs.Get(DefaultDBAlias)
+	require.False(t, ok)
+
+	err = rs.HealthCheckAll(context.Background())
+	require.ErrorIs(t, err, ErrDBNotInitialized)
+	require.ErrorContains(t, err, `database "orders"`)
+	require.ErrorContains(t, err, `database "users"`)
+
+	t.Run("defaults are not altered by the config", func(t *testing.T) {
+		_, ok := defaultSettings.Databases["orders"]
+		require.False(t, ok)
+		require.Equal(t, 0, defaultSettings.PGConfig.MaxOpenConns)
+	})
+
+	t.Run("without config, the default alias is managed", func(t *testing.T) {
+		rs, err := NewRepositories(nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{DefaultDBAlias}, rs.Aliases())
+	})
+
+	t.Run("start fails fast on invalid settings", func(t *testing.T) {
+		rs, err := NewRepositories(nil, WithDatabaseSettings(DefaultDBAlias, WithURL("mysql://localhost")))
+		require.NoError(t, err)
+
+		err = rs.StartAll()
+		require.Error(t, err)
+		require.ErrorContains(t, err, `database "default"`)
+		require.NoError(t, rs.StopAll())
+		require.NoError(t, rs.ShutdownAll(context.Background()))
+	})
+}
+
+func TestSettingsClone(t *testing.T) {
+	readOnly := true
+	s := settingsFromOptions([]Option{
+		WithDefaultPoolOptions(WithSetClause("work_mem", "64MB")),
+		WithDatabaseSettings("orders",
+			WithSearchPath("orders"),
+			WithPoolSettings(WithSetClause("plan_cache_mode", "force_custom_plan")),
+		),
+	})
+	s.PGConfig.ReplicaReadOnly = &readOnly
+	orders := s.Databases["orders"]
+	orders.Tags = map[string]string{"team": "billing"}
+	orders.Pools = map[string]*poolSettings{"worker": poolSettingsFromOptions([]PoolOption{WithMaxOpenConns(2)})}
+	s.Databases["orders"] = orders
+
+	c := s.clone()
+	c.PGConfig.Set["work_mem"] = "1GB"
+	*c.PGConfig.ReplicaReadOnly = false
+	cloned := c.Databases["orders"]
+	cloned.Tags["team"] = "sales"
+	cloned.Schema[0] = "sales"
+	cloned.PGConfig.Set["plan_cache_mode"] = "auto"
+	cloned.Pools["worker"].MaxOpenConns = 20
+
+	require.Equal(t, "64MB", s.PGConfig.Set["work_mem"])
+	require.True(t, *s.PGConfig.ReplicaReadOnly)
+	require.Equal(t, "billing", orders.Tags["team"])
+	require.Equal(t, []string{"orders"}, orders.Schema)
+	require.Equal(t, "force_custom_plan", orders.PGConfig.Set["plan_cache_mode"])
+	require.Equal(t, 2, orders.Pools["worker"].MaxOpenConns)
+}
@@ -0,0 +1,124 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultQueryAttempts is the number of attempts of Repository.Retry, when the policy sets no maximum.
+const defaultQueryAttempts = 3
+
+var defaultRetryPolicy = RetryPolicy{
+	InitialInterval: 250 * time.Millisecond,
+	Multiplier:      2,
+	MaxInterval:     5 * time.Second,
+	Jitter:          0.2,
+}
+
+// RetryPolicy configures retries with an exponential backoff.
+//
+// It applies to the connection to the database at startup, and optionally to transient query failures
+// (see Repository.Retry).
+type RetryPolicy struct {
+	InitialInterval time.Duration // delay before the first retry
+	Multiplier      float64       // growth factor of the delay between two retries
+	MaxInterval     time.Duration // upper bound of the delay between two retries
+	Jitter          float64       // randomization of delays, as a fraction of the delay in [0, 1]
+	MaxAttempts     int           // maximum number of attempts, including the first one. 0 means no limit at startup, and 3 for queries
+	Queries         bool          // retry transient query failures with Repository.Retry
+}
+
+// WithRetryPolicy sets the retry policy used to connect at startup and to retry transient query failures.
+func WithRetryPolicy(policy RetryPolicy) PoolOption {
+	return func(o *poolSettings) {
+		o.Retry = policy
+	}
+}
+
+// Retry runs fn, and retries it with the configured backoff when it fails with a transient error:
+// a broken connection, a serialization failure or a deadlock.
+//
+// Queries are retried only if enabled by the retry policy ("retry.queries" setting, or WithRetryPolicy).
+// Otherwise, fn is run once.
+//
+// fn must then be idempotent.
+func (r *Repository) Retry(ctx context.Context, fn func(context.Context) error) error {
+	policy := r.retryPolicy()
+	maxAttempts := 1
+	if policy.Queries {
+		maxAttempts = policy.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = defaultQueryAttempts
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil || attempt >= maxAttempts || !isTransientError(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		r.logger(ctx).Debug("retrying query", zap.Int("attempt", attempt), zap.Duration("delay", delay), zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// isTransientError tells if an error is likely to be resolved by retrying.
+func isTransientError(err error) bool {
+	return errors.Is(err, driver.ErrBadConn) || IsConnectionError(err) || IsSerializationFailure(err) || IsDeadlock(err)
+}
+
+// backoff returns the delay to wait after a number of failed attempts.
+func (p RetryPolicy) backoff(attempts int) time.Duration {
+	delay := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempts-1))
+	if maxDelay := float64(p.MaxInterval); delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// withDefaults fills unset parameters with defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	defaults := defaultRetryPolicy
+
+	if p.InitialInterval <= 0 {
+		p.InitialInterval = defaults.InitialInterval
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaults.Multiplier
+	}
+	if p.MaxInterval < p.InitialInterval {
+		p.MaxInterval = max(defaults.MaxInterval, p.InitialInterval)
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+
+	return p
+}
+
+func (r databaseSettings) retryPolicy() RetryPolicy {
+	if r.PGConfig == nil {
+		return defaultRetryPolicy
+	}
+
+	return r.PGConfig.Retry.withDefaults()
+}
@@ -0,0 +1,103 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("should back off exponentially", func(t *testing.T) {
+		policy := RetryPolicy{InitialInterval: 100 * time.Millisecond, Multiplier: 2, MaxInterval: time.Second}.withDefaults()
+
+		require.Equal(t, 100*time.Millisecond, policy.backoff(1))
+		require.Equal(t, 200*time.Millisecond, policy.backoff(2))
+		require.Equal(t, 800*time.Millisecond, policy.backoff(4))
+		require.Equal(t, time.Second, policy.backoff(10))
+	})
+
+	t.Run("should add jitter", func(t *testing.T) {
+		policy := RetryPolicy{InitialInterval: time.Second, Multiplier: 1, Jitter: 0.5}.withDefaults()
+
+		for i := 0; i < 100; i++ {
+			delay := policy.backoff(1)
+			require.GreaterOrEqual(t, delay, 500*time.Millisecond)
+			require.LessOrEqual(t, delay, 1500*time.Millisecond)
+		}
+	})
+
+	t.Run("should apply defaults", func(t *testing.T) {
+		require.Equal(t, defaultRetryPolicy, RetryPolicy{Jitter: 0.2}.withDefaults())
+		require.Equal(t, defaultRetryPolicy, databaseSettings{}.retryPolicy())
+
+		dbs := settingsFromOptions([]Option{
+			WithDefaultPoolOptions(WithRetryPolicy(RetryPolicy{InitialInterval: time.Second, Jitter: 2})),
+		}).DBSettingsFor(DefaultDBAlias)
+		policy := dbs.retryPolicy()
+		require.Equal(t, time.Second, policy.InitialInterval)
+		require.Equal(t, defaultRetryPolicy.Multiplier, policy.Multiplier)
+		require.Equal(t, defaultRetryPolicy.MaxInterval, policy.MaxInterval)
+		require.Equal(t, 1.0, policy.Jitter)
+	})
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	errDeadlock := &pgconn.PgError{Code: "40P01", Message: "deadlock detected"}
+	repo := func(policy RetryPolicy) *Repository {
+		return New(DefaultDBAlias, WithDefaultPoolOptions(WithRetryPolicy(policy)))
+	}
+
+	t.Run("should run once when query retries are disabled", func(t *testing.T) {
+		var calls int
+		err := repo(RetryPolicy{}).Retry(ctx, func(context.Context) error {
+			calls++
+
+			return driver.ErrBadConn
+		})
+		require.ErrorIs(t, err, driver.ErrBadConn)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("should retry transient errors", func(t *testing.T) {
+		var calls int
+		err := repo(RetryPolicy{InitialInterval: time.Millisecond, Queries: true}).Retry(ctx, func(context.Context) error {
+			calls++
+			if calls < 3 {
+				return errDeadlock
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("should give up after max attempts", func(t *testing.T) {
+		var calls int
+		err := repo(RetryPolicy{InitialInterval: time.Millisecond, MaxAttempts: 2, Queries: true}).Retry(ctx, func(context.Context) error {
+			calls++
+
+			return driver.ErrBadConn
+		})
+		require.ErrorIs(t, err, driver.ErrBadConn)
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("should not retry other errors", func(t *testing.T) {
+		errOther := errors.New("syntax error")
+		var calls int
+		err := repo(RetryPolicy{InitialInterval: time.Millisecond, Queries: true}).Retry(ctx, func(context.Context) error {
+			calls++
+
+			return errOther
+		})
+		require.ErrorIs(t, err, errOther)
+		require.Equal(t, 1, calls)
+	})
+}
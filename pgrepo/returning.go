@@ -0,0 +1,173 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
+)
+
+// Options of "db" struct tags, for InsertReturning and UpsertReturning.
+const (
+	// TagDefault marks a column with a default value (e.g. a generated id): it is not inserted when the field
+	// holds a zero value, so the database default applies.
+	TagDefault = "default"
+
+	// TagReadOnly marks a column which is never inserted nor updated (e.g. a value set by a trigger).
+	TagReadOnly = "readonly"
+)
+
+var modelMapper = reflectx.NewMapperFunc("db", sqlx.NameMapper)
+
+// InsertReturning inserts a model into a table, then scans back the inserted row into the model,
+// with the values set by the database (e.g. generated ids, defaults or values set by triggers).
+//
+// Columns are mapped from the "db" struct tags of the model, like sqlx does. Tag options tell which columns are
+// set by the database:
+//
+//	type User struct {
+//		ID        int64     `db:"id,default"`
+//		Name      string    `db:"name"`
+//		CreatedAt time.Time `db:"created_at,readonly"`
+//	}
+//
+// The model must be a pointer to a struct.
+func InsertReturning[T any](ctx context.Context, db sqlx.QueryerContext, table string, model *T) error {
+	return insertReturning(ctx, db, table, nil, model)
+}
+
+// UpsertReturning inserts a model into a table, or updates the existing row when the insertion conflicts on
+// the given columns. The resulting row is scanned back into the model.
+//
+// See InsertReturning.
+func UpsertReturning[T any](ctx context.Context, db sqlx.QueryerContext, table string, conflict []string, model *T) error {
+	if len(conflict) == 0 {
+		return fmt.Errorf("an upsert requires conflict columns: %w", ErrInvalidConfig)
+	}
+
+	return insertReturning(ctx, db, table, conflict, model)
+}
+
+func insertReturning(ctx context.Context, db sqlx.QueryerContext, table string, conflict []string, model interface{}) error {
+	query, args, err := insertReturningStatement(table, conflict, model)
+	if err != nil {
+		return err
+	}
+
+	return sqlx.GetContext(ctx, db, model, query, args...)
+}
+
+// insertReturningStatement builds an INSERT ... RETURNING statement for a model.
+func insertReturningStatement(table string, conflict []string, model interface{}) (string, []interface{}, error) {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.IsNil() || reflect.Indirect(v).Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("expected a pointer to a struct, but got %T: %w", model, ErrInvalidConfig)
+	}
+	v = v.Elem()
+
+	var (
+		inserted  []string
+		returning []string
+		args      []interface{}
+	)
+
+	for _, fi := range modelMapper.TypeMap(v.Type()).Index {
+		if fi.Embedded || strings.Contains(fi.Path, ".") {
+			// embedded structs are flattened, nested structs are single columns
+			continue
+		}
+
+		column := quoteIdentifier(fi.Name)
+		returning = append(returning, column)
+
+		if _, readOnly := fi.Options[TagReadOnly]; readOnly {
+			continue
+		}
+
+		value, ok := fieldByIndexes(v, fi.Index)
+		if _, hasDefault := fi.Options[TagDefault]; hasDefault && (!ok || value.IsZero()) {
+			continue
+		}
+
+		inserted = append(inserted, column)
+		if !ok {
+			// the field belongs to a nil embedded struct
+			args = append(args, nil)
+
+			continue
+		}
+		args = append(args, value.Interface())
+	}
+
+	if len(returning) == 0 {
+		return "", nil, fmt.Errorf("no column mapped for %T: %w", model, ErrInvalidConfig)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `INSERT INTO %s`, quoteQualifiedIdentifier(table))
+
+	if len(inserted) == 0 {
+		b.WriteString(` DEFAULT VALUES`)
+	} else {
+		placeholders := make([]string, len(inserted))
+		for i := range inserted {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		fmt.Fprintf(&b, ` (%s) VALUES (%s)`, strings.Join(inserted, ", "), strings.Join(placeholders, ", "))
+	}
+
+	if len(conflict) > 0 {
+		b.WriteString(onConflictUpdate(conflict, inserted))
+	}
+
+	fmt.Fprintf(&b, ` RETURNING %s`, strings.Join(returning, ", "))
+
+	return b.String(), args, nil
+}
+
+// fieldByIndexes returns the field of a struct at an index path, like reflectx.FieldByIndexesReadOnly.
+//
+// It returns false when the path goes through a nil pointer to an embedded struct.
+func fieldByIndexes(v reflect.Value, indexes []int) (reflect.Value, bool) {
+	for n, i := range indexes {
+		if n > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+
+	return v, true
+}
+
+// onConflictUpdate builds an ON CONFLICT clause which updates inserted columns.
+//
+// The conflicting row is always updated, so it is returned even when there is nothing else to update.
+func onConflictUpdate(conflict, inserted []string) string {
+	keys := make(map[string]struct{}, len(conflict))
+	quotedKeys := make([]string, 0, len(conflict))
+	for _, column := range conflict {
+		quoted := quoteIdentifier(column)
+		keys[quoted] = struct{}{}
+		quotedKeys = append(quotedKeys, quoted)
+	}
+
+	updates := make([]string, 0, len(inserted))
+	for _, column := range inserted {
+		if _, isKey := keys[column]; isKey {
+			continue
+		}
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+
+	if len(updates) == 0 {
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", quotedKeys[0], quotedKeys[0]))
+	}
+
+	return fmt.Sprintf(` ON CONFLICT (%s) DO UPDATE SET %s`, strings.Join(quotedKeys, ", "), strings.Join(updates, ", "))
+}
@@ -0,0 +1,114 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type (
+	testAudit struct {
+		CreatedAt time.Time `db:"created_at,readonly"`
+		UpdatedBy string    `db:"updated_by"`
+	}
+
+	testUser struct {
+		ID      int64  `db:"id,default"`
+		Name    string `db:"name"`
+		Email   string
+		Ignored string `db:"-"`
+		testAudit
+	}
+)
+
+func TestInsertReturningStatement(t *testing.T) {
+	t.Run("should omit default and read-only columns", func(t *testing.T) {
+		query, args, err := insertReturningStatement("app.users", nil, &testUser{Name: "fred", Email: "fred@example.com", testAudit: testAudit{UpdatedBy: "admin"}})
+		require.NoError(t, err)
+
+		require.Equal(t,
+			`INSERT INTO "app"."users" ("name", "email", "updated_by") VALUES ($1, $2, $3) `+
+				`RETURNING "id", "name", "email", "created_at", "updated_by"`,
+			query,
+		)
+		require.Equal(t, []interface{}{"fred", "fred@example.com", "admin"}, args)
+	})
+
+	t.Run("should insert default columns when set", func(t *testing.T) {
+		query, args, err := insertReturningStatement("users", nil, &testUser{ID: 42, Name: "fred"})
+		require.NoError(t, err)
+
+		require.Contains(t, query, `("id", "name", "email", "updated_by") VALUES ($1, $2, $3, $4)`)
+		require.Equal(t, []interface{}{int64(42), "fred", "", ""}, args)
+	})
+
+	t.Run("should upsert", func(t *testing.T) {
+		query, _, err := insertReturningStatement("users", []string{"email"}, &testUser{Name: "fred", Email: "fred@example.com"})
+		require.NoError(t, err)
+
+		require.Contains(t, query,
+			`ON CONFLICT ("email") DO UPDATE SET "name" = EXCLUDED."name", "updated_by" = EXCLUDED."updated_by" RETURNING`,
+		)
+	})
+
+	t.Run("should return the conflicting row when there is nothing to update", func(t *testing.T) {
+		type tag struct {
+			ID    int64  `db:"id,default"`
+			Label string `db:"label"`
+		}
+
+		query, _, err := insertReturningStatement("tags", []string{"label"}, &tag{Label: "go"})
+		require.NoError(t, err)
+
+		require.Equal(t,
+			`INSERT INTO "tags" ("label") VALUES ($1) ON CONFLICT ("label") DO UPDATE SET "label" = EXCLUDED."label" RETURNING "id", "label"`,
+			query,
+		)
+	})
+
+	t.Run("should insert default values", func(t *testing.T) {
+		type counter struct {
+			ID int64 `db:"id,default"`
+		}
+
+		query, args, err := insertReturningStatement("counters", nil, &counter{})
+		require.NoError(t, err)
+
+		require.Equal(t, `INSERT INTO "counters" DEFAULT VALUES RETURNING "id"`, query)
+		require.Empty(t, args)
+	})
+
+	t.Run("should insert nulls for a nil embedded struct", func(t *testing.T) {
+		type account struct {
+			ID   int64  `db:"id,default"`
+			Name string `db:"name"`
+			*testAudit
+		}
+
+		query, args, err := insertReturningStatement("accounts", nil, &account{Name: "fred"})
+		require.NoError(t, err)
+
+		require.Equal(t,
+			`INSERT INTO "accounts" ("name", "updated_by") VALUES ($1, $2) RETURNING "id", "name", "created_at", "updated_by"`,
+			query,
+		)
+		require.Equal(t, []interface{}{"fred", nil}, args)
+
+		_, args, err = insertReturningStatement("accounts", nil, &account{Name: "fred", testAudit: &testAudit{UpdatedBy: "admin"}})
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"fred", "admin"}, args)
+	})
+
+	t.Run("should reject invalid models", func(t *testing.T) {
+		_, _, err := insertReturningStatement("users", nil, testUser{})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+
+		var user *testUser
+		_, _, err = insertReturningStatement("users", nil, user)
+		require.ErrorIs(t, err, ErrInvalidConfig)
+
+		require.ErrorIs(t, UpsertReturning(context.Background(), nil, "users", nil, &testUser{}), ErrInvalidConfig)
+	})
+}
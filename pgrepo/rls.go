@@ -0,0 +1,69 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// WithRLS runs a function within a transaction, with run-time parameters used by row-level security policies
+// set locally to the transaction (e.g. "app.user_id"), like Repository.RunInTx.
+//
+// Parameters are set with SET LOCAL semantics, so they don't leak to other transactions once the connection
+// is returned to the pool. Policies read them with current_setting, e.g.:
+//
+//	CREATE POLICY owner_only ON documents USING (owner_id = current_setting('app.user_id')::bigint)
+//
+// Parameter names must be qualified by a prefix (e.g. "app.user_id").
+func (r *Repository) WithRLS(ctx context.Context, params map[string]string, fn func(context.Context, *sqlx.Tx) error, opts ...TxOption) error {
+	if err := validateRLSParams(params); err != nil {
+		return err
+	}
+
+	return r.RunInTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := setLocalParams(ctx, tx, params); err != nil {
+			return err
+		}
+
+		return fn(ctx, tx)
+	}, opts...)
+}
+
+func validateRLSParams(params map[string]string) error {
+	for name := range params {
+		if !rexGUCName.MatchString(name) || !strings.Contains(name, ".") {
+			return fmt.Errorf("invalid RLS parameter %q: expected a qualified name such as \"app.user_id\": %w", name, ErrInvalidConfig)
+		}
+	}
+
+	return nil
+}
+
+// setLocalParams sets run-time parameters locally to a transaction, in a single round trip.
+func setLocalParams(ctx context.Context, tx sqlx.ExecerContext, params map[string]string) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	calls := make([]string, 0, len(names))
+	args := make([]any, 0, 2*len(names))
+	for i, name := range names {
+		calls = append(calls, fmt.Sprintf("set_config($%d, $%d, true)", 2*i+1, 2*i+2))
+		args = append(args, name, params[name])
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT `+strings.Join(calls, ", "), args...); err != nil {
+		return fmt.Errorf("could not set local parameters %v: %w", names, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRLS(t *testing.T) {
+	ctx := context.Background()
+	noop := func(context.Context, *sqlx.Tx) error { return nil }
+
+	t.Run("should require qualified parameter names", func(t *testing.T) {
+		r := New(DefaultDBAlias)
+		require.ErrorIs(t, r.WithRLS(ctx, map[string]string{"user_id": "42"}, noop), ErrInvalidConfig)
+		require.ErrorIs(t, r.WithRLS(ctx, map[string]string{"app.user id": "42"}, noop), ErrInvalidConfig)
+		require.ErrorIs(t, r.WithRLS(ctx, map[string]string{"app.user_id": "42"}, noop), ErrDBNotInitialized)
+	})
+
+	t.Run("should set parameters in a single statement", func(t *testing.T) {
+		exec := &recordingExecer{}
+		require.NoError(t, setLocalParams(ctx, exec, map[string]string{
+			"app.user_id":   "42",
+			"app.tenant_id": "acme",
+		}))
+		require.Equal(t, `SELECT set_config($1, $2, true), set_config($3, $4, true)`, exec.query)
+		require.Equal(t, []any{"app.tenant_id", "acme", "app.user_id", "42"}, exec.args)
+	})
+
+	t.Run("should not run a statement without parameters", func(t *testing.T) {
+		exec := &recordingExecer{}
+		require.NoError(t, setLocalParams(ctx, exec, nil))
+		require.Empty(t, exec.query)
+	})
+}
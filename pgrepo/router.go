@@ -0,0 +1,141 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// rexNotReadOnly detects clauses that prevent a SELECT statement from running on a replica,
+// e.g. locking clauses or calls to sequence functions.
+var rexNotReadOnly = regexp.MustCompile(
+	`(?i)\b(INSERT|UPDATE|DELETE|MERGE|INTO|FOR\s+(KEY\s+)?SHARE|NEXTVAL|SETVAL|PG_ADVISORY_\w+)\b`,
+)
+
+type (
+	// ReadQuerier executes queries, possibly on a replica (see Repository.Router).
+	//
+	// ReadQuerier is implemented by *sqlx.DB, *sqlx.Tx and *Router.
+	ReadQuerier interface {
+		sqlx.ExtContext
+		GetContext(ctx context.Context, dest any, query string, args ...any) error
+		SelectContext(ctx context.Context, dest any, query string, args ...any) error
+	}
+
+	// Router routes read-only statements to replicas, and all other statements to the master.
+	//
+	// After a write in a context prepared with ReadYourWrites, statements in this context stick to the master,
+	// so that reads observe prior writes despite the replication lag.
+	Router struct {
+		r *Repository
+	}
+
+	routingScopeKey struct{}
+
+	// routingScope records writes in a context.
+	routingScope struct {
+		wrote atomic.Bool
+	}
+)
+
+var (
+	_ ReadQuerier = &sqlx.DB{}
+	_ ReadQuerier = &sqlx.Tx{}
+	_ ReadQuerier = &Router{}
+)
+
+// Reader returns a handle for read-only statements, i.e. a replica when replicas are configured (see ReplicaDB).
+func (r *Repository) Reader() *sqlx.DB {
+	return r.ReplicaDB()
+}
+
+// Writer returns a handle for statements that modify data, i.e. the master (see DB).
+func (r *Repository) Writer() *sqlx.DB {
+	return r.DB()
+}
+
+// Router returns a ReadQuerier which sends SELECT-only statements to replicas, and all other statements to the master.
+//
+// Statements with locking clauses (e.g. SELECT ... FOR UPDATE) or calls to sequence functions are sent to the master.
+// Without replicas, all statements are sent to the master.
+func (r *Repository) Router() *Router {
+	return &Router{r: r}
+}
+
+// ReadYourWrites scopes the routing of statements to a context, e.g. a request: after a first write with a Router,
+// all statements executed with this context (or a derived one) are sent to the master.
+func ReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(routingScopeKey{}).(*routingScope); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, routingScopeKey{}, &routingScope{})
+}
+
+// isReadOnlyQuery tells if a statement may run on a replica.
+func isReadOnlyQuery(query string) bool {
+	switch sqlOperation(query) {
+	case "SELECT", "VALUES", "TABLE", "WITH":
+		return !rexNotReadOnly.MatchString(query)
+	default:
+		return false
+	}
+}
+
+// route returns the database handle to execute a statement with.
+func (rt *Router) route(ctx context.Context, query string) *sqlx.DB {
+	scope, _ := ctx.Value(routingScopeKey{}).(*routingScope)
+
+	if !isReadOnlyQuery(query) {
+		if scope != nil {
+			scope.wrote.Store(true)
+		}
+
+		return rt.r.Writer()
+	}
+
+	if scope != nil && scope.wrote.Load() {
+		return rt.r.Writer()
+	}
+
+	return rt.r.Reader()
+}
+
+func (rt *Router) DriverName() string {
+	return rt.r.Writer().DriverName()
+}
+
+func (rt *Router) Rebind(query string) string {
+	return rt.r.Writer().Rebind(query)
+}
+
+func (rt *Router) BindNamed(query string, arg any) (string, []any, error) {
+	return rt.r.Writer().BindNamed(query, arg)
+}
+
+func (rt *Router) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return rt.route(ctx, query).QueryContext(ctx, query, args...)
+}
+
+func (rt *Router) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	return rt.route(ctx, query).QueryxContext(ctx, query, args...)
+}
+
+func (rt *Router) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	return rt.route(ctx, query).QueryRowxContext(ctx, query, args...)
+}
+
+func (rt *Router) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return rt.route(ctx, query).ExecContext(ctx, query, args...)
+}
+
+func (rt *Router) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	return rt.route(ctx, query).GetContext(ctx, dest, query, args...)
+}
+
+func (rt *Router) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	return rt.route(ctx, query).SelectContext(ctx, dest, query, args...)
+}
@@ -0,0 +1,95 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReadOnlyQuery(t *testing.T) {
+	for _, query := range []string{
+		`SELECT * FROM accounts WHERE id = $1`,
+		`/* list */ select id from accounts`,
+		`WITH recent AS (SELECT * FROM orders) SELECT count(*) FROM recent`,
+		`VALUES (1), (2)`,
+		`TABLE accounts`,
+	} {
+		require.Truef(t, isReadOnlyQuery(query), "expected %q to be read-only", query)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO accounts (id) VALUES ($1)`,
+		`UPDATE accounts SET name = $1`,
+		`SELECT * FROM accounts WHERE id = $1 FOR UPDATE`,
+		`SELECT * FROM accounts FOR NO KEY UPDATE SKIP LOCKED`,
+		`SELECT * FROM accounts FOR KEY SHARE`,
+		`SELECT nextval('accounts_id_seq')`,
+		`SELECT pg_advisory_lock(1)`,
+		`SELECT * INTO archive FROM accounts`,
+		`WITH moved AS (DELETE FROM orders RETURNING *) SELECT count(*) FROM moved`,
+		`SET search_path = app`,
+		``,
+	} {
+		require.Falsef(t, isReadOnlyQuery(query), "expected %q not to be read-only", query)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	newDB := func(inRecovery bool) *sqlx.DB {
+		server := &fakeReplica{}
+		server.inRecovery.Store(inRecovery)
+		db := sqlx.NewDb(sql.OpenDB(server), driverName)
+		t.Cleanup(func() { _ = db.Close() })
+
+		return db
+	}
+
+	master := newDB(false)
+	member := &replica{db: newDB(true), name: "replica"}
+	member.healthy.Store(true)
+	r := &Repository{db: master, replicas: &replicaSet{members: []*replica{member}}}
+
+	require.Same(t, master, r.Writer())
+	require.Same(t, member.db, r.Reader())
+
+	// the fake servers answer whether they are in recovery to any query
+	const query = `SELECT pg_is_in_recovery()`
+	onReplica := func(ctx context.Context, q ReadQuerier) bool {
+		var inRecovery bool
+		require.NoError(t, q.GetContext(ctx, &inRecovery, query))
+
+		return inRecovery
+	}
+	router := r.Router()
+
+	t.Run("should send reads to replicas", func(t *testing.T) {
+		require.True(t, onReplica(context.Background(), router))
+		require.False(t, onReplica(context.Background(), master))
+	})
+
+	t.Run("should send writes to the master", func(t *testing.T) {
+		var inRecovery bool
+		require.NoError(t, router.QueryRowxContext(context.Background(), `SELECT pg_is_in_recovery() FOR UPDATE`).Scan(&inRecovery))
+		require.False(t, inRecovery)
+	})
+
+	t.Run("should stick to the master after a write", func(t *testing.T) {
+		ctx := ReadYourWrites(context.Background())
+		require.True(t, onReplica(ctx, router))
+
+		_, _ = router.ExecContext(ctx, `UPDATE accounts SET name = 'x'`)
+		require.False(t, onReplica(ctx, router))
+		require.False(t, onReplica(ReadYourWrites(ctx), router), "expected a derived scope to keep the former writes")
+		require.True(t, onReplica(context.Background(), router), "expected other contexts not to be affected")
+	})
+
+	t.Run("should fall back to the master without healthy replicas", func(t *testing.T) {
+		member.healthy.Store(false)
+		defer member.healthy.Store(true)
+
+		require.False(t, onReplica(context.Background(), router))
+	})
+}
@@ -0,0 +1,101 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrUnexpectedRows is matched with errors.Is by a *RowsAffectedError.
+var ErrUnexpectedRows = errors.New("unexpected number of rows affected")
+
+// RowsExpectation is the expected number of rows affected by a statement, between Min and Max (inclusive).
+//
+// A negative Max stands for no upper bound.
+type RowsExpectation struct {
+	Min int64
+	Max int64
+}
+
+// ExactRows expects exactly n rows to be affected.
+func ExactRows(n int64) RowsExpectation {
+	return RowsExpectation{Min: n, Max: n}
+}
+
+// AtMostRows expects no more than n rows to be affected.
+func AtMostRows(n int64) RowsExpectation {
+	return RowsExpectation{Min: 0, Max: n}
+}
+
+// AtLeastRows expects at least n rows to be affected.
+func AtLeastRows(n int64) RowsExpectation {
+	return RowsExpectation{Min: n, Max: -1}
+}
+
+// RowsBetween expects between min and max rows to be affected (inclusive).
+func RowsBetween(min, max int64) RowsExpectation {
+	return RowsExpectation{Min: min, Max: max}
+}
+
+// Match tells if the number of affected rows matches the expectation.
+func (e RowsExpectation) Match(affected int64) bool {
+	return affected >= e.Min && (e.Max < 0 || affected <= e.Max)
+}
+
+func (e RowsExpectation) String() string {
+	switch {
+	case e.Max < 0:
+		return fmt.Sprintf("at least %d", e.Min)
+	case e.Min == e.Max:
+		return fmt.Sprintf("exactly %d", e.Min)
+	case e.Min == 0:
+		return fmt.Sprintf("at most %d", e.Max)
+	default:
+		return fmt.Sprintf("between %d and %d", e.Min, e.Max)
+	}
+}
+
+// RowsAffectedError reports a statement which affected an unexpected number of rows.
+type RowsAffectedError struct {
+	Expected RowsExpectation
+	Affected int64
+}
+
+func (e *RowsAffectedError) Error() string {
+	return fmt.Sprintf("%v: expected %v, but got %d", ErrUnexpectedRows, e.Expected, e.Affected)
+}
+
+func (e *RowsAffectedError) Unwrap() error {
+	return ErrUnexpectedRows
+}
+
+// ExecExpecting executes a statement and checks the number of rows it affected, e.g. to safely update
+// or delete a row by its primary key:
+//
+//	_, err := ExecExpecting(ctx, tx, `DELETE FROM users WHERE id = $1`, []any{id}, ExactRows(1))
+//	if errors.Is(err, ErrUnexpectedRows) {
+//		// not found
+//	}
+//
+// The number of affected rows is returned. When it does not match the expectation, the error is a *RowsAffectedError.
+//
+// Statements are not rolled back when the expectation is not met: this is left to the enclosing transaction.
+func ExecExpecting(ctx context.Context, db sqlx.ExecerContext, query string, args []any, expect RowsExpectation) (int64, error) {
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if !expect.Match(affected) {
+		return affected, &RowsAffectedError{Expected: expect, Affected: affected}
+	}
+
+	return affected, nil
+}
@@ -0,0 +1,79 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowsExpectation(t *testing.T) {
+	require.True(t, ExactRows(1).Match(1))
+	require.False(t, ExactRows(1).Match(0))
+	require.False(t, ExactRows(1).Match(2))
+
+	require.True(t, AtMostRows(1).Match(0))
+	require.False(t, AtMostRows(1).Match(2))
+
+	require.True(t, AtLeastRows(1).Match(1000))
+	require.False(t, AtLeastRows(1).Match(0))
+
+	require.True(t, RowsBetween(2, 3).Match(3))
+	require.False(t, RowsBetween(2, 3).Match(1))
+
+	require.Equal(t, "exactly 1", ExactRows(1).String())
+	require.Equal(t, "at most 1", AtMostRows(1).String())
+	require.Equal(t, "at least 1", AtLeastRows(1).String())
+	require.Equal(t, "between 2 and 3", RowsBetween(2, 3).String())
+}
+
+func TestExecExpecting(t *testing.T) {
+	ctx := context.Background()
+	const query = `UPDATE users SET name = $1 WHERE id = $2`
+
+	t.Run("should return the affected rows", func(t *testing.T) {
+		exec := &affectingExecer{affected: 1}
+		affected, err := ExecExpecting(ctx, exec, query, []any{"x", 1}, ExactRows(1))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+		require.Equal(t, query, exec.query)
+		require.Equal(t, []any{"x", 1}, exec.args)
+	})
+
+	t.Run("should report unexpected affected rows", func(t *testing.T) {
+		affected, err := ExecExpecting(ctx, &affectingExecer{affected: 0}, query, []any{"x", 1}, ExactRows(1))
+		require.ErrorIs(t, err, ErrUnexpectedRows)
+		require.Equal(t, int64(0), affected)
+
+		var rowsErr *RowsAffectedError
+		require.ErrorAs(t, err, &rowsErr)
+		require.Equal(t, ExactRows(1), rowsErr.Expected)
+		require.Equal(t, int64(0), rowsErr.Affected)
+		require.EqualError(t, err, "unexpected number of rows affected: expected exactly 1, but got 0")
+	})
+
+	t.Run("should return execution errors", func(t *testing.T) {
+		failure := errors.New("boom")
+		_, err := ExecExpecting(ctx, &affectingExecer{err: failure}, query, nil, ExactRows(1))
+		require.ErrorIs(t, err, failure)
+		require.NotErrorIs(t, err, ErrUnexpectedRows)
+	})
+}
+
+type affectingExecer struct {
+	recordingExecer
+	affected int64
+	err      error
+}
+
+func (e *affectingExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	_, _ = e.recordingExecer.ExecContext(ctx, query, args...)
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return driver.RowsAffected(e.affected), nil
+}
@@ -0,0 +1,178 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const defaultSecretWatchInterval = 30 * time.Second
+
+// secretSource is an indirection to a value held outside of the configuration,
+// e.g. a secret file mounted by kubernetes:
+//
+//	urlFrom:
+//	  file: /var/run/secrets/db-url
+//	passwordFrom:
+//	  file: /var/run/secrets/db-password
+type secretSource struct {
+	File string
+}
+
+func (s secretSource) isSet() bool {
+	return s.File != ""
+}
+
+// read the secret value. Leading and trailing blanks (e.g. a trailing new line) are trimmed.
+func (s secretSource) read() (string, error) {
+	content, err := os.ReadFile(os.ExpandEnv(s.File))
+	if err != nil {
+		return "", fmt.Errorf("%w: could not read secret file: %w", ErrInvalidConfig, err)
+	}
+
+	return strings.TrimSpace(string(content)), nil
+}
+
+// WithURLFromFile reads the connection URL from a file, e.g. a mounted secret.
+//
+// This takes precedence over the URL setting.
+func WithURLFromFile(pth string) DBOption {
+	return func(o *databaseSettings) {
+		o.URLFrom = secretSource{File: pth}
+	}
+}
+
+// WithPasswordFromFile reads the password from a file, e.g. a mounted secret.
+//
+// The file is read again whenever a new connection is established, so rotated passwords
+// are taken into account without restarting.
+//
+// This takes precedence over the password setting.
+func WithPasswordFromFile(pth string) DBOption {
+	return func(o *databaseSettings) {
+		o.PasswordFrom = secretSource{File: pth}
+	}
+}
+
+// sourcedURL returns the connection string, read from a file or from the URL or DSN settings.
+func (r databaseSettings) sourcedURL() (string, error) {
+	if !r.URLFrom.isSet() {
+		if r.DSN != "" {
+			return os.ExpandEnv(r.DSN), nil
+		}
+
+		return os.ExpandEnv(r.URL), nil
+	}
+
+	return r.URLFrom.read()
+}
+
+// sourcedPassword returns the password, read from a file or from the password setting.
+func (r databaseSettings) sourcedPassword() (string, error) {
+	if !r.PasswordFrom.isSet() {
+		return os.ExpandEnv(r.Password), nil
+	}
+
+	return r.PasswordFrom.read()
+}
+
+// refreshPassword reads the password file again before connecting.
+func (r databaseSettings) refreshPassword() func(context.Context, *pgx.ConnConfig) error {
+	if !r.PasswordFrom.isSet() {
+		return nil
+	}
+
+	return func(_ context.Context, cfg *pgx.ConnConfig) error {
+		password, err := r.PasswordFrom.read()
+		if err != nil {
+			return err
+		}
+
+		cfg.Password = password
+
+		return nil
+	}
+}
+
+// beforeConnect returns the function called before a new connection is established, to refresh credentials.
+//
+// It returns nil if credentials are static.
+func (r databaseSettings) beforeConnect() func(context.Context, *pgx.ConnConfig) error {
+	var hooks []func(context.Context, *pgx.ConnConfig) error
+	for _, hook := range []func(context.Context, *pgx.ConnConfig) error{
+		r.refreshPassword(),
+		r.provideCredentials(),
+		r.authenticate(),
+	} {
+		if hook != nil {
+			hooks = append(hooks, hook)
+		}
+	}
+
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, cfg *pgx.ConnConfig) error {
+		for _, hook := range hooks {
+			if err := hook(ctx, cfg); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// startURLWatch watches the file holding the connection URL and calls onChange whenever its content changes.
+//
+// It returns a function to stop watching.
+func (r databaseSettings) startURLWatch(interval time.Duration, l log.Logger, onChange func(string)) func() {
+	if interval <= 0 {
+		interval = defaultSecretWatchInterval
+	}
+
+	last, _ := r.URLFrom.read()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current, err := r.URLFrom.read()
+				if err != nil {
+					l.Warn("could not read the connection URL", zap.Error(err))
+
+					continue
+				}
+
+				if current == last {
+					continue
+				}
+
+				last = current
+				l.Info("the connection URL has changed", zap.String("db_url", redactURL(current)))
+				onChange(current)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,103 @@
+package pgrepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSecretSources(t *testing.T) {
+	dir := t.TempDir()
+	urlFile := filepath.Join(dir, "db-url")
+	passwordFile := filepath.Join(dir, "db-password")
+	require.NoError(t, os.WriteFile(urlFile, []byte("postgresql://app@db.example.com:5432/orders\n"), 0o600))
+	require.NoError(t, os.WriteFile(passwordFile, []byte("s3cret\n"), 0o600))
+
+	t.Run("should read secrets from config", func(t *testing.T) {
+		cfg := viper.New()
+		cfg.SetConfigType("yaml")
+		require.NoError(t, cfg.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    orders:
+      urlFrom:
+        file: `+urlFile+`
+      passwordFrom:
+        file: `+passwordFile+`
+`)))
+
+		s, err := makeSettingsFromViper(cfg, zap.NewNop())
+		require.NoError(t, err)
+
+		dbs := s.DBSettingsFor("orders")
+		require.NoError(t, dbs.Validate())
+		require.Equal(t, "postgresql://app@db.example.com:5432/orders", dbs.DBURL())
+
+		connCfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "")
+		require.NotNil(t, connCfg)
+		require.Equal(t, "s3cret", connCfg.Password)
+
+		for _, issue := range LintConfig(cfg) {
+			require.NotContains(t, issue.Message, "unknown key")
+		}
+	})
+
+	t.Run("should refresh password before connecting", func(t *testing.T) {
+		dbs := databaseSettings{}
+		WithPasswordFromFile(passwordFile)(&dbs)
+
+		refresh := dbs.refreshPassword()
+		require.NotNil(t, refresh)
+
+		require.NoError(t, os.WriteFile(passwordFile, []byte("rotated"), 0o600))
+		connCfg := &pgx.ConnConfig{}
+		require.NoError(t, refresh(context.Background(), connCfg))
+		require.Equal(t, "rotated", connCfg.Password)
+
+		require.Nil(t, databaseSettings{Password: "static"}.refreshPassword())
+	})
+
+	t.Run("should fail on missing secret file", func(t *testing.T) {
+		dbs := databaseSettings{URL: "postgresql://localhost:5432/test"}
+		WithPasswordFromFile(filepath.Join(dir, "missing"))(&dbs)
+
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should switch database from a URL file", func(t *testing.T) {
+		dbs := databaseSettings{}
+		WithURLFromFile(urlFile)(&dbs)
+
+		require.NoError(t, dbs.SwitchDB("other"))
+		require.Equal(t, "postgresql://app@db.example.com:5432/other", dbs.DBURL())
+	})
+
+	t.Run("should watch URL file", func(t *testing.T) {
+		dbs := databaseSettings{}
+		WithURLFromFile(urlFile)(&dbs)
+
+		changed := make(chan string, 1)
+		stop := dbs.startURLWatch(10*time.Millisecond, log.NewFactory(zap.NewNop()).Bg(), func(u string) {
+			changed <- u
+		})
+		defer stop()
+
+		require.NoError(t, os.WriteFile(urlFile, []byte("postgresql://db2.example.com:5432/orders"), 0o600))
+
+		select {
+		case u := <-changed:
+			require.Equal(t, "postgresql://db2.example.com:5432/orders", u)
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a change to be detected")
+		}
+	})
+}
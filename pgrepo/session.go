@@ -0,0 +1,116 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrSessionPinningDisabled is returned when acquiring a session behind a transaction-pooling proxy.
+var ErrSessionPinningDisabled = errors.New("session pinning is disabled behind a transaction-pooling proxy")
+
+type (
+	// Session is a connection of the pool pinned for the caller: all statements run in the same server session,
+	// e.g. to use temporary tables, cursors, advisory locks or LISTEN.
+	//
+	// A Session is not safe for concurrent use. It must be closed to return the connection to the pool.
+	Session interface {
+		ReadQuerier
+
+		// BeginTxx starts a transaction in the session.
+		BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error)
+
+		// Raw runs a function on the native pgx connection of the session, e.g. to wait for notifications.
+		//
+		// The native connection is not available when the driver connections are wrapped (e.g. when
+		// opencensus tracing is enabled).
+		Raw(ctx context.Context, fn func(context.Context, *pgx.Conn) error) error
+
+		// Close discards the state of the session, and returns the connection to the pool.
+		Close() error
+	}
+
+	pinnedSession struct {
+		*sqlx.Conn
+		dbs databaseSettings
+	}
+)
+
+var _ Session = &pinnedSession{}
+
+// AcquireSession pins a connection of the master pool for the caller, until the session is closed.
+//
+// When the session is closed, its state (temporary tables, advisory locks, LISTEN, SET parameters...) is
+// discarded before the connection is returned to the pool. Connections whose state cannot be discarded
+// (e.g. within an aborted transaction) are closed instead.
+//
+// Behind a transaction-pooling proxy (see WithCompat), AcquireSession returns ErrSessionPinningDisabled.
+func (r *Repository) AcquireSession(ctx context.Context) (Session, error) {
+	if r.db == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	if r.PGConfig.transactionPooling() {
+		return nil, ErrSessionPinningDisabled
+	}
+
+	conn, err := r.db.Connx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pinnedSession{Conn: conn, dbs: r.databaseSettings}, nil
+}
+
+func (s *pinnedSession) DriverName() string {
+	return driverName
+}
+
+func (s *pinnedSession) BindNamed(query string, arg any) (string, []any, error) {
+	return sqlx.BindNamed(sqlx.BindType(driverName), query, arg)
+}
+
+func (s *pinnedSession) Raw(ctx context.Context, fn func(context.Context, *pgx.Conn) error) error {
+	return s.Conn.Raw(func(driverConn any) error {
+		native, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errWrappedConn
+		}
+
+		return fn(ctx, native.Conn())
+	})
+}
+
+func (s *pinnedSession) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.dbs.maxWait())
+	defer cancel()
+
+	err := s.Conn.Raw(func(driverConn any) error {
+		native, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			// the state of the session may not be discarded safely: the connection is closed
+			return driver.ErrBadConn
+		}
+
+		if err := discardAll(ctx, native.Conn(), s.dbs.setParams()); err != nil {
+			return driver.ErrBadConn
+		}
+
+		return nil
+	})
+	switch {
+	case errors.Is(err, driver.ErrBadConn):
+		// the connection has been closed
+		return nil
+	case err != nil:
+		return fmt.Errorf("could not release session: %w", err)
+	default:
+		return s.Conn.Close()
+	}
+}
@@ -0,0 +1,87 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// ResetPolicyNone leaves the session state of pooled connections untouched (the default).
+	ResetPolicyNone = "none"
+
+	// ResetPolicyResetAll restores all run-time parameters to their defaults before a connection is reused.
+	ResetPolicyResetAll = "reset_all"
+
+	// ResetPolicyDiscardAll discards all session state (parameters, temporary tables, prepared statements, advisory locks...)
+	// before a connection is reused.
+	ResetPolicyDiscardAll = "discard_all"
+)
+
+// resetPolicy returns the normalized reset policy for pooled connections.
+func (p *poolSettings) resetPolicy() string {
+	if p == nil {
+		return ResetPolicyNone
+	}
+
+	policy := strings.TrimSpace(strings.ToLower(p.ResetPolicy))
+	if policy == "" {
+		return ResetPolicyNone
+	}
+
+	return policy
+}
+
+func (p *poolSettings) validateResetPolicy() error {
+	switch p.resetPolicy() {
+	case ResetPolicyNone, ResetPolicyResetAll, ResetPolicyDiscardAll:
+		return nil
+	default:
+		return fmt.Errorf("unsupported reset policy %q: %w", p.ResetPolicy, ErrInvalidConfig)
+	}
+}
+
+// resetSession returns the function called before a pooled connection is reused, or nil if no reset is required.
+//
+// The configured SET parameters are applied again after the session state has been reset.
+func (r databaseSettings) resetSession() func(context.Context, *pgx.Conn) error {
+	var stmt string
+	switch r.PGConfig.resetPolicy() {
+	case ResetPolicyResetAll:
+		stmt = `RESET ALL`
+	case ResetPolicyDiscardAll:
+		stmt = `DISCARD ALL`
+	default:
+		return nil
+	}
+
+	params := r.setParams()
+
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		if stmt == `DISCARD ALL` {
+			return discardAll(ctx, conn, params)
+		}
+
+		if _, err := conn.PgConn().Exec(ctx, stmt).ReadAll(); err != nil {
+			return fmt.Errorf("%s failed: %w", stmt, err)
+		}
+
+		return execSetParams(ctx, conn.PgConn(), params)
+	}
+}
+
+// discardAll discards all session state, then applies the SET parameters again.
+func discardAll(ctx context.Context, conn *pgx.Conn, params map[string]string) error {
+	// prepared statements are dropped: the statement cache of the driver must be cleared too
+	if err := conn.DeallocateAll(ctx); err != nil {
+		return err
+	}
+
+	if _, err := conn.PgConn().Exec(ctx, `DISCARD ALL`).ReadAll(); err != nil {
+		return fmt.Errorf("DISCARD ALL failed: %w", err)
+	}
+
+	return execSetParams(ctx, conn.PgConn(), params)
+}
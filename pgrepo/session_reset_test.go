@@ -0,0 +1,30 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPolicy(t *testing.T) {
+	var nilConfig *poolSettings
+	require.Equal(t, ResetPolicyNone, nilConfig.resetPolicy())
+	require.Nil(t, databaseSettings{}.resetSession())
+
+	for _, policy := range []string{"", ResetPolicyNone, ResetPolicyResetAll, ResetPolicyDiscardAll, "DISCARD_ALL"} {
+		p := poolSettingsFromOptions([]PoolOption{WithResetPolicy(policy)})
+		require.NoError(t, p.validateResetPolicy())
+	}
+
+	p := poolSettingsFromOptions([]PoolOption{WithResetPolicy("discard")})
+	require.ErrorIs(t, p.validateResetPolicy(), ErrInvalidConfig)
+
+	dbs := databaseSettings{URL: DefaultURL, PGConfig: p}
+	require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+
+	dbs.PGConfig = poolSettingsFromOptions([]PoolOption{WithResetPolicy(ResetPolicyNone)})
+	require.Nil(t, dbs.resetSession())
+
+	dbs.PGConfig = poolSettingsFromOptions([]PoolOption{WithResetPolicy(ResetPolicyResetAll)})
+	require.NotNil(t, dbs.resetSession())
+}
@@ -0,0 +1,61 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireSession(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		_, err := (&Repository{}).AcquireSession(ctx)
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	server := &fakeReplica{}
+	server.inRecovery.Store(true)
+	db := sqlx.NewDb(sql.OpenDB(server), driverName)
+	t.Cleanup(func() { _ = db.Close() })
+
+	t.Run("should be disabled behind a transaction-pooling proxy", func(t *testing.T) {
+		r := &Repository{db: db, databaseSettings: databaseSettings{
+			PGConfig: poolSettingsFromOptions([]PoolOption{WithCompat(CompatPgBouncer)}),
+		}}
+
+		_, err := r.AcquireSession(ctx)
+		require.ErrorIs(t, err, ErrSessionPinningDisabled)
+	})
+
+	r := &Repository{db: db}
+
+	t.Run("should pin a connection", func(t *testing.T) {
+		session, err := r.AcquireSession(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, db.Stats().InUse)
+
+		var inRecovery bool
+		require.NoError(t, session.GetContext(ctx, &inRecovery, `SELECT pg_is_in_recovery()`))
+		require.True(t, inRecovery)
+
+		query, args, err := session.BindNamed(`SELECT :id`, map[string]any{"id": 1})
+		require.NoError(t, err)
+		require.Equal(t, `SELECT $1`, query)
+		require.Equal(t, []any{1}, args)
+
+		t.Run("native connection should not be available from a fake driver", func(t *testing.T) {
+			err := session.Raw(ctx, func(context.Context, *pgx.Conn) error { return nil })
+			require.ErrorIs(t, err, errWrappedConn)
+		})
+
+		require.NoError(t, session.Close())
+		require.Equal(t, 0, db.Stats().InUse)
+		require.Equal(t, 0, db.Stats().OpenConnections, "expected a session which state cannot be discarded to be closed")
+		require.ErrorIs(t, session.Close(), sql.ErrConnDone)
+	})
+}
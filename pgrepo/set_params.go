@@ -0,0 +1,226 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// rexGUCName matches the syntax of postgres run-time parameters (GUC), including
+// custom parameters qualified by a prefix (e.g. "app.tenant_id").
+var rexGUCName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+
+// validateSetParams checks that the keys of SET clauses are valid run-time parameter names.
+func validateSetParams(params map[string]string) error {
+	for k := range params {
+		if name := os.ExpandEnv(k); !rexGUCName.MatchString(name) {
+			return fmt.Errorf("invalid parameter name in SET clause: %q: %w", name, ErrInvalidConfig)
+		}
+	}
+
+	return nil
+}
+
+// setParams returns the SET parameters of a database: the parameters of the pool settings,
+// overridden by the search path of the schema setting, then by the parameters of the database.
+//
+// The search path of a namespace takes precedence (see Namespace).
+func (r databaseSettings) setParams() map[string]string {
+	var pool map[string]string
+	if r.PGConfig != nil {
+		pool = r.PGConfig.Set
+	}
+
+	if len(r.Set) == 0 && len(r.Schema) == 0 && r.namespace == "" {
+		return pool
+	}
+
+	params := make(map[string]string, len(pool)+len(r.Set)+1)
+	for k, v := range pool {
+		params[k] = v
+	}
+	if len(r.Schema) > 0 {
+		params["search_path"] = r.searchPath()
+	}
+	for k, v := range r.Set {
+		params[k] = v
+	}
+	if r.namespace != "" {
+		params["search_path"] = quoteIdentifier(r.namespace) + ", public"
+	}
+
+	return params
+}
+
+// execSetParams executes SET key = value commands on a freshly established connection.
+func execSetParams(ctx context.Context, conn *pgconn.PgConn, params map[string]string) error {
+	for k, v := range params {
+		k = os.ExpandEnv(k)
+		v = os.ExpandEnv(v)
+
+		m := conn.Exec(ctx, fmt.Sprintf(`SET %s = %s`, k, v))
+		_, e := m.ReadAll()
+		if e != nil {
+			return setParamError(k, v, e)
+		}
+
+		e = m.Close()
+		if e != nil {
+			return setParamError(k, v, e)
+		}
+	}
+
+	return nil
+}
+
+// setParamError reports a failed SET command.
+//
+// An error returned by the server (e.g. an unknown parameter or an invalid value) wraps ErrInvalidConfig,
+// so the connection is not retried at startup.
+func setParamError(k, v string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return fmt.Errorf("SET %s = %s failed: %w", k, redactValue(v), errors.Join(ErrInvalidConfig, err))
+	}
+
+	return fmt.Errorf("SET %s = %s failed: %w", k, redactValue(v), err)
+}
+
+// redactValue hides all but the first characters of a value, which may be sensitive.
+func redactValue(v string) string {
+	const visible = 2
+
+	runes := []rune(v)
+	if len(runes) <= visible {
+		return "***"
+	}
+
+	return string(runes[:visible]) + "***"
+}
+
+// setParamsProbe checks that configured SET parameters are valid, when connections fail to apply them at startup.
+//
+// The probe uses a dedicated connection, without the AfterConnect hook that would otherwise fail
+// on the first invalid parameter. It runs once, after the database has been reached by the startup ping.
+type setParamsProbe struct {
+	cfg    *pgx.ConnConfig
+	params map[string]string
+	before func(context.Context, *pgx.ConnConfig) error
+}
+
+// reportSetParams reports all invalid SET parameters at once, when the startup ping failed on a SET parameter.
+//
+// Other startup errors are returned unchanged.
+func (r databaseSettings) reportSetParams(ctx context.Context, connCfg *pgx.ConnConfig, err error) error {
+	params := r.setParams()
+	if len(params) == 0 || !errors.Is(err, ErrInvalidConfig) {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.maxWait())
+	defer cancel()
+
+	probe := setParamsProbe{cfg: connCfg, params: params, before: r.beforeConnect()}
+	if probeErr := probe.PingContext(ctx); errors.Is(probeErr, ErrInvalidConfig) {
+		return probeErr
+	}
+
+	return err
+}
+
+func (p setParamsProbe) PingContext(ctx context.Context) error {
+	cfg := p.cfg.Copy()
+	cfg.AfterConnect = nil
+
+	if p.before != nil {
+		if err := p.before(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close(context.Background())
+	}()
+
+	return probeSetParams(ctx, conn, p.params)
+}
+
+// probeSetParams verifies that every SET parameter exists in pg_settings and that its value is accepted,
+// using set_config in a transaction which is eventually rolled back.
+//
+// All invalid parameters are reported at once, as an error wrapping ErrInvalidConfig.
+func probeSetParams(ctx context.Context, conn *pgx.Conn, params map[string]string) error {
+	tx, err := conn.Begin(withManagedTx(ctx))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(context.Background())
+	}()
+
+	var invalid error
+	for k, v := range params {
+		name := os.ExpandEnv(k)
+		value := os.ExpandEnv(v)
+
+		// custom parameters (e.g. "app.tenant_id") are not listed in pg_settings until they are set
+		if !strings.Contains(name, ".") {
+			var exists bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_settings WHERE name = lower($1))`, name).Scan(&exists); err != nil {
+				return err
+			}
+
+			if !exists {
+				invalid = errors.Join(invalid, fmt.Errorf("unknown run-time parameter %q", name))
+
+				continue
+			}
+		}
+
+		// each value is checked within a savepoint, so the transaction remains usable after a failure
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return err
+		}
+
+		_, err = savepoint.Exec(ctx, `SELECT set_config($1, $2, true)`, name, unquoteSetValue(value))
+		_ = savepoint.Rollback(ctx)
+
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			invalid = errors.Join(invalid, fmt.Errorf("SET %s = %s is not valid: %w", name, redactValue(value), err))
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if invalid != nil {
+		return fmt.Errorf("%w: SET parameters: %w", ErrInvalidConfig, invalid)
+	}
+
+	return nil
+}
+
+// unquoteSetValue converts the value of a SET clause into the plain text expected by set_config.
+func unquoteSetValue(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && strings.HasPrefix(v, "'") && strings.HasSuffix(v, "'") {
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+	}
+
+	return v
+}
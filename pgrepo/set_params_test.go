@@ -0,0 +1,141 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateSetParams(t *testing.T) {
+	require.NoError(t, validateSetParams(nil))
+	require.NoError(t, validateSetParams(map[string]string{
+		"plan_cache_mode": "force_custom_plan",
+		"app.tenant_id":   "42",
+		"search_path":     "public",
+	}))
+
+	for _, invalid := range []string{"", "1abc", "work_mem; DROP TABLE x", "app..x", "app.", "a b"} {
+		require.ErrorIsf(t, validateSetParams(map[string]string{invalid: "x"}), ErrInvalidConfig, "expected %q to be invalid", invalid)
+	}
+
+	t.Run("keys are expanded from env", func(t *testing.T) {
+		t.Setenv("PG_TEST_GUC", "work_mem")
+		require.NoError(t, validateSetParams(map[string]string{"$PG_TEST_GUC": "64MB"}))
+	})
+
+	t.Run("settings are validated", func(t *testing.T) {
+		dbs := databaseSettings{
+			URL:      DefaultURL,
+			PGConfig: poolSettingsFromOptions([]PoolOption{WithSetClause("bad name", "x")}),
+		}
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+}
+
+func TestRedactValue(t *testing.T) {
+	require.Equal(t, "***", redactValue(""))
+	require.Equal(t, "***", redactValue("on"))
+	require.Equal(t, "se***", redactValue("secret"))
+	require.Equal(t, "***", redactValue("é"))
+	require.Equal(t, "日本***", redactValue("日本語"))
+}
+
+func TestSetParamError(t *testing.T) {
+	err := setParamError("work_mem", "lots", &pgconn.PgError{Code: "22023", Message: `invalid value for parameter "work_mem"`})
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	require.Contains(t, err.Error(), "SET work_mem = lo***")
+
+	errBroken := errors.New("connection reset by peer")
+	err = setParamError("work_mem", "64MB", errBroken)
+	require.ErrorIs(t, err, errBroken)
+	require.NotErrorIs(t, err, ErrInvalidConfig, "network errors are retried at startup")
+
+	t.Run("other startup errors are not probed", func(t *testing.T) {
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithSetClause("work_mem", "64MB")})}
+		require.ErrorIs(t, dbs.reportSetParams(context.Background(), nil, errBroken), errBroken)
+	})
+}
+
+func TestSettingsFromViperWithInvalidSetParams(t *testing.T) {
+	cfg := viper.New()
+	cfg.SetConfigType("yaml")
+	require.NoError(t, cfg.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    default:
+      url: 'postgresql://localhost:5432/testdb'
+      pgconfig:
+        set:
+          'statement timeout': 10s
+`)))
+
+	_, err := SettingsFromViper(cfg)
+	require.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestUnquoteSetValue(t *testing.T) {
+	require.Equal(t, "UTC", unquoteSetValue("'UTC'"))
+	require.Equal(t, "it's", unquoteSetValue("'it''s'"))
+	require.Equal(t, "64MB", unquoteSetValue(" 64MB "))
+	require.Equal(t, "public, app", unquoteSetValue("public, app"))
+	require.Equal(t, "'", unquoteSetValue("'"))
+}
+
+func TestDBSetParams(t *testing.T) {
+	s := settingsFromOptions([]Option{
+		WithDefaultPoolOptions(
+			WithSetClause("plan_cache_mode", "force_custom_plan"),
+			WithSetClause("statement_timeout", "10s"),
+		),
+		WithDatabaseSettings("reporting",
+			WithURL(DefaultURL),
+			WithDBSetClause("statement_timeout", "5min"),
+			WithDBSetClause("search_path", "reporting, public"),
+		),
+	})
+
+	t.Run("database parameters should take precedence over pool parameters", func(t *testing.T) {
+		dbs := s.DBSettingsFor("reporting")
+		require.Equal(t, map[string]string{
+			"plan_cache_mode":   "force_custom_plan",
+			"statement_timeout": "5min",
+			"search_path":       "reporting, public",
+		}, dbs.setParams())
+		require.Equal(t, "10s", dbs.PGConfig.Set["statement_timeout"])
+	})
+
+	t.Run("should use pool parameters otherwise", func(t *testing.T) {
+		require.Equal(t, map[string]string{
+			"plan_cache_mode":   "force_custom_plan",
+			"statement_timeout": "10s",
+		}, s.DBSettingsFor(DefaultDBAlias).setParams())
+	})
+
+	t.Run("database parameters are validated", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithURL(DefaultURL), WithDBSetClause("bad name", "x")})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should load database parameters from config", func(t *testing.T) {
+		cfg := viper.New()
+		cfg.SetConfigType("yaml")
+		require.NoError(t, cfg.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    default:
+      url: 'postgresql://localhost:5432/testdb'
+      set:
+        search_path: app, public
+`)))
+
+		loaded, err := makeSettingsFromViper(cfg, zap.NewNop())
+		require.NoError(t, err)
+		require.Equal(t, "app, public", loaded.DBSettingsFor(DefaultDBAlias).setParams()["search_path"])
+	})
+}
@@ -0,0 +1,69 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrShuttingDown is returned when a new connection is requested while the repository is shutting down.
+var ErrShuttingDown = errors.New("repository shutting down")
+
+const shutdownPollInterval = 50 * time.Millisecond
+
+// Shutdown stops the repository gracefully, e.g. for a rolling update.
+//
+// Background workers (e.g. health checks, the activity monitor, maintenance jobs) are stopped first.
+// New connections to the master are refused with ErrShuttingDown, and idle connections are closed.
+// In-flight work on the master and the replicas is allowed to complete until the context is done:
+// the connections to the master still in use are then force-closed, and the error of the context is returned.
+//
+// The repository is eventually stopped, like with Stop.
+func (r *Repository) Shutdown(ctx context.Context) error {
+	if r.db == nil || r.connector == nil {
+		return r.Stop()
+	}
+
+	l := r.log.Bg()
+	r.stopBackground()
+	r.connector.shutdown()
+	r.db.SetMaxIdleConns(0)
+	r.replicas.drain()
+
+	err := r.waitInUse(ctx)
+	if err != nil {
+		forced := r.connector.forceClose()
+		l.Warn("shutdown: connections in use force-closed", zap.Int("force_closed", forced), zap.Error(err))
+	} else {
+		l.Info("shutdown: all connections drained")
+	}
+
+	return errors.Join(err, r.Stop())
+}
+
+// waitInUse waits until no connection of the master and replica pools is in use.
+func (r *Repository) waitInUse(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for r.inUse() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) inUse() int {
+	inUse := r.db.Stats().InUse
+	if stats, ok := r.replicas.stats(); ok {
+		inUse += stats.InUse
+	}
+
+	return inUse
+}
@@ -0,0 +1,110 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestShutdown(t *testing.T) {
+	start := func(t *testing.T) (*Repository, *observer.ObservedLogs) {
+		core, logs := observer.New(zap.InfoLevel)
+		connector := &reloadableConnector{connector: &fakeReplica{}}
+		db := sqlx.NewDb(sql.OpenDB(connector), driverName)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		return &Repository{db: db, connector: connector, log: log.NewFactory(zap.New(core))}, logs
+	}
+
+	t.Run("should drain idle connections", func(t *testing.T) {
+		r, logs := start(t)
+		require.NoError(t, r.db.Ping())
+
+		require.NoError(t, r.Shutdown(context.Background()))
+		require.Equal(t, 1, logs.FilterMessage("shutdown: all connections drained").Len())
+
+		_, err := r.connector.Connect(context.Background())
+		require.ErrorIs(t, err, ErrShuttingDown)
+	})
+
+	t.Run("should wait for connections in use", func(t *testing.T) {
+		r, _ := start(t)
+		conn, err := r.db.Conn(context.Background())
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			_ = conn.Close()
+		}()
+
+		require.NoError(t, r.Shutdown(context.Background()))
+	})
+
+	t.Run("should force-close connections in use after the deadline", func(t *testing.T) {
+		r, logs := start(t)
+		conn, err := r.db.Conn(context.Background())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		require.ErrorIs(t, r.Shutdown(ctx), context.DeadlineExceeded)
+		require.Equal(t, 1, logs.FilterMessage("shutdown: connections in use force-closed").Len())
+	})
+
+	t.Run("should wait for replica connections in use", func(t *testing.T) {
+		r, _ := start(t)
+		replicaDB := sqlx.NewDb(sql.OpenDB(&fakeReplica{}), driverName)
+		r.replicas = &replicaSet{members: []*replica{{db: replicaDB, name: "replica"}}}
+
+		conn, err := replicaDB.Conn(context.Background())
+		require.NoError(t, err)
+
+		released := make(chan struct{})
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			close(released)
+			_ = conn.Close()
+		}()
+
+		require.NoError(t, r.Shutdown(context.Background()))
+		select {
+		case <-released:
+		default:
+			require.Fail(t, "expected shutdown to wait for the replica connection")
+		}
+	})
+
+	t.Run("should stop background workers first", func(t *testing.T) {
+		r, _ := start(t)
+		conn, err := r.db.Conn(context.Background())
+		require.NoError(t, err)
+
+		// a background worker holding a connection until it is stopped
+		r.stop = append(r.stop, func() {
+			_ = conn.Close()
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		require.NoError(t, r.Shutdown(ctx))
+		require.Nil(t, r.stop)
+	})
+
+	t.Run("repository is not started", func(t *testing.T) {
+		require.NoError(t, (&Repository{}).Shutdown(context.Background()))
+	})
+}
@@ -0,0 +1,181 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// ErrNoStandby is returned when promoting a standby which is not configured, or already promoted.
+var ErrNoStandby = errors.New("no standby to promote")
+
+type standbySettings struct {
+	URL   string        // connection string to the disaster recovery cluster
+	Check time.Duration // interval between probes of the standby
+}
+
+// WithStandby keeps a warm standby connection to a disaster recovery cluster, probed every check interval
+// but serving no traffic, until promoted with Repository.PromoteStandby.
+func WithStandby(url string, check time.Duration) DBOption {
+	return func(o *databaseSettings) {
+		o.Standby.URL = url
+		o.Standby.Check = check
+	}
+}
+
+func (s standbySettings) checkInterval() time.Duration {
+	if s.Check <= 0 {
+		return defaultSettings.PGConfig.ReplicaCheck
+	}
+
+	return s.Check
+}
+
+func (s standbySettings) validate() error {
+	if s.URL == "" {
+		return nil
+	}
+
+	if _, err := pgx.ParseConfig(os.ExpandEnv(s.URL)); err != nil {
+		return fmt.Errorf("invalid connection string for standby: %s", err)
+	}
+
+	return nil
+}
+
+// standby is a warm connection pool to a disaster recovery cluster.
+type standby struct {
+	db        *sqlx.DB
+	url       string
+	name      string
+	checked   atomic.Bool
+	reachable atomic.Bool
+	promoted  atomic.Bool
+}
+
+// openStandby opens a minimal connection pool to the standby cluster.
+func (r *Repository) openStandby(ctx context.Context) (*standby, error) {
+	u := os.ExpandEnv(r.Standby.URL)
+	db, _, err := r.openPool(r.ConnConfig(u, r.log, r.app, r.connTracers(roleStandby)...), nil)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	sb := &standby{db: db, url: u, name: redactURL(u)}
+	sb.check(ctx, r.maxWait(), r.log.Bg())
+
+	return sb, nil
+}
+
+// check probes the standby and logs changes of its reachability.
+func (s *standby) check(ctx context.Context, timeout time.Duration, l log.Logger) {
+	if s.promoted.Load() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := s.db.PingContext(ctx)
+	reachable := err == nil
+	first := !s.checked.Swap(true)
+	if previous := s.reachable.Swap(reachable); first || previous != reachable {
+		if reachable {
+			l.Info("standby reachable", zap.String("standby", s.name))
+		} else {
+			l.Warn("standby unreachable", zap.String("standby", s.name), zap.Error(err))
+		}
+	}
+}
+
+func (s *standby) startCheck(interval, timeout time.Duration, l log.Logger) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.check(ctx, timeout, l)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (s *standby) close() error {
+	if s == nil {
+		return nil
+	}
+
+	return s.db.Close()
+}
+
+// StandbyReachable tells if the standby was reachable when last probed.
+func (r *Repository) StandbyReachable() bool {
+	return r.standby != nil && !r.standby.promoted.Load() && r.standby.reachable.Load()
+}
+
+// PromoteStandby flips the traffic over to the standby cluster, e.g. for a disaster recovery drill.
+//
+// The standby must be reachable. New connections of the master pool are established to the standby, and connections
+// to the former master are discarded. Handles obtained with DB() remain valid.
+//
+// Once the standby is promoted, the endpoints of the former cluster are no longer resolved (see WithEndpointResolver).
+//
+// NOTE: the promotion holds until the next reload of the configuration (see Reload), which connects to the
+// configured URL again.
+func (r *Repository) PromoteStandby(ctx context.Context) error {
+	sb := r.standby
+	if sb == nil || r.connector == nil || sb.promoted.Load() {
+		return ErrNoStandby
+	}
+
+	if err := sb.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("standby unreachable: %w", err)
+	}
+
+	dbs := r.connector.appliedSettings()
+	dbs.URL = sb.url
+	dbs.DSN = "" // the DSN takes precedence over the URL
+	dbs.URLFrom = secretSource{}
+
+	// the endpoints resolved for the former cluster no longer apply
+	r.endpoints.setDetached(true)
+	if err := r.reload(dbs, true); err != nil {
+		r.endpoints.setDetached(false)
+
+		return fmt.Errorf("could not promote standby: %w", err)
+	}
+	r.endpoints.unwatch()
+
+	// close idle connections to the former master right away
+	r.db.SetMaxIdleConns(0)
+	dbs.restoreIdleConns(r.db.DB)
+
+	sb.promoted.Store(true)
+	r.log.Bg().Warn("standby promoted: traffic flipped over", zap.String("standby", sb.name))
+
+	return sb.close()
+}
@@ -0,0 +1,98 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStandbyCheck(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := log.NewFactory(zap.New(core)).Bg()
+
+	dr := &fakeReplica{}
+	sb := &standby{db: sqlx.NewDb(sql.OpenDB(dr), driverName), name: "dr"}
+	t.Cleanup(func() {
+		_ = sb.close()
+	})
+
+	sb.check(context.Background(), time.Second, l)
+	require.True(t, sb.reachable.Load())
+	require.Equal(t, 1, logs.FilterMessage("standby reachable").Len())
+
+	t.Run("changes of reachability are logged once", func(t *testing.T) {
+		_ = sb.db.Close()
+		sb.db = sqlx.NewDb(sql.OpenDB(dr), driverName)
+		dr.down.Store(true)
+
+		sb.check(context.Background(), time.Second, l)
+		sb.check(context.Background(), time.Second, l)
+		require.False(t, sb.reachable.Load())
+		require.Equal(t, 1, logs.FilterMessage("standby unreachable").Len())
+	})
+}
+
+func TestPromoteStandby(t *testing.T) {
+	dbs := databaseSettingsFromOptions([]DBOption{WithURL(DefaultURL)})
+	lf := log.NewFactory(zap.NewNop())
+	connector := newReloadableConnector(dbs.ConnConfig(dbs.DBURL(), lf, ""), dbs, nil)
+	db := sqlx.NewDb(sql.OpenDB(connector), driverName)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	dr := &fakeReplica{}
+	r := &Repository{db: db, connector: connector, log: lf, databaseSettings: dbs}
+
+	t.Run("should require a standby", func(t *testing.T) {
+		require.ErrorIs(t, r.PromoteStandby(context.Background()), ErrNoStandby)
+	})
+
+	t.Run("should require a reachable standby", func(t *testing.T) {
+		dr.down.Store(true)
+		r.standby = &standby{db: sqlx.NewDb(sql.OpenDB(dr), driverName), url: "postgres://dr-cluster:5432/test", name: "dr"}
+
+		require.Error(t, r.PromoteStandby(context.Background()))
+		require.Equal(t, DefaultURL, r.connector.appliedSettings().URL)
+	})
+
+	t.Run("should flip traffic over to the standby", func(t *testing.T) {
+		dr.down.Store(false)
+
+		require.NoError(t, r.PromoteStandby(context.Background()))
+		require.Equal(t, "postgres://dr-cluster:5432/test", r.connector.appliedSettings().URL)
+
+		cfg, err := r.connector.connectConfig(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "dr-cluster", cfg.Host)
+		require.False(t, r.StandbyReachable())
+
+		require.ErrorIs(t, r.PromoteStandby(context.Background()), ErrNoStandby)
+	})
+
+	t.Run("should flip traffic over to the standby with a DSN", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithDSN("host=primary port=5432 dbname=test")})
+		connector := newReloadableConnector(dbs.ConnConfig(dbs.DBURL(), lf, ""), dbs, nil)
+		db := sqlx.NewDb(sql.OpenDB(connector), driverName)
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		r := &Repository{db: db, connector: connector, log: lf, databaseSettings: dbs}
+		r.standby = &standby{db: sqlx.NewDb(sql.OpenDB(&fakeReplica{}), driverName), url: "postgres://dr-cluster:5432/test", name: "dr"}
+
+		require.NoError(t, r.PromoteStandby(context.Background()))
+		require.Empty(t, r.connector.appliedSettings().DSN)
+
+		cfg, err := r.connector.connectConfig(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "dr-cluster", cfg.Host)
+	})
+}
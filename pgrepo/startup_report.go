@@ -0,0 +1,159 @@
+package pgrepo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap/zapcore"
+)
+
+// reportedSettings are the run-time parameters reported at startup.
+var reportedSettings = []string{
+	"work_mem",
+	"statement_timeout",
+	"lock_timeout",
+	"idle_in_transaction_session_timeout",
+	"default_transaction_isolation",
+	"max_connections",
+}
+
+var _ zapcore.ObjectMarshaler = StartupReport{}
+
+// StartupReport describes how a repository is connected to its database: who it is connected as,
+// to which server, with which session settings and pool sizes.
+//
+// It is logged when the repository is started, and is intended for admin endpoints and support tickets.
+type StartupReport struct {
+	Alias         string            `json:"alias"`
+	User          string            `json:"user" db:"user_name"`
+	Database      string            `json:"database" db:"database"`
+	ServerVersion string            `json:"server_version" db:"server_version"`
+	SSL           bool              `json:"ssl" db:"ssl"`
+	SearchPath    string            `json:"search_path" db:"search_path"`
+	TimeZone      string            `json:"timezone" db:"timezone"`
+	Settings      map[string]string `json:"settings"` // key run-time parameters, e.g. work_mem
+	Pool          PoolReport        `json:"pool"`
+	StartedAt     time.Time         `json:"started_at"`
+}
+
+// PoolReport describes the effective sizes and limits of a connection pool.
+type PoolReport struct {
+	MaxOpenConns    int           `json:"max_open_conns"` // 0 means unlimited
+	MaxIdleConns    int           `json:"max_idle_conns"`
+	MinConns        int           `json:"min_conns"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+}
+
+// StartupReport returns the report established when the repository was started.
+//
+// The report is not available when the repository is not started, or when the database could not be queried for it.
+func (r *Repository) StartupReport() (StartupReport, bool) {
+	if r.report == nil {
+		return StartupReport{}, false
+	}
+
+	report := *r.report
+	report.Settings = make(map[string]string, len(r.report.Settings))
+	for k, v := range r.report.Settings {
+		report.Settings[k] = v
+	}
+
+	return report, true
+}
+
+// startupReport queries the database for the identity and session settings of a connection.
+func (r *Repository) startupReport(ctx context.Context, db sqlx.QueryerContext) (*StartupReport, error) {
+	report := &StartupReport{
+		Alias:     r.alias,
+		Pool:      r.poolReport(),
+		StartedAt: time.Now(),
+	}
+
+	if err := sqlx.GetContext(ctx, db, report, `SELECT
+		current_user AS user_name,
+		current_database() AS database,
+		current_setting('server_version') AS server_version,
+		coalesce((SELECT ssl FROM pg_stat_ssl WHERE pid = pg_backend_pid()), false) AS ssl,
+		current_setting('search_path') AS search_path,
+		current_setting('TimeZone') AS timezone`,
+	); err != nil {
+		return nil, fmt.Errorf("could not query the startup report: %w", err)
+	}
+
+	var settings []struct {
+		Name    string `db:"name"`
+		Setting string `db:"setting"`
+	}
+	if err := sqlx.SelectContext(ctx, db, &settings,
+		`SELECT name, current_setting(name) AS setting FROM unnest($1::text[]) AS name`,
+		reportedSettings,
+	); err != nil {
+		return nil, fmt.Errorf("could not query the settings of the startup report: %w", err)
+	}
+
+	report.Settings = make(map[string]string, len(settings))
+	for _, s := range settings {
+		report.Settings[s.Name] = s.Setting
+	}
+
+	return report, nil
+}
+
+func (r databaseSettings) poolReport() PoolReport {
+	p := r.PGConfig
+	if p == nil {
+		return PoolReport{}
+	}
+
+	return PoolReport{
+		MaxOpenConns:    max(p.MaxOpenConns, 0),
+		MaxIdleConns:    p.idleConns(),
+		MinConns:        p.MinConns,
+		ConnMaxLifetime: p.maxLifetime(),
+		ConnMaxIdleTime: p.idleTime(),
+	}
+}
+
+// MarshalLogObject logs the report as a structured record.
+func (s StartupReport) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("alias", s.Alias)
+	enc.AddString("user", s.User)
+	enc.AddString("database", s.Database)
+	enc.AddString("server_version", s.ServerVersion)
+	enc.AddBool("ssl", s.SSL)
+	enc.AddString("search_path", s.SearchPath)
+	enc.AddString("timezone", s.TimeZone)
+
+	names := make([]string, 0, len(s.Settings))
+	for name := range s.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if err := enc.AddObject("settings", zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for _, name := range names {
+			enc.AddString(name, s.Settings[name])
+		}
+
+		return nil
+	})); err != nil {
+		return err
+	}
+
+	return enc.AddObject("pool", s.Pool)
+}
+
+// MarshalLogObject logs the pool sizes as a structured record.
+func (p PoolReport) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("max_open_conns", p.MaxOpenConns)
+	enc.AddInt("max_idle_conns", p.MaxIdleConns)
+	enc.AddInt("min_conns", p.MinConns)
+	enc.AddDuration("conn_max_lifetime", p.ConnMaxLifetime)
+	enc.AddDuration("conn_max_idle_time", p.ConnMaxIdleTime)
+
+	return nil
+}
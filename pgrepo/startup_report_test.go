@@ -0,0 +1,74 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStartupReport(t *testing.T) {
+	r := &Repository{
+		alias: "orders",
+		databaseSettings: databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{
+			WithMaxOpenConns(10),
+			WithMaxIdleConns(20),
+			WithConnMaxLifeTime(time.Hour),
+		})},
+	}
+
+	t.Run("should not be available before start", func(t *testing.T) {
+		_, ok := r.StartupReport()
+		require.False(t, ok)
+	})
+
+	t.Run("should fail when the database cannot be queried", func(t *testing.T) {
+		db := &recordingQueryer{}
+		_, err := r.startupReport(context.Background(), db)
+		require.Error(t, err)
+		require.Contains(t, db.query, "current_user")
+	})
+
+	t.Run("should report effective pool sizes", func(t *testing.T) {
+		require.Equal(t, PoolReport{MaxOpenConns: 10, MaxIdleConns: 10, ConnMaxLifetime: time.Hour}, r.poolReport())
+		require.Equal(t, PoolReport{}, databaseSettings{}.poolReport())
+	})
+
+	r.report = &StartupReport{
+		Alias:         "orders",
+		User:          "app",
+		Database:      "orders",
+		ServerVersion: "16.1",
+		SSL:           true,
+		SearchPath:    "app, public",
+		TimeZone:      "UTC",
+		Settings:      map[string]string{"work_mem": "4MB", "statement_timeout": "0"},
+		Pool:          r.poolReport(),
+	}
+
+	t.Run("should expose a copy of the report", func(t *testing.T) {
+		report, ok := r.StartupReport()
+		require.True(t, ok)
+		report.Settings["work_mem"] = "1GB"
+		require.Equal(t, "4MB", r.report.Settings["work_mem"])
+
+		asJSON, err := json.Marshal(report)
+		require.NoError(t, err)
+		require.Contains(t, string(asJSON), `"server_version":"16.1"`)
+	})
+
+	t.Run("should log a structured record", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		zap.New(core).Info("connection pool ok", zap.Object("startup_report", r.report))
+
+		record := logs.All()[0].ContextMap()["startup_report"].(map[string]interface{})
+		require.Equal(t, "app", record["user"])
+		require.Equal(t, true, record["ssl"])
+		require.Equal(t, map[string]interface{}{"work_mem": "4MB", "statement_timeout": "0"}, record["settings"])
+		require.Equal(t, 10, record["pool"].(map[string]interface{})["max_open_conns"])
+	})
+}
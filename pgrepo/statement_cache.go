@@ -0,0 +1,156 @@
+package pgrepo
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/sync/singleflight"
+)
+
+// maxCachedStatements bounds the number of prepared statements cached for a connection pool.
+const maxCachedStatements = 256
+
+// stmtCache caches prepared statements for a connection pool.
+//
+// The least recently used statements are closed when the cache is full.
+type stmtCache struct {
+	mx     sync.Mutex
+	db     *sqlx.DB
+	size   int
+	stmts  map[string]*list.Element
+	lru    *list.List
+	flight singleflight.Group
+}
+
+type cachedStmt struct {
+	query string
+	stmt  *sqlx.Stmt
+}
+
+func newStmtCache(db *sqlx.DB) *stmtCache {
+	return &stmtCache{
+		db:    db,
+		size:  maxCachedStatements,
+		stmts: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// Preparex returns a prepared statement for a query, from the cache of the connection pool.
+//
+// Statements are prepared once and shared by all callers: they must not be closed by the caller.
+// The cache is invalidated when the connection pool is closed.
+//
+// The cache holds up to 256 statements: the least recently used ones are closed to make room for new ones.
+// Statements should therefore be used right away, rather than retained by the caller.
+//
+// NOTE: prepared statements do not survive a session reset with the "discard_all" policy.
+// They are disabled behind a transaction-pooling proxy (see WithCompat).
+func (r *Repository) Preparex(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	if r.db == nil || r.stmts == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	if r.PGConfig.transactionPooling() {
+		return nil, ErrPreparedStatementsDisabled
+	}
+
+	return r.stmts.prepare(ctx, query)
+}
+
+func (c *stmtCache) prepare(ctx context.Context, query string) (*sqlx.Stmt, error) {
+	if stmt, ok := c.cached(query); ok {
+		return stmt, nil
+	}
+
+	// concurrent callers wait for a single statement to be prepared, without holding the lock of the cache
+	stmt, err, _ := c.flight.Do(query, func() (interface{}, error) {
+		if stmt, ok := c.cached(query); ok {
+			return stmt, nil
+		}
+
+		c.mx.Lock()
+		db := c.db
+		c.mx.Unlock()
+
+		if db == nil {
+			return nil, ErrDBNotInitialized
+		}
+
+		stmt, err := db.PreparexContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		return stmt, c.add(db, query, stmt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.(*sqlx.Stmt), nil
+}
+
+// cached returns a statement from the cache.
+func (c *stmtCache) cached(query string) (*sqlx.Stmt, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	elem, ok := c.stmts[query]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+
+	return elem.Value.(cachedStmt).stmt, true
+}
+
+// add a statement prepared on db to the cache, and close the statements evicted from the cache.
+func (c *stmtCache) add(db *sqlx.DB, query string, stmt *sqlx.Stmt) error {
+	c.mx.Lock()
+	if c.db != db {
+		// the cache has been reset in the meantime
+		c.mx.Unlock()
+
+		return errors.Join(ErrDBNotInitialized, stmt.Close())
+	}
+
+	c.stmts[query] = c.lru.PushFront(cachedStmt{query: query, stmt: stmt})
+
+	var evicted []*sqlx.Stmt
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Remove(c.lru.Back()).(cachedStmt)
+		delete(c.stmts, oldest.query)
+		evicted = append(evicted, oldest.stmt)
+	}
+	c.mx.Unlock()
+
+	for _, stale := range evicted {
+		_ = stale.Close()
+	}
+
+	return nil
+}
+
+// reset closes all cached statements.
+func (c *stmtCache) reset() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	var err error
+	for query, elem := range c.stmts {
+		err = errors.Join(err, elem.Value.(cachedStmt).stmt.Close())
+		delete(c.stmts, query)
+	}
+	c.lru.Init()
+	c.db = nil
+
+	return err
+}
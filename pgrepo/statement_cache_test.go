@@ -0,0 +1,142 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtCache(t *testing.T) {
+	ctx := context.Background()
+
+	newCache := func(t *testing.T) (*stmtCache, *preparingServer) {
+		server := &preparingServer{}
+		db := sqlx.NewDb(sql.OpenDB(server), driverName)
+		t.Cleanup(func() { _ = db.Close() })
+
+		return newStmtCache(db), server
+	}
+
+	t.Run("repository is not started", func(t *testing.T) {
+		r := &Repository{}
+		_, err := r.Preparex(ctx, `SELECT 1`)
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+
+		_, err = newStmtCache(nil).prepare(ctx, `SELECT 1`)
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("cached statements are shared", func(t *testing.T) {
+		c, server := newCache(t)
+
+		var wg sync.WaitGroup
+		stmts := make([]*sqlx.Stmt, 10)
+		errs := make([]error, len(stmts))
+		for i := range stmts {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				stmts[i], errs[i] = c.prepare(ctx, `SELECT 1`)
+			}(i)
+		}
+		wg.Wait()
+
+		require.NoError(t, errors.Join(errs...))
+		require.Equal(t, int32(1), server.prepared.Load())
+		for _, stmt := range stmts {
+			require.Same(t, stmts[0], stmt)
+		}
+	})
+
+	t.Run("least recently used statements are evicted", func(t *testing.T) {
+		c, server := newCache(t)
+		c.size = 2
+
+		first, err := c.prepare(ctx, `SELECT 1`)
+		require.NoError(t, err)
+		_, err = c.prepare(ctx, `SELECT 2`)
+		require.NoError(t, err)
+		_, err = c.prepare(ctx, `SELECT 1`)
+		require.NoError(t, err)
+		_, err = c.prepare(ctx, `SELECT 3`)
+		require.NoError(t, err)
+
+		require.Equal(t, int32(3), server.prepared.Load())
+		require.Equal(t, int32(1), server.closed.Load())
+		require.Len(t, c.stmts, 2)
+		require.NotContains(t, c.stmts, `SELECT 2`)
+
+		cached, err := c.prepare(ctx, `SELECT 1`)
+		require.NoError(t, err)
+		require.Same(t, first, cached)
+	})
+
+	t.Run("reset closes statements", func(t *testing.T) {
+		c, server := newCache(t)
+		_, err := c.prepare(ctx, `SELECT 1`)
+		require.NoError(t, err)
+
+		require.NoError(t, c.reset())
+		require.Equal(t, int32(1), server.closed.Load())
+		require.Empty(t, c.stmts)
+
+		_, err = c.prepare(ctx, `SELECT 1`)
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("reset is nil-safe", func(t *testing.T) {
+		var c *stmtCache
+		require.NoError(t, c.reset())
+		require.NoError(t, newStmtCache(nil).reset())
+	})
+}
+
+// preparingServer is a database/sql connector which counts prepared and closed statements.
+type preparingServer struct {
+	prepared atomic.Int32
+	closed   atomic.Int32
+}
+
+type (
+	preparingConn struct{ s *preparingServer }
+	preparingStmt struct{ s *preparingServer }
+)
+
+func (s *preparingServer) Connect(context.Context) (driver.Conn, error) {
+	return preparingConn{s: s}, nil
+}
+
+func (s *preparingServer) Driver() driver.Driver { return nil }
+
+func (c preparingConn) Prepare(string) (driver.Stmt, error) {
+	c.s.prepared.Add(1)
+
+	return preparingStmt(c), nil
+}
+
+func (c preparingConn) Close() error              { return nil }
+func (c preparingConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (s preparingStmt) Close() error {
+	s.s.closed.Add(1)
+
+	return nil
+}
+
+func (s preparingStmt) NumInput() int { return -1 }
+
+func (s preparingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s preparingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
@@ -0,0 +1,85 @@
+package pgrepo
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SQLOperation returns the leading keyword of a SQL statement, in upper case (e.g. "SELECT"),
+// e.g. to tag the spans of a tracer (see WithPGXTracer).
+//
+// Leading white space and comments are skipped.
+func SQLOperation(query string) string {
+	return sqlOperation(query)
+}
+
+func sqlOperation(query string) string {
+	query = skipLeadingComments(query)
+
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	if end < 0 {
+		end = len(query)
+	}
+
+	return strings.ToUpper(query[:end])
+}
+
+func skipLeadingComments(query string) string {
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+
+		switch {
+		case strings.HasPrefix(query, "--"):
+			idx := strings.IndexByte(query, '\n')
+			if idx < 0 {
+				return ""
+			}
+			query = query[idx+1:]
+		case strings.HasPrefix(query, "/*"):
+			idx := strings.Index(query, "*/")
+			if idx < 0 {
+				return ""
+			}
+			query = query[idx+2:]
+		default:
+			return query
+		}
+	}
+}
+
+// Statement classes, after the classes of the pgaudit extension.
+const (
+	StatementClassRead     = "read"
+	StatementClassWrite    = "write"
+	StatementClassFunction = "function"
+	StatementClassRole     = "role"
+	StatementClassDDL      = "ddl"
+	StatementClassMisc     = "misc"
+)
+
+// statementClass classifies a SQL statement like pgaudit does: read, write, function, role, ddl or misc.
+func statementClass(query string) string {
+	switch op := sqlOperation(query); op {
+	case "SELECT", "WITH", "VALUES", "TABLE", "SHOW", "FETCH", "EXPLAIN":
+		return StatementClassRead
+	case "INSERT", "UPDATE", "DELETE", "MERGE", "TRUNCATE", "COPY":
+		return StatementClassWrite
+	case "CALL", "DO":
+		return StatementClassFunction
+	case "GRANT", "REVOKE":
+		return StatementClassRole
+	case "CREATE", "ALTER", "DROP":
+		switch sqlOperation(skipLeadingComments(query)[len(op):]) {
+		case "ROLE", "USER", "GROUP":
+			return StatementClassRole
+		default:
+			return StatementClassDDL
+		}
+	case "COMMENT", "REINDEX", "SECURITY", "IMPORT":
+		return StatementClassDDL
+	default:
+		return StatementClassMisc
+	}
+}
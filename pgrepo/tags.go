@@ -0,0 +1,112 @@
+package pgrepo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxApplicationNameLength is the maximum length of the application_name parameter (NAMEDATALEN - 1)
+	maxApplicationNameLength = 63
+
+	// adminApplicationSuffix marks the sessions of administrative tasks (e.g. migrations, maintenance jobs),
+	// so that they are told apart from the sessions of the application (see WithActivityMonitor).
+	adminApplicationSuffix = "/admin"
+)
+
+func (r databaseSettings) sortedTagKeys() []string {
+	keys := make([]string, 0, len(r.Tags))
+	for k := range r.Tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// applicationName builds the application_name runtime parameter from the app name,
+// suffixed by the configured tags, e.g. "checkout[service=checkout,team=payments]".
+//
+// The result is truncated to the maximum length supported by postgres.
+func (r databaseSettings) applicationName(app string) string {
+	if len(r.Tags) == 0 {
+		return app
+	}
+
+	var b strings.Builder
+	b.WriteString(app)
+	b.WriteByte('[')
+	for i, k := range r.sortedTagKeys() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(r.Tags[k])
+	}
+	b.WriteByte(']')
+
+	return truncateApplicationName(b.String(), maxApplicationNameLength)
+}
+
+// adminApplicationName is the application_name of the sessions of administrative tasks, or "" if the application
+// has no name.
+func (r databaseSettings) adminApplicationName(app string) string {
+	name := r.applicationName(app)
+	if name == "" {
+		return ""
+	}
+
+	return truncateApplicationName(name, maxApplicationNameLength-len(adminApplicationSuffix)) + adminApplicationSuffix
+}
+
+// markAdminSession sets the application_name of a session running an administrative task.
+//
+// It returns a function to restore the application_name of the connection.
+func (r *Repository) markAdminSession(ctx context.Context, conn sqlx.ExecerContext) func() {
+	name := r.adminApplicationName(r.app)
+	if name == "" {
+		return func() {}
+	}
+
+	if _, err := conn.ExecContext(ctx, `SELECT set_config('application_name', $1, false)`, name); err != nil {
+		return func() {}
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), `RESET application_name`)
+	}
+}
+
+// truncateApplicationName truncates a name to at most maxLength bytes, without splitting a multi-byte character.
+func truncateApplicationName(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+
+	end := maxLength
+	for end > 0 && !utf8.RuneStart(name[end]) {
+		end--
+	}
+
+	return name[:end]
+}
+
+// tagFields returns the configured tags as logger fields.
+func (r databaseSettings) tagFields() []zap.Field {
+	if len(r.Tags) == 0 {
+		return nil
+	}
+
+	fields := make([]zap.Field, 0, len(r.Tags))
+	for _, k := range r.sortedTagKeys() {
+		fields = append(fields, zap.String("tag."+k, r.Tags[k]))
+	}
+
+	return fields
+}
@@ -0,0 +1,62 @@
+package pgrepo
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplicationName(t *testing.T) {
+	t.Run("without tags", func(t *testing.T) {
+		require.Equal(t, "checkout", databaseSettings{}.applicationName("checkout"))
+		require.Empty(t, databaseSettings{}.applicationName(""))
+	})
+
+	t.Run("with tags", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithTag("team", "payments"),
+			WithTag("service", "checkout"),
+		})
+
+		require.Equal(t, "checkout[service=checkout,team=payments]", dbs.applicationName("checkout"))
+		require.Equal(t, "[service=checkout,team=payments]", dbs.applicationName(""))
+		require.Len(t, dbs.tagFields(), 2)
+	})
+
+	t.Run("with truncated name", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithTag("team", strings.Repeat("x", 100)),
+		})
+
+		require.Len(t, dbs.applicationName("checkout"), maxApplicationNameLength)
+	})
+
+	t.Run("with truncated multi-byte characters", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithTag("team", strings.Repeat("é", 100)),
+		})
+
+		name := dbs.applicationName("checkout")
+		require.True(t, utf8.ValidString(name))
+		require.LessOrEqual(t, len(name), maxApplicationNameLength)
+		require.Greater(t, len(name), maxApplicationNameLength-utf8.UTFMax)
+
+		admin := dbs.adminApplicationName("checkout")
+		require.True(t, utf8.ValidString(admin))
+		require.True(t, strings.HasSuffix(admin, adminApplicationSuffix))
+	})
+
+	t.Run("with administrative sessions", func(t *testing.T) {
+		require.Equal(t, "checkout/admin", databaseSettings{}.adminApplicationName("checkout"))
+		require.Empty(t, databaseSettings{}.adminApplicationName(""))
+
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithTag("team", strings.Repeat("x", 100)),
+		})
+		name := dbs.adminApplicationName("checkout")
+		require.Len(t, name, maxApplicationNameLength)
+		require.True(t, strings.HasSuffix(name, adminApplicationSuffix))
+	})
+}
@@ -0,0 +1,65 @@
+package pgrepo
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Session attributes required from a server, when several hosts are specified (libpq "target_session_attrs").
+const (
+	TargetSessionAttrsAny           = "any"
+	TargetSessionAttrsReadWrite     = "read-write"
+	TargetSessionAttrsReadOnly      = "read-only"
+	TargetSessionAttrsPrimary       = "primary"
+	TargetSessionAttrsStandby       = "standby"
+	TargetSessionAttrsPreferStandby = "prefer-standby"
+)
+
+var targetSessionValidators = map[string]pgconn.ValidateConnectFunc{
+	TargetSessionAttrsAny:           nil,
+	TargetSessionAttrsReadWrite:     pgconn.ValidateConnectTargetSessionAttrsReadWrite,
+	TargetSessionAttrsReadOnly:      pgconn.ValidateConnectTargetSessionAttrsReadOnly,
+	TargetSessionAttrsPrimary:       pgconn.ValidateConnectTargetSessionAttrsPrimary,
+	TargetSessionAttrsStandby:       pgconn.ValidateConnectTargetSessionAttrsStandby,
+	TargetSessionAttrsPreferStandby: pgconn.ValidateConnectTargetSessionAttrsPreferStandby,
+}
+
+// WithTargetSessionAttrs sets the attributes required from a server, with precedence over the "target_session_attrs"
+// parameter of the URL.
+//
+// With several hosts, e.g. "postgres://h1,h2,h3/db", hosts are tried in turn until one matches. For instance,
+// TargetSessionAttrsReadWrite connects to the primary of a high-availability cluster, wherever it runs.
+// When ports are specified in a URL, every host must specify its port, e.g. "postgres://h1:5432,h2:5433/db".
+//
+// Connections established before a failover are not validated again: they fail when the former primary is demoted.
+func WithTargetSessionAttrs(attrs string) DBOption {
+	return func(o *databaseSettings) {
+		o.TargetSessionAttrs = attrs
+	}
+}
+
+func (r databaseSettings) validateTargetSessionAttrs() error {
+	if r.TargetSessionAttrs == "" {
+		return nil
+	}
+
+	if _, ok := targetSessionValidators[r.TargetSessionAttrs]; !ok {
+		return fmt.Errorf("invalid target_session_attrs %q: expected one of any, read-write, read-only, primary, standby or prefer-standby: %w",
+			r.TargetSessionAttrs, ErrInvalidConfig,
+		)
+	}
+
+	return nil
+}
+
+// applyTargetSessionAttrs validates new connections against the target session attributes, when set.
+func (r databaseSettings) applyTargetSessionAttrs(dcfg *pgx.ConnConfig) {
+	validator, ok := targetSessionValidators[r.TargetSessionAttrs]
+	if !ok {
+		return
+	}
+
+	dcfg.ValidateConnect = validator
+}
@@ -0,0 +1,42 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTargetSessionAttrs(t *testing.T) {
+	lg := log.NewFactory(zap.NewNop())
+	const multiHostURL = "postgres://app@h1,h2,h3/orders?sslmode=disable"
+
+	t.Run("should fall back to hosts in turn", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithURL(multiHostURL), WithTargetSessionAttrs(TargetSessionAttrsReadWrite)})
+		require.NoError(t, dbs.Validate())
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), lg, "")
+		require.NotNil(t, dcfg)
+		require.Equal(t, "h1", dcfg.Host)
+		require.Len(t, dcfg.Fallbacks, 2)
+		require.NotNil(t, dcfg.ValidateConnect)
+	})
+
+	t.Run("should take precedence over the URL", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{
+			WithURL(multiHostURL + "&target_session_attrs=read-write"),
+			WithTargetSessionAttrs(TargetSessionAttrsAny),
+		})
+		require.NoError(t, dbs.Validate())
+		require.Nil(t, dbs.ConnConfig(dbs.DBURL(), lg, "").ValidateConnect)
+
+		dbs.TargetSessionAttrs = ""
+		require.NotNil(t, dbs.ConnConfig(dbs.DBURL(), lg, "").ValidateConnect)
+	})
+
+	t.Run("should reject invalid attributes", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithURL(multiHostURL), WithTargetSessionAttrs("master")})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+}
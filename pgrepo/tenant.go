@@ -0,0 +1,166 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Multi-tenancy modes (see ForTenant).
+const (
+	TenancySetting = "setting" // tenants are isolated by row-level security policies, using a run-time parameter
+	TenancySchema  = "schema"  // each tenant has its own schema
+
+	defaultTenantSetting = "app.tenant_id"
+)
+
+// ErrUnknownTenant is returned by ForTenant when the schema of a tenant does not exist.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+type (
+	tenancySettings struct {
+		Mode         string // setting (the default) or schema
+		Setting      string // run-time parameter set to the tenant. Defaults to app.tenant_id
+		SchemaPrefix string // prefix of the schemas of tenants, e.g. "tenant_"
+	}
+
+	// TenantScope runs transactions scoped to a tenant (see Repository.ForTenant).
+	TenantScope struct {
+		r      *Repository
+		tenant string
+	}
+)
+
+// WithTenantSetting isolates tenants with row-level security policies: transactions of a tenant set a run-time
+// parameter (e.g. "app.tenant_id") to the tenant, for use by policies, e.g.:
+//
+//	CREATE POLICY tenant_isolation ON orders USING (tenant_id = current_setting('app.tenant_id'))
+//
+// This is the default, with the "app.tenant_id" parameter.
+func WithTenantSetting(setting string) DBOption {
+	return func(o *databaseSettings) {
+		o.Tenancy.Mode = TenancySetting
+		o.Tenancy.Setting = setting
+	}
+}
+
+// WithSchemaPerTenant isolates tenants in their own schema, named after the tenant with a prefix (possibly empty):
+// transactions of a tenant set the search path to the schema of the tenant, then "public".
+func WithSchemaPerTenant(prefix string) DBOption {
+	return func(o *databaseSettings) {
+		o.Tenancy.Mode = TenancySchema
+		o.Tenancy.SchemaPrefix = prefix
+	}
+}
+
+// ForTenant returns a handle to run transactions scoped to a tenant, with SET LOCAL semantics.
+//
+// Depending on the tenancy mode of the database, transactions either set the search path to the schema of the tenant
+// (see WithSchemaPerTenant), or set a run-time parameter to the tenant, for row-level security policies
+// (see WithTenantSetting).
+//
+// With a schema per tenant, ForTenant checks that the schema exists, and returns ErrUnknownTenant otherwise.
+func (r *Repository) ForTenant(ctx context.Context, tenant string) (*TenantScope, error) {
+	if r.db == nil {
+		return nil, ErrDBNotInitialized
+	}
+
+	if tenant == "" {
+		return nil, fmt.Errorf("%w: empty tenant", ErrUnknownTenant)
+	}
+
+	if r.Tenancy.mode() == TenancySchema {
+		schema := r.Tenancy.schema(tenant)
+		if len(schema) > maxIdentifierLength {
+			return nil, fmt.Errorf("%w: schema %q exceeds %d characters", ErrUnknownTenant, schema, maxIdentifierLength)
+		}
+
+		var exists bool
+		if err := r.db.GetContext(ctx, &exists,
+			`SELECT EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = $1)`, schema,
+		); err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, fmt.Errorf("%w: no schema %q for tenant %q", ErrUnknownTenant, schema, tenant)
+		}
+	}
+
+	return &TenantScope{r: r, tenant: tenant}, nil
+}
+
+// Tenant returns the tenant of this scope.
+func (s *TenantScope) Tenant() string {
+	return s.tenant
+}
+
+// RunInTx runs a function within a transaction scoped to the tenant, like Repository.RunInTx.
+//
+// The scope of the tenant is set with SET LOCAL semantics, so it does not leak to other transactions
+// once the connection is returned to the pool.
+func (s *TenantScope) RunInTx(ctx context.Context, fn func(context.Context, *sqlx.Tx) error, opts ...TxOption) error {
+	return s.r.RunInTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+		if err := s.apply(ctx, tx); err != nil {
+			return err
+		}
+
+		return fn(ctx, tx)
+	}, opts...)
+}
+
+// apply the scope of the tenant to a transaction.
+func (s *TenantScope) apply(ctx context.Context, tx sqlx.ExecerContext) error {
+	setting, value := s.r.Tenancy.scope(s.tenant)
+	if err := setLocalParams(ctx, tx, map[string]string{setting: value}); err != nil {
+		return fmt.Errorf("could not scope the transaction to tenant %q: %w", s.tenant, err)
+	}
+
+	return nil
+}
+
+func (t tenancySettings) mode() string {
+	if t.Mode == "" {
+		return TenancySetting
+	}
+
+	return t.Mode
+}
+
+func (t tenancySettings) setting() string {
+	if t.Setting == "" {
+		return defaultTenantSetting
+	}
+
+	return t.Setting
+}
+
+func (t tenancySettings) schema(tenant string) string {
+	return t.SchemaPrefix + tenant
+}
+
+// scope returns the run-time parameter to set locally for a tenant, and its value.
+func (t tenancySettings) scope(tenant string) (string, string) {
+	if t.mode() == TenancySchema {
+		return "search_path", quoteIdentifier(t.schema(tenant)) + ", public"
+	}
+
+	return t.setting(), tenant
+}
+
+func (t tenancySettings) validate() error {
+	switch t.mode() {
+	case TenancySetting:
+		if !rexGUCName.MatchString(t.setting()) {
+			return fmt.Errorf("invalid tenant setting %q: %w", t.setting(), ErrInvalidConfig)
+		}
+
+		return nil
+	case TenancySchema:
+		return nil
+	default:
+		return fmt.Errorf("unsupported tenancy mode %q, expected %s or %s: %w", t.Mode, TenancySetting, TenancySchema, ErrInvalidConfig)
+	}
+}
@@ -0,0 +1,80 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForTenant(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should require a started repository", func(t *testing.T) {
+		_, err := New(DefaultDBAlias).ForTenant(ctx, "acme")
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	db, err := sql.Open(driverName, DefaultURL) // no connection established
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	r := New(DefaultDBAlias)
+	r.db = sqlx.NewDb(db, driverName)
+
+	t.Run("should scope transactions with a run-time parameter", func(t *testing.T) {
+		scope, err := r.ForTenant(ctx, "acme")
+		require.NoError(t, err)
+		require.Equal(t, "acme", scope.Tenant())
+
+		exec := &recordingExecer{}
+		require.NoError(t, scope.apply(ctx, exec))
+		require.Equal(t, `SELECT set_config($1, $2, true)`, exec.query)
+		require.Equal(t, []any{"app.tenant_id", "acme"}, exec.args)
+	})
+
+	t.Run("should not accept an empty tenant", func(t *testing.T) {
+		_, err := r.ForTenant(ctx, "")
+		require.ErrorIs(t, err, ErrUnknownTenant)
+	})
+}
+
+func TestTenancySettings(t *testing.T) {
+	t.Run("should scope the search path to the schema of a tenant", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithSchemaPerTenant("tenant_")})
+		setting, value := dbs.Tenancy.scope("acme")
+		require.Equal(t, "search_path", setting)
+		require.Equal(t, `"tenant_acme", public`, value)
+	})
+
+	t.Run("should scope a custom run-time parameter", func(t *testing.T) {
+		dbs := databaseSettingsFromOptions([]DBOption{WithTenantSetting("rls.tenant")})
+		setting, value := dbs.Tenancy.scope("acme")
+		require.Equal(t, "rls.tenant", setting)
+		require.Equal(t, "acme", value)
+	})
+
+	t.Run("should validate settings", func(t *testing.T) {
+		require.NoError(t, tenancySettings{}.validate())
+		require.NoError(t, tenancySettings{Mode: TenancySchema}.validate())
+		require.ErrorIs(t, tenancySettings{Mode: "database"}.validate(), ErrInvalidConfig)
+
+		dbs := databaseSettingsFromOptions([]DBOption{WithURL(DefaultURL), WithTenantSetting("tenant id")})
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+}
+
+type recordingExecer struct {
+	query string
+	args  []any
+}
+
+func (e *recordingExecer) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	e.query = query
+	e.args = args
+
+	return driver.RowsAffected(0), nil
+}
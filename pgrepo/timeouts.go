@@ -0,0 +1,64 @@
+package pgrepo
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithStatementTimeout aborts statements which take longer than a timeout (statement_timeout).
+func WithStatementTimeout(timeout time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.StatementTimeout = timeout
+	}
+}
+
+// WithLockTimeout aborts statements which wait longer than a timeout to acquire a lock (lock_timeout).
+func WithLockTimeout(timeout time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.LockTimeout = timeout
+	}
+}
+
+// WithIdleInTransactionTimeout terminates sessions which stay idle in a transaction for longer than a timeout
+// (idle_in_transaction_session_timeout).
+func WithIdleInTransactionTimeout(timeout time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.IdleInTransactionTimeout = timeout
+	}
+}
+
+// timeoutParams returns the runtime parameters of the configured timeouts, in milliseconds.
+//
+// Timeouts which are not set are left to the server defaults.
+func (p *poolSettings) timeoutParams() map[string]string {
+	if p == nil {
+		return nil
+	}
+
+	params := make(map[string]string, 3)
+	for param, timeout := range map[string]time.Duration{
+		"statement_timeout":                   p.StatementTimeout,
+		"lock_timeout":                        p.LockTimeout,
+		"idle_in_transaction_session_timeout": p.IdleInTransactionTimeout,
+	} {
+		if timeout > 0 {
+			params[param] = fmt.Sprintf("%d", max(timeout.Milliseconds(), 1))
+		}
+	}
+
+	return params
+}
+
+func (p *poolSettings) validateTimeouts() error {
+	for key, timeout := range map[string]time.Duration{
+		"statementTimeout":         p.StatementTimeout,
+		"lockTimeout":              p.LockTimeout,
+		"idleInTransactionTimeout": p.IdleInTransactionTimeout,
+	} {
+		if timeout < 0 {
+			return fmt.Errorf("invalid %s: %v must not be negative: %w", key, timeout, ErrInvalidConfig)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package pgrepo
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTimeouts(t *testing.T) {
+	t.Run("should apply timeouts as runtime parameters", func(t *testing.T) {
+		dbs := databaseSettings{
+			URL: DefaultURL,
+			PGConfig: poolSettingsFromOptions([]PoolOption{
+				WithStatementTimeout(30 * time.Second),
+				WithLockTimeout(500 * time.Microsecond),
+				WithIdleInTransactionTimeout(time.Minute),
+			}),
+		}
+		require.NoError(t, dbs.Validate())
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "app")
+		require.NotNil(t, dcfg)
+		require.Equal(t, map[string]string{
+			"application_name":                    "app",
+			"statement_timeout":                   "30000",
+			"lock_timeout":                        "1",
+			"idle_in_transaction_session_timeout": "60000",
+		}, dcfg.RuntimeParams)
+	})
+
+	t.Run("should leave unset timeouts to the server", func(t *testing.T) {
+		require.Empty(t, poolSettingsFromOptions(nil).timeoutParams())
+		require.Empty(t, (*poolSettings)(nil).timeoutParams())
+	})
+
+	t.Run("should not accept negative timeouts", func(t *testing.T) {
+		dbs := databaseSettings{
+			URL:      DefaultURL,
+			PGConfig: poolSettingsFromOptions([]PoolOption{WithLockTimeout(-time.Second)}),
+		}
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+
+	t.Run("should load timeouts from config", func(t *testing.T) {
+		cfg := viper.New()
+		cfg.SetConfigType("yaml")
+		require.NoError(t, cfg.ReadConfig(strings.NewReader(`
+databases:
+  postgres:
+    default:
+      url: 'postgresql://localhost:5432/testdb'
+      pgconfig:
+        statementTimeout: 10s
+        lockTimeout: 2s
+        idleInTransactionTimeout: 1m
+`)))
+
+		s, err := makeSettingsFromViper(cfg, zap.NewNop())
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{
+			"statement_timeout":                   "10000",
+			"lock_timeout":                        "2000",
+			"idle_in_transaction_session_timeout": "60000",
+		}, s.DBSettingsFor(DefaultDBAlias).PGConfig.timeoutParams())
+	})
+}
@@ -0,0 +1,128 @@
+package pgrepo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tlsSettings configure TLS connections, in addition to (and with precedence over) the TLS parameters of the URL.
+type tlsSettings struct {
+	RootCAs    string // path to a PEM file with the certificate authorities used to verify the server
+	ClientCert string // path to a PEM client certificate
+	ClientKey  string // path to the PEM private key of the client certificate
+	ServerName string // the server name to verify, if it differs from the host
+
+	config *tls.Config // in-memory configuration (see WithTLSConfig)
+}
+
+// WithTLSConfig sets the TLS configuration to connect to the database, e.g. with in-memory certificates.
+//
+// TLS is then required to connect.
+func WithTLSConfig(cfg *tls.Config) DBOption {
+	return func(o *databaseSettings) {
+		o.TLS.config = cfg
+	}
+}
+
+// WithClientCert authenticates with a client certificate, loaded from PEM files.
+//
+// TLS is then required to connect.
+func WithClientCert(certFile, keyFile string) DBOption {
+	return func(o *databaseSettings) {
+		o.TLS.ClientCert = certFile
+		o.TLS.ClientKey = keyFile
+	}
+}
+
+// WithRootCAs verifies the server certificate with the certificate authorities from a PEM file.
+//
+// TLS is then required to connect, and the server name is verified.
+func WithRootCAs(pth string) DBOption {
+	return func(o *databaseSettings) {
+		o.TLS.RootCAs = pth
+	}
+}
+
+func (t tlsSettings) isSet() bool {
+	return t.config != nil || t.RootCAs != "" || t.ClientCert != "" || t.ClientKey != "" || t.ServerName != ""
+}
+
+// tlsConfig builds a TLS configuration from the settings, starting from a base configuration (e.g. from the URL).
+func (t tlsSettings) tlsConfig(base *tls.Config, host string) (*tls.Config, error) {
+	var cfg *tls.Config
+	switch {
+	case t.config != nil:
+		cfg = t.config.Clone()
+	case base != nil:
+		cfg = base.Clone()
+	default:
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if cfg.ServerName == "" {
+		cfg.ServerName = host
+	}
+	if t.ServerName != "" {
+		cfg.ServerName = t.ServerName
+	}
+
+	if t.RootCAs != "" {
+		pem, err := os.ReadFile(os.ExpandEnv(t.RootCAs))
+		if err != nil {
+			return nil, fmt.Errorf("could not read root CAs: %w: %w", ErrInvalidConfig, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in root CAs %s: %w", t.RootCAs, ErrInvalidConfig)
+		}
+
+		cfg.RootCAs = pool
+		// verify the server certificate, even if the URL says sslmode=require
+		cfg.InsecureSkipVerify = false
+		cfg.VerifyPeerCertificate = nil
+	}
+
+	if t.ClientCert != "" || t.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(os.ExpandEnv(t.ClientCert), os.ExpandEnv(t.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w: %w", ErrInvalidConfig, err)
+		}
+
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// applyTLS applies the TLS settings onto a driver configuration. Fallbacks without TLS are removed.
+func (r databaseSettings) applyTLS(dcfg *pgx.ConnConfig) error {
+	if !r.TLS.isSet() {
+		return nil
+	}
+
+	cfg, err := r.TLS.tlsConfig(dcfg.TLSConfig, dcfg.Host)
+	if err != nil {
+		return err
+	}
+	dcfg.TLSConfig = cfg
+
+	fallbacks := dcfg.Fallbacks[:0]
+	for _, fallback := range dcfg.Fallbacks {
+		if fallback.TLSConfig == nil {
+			continue
+		}
+
+		if fallback.TLSConfig, err = r.TLS.tlsConfig(fallback.TLSConfig, fallback.Host); err != nil {
+			return err
+		}
+		fallbacks = append(fallbacks, fallback)
+	}
+	dcfg.Fallbacks = fallbacks
+
+	return nil
+}
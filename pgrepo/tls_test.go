@@ -0,0 +1,106 @@
+package pgrepo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTLSSettings(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+	lg := log.NewFactory(zap.NewNop())
+
+	t.Run("should apply certificates onto the driver configuration", func(t *testing.T) {
+		dbs := databaseSettings{URL: "postgresql://app@db.example.com:5432/orders?sslmode=prefer"}
+		WithRootCAs(certFile)(&dbs)
+		WithClientCert(certFile, keyFile)(&dbs)
+		require.NoError(t, dbs.Validate())
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), lg, "")
+		require.NotNil(t, dcfg)
+		require.NotNil(t, dcfg.TLSConfig)
+		require.NotNil(t, dcfg.TLSConfig.RootCAs)
+		require.False(t, dcfg.TLSConfig.InsecureSkipVerify)
+		require.Equal(t, "db.example.com", dcfg.TLSConfig.ServerName)
+		require.Len(t, dcfg.TLSConfig.Certificates, 1)
+
+		for _, fallback := range dcfg.Fallbacks {
+			require.NotNil(t, fallback.TLSConfig, "expected fallbacks without TLS to be removed")
+		}
+	})
+
+	t.Run("should require TLS with an in-memory configuration", func(t *testing.T) {
+		cfg := &tls.Config{ServerName: "primary.example.com", MinVersion: tls.VersionTLS13}
+		dbs := databaseSettings{URL: "postgresql://app@db.example.com:5432/orders?sslmode=disable"}
+		WithTLSConfig(cfg)(&dbs)
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), lg, "")
+		require.NotNil(t, dcfg)
+		require.NotNil(t, dcfg.TLSConfig)
+		require.NotSame(t, cfg, dcfg.TLSConfig)
+		require.Equal(t, "primary.example.com", dcfg.TLSConfig.ServerName)
+		require.Equal(t, uint16(tls.VersionTLS13), dcfg.TLSConfig.MinVersion)
+	})
+
+	t.Run("should leave the URL settings untouched", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL}
+
+		dcfg := dbs.ConnConfig(dbs.DBURL(), lg, "")
+		require.NotNil(t, dcfg)
+		require.Nil(t, dcfg.TLSConfig)
+	})
+
+	t.Run("should reject invalid certificates", func(t *testing.T) {
+		dbs := databaseSettings{URL: DefaultURL}
+		WithRootCAs(keyFile)(&dbs)
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+
+		dbs = databaseSettings{URL: DefaultURL}
+		WithClientCert(certFile, filepath.Join(dir, "missing.key"))(&dbs)
+		require.ErrorIs(t, dbs.Validate(), ErrInvalidConfig)
+	})
+}
+
+// writeTestCertificate writes a self-signed certificate and its private key as PEM files.
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pgrepo test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
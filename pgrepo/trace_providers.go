@@ -0,0 +1,78 @@
+package pgrepo
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TraceProviderDatadog instruments the pgx driver with Datadog APM.
+//
+// This provider is registered by the datadog package, which must be imported to select it by the settings.
+const TraceProviderDatadog = "datadog"
+
+type (
+	// TracerSettings are the settings passed to a registered trace provider, for the connections to a database.
+	TracerSettings struct {
+		Service string            // the service name of spans (the "service" trace setting), if any
+		Alias   string            // the alias of the database
+		Tags    map[string]string // the tags of the database (see WithTag)
+	}
+
+	// TraceProvider builds a pgx tracer for the connections to a database.
+	TraceProvider func(TracerSettings) pgx.QueryTracer
+)
+
+var (
+	traceProviders   = map[string]TraceProvider{}
+	traceProvidersMx sync.RWMutex
+)
+
+// RegisterTraceProvider registers a trace provider, which may then be selected by the trace settings of a pool
+// (see WithTraceProvider).
+//
+// Trace providers which depend on third-party tracing libraries are provided by separate packages, which register
+// them when imported, e.g.:
+//
+//	import _ "github.com/fredbi/pgxutils/pgrepo/datadog"
+//
+// Alternatively, tracers may be added with WithPGXTracer.
+func RegisterTraceProvider(name string, provider TraceProvider) {
+	traceProvidersMx.Lock()
+	defer traceProvidersMx.Unlock()
+
+	traceProviders[strings.ToLower(name)] = provider
+}
+
+func registeredTraceProvider(name string) (TraceProvider, bool) {
+	traceProvidersMx.RLock()
+	defer traceProvidersMx.RUnlock()
+
+	provider, ok := traceProviders[name]
+
+	return provider, ok
+}
+
+// registeredTracers returns the tracers of the registered trace providers selected by the settings.
+func (r databaseSettings) registeredTracers() []pgx.QueryTracer {
+	if r.PGConfig == nil || !r.PGConfig.Trace.Enabled || r.PGConfig.Trace.Provider == "" {
+		return nil
+	}
+
+	var tracers []pgx.QueryTracer
+	for _, name := range strings.Split(r.PGConfig.Trace.Provider, ",") {
+		provider, ok := registeredTraceProvider(strings.TrimSpace(strings.ToLower(name)))
+		if !ok {
+			continue
+		}
+
+		tracers = append(tracers, provider(TracerSettings{
+			Service: r.PGConfig.Trace.Service,
+			Alias:   r.alias,
+			Tags:    r.Tags,
+		}))
+	}
+
+	return tracers
+}
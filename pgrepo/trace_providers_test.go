@@ -0,0 +1,40 @@
+package pgrepo
+
+import (
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRegisteredTraceProvider(t *testing.T) {
+	t.Run("should require a registered provider", func(t *testing.T) {
+		// the datadog package is not imported
+		require.ErrorIs(t, traceSettings{Provider: TraceProviderDatadog}.validate(), ErrInvalidConfig)
+	})
+
+	var registered TracerSettings
+	RegisterTraceProvider("Test-Provider", func(s TracerSettings) pgx.QueryTracer {
+		registered = s
+
+		return &captureTracer{}
+	})
+	require.NoError(t, traceSettings{Provider: "otel, test-provider"}.validate())
+
+	dbs := settingsFromOptions([]Option{
+		WithDatabaseSettings("orders",
+			WithTag("team", "payments"),
+			WithPoolSettings(WithTracing(true), WithTraceProvider("test-provider")),
+		),
+	}).DBSettingsFor("orders")
+	dbs.PGConfig.Trace.Service = "orders.db"
+	require.Empty(t, dbs.TraceOptions(dbs.DBURL()), "opencensus should not be enabled")
+
+	dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "app")
+	composed, ok := dcfg.Tracer.(multiTracer)
+	require.True(t, ok)
+	require.IsType(t, &captureTracer{}, composed.tracers[len(composed.tracers)-1])
+	require.Equal(t, TracerSettings{Service: "orders.db", Alias: "orders", Tags: map[string]string{"team": "payments"}}, registered)
+}
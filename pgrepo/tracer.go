@@ -0,0 +1,188 @@
+package pgrepo
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// multiTracer composes several pgx tracers.
+//
+// Optional tracer interfaces (batch, copy, prepare, connect) are forwarded to the tracers that implement them.
+type multiTracer struct {
+	tracers []pgx.QueryTracer
+}
+
+var (
+	_ pgx.QueryTracer    = multiTracer{}
+	_ pgx.BatchTracer    = multiTracer{}
+	_ pgx.CopyFromTracer = multiTracer{}
+	_ pgx.PrepareTracer  = multiTracer{}
+	_ pgx.ConnectTracer  = multiTracer{}
+)
+
+// WithPGXTracer adds a pgx tracer, e.g. to trace queries with a third-party tracing library.
+//
+// Tracers are composed with the tracers configured by the settings, so the logging of the driver is retained.
+// A tracer may implement the optional tracer interfaces of pgx, e.g. pgx.BatchTracer or pgx.CopyFromTracer.
+func WithPGXTracer(tracer pgx.QueryTracer) Option {
+	return func(o *settings) {
+		o.tracers = append(o.tracers[:len(o.tracers):len(o.tracers)], tracer)
+	}
+}
+
+// composeTracers returns a single tracer from several ones, skipping nil tracers.
+func composeTracers(tracers ...pgx.QueryTracer) pgx.QueryTracer {
+	nonNil := make([]pgx.QueryTracer, 0, len(tracers))
+	for _, t := range tracers {
+		if t != nil {
+			nonNil = append(nonNil, t)
+		}
+	}
+
+	switch len(nonNil) {
+	case 0:
+		return nil
+	case 1:
+		return nonNil[0]
+	default:
+		return multiTracer{tracers: nonNil}
+	}
+}
+
+func (m multiTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	for _, t := range m.tracers {
+		ctx = t.TraceQueryStart(ctx, conn, data)
+	}
+
+	return ctx
+}
+
+func (m multiTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	for _, t := range m.tracers {
+		t.TraceQueryEnd(ctx, conn, data)
+	}
+}
+
+func (m multiTracer) TraceBatchStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	for _, t := range m.tracers {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			ctx = bt.TraceBatchStart(ctx, conn, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m multiTracer) TraceBatchQuery(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchQueryData) {
+	for _, t := range m.tracers {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			bt.TraceBatchQuery(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TraceBatchEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceBatchEndData) {
+	for _, t := range m.tracers {
+		if bt, ok := t.(pgx.BatchTracer); ok {
+			bt.TraceBatchEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TraceCopyFromStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	for _, t := range m.tracers {
+		if ct, ok := t.(pgx.CopyFromTracer); ok {
+			ctx = ct.TraceCopyFromStart(ctx, conn, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m multiTracer) TraceCopyFromEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	for _, t := range m.tracers {
+		if ct, ok := t.(pgx.CopyFromTracer); ok {
+			ct.TraceCopyFromEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TracePrepareStart(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareStartData) context.Context {
+	for _, t := range m.tracers {
+		if pt, ok := t.(pgx.PrepareTracer); ok {
+			ctx = pt.TracePrepareStart(ctx, conn, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m multiTracer) TracePrepareEnd(ctx context.Context, conn *pgx.Conn, data pgx.TracePrepareEndData) {
+	for _, t := range m.tracers {
+		if pt, ok := t.(pgx.PrepareTracer); ok {
+			pt.TracePrepareEnd(ctx, conn, data)
+		}
+	}
+}
+
+func (m multiTracer) TraceConnectStart(ctx context.Context, data pgx.TraceConnectStartData) context.Context {
+	for _, t := range m.tracers {
+		if ct, ok := t.(pgx.ConnectTracer); ok {
+			ctx = ct.TraceConnectStart(ctx, data)
+		}
+	}
+
+	return ctx
+}
+
+func (m multiTracer) TraceConnectEnd(ctx context.Context, data pgx.TraceConnectEndData) {
+	for _, t := range m.tracers {
+		if ct, ok := t.(pgx.ConnectTracer); ok {
+			ct.TraceConnectEnd(ctx, data)
+		}
+	}
+}
+
+// poolRole tells which server a connection pool connects to.
+type poolRole uint8
+
+const (
+	roleMaster poolRole = iota
+	roleReplica
+	roleStandby
+)
+
+// connTracers returns the tracers which keep the state of the repository, for the connections of a pool.
+//
+// The circuit breaker and the liveness check guard the master only. Transactions are watched on the master and
+// the replicas only.
+func (r *Repository) connTracers(role poolRole) []pgx.QueryTracer {
+	var tracers []pgx.QueryTracer
+
+	if r.recent != nil {
+		tracers = append(tracers, r.recent)
+	}
+
+	if r.breaker != nil && role == roleMaster {
+		tracers = append(tracers, r.breaker)
+	}
+
+	if r.txWatch != nil && role != roleStandby {
+		tracers = append(tracers, r.txWatch)
+	}
+
+	if r.jobMetrics != nil {
+		tracers = append(tracers, r.jobMetrics)
+	}
+
+	if r.liveness != nil && role == roleMaster {
+		tracers = append(tracers, r.liveness)
+	}
+
+	if r.otelMetrics != nil {
+		tracers = append(tracers, r.otelMetrics)
+	}
+
+	return tracers
+}
@@ -0,0 +1,91 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type captureTracerKey struct{}
+
+// captureTracer records the SQL of traced queries.
+type captureTracer struct {
+	started []string
+	ended   []string
+}
+
+func (c *captureTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	c.started = append(c.started, data.SQL)
+
+	return context.WithValue(ctx, captureTracerKey{}, data.SQL)
+}
+
+func (c *captureTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, _ pgx.TraceQueryEndData) {
+	sql, _ := ctx.Value(captureTracerKey{}).(string)
+	c.ended = append(c.ended, sql)
+}
+
+func TestComposeTracers(t *testing.T) {
+	require.Nil(t, composeTracers(nil, nil))
+
+	single := &captureTracer{}
+	require.Same(t, single, composeTracers(nil, single))
+
+	first, second := &captureTracer{}, &captureTracer{}
+	tracer := composeTracers(first, nil, second)
+	require.IsType(t, multiTracer{}, tracer)
+
+	ctx := tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.Equal(t, []string{"SELECT 1"}, first.started)
+	require.Equal(t, []string{"SELECT 1"}, second.ended)
+
+	// optional interfaces are skipped by tracers which don't implement them
+	bt, ok := tracer.(pgx.BatchTracer)
+	require.True(t, ok)
+	ctx = bt.TraceBatchStart(context.Background(), nil, pgx.TraceBatchStartData{})
+	bt.TraceBatchEnd(ctx, nil, pgx.TraceBatchEndData{})
+}
+
+func TestWithPGXTracer(t *testing.T) {
+	first, second := &captureTracer{}, &captureTracer{}
+	s := settingsFromOptions([]Option{
+		WithPGXTracer(first),
+		WithPGXTracer(second),
+	})
+	dbs := s.DBSettingsFor(DefaultDBAlias)
+
+	dcfg := dbs.ConnConfig(dbs.DBURL(), log.NewFactory(zap.NewNop()), "app")
+	require.NotNil(t, dcfg)
+
+	composed, ok := dcfg.Tracer.(multiTracer)
+	require.True(t, ok)
+	require.Len(t, composed.tracers, 3)
+	require.IsType(t, &tracelog.TraceLog{}, composed.tracers[0], "the driver logger should be retained")
+
+	ctx := dcfg.Tracer.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT 1"})
+	dcfg.Tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.Equal(t, []string{"SELECT 1"}, first.started)
+	require.Equal(t, []string{"SELECT 1"}, second.ended)
+}
+
+func TestConnTracers(t *testing.T) {
+	r := &Repository{
+		recent:   newQueryRing(10),
+		breaker:  newCircuitBreaker(breakerSettings{Enabled: true}, log.NewFactory(zap.NewNop()).Bg()),
+		txWatch:  &txWatch{},
+		liveness: newLiveness(),
+	}
+
+	require.Equal(t, []pgx.QueryTracer{r.recent, r.breaker, r.txWatch, r.liveness}, r.connTracers(roleMaster))
+	require.Equal(t, []pgx.QueryTracer{r.recent, r.txWatch}, r.connTracers(roleReplica))
+	require.Equal(t, []pgx.QueryTracer{r.recent}, r.connTracers(roleStandby))
+	require.Empty(t, (&Repository{}).connTracers(roleMaster))
+}
@@ -0,0 +1,156 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+const (
+	txCommitted  = "committed"
+	txRolledBack = "rolled_back"
+)
+
+type (
+	// TxOption tunes a transaction run by RunInTx.
+	TxOption func(*txOptions)
+
+	txOptions struct {
+		isolation  sql.IsolationLevel
+		readOnly   bool
+		maxRetries int
+	}
+)
+
+func txOptionsWithDefaults(opts []TxOption) txOptions {
+	var o txOptions
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	return o
+}
+
+// WithIsolation sets the isolation level of the transaction.
+//
+// The default is the isolation level configured on the server (usually read committed).
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.isolation = level
+	}
+}
+
+// WithReadOnly runs a read-only transaction.
+func WithReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithRetries retries the transaction up to n times, when it fails with a serialization failure
+// or a deadlock.
+//
+// Retries wait for the backoff of the retry policy (see WithRetryPolicy), and stop when the context is done.
+//
+// The transaction function must then be idempotent.
+func WithRetries(n int) TxOption {
+	return func(o *txOptions) {
+		o.maxRetries = n
+	}
+}
+
+// RunInTx runs a function within a transaction, which is committed if the function returns no error
+// and rolled back otherwise.
+//
+// The transaction is rolled back if the function panics, and the panic is propagated.
+//
+// When tracing is enabled, the transaction is traced as a parent span for all its statements,
+// annotated with its outcome.
+//
+// The default deadline of writes (or reads, for read-only transactions) applies to the transaction and its retries
+// if the context has no deadline (see WithDefaultDeadline).
+func (r *Repository) RunInTx(ctx context.Context, fn func(context.Context, *sqlx.Tx) error, opts ...TxOption) error {
+	if r.db == nil {
+		return ErrDBNotInitialized
+	}
+
+	o := txOptionsWithDefaults(opts)
+	category := OperationWrite
+	if o.readOnly {
+		category = OperationRead
+	}
+	ctx, cancel := r.withDefaultDeadline(ctx, category)
+	defer cancel()
+
+	ctx, end := r.startTxSpan(ctx, o)
+
+	var retries int
+	for {
+		err := r.runTx(ctx, fn, o)
+		if err == nil {
+			end(txCommitted, retries, nil)
+
+			return nil
+		}
+
+		if retries >= o.maxRetries || !isRetryableTxError(err) || ctx.Err() != nil {
+			end(txRolledBack, retries, err)
+
+			return err
+		}
+
+		delay := r.retryPolicy().backoff(retries + 1)
+		r.logger(ctx).Debug("retrying transaction", zap.Int("retries", retries+1), zap.Duration("delay", delay), zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			err = errors.Join(err, ctx.Err())
+			end(txRolledBack, retries, err)
+
+			return err
+		case <-timer.C:
+		}
+
+		retries++
+	}
+}
+
+func (r *Repository) runTx(ctx context.Context, fn func(context.Context, *sqlx.Tx) error, o txOptions) (err error) {
+	tx, err := r.db.BeginTxx(withManagedTx(ctx), &sql.TxOptions{Isolation: o.isolation, ReadOnly: o.readOnly})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+
+			panic(p)
+		}
+	}()
+
+	if err = fn(ctx, tx); err != nil {
+		return errors.Join(err, ignoreTxDone(tx.Rollback()))
+	}
+
+	return tx.Commit()
+}
+
+// isRetryableTxError tells if a transaction failed with a serialization failure or a deadlock.
+func isRetryableTxError(err error) bool {
+	return IsSerializationFailure(err) || IsDeadlock(err)
+}
+
+func ignoreTxDone(err error) error {
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,120 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+)
+
+func TestRunInTx(t *testing.T) {
+	t.Run("repository is not started", func(t *testing.T) {
+		r := &Repository{}
+		err := r.RunInTx(context.Background(), func(context.Context, *sqlx.Tx) error { return nil })
+		require.ErrorIs(t, err, ErrDBNotInitialized)
+	})
+
+	t.Run("retryable errors", func(t *testing.T) {
+		require.True(t, isRetryableTxError(fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "40001"})))
+		require.True(t, isRetryableTxError(&pgconn.PgError{Code: "40P01"}))
+		require.False(t, isRetryableTxError(&pgconn.PgError{Code: "23505"}))
+		require.False(t, isRetryableTxError(errors.New("serialization failure")))
+	})
+
+	t.Run("should wait between retries", func(t *testing.T) {
+		errSerialization := &pgconn.PgError{Code: "40001"}
+		server := &fakeServer{}
+		db := sqlx.NewDb(sql.OpenDB(server), driverName)
+		t.Cleanup(func() { _ = db.Close() })
+
+		r := New(DefaultDBAlias, WithLogger(zap.NewNop()), WithDefaultPoolOptions(WithRetryPolicy(RetryPolicy{InitialInterval: 20 * time.Millisecond, Multiplier: 1})))
+		r.db = db
+
+		var calls int
+		start := time.Now()
+		err := r.RunInTx(context.Background(), func(context.Context, *sqlx.Tx) error {
+			calls++
+			if calls < 3 {
+				return errSerialization
+			}
+
+			return nil
+		}, WithRetries(3))
+		require.NoError(t, err)
+		require.Equal(t, 3, calls)
+		require.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+		t.Run("should stop retrying when the context is done", func(t *testing.T) {
+			r := New(DefaultDBAlias, WithLogger(zap.NewNop()), WithDefaultPoolOptions(WithRetryPolicy(RetryPolicy{InitialInterval: time.Minute})))
+			r.db = db
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			calls = 0
+			err := r.RunInTx(ctx, func(context.Context, *sqlx.Tx) error {
+				calls++
+
+				return errSerialization
+			}, WithRetries(3))
+			require.ErrorIs(t, err, errSerialization)
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+			require.Equal(t, 1, calls)
+		})
+	})
+
+	t.Run("options", func(t *testing.T) {
+		o := txOptionsWithDefaults([]TxOption{WithIsolation(sql.LevelSerializable), WithReadOnly(), WithRetries(3)})
+		require.Equal(t, txOptions{isolation: sql.LevelSerializable, readOnly: true, maxRetries: 3}, o)
+	})
+}
+
+func TestTxSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	r := &Repository{databaseSettings: databaseSettings{
+		PGConfig:        poolSettingsFromOptions([]PoolOption{WithTracing(true), WithTraceProvider(TraceProviderOTel)}),
+		runtimeSettings: runtimeSettings{tracerProvider: tp},
+	}}
+
+	ctx, end := r.startTxSpan(context.Background(), txOptions{isolation: sql.LevelSerializable})
+	_, stmt := tp.Tracer("test").Start(ctx, "statement")
+	stmt.End()
+	end(txRolledBack, 2, errors.New("could not serialize access"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	txSpan := spans[1]
+	require.Equal(t, txSpanName, txSpan.Name())
+	require.Equal(t, txSpan.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, codes.Error, txSpan.Status().Code)
+
+	attrs := make(map[string]interface{})
+	for _, attr := range txSpan.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	require.Equal(t, "serializable", attrs["db.transaction.isolation"])
+	require.Equal(t, txRolledBack, attrs["db.transaction.outcome"])
+	require.Equal(t, int64(2), attrs["db.transaction.retries"])
+	require.Contains(t, attrs, "db.transaction.duration_ms")
+
+	t.Run("no span when tracing is disabled", func(t *testing.T) {
+		r := &Repository{}
+		ctx := context.Background()
+		txCtx, end := r.startTxSpan(ctx, txOptions{})
+		require.Equal(t, ctx, txCtx)
+		end(txCommitted, 0, nil)
+	})
+}
@@ -0,0 +1,82 @@
+package pgrepo
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	octrace "go.opencensus.io/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const txSpanName = "postgresql.transaction"
+
+// startTxSpan starts a span for a transaction, when tracing is enabled.
+//
+// Statement spans are nested under the transaction span, since they are started from the returned context.
+// The returned function ends the span, annotated with the outcome of the transaction.
+func (r *Repository) startTxSpan(ctx context.Context, o txOptions) (context.Context, func(outcome string, retries int, err error)) {
+	start := time.Now()
+	isolation := strings.ToLower(o.isolation.String())
+	var ends []func(string, int, error)
+
+	if r.PGConfig != nil && r.PGConfig.Trace.usesTraceProvider(TraceProviderOTel) {
+		tp := r.tracerProvider
+		if tp == nil {
+			tp = otel.GetTracerProvider()
+		}
+
+		var span trace.Span
+		ctx, span = tp.Tracer(otelInstrumentationName).Start(ctx, txSpanName,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.transaction.isolation", isolation),
+				attribute.Bool("db.transaction.read_only", o.readOnly),
+			),
+		)
+
+		ends = append(ends, func(outcome string, retries int, err error) {
+			span.SetAttributes(
+				attribute.String("db.transaction.outcome", outcome),
+				attribute.Int("db.transaction.retries", retries),
+				attribute.Int64("db.transaction.duration_ms", time.Since(start).Milliseconds()),
+			)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		})
+	}
+
+	if r.PGConfig != nil && r.PGConfig.Trace.usesTraceProvider(TraceProviderOpenCensus) {
+		var span *octrace.Span
+		ctx, span = octrace.StartSpan(ctx, txSpanName, octrace.WithSpanKind(octrace.SpanKindClient))
+		span.AddAttributes(
+			octrace.StringAttribute("db.transaction.isolation", isolation),
+			octrace.BoolAttribute("db.transaction.read_only", o.readOnly),
+		)
+
+		ends = append(ends, func(outcome string, retries int, err error) {
+			span.AddAttributes(
+				octrace.StringAttribute("db.transaction.outcome", outcome),
+				octrace.Int64Attribute("db.transaction.retries", int64(retries)),
+				octrace.Int64Attribute("db.transaction.duration_ms", time.Since(start).Milliseconds()),
+			)
+			if err != nil {
+				span.SetStatus(octrace.Status{Code: octrace.StatusCodeUnknown, Message: err.Error()})
+			}
+			span.End()
+		})
+	}
+
+	return ctx, func(outcome string, retries int, err error) {
+		for _, end := range ends {
+			end(outcome, retries, err)
+		}
+	}
+}
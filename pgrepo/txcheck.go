@@ -0,0 +1,201 @@
+package pgrepo
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+const maxStackFrames = 32
+
+var _ pgx.QueryTracer = &txWatch{}
+
+// managedTxKey marks the context of transactions managed by this package, e.g. with RunInTx.
+type managedTxKey struct{}
+
+// WithTxCheck enables a development check of transactions started outside of RunInTx, e.g. with DB().Beginx().
+//
+// A warning is logged with the stack of the caller which started the transaction, when such a transaction
+// is not committed or rolled back within maxDuration. This helps converge on the managed transaction API,
+// which never leaks transactions.
+//
+// Capturing stacks has a cost: this is intended for development and testing.
+func WithTxCheck(maxDuration time.Duration) PoolOption {
+	return func(o *poolSettings) {
+		o.TxCheck.Enabled = true
+		o.TxCheck.MaxDuration = maxDuration
+	}
+}
+
+// txWatch tracks the transactions started outside of RunInTx, to detect transactions left open.
+type txWatch struct {
+	mx          sync.Mutex
+	maxDuration time.Duration
+	open        map[*pgx.Conn]*unmanagedTx
+	isClosed    func(*pgx.Conn) bool
+}
+
+type unmanagedTx struct {
+	start    time.Time
+	stack    string
+	reported bool
+}
+
+func newTxWatch(ts txCheckSettings) *txWatch {
+	if !ts.Enabled {
+		return nil
+	}
+
+	if ts.MaxDuration <= 0 {
+		ts.MaxDuration = defaultSettings.PGConfig.TxCheck.MaxDuration
+	}
+
+	return &txWatch{
+		maxDuration: ts.MaxDuration,
+		open:        make(map[*pgx.Conn]*unmanagedTx),
+		isClosed:    (*pgx.Conn).IsClosed,
+	}
+}
+
+func withManagedTx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, managedTxKey{}, true)
+}
+
+func isManagedTx(ctx context.Context) bool {
+	managed, _ := ctx.Value(managedTxKey{}).(bool)
+
+	return managed
+}
+
+func (w *txWatch) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	switch sqlOperation(data.SQL) {
+	case "BEGIN", "START":
+		if isManagedTx(ctx) {
+			return ctx
+		}
+
+		w.mx.Lock()
+		w.open[conn] = &unmanagedTx{start: time.Now(), stack: callerStack()}
+		w.mx.Unlock()
+	case "COMMIT", "ROLLBACK", "END", "ABORT":
+		w.mx.Lock()
+		delete(w.open, conn)
+		w.mx.Unlock()
+	}
+
+	return ctx
+}
+
+func (w *txWatch) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
+
+// leaks returns the transactions open for longer than the maximum duration, which have not been reported yet.
+func (w *txWatch) leaks(now time.Time) []unmanagedTx {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	var leaks []unmanagedTx
+	for conn, tx := range w.open {
+		if w.isClosed(conn) {
+			delete(w.open, conn)
+
+			continue
+		}
+
+		if tx.reported || now.Sub(tx.start) < w.maxDuration {
+			continue
+		}
+
+		tx.reported = true
+		leaks = append(leaks, *tx)
+	}
+
+	return leaks
+}
+
+// start periodically reports transactions left open.
+//
+// It returns a function to stop the watch.
+func (w *txWatch) start(l log.Logger) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(max(w.maxDuration/2, 10*time.Millisecond))
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, tx := range w.leaks(now) {
+					l.Warn("transaction left open outside of RunInTx: use Repository.RunInTx, or commit or roll back",
+						zap.Duration("open_for", now.Sub(tx.start)),
+						zap.Duration("max_duration", w.maxDuration),
+						zap.String("stack", tx.stack),
+					)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// callerStack returns the stack of the caller starting a transaction, skipping the frames of
+// the database drivers and of this tracer.
+func callerStack() string {
+	pcs := make([]uintptr, maxStackFrames)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if !isDriverFrame(frame.Function) {
+			b.WriteString(frame.Function)
+			b.WriteString("\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+			b.WriteByte('\n')
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	return b.String()
+}
+
+func isDriverFrame(function string) bool {
+	for _, prefix := range []string{
+		"github.com/jackc/pgx/",
+		"github.com/jmoiron/sqlx.",
+		"github.com/opencensus-integrations/ocsql.",
+		"database/sql.",
+		"runtime.",
+		"github.com/fredbi/pgxutils/pgrepo.(*txWatch)",
+		"github.com/fredbi/pgxutils/pgrepo.callerStack",
+		"github.com/fredbi/pgxutils/pgrepo.multiTracer",
+	} {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
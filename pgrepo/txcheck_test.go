@@ -0,0 +1,67 @@
+package pgrepo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxWatch(t *testing.T) {
+	newWatch := func() *txWatch {
+		w := newTxWatch(txCheckSettings{Enabled: true, MaxDuration: time.Minute})
+		w.isClosed = func(*pgx.Conn) bool { return false }
+
+		return w
+	}
+	ctx := context.Background()
+	later := time.Now().Add(2 * time.Minute)
+
+	t.Run("should be disabled by default", func(t *testing.T) {
+		require.Nil(t, newTxWatch(defaultSettings.PGConfig.TxCheck))
+	})
+
+	t.Run("should report unmanaged transactions left open, once", func(t *testing.T) {
+		w := newWatch()
+		conn := &pgx.Conn{}
+		w.TraceQueryStart(ctx, conn, pgx.TraceQueryStartData{SQL: "begin isolation level serializable"})
+
+		require.Empty(t, w.leaks(time.Now()))
+
+		leaks := w.leaks(later)
+		require.Len(t, leaks, 1)
+		require.Contains(t, leaks[0].stack, "pgrepo.TestTxWatch")
+		require.NotContains(t, leaks[0].stack, "txWatch")
+
+		require.Empty(t, w.leaks(later))
+	})
+
+	t.Run("should forget transactions committed or rolled back", func(t *testing.T) {
+		w := newWatch()
+		committed, rolledBack := &pgx.Conn{}, &pgx.Conn{}
+		w.TraceQueryStart(ctx, committed, pgx.TraceQueryStartData{SQL: "begin"})
+		w.TraceQueryStart(ctx, rolledBack, pgx.TraceQueryStartData{SQL: "BEGIN"})
+		w.TraceQueryStart(ctx, committed, pgx.TraceQueryStartData{SQL: "commit"})
+		w.TraceQueryStart(ctx, rolledBack, pgx.TraceQueryStartData{SQL: "rollback"})
+
+		require.Empty(t, w.leaks(later))
+	})
+
+	t.Run("should ignore transactions of RunInTx", func(t *testing.T) {
+		w := newWatch()
+		w.TraceQueryStart(withManagedTx(ctx), &pgx.Conn{}, pgx.TraceQueryStartData{SQL: "begin"})
+
+		require.Empty(t, w.leaks(later))
+	})
+
+	t.Run("should forget closed connections", func(t *testing.T) {
+		w := newWatch()
+		w.isClosed = func(*pgx.Conn) bool { return true }
+		w.TraceQueryStart(ctx, &pgx.Conn{}, pgx.TraceQueryStartData{SQL: "begin"})
+
+		require.Empty(t, w.leaks(later))
+		require.Empty(t, w.open)
+	})
+}
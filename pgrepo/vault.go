@@ -0,0 +1,221 @@
+package pgrepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const defaultVaultMount = "database"
+
+// ErrVault is returned when credentials cannot be retrieved from Vault.
+var ErrVault = errors.New("vault error")
+
+// VaultConfig configures a provider of dynamic credentials from the Vault database secrets engine.
+type VaultConfig struct {
+	Address    string       // the Vault server address. Defaults to the VAULT_ADDR environment variable
+	Token      string       // the Vault token. Defaults to the VAULT_TOKEN environment variable
+	Namespace  string       // the Vault enterprise namespace, if any. Defaults to the VAULT_NAMESPACE environment variable
+	Mount      string       // the mount path of the database secrets engine. Defaults to "database"
+	Role       string       // the database role to get credentials for
+	HTTPClient *http.Client // defaults to http.DefaultClient
+	Logger     *zap.Logger  // logs failed lease renewals. Defaults to a no-op logger
+}
+
+// VaultProvider supplies dynamic database credentials from the Vault database secrets engine.
+//
+// Credentials are leased: the lease is renewed when a third of its duration remains. When the lease cannot be
+// renewed any longer (e.g. when reaching its max TTL), new credentials are requested.
+//
+// Credentials returned with a zero lease duration don't expire.
+type VaultProvider struct {
+	cfg VaultConfig
+
+	mx       sync.Mutex
+	creds    Credentials
+	leaseID  string
+	duration time.Duration
+	renew    bool
+}
+
+// vaultSecret is the part of a Vault API response about a leased secret.
+type vaultSecret struct {
+	LeaseID       string `json:"lease_id"`
+	LeaseDuration int64  `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+	Data          struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"data"`
+}
+
+// NewVaultProvider builds a credentials provider for the Vault database secrets engine (see WithCredentialsProvider).
+func NewVaultProvider(cfg VaultConfig) (*VaultProvider, error) {
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = defaultVaultMount
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
+	if cfg.Address == "" || cfg.Role == "" {
+		return nil, fmt.Errorf("a vault address and a role are required: %w", ErrInvalidConfig)
+	}
+
+	if _, err := url.Parse(cfg.Address); err != nil {
+		return nil, fmt.Errorf("invalid vault address: %w: %w", ErrInvalidConfig, err)
+	}
+
+	return &VaultProvider{cfg: cfg}, nil
+}
+
+// Credentials returns the current credentials, renewing the lease or requesting new credentials when needed.
+func (p *VaultProvider) Credentials(ctx context.Context) (Credentials, error) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if p.creds.User != "" && (p.creds.Expires.IsZero() || time.Until(p.creds.Expires) > p.duration/3) {
+		return p.creds, nil
+	}
+
+	var renewErr error
+	if p.leaseID != "" && p.renew && time.Until(p.creds.Expires) > 0 {
+		renewErr = p.renewLease(ctx)
+		if renewErr == nil {
+			return p.creds, nil
+		}
+
+		// falls back to new credentials
+		p.cfg.Logger.Warn("could not renew the vault lease: requesting new credentials",
+			zap.String("lease_id", p.leaseID), zap.Error(renewErr),
+		)
+	}
+
+	if err := p.newCredentials(ctx); err != nil {
+		return Credentials{}, errors.Join(renewErr, err)
+	}
+
+	return p.creds, nil
+}
+
+func (p *VaultProvider) newCredentials(ctx context.Context) error {
+	var secret vaultSecret
+	pth := fmt.Sprintf("%s/creds/%s", strings.Trim(p.cfg.Mount, "/"), p.cfg.Role)
+	if err := p.call(ctx, http.MethodGet, pth, nil, &secret); err != nil {
+		return err
+	}
+
+	if secret.Data.Username == "" {
+		return fmt.Errorf("%w: no credentials returned for role %q", ErrVault, p.cfg.Role)
+	}
+
+	p.setLease(secret)
+	p.creds.User = secret.Data.Username
+	p.creds.Password = secret.Data.Password
+
+	return nil
+}
+
+func (p *VaultProvider) renewLease(ctx context.Context) error {
+	var secret vaultSecret
+	body := map[string]interface{}{
+		"lease_id":  p.leaseID,
+		"increment": int64(p.duration / time.Second),
+	}
+	if err := p.call(ctx, http.MethodPut, "sys/leases/renew", body, &secret); err != nil {
+		return err
+	}
+
+	if time.Duration(secret.LeaseDuration)*time.Second <= p.duration/3 {
+		// the lease is about to reach its max TTL
+		return fmt.Errorf("%w: lease %s may no longer be renewed", ErrVault, p.leaseID)
+	}
+
+	p.setLease(secret)
+
+	return nil
+}
+
+func (p *VaultProvider) setLease(secret vaultSecret) {
+	if secret.LeaseID != "" {
+		p.leaseID = secret.LeaseID
+	}
+	p.duration = time.Duration(secret.LeaseDuration) * time.Second
+	p.renew = secret.Renewable
+
+	if p.duration == 0 {
+		// the secret is not leased for a limited time
+		p.creds.Expires = time.Time{}
+
+		return
+	}
+
+	p.creds.Expires = time.Now().Add(p.duration)
+}
+
+func (p *VaultProvider) call(ctx context.Context, method, pth string, body, target interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(p.cfg.Address, "/")+"/v1/"+pth, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Vault-Token", p.cfg.Token)
+	if p.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.cfg.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrVault, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return fmt.Errorf("%w: %s %s: %s: %s", ErrVault, method, pth, resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("%w: invalid response: %w", ErrVault, err)
+	}
+
+	return nil
+}
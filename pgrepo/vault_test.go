@@ -0,0 +1,158 @@
+package pgrepo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProvider(t *testing.T) {
+	var (
+		issued   atomic.Int32
+		static   atomic.Int32
+		renewals atomic.Int32
+		maxTTL   atomic.Bool
+		broken   atomic.Bool
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+
+			return
+		}
+
+		if broken.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"errors":["vault is sealed"]}`))
+
+			return
+		}
+
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/db/creds/app":
+			n := issued.Add(1)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"lease_id":       "db/creds/app/lease",
+				"lease_duration": 3,
+				"renewable":      true,
+				"data": map[string]string{
+					"username": "v-app-" + string(rune('0'+n)),
+					"password": "secret",
+				},
+			})
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/db/creds/static":
+			static.Add(1)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"lease_duration": 0,
+				"data": map[string]string{
+					"username": "static",
+					"password": "secret",
+				},
+			})
+		case req.Method == http.MethodPut && req.URL.Path == "/v1/sys/leases/renew":
+			var body map[string]interface{}
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+			require.Equal(t, "db/creds/app/lease", body["lease_id"])
+			renewals.Add(1)
+
+			duration := 3
+			if maxTTL.Load() {
+				duration = 0
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"lease_id":       "db/creds/app/lease",
+				"lease_duration": duration,
+				"renewable":      true,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+
+	t.Run("should require an address and a role", func(t *testing.T) {
+		t.Setenv("VAULT_ADDR", "")
+
+		_, err := NewVaultProvider(VaultConfig{Role: "app"})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+	})
+
+	t.Run("should report vault errors", func(t *testing.T) {
+		p, err := NewVaultProvider(VaultConfig{Address: server.URL, Token: "wrong", Mount: "db", Role: "app"})
+		require.NoError(t, err)
+
+		_, err = p.Credentials(ctx)
+		require.ErrorIs(t, err, ErrVault)
+		require.Contains(t, err.Error(), "permission denied")
+	})
+
+	t.Run("should not expire credentials without a lease duration", func(t *testing.T) {
+		p, err := NewVaultProvider(VaultConfig{Address: server.URL, Token: "test-token", Mount: "db", Role: "static"})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			creds, err := p.Credentials(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "static", creds.User)
+			require.True(t, creds.Expires.IsZero())
+		}
+		require.Equal(t, int32(1), static.Load())
+	})
+
+	p, err := NewVaultProvider(VaultConfig{Address: server.URL, Token: "test-token", Mount: "db", Role: "app"})
+	require.NoError(t, err)
+
+	creds, err := p.Credentials(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "v-app-1", creds.User)
+	require.Equal(t, "secret", creds.Password)
+	require.WithinDuration(t, time.Now().Add(3*time.Second), creds.Expires, time.Second)
+
+	t.Run("should cache credentials", func(t *testing.T) {
+		cached, err := p.Credentials(ctx)
+		require.NoError(t, err)
+		require.Equal(t, creds, cached)
+		require.Equal(t, int32(1), issued.Load())
+	})
+
+	t.Run("should renew the lease", func(t *testing.T) {
+		time.Sleep(2100 * time.Millisecond)
+
+		renewed, err := p.Credentials(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "v-app-1", renewed.User)
+		require.True(t, renewed.Expires.After(creds.Expires))
+		require.Equal(t, int32(1), renewals.Load())
+	})
+
+	t.Run("should request new credentials when the lease may no longer be renewed", func(t *testing.T) {
+		maxTTL.Store(true)
+		time.Sleep(2100 * time.Millisecond)
+
+		renewed, err := p.Credentials(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "v-app-2", renewed.User)
+		require.Equal(t, int32(2), issued.Load())
+	})
+
+	t.Run("should report renewal errors", func(t *testing.T) {
+		maxTTL.Store(false)
+		broken.Store(true)
+		time.Sleep(2100 * time.Millisecond)
+
+		_, err := p.Credentials(ctx)
+		require.ErrorIs(t, err, ErrVault)
+		require.Contains(t, err.Error(), "sys/leases/renew")
+		require.Contains(t, err.Error(), "db/creds/app")
+	})
+}
@@ -0,0 +1,131 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+)
+
+var (
+	// KeyDBAlias tags metrics with the database alias of the repository.
+	KeyDBAlias = tag.MustNewKey("db_alias")
+
+	// MeasurePoolWaitDuration measures the cumulated time spent waiting for a connection over a sampling interval.
+	MeasurePoolWaitDuration = stats.Float64("pgrepo/pool/wait_duration", "Time spent waiting for a connection over a sampling interval", stats.UnitMilliseconds)
+
+	// MeasurePoolWaitCount measures the number of connections waited for over a sampling interval.
+	MeasurePoolWaitCount = stats.Int64("pgrepo/pool/wait_count", "Number of connections waited for over a sampling interval", stats.UnitDimensionless)
+
+	// PoolWaitDurationView aggregates MeasurePoolWaitDuration.
+	PoolWaitDurationView = &view.View{
+		Name:        "pgrepo/pool/wait_duration",
+		Description: "Time spent waiting for a connection over a sampling interval",
+		Measure:     MeasurePoolWaitDuration,
+		TagKeys:     []tag.Key{KeyDBAlias},
+		Aggregation: view.Distribution(1, 10, 100, 500, 1000, 5000, 10000, 60000),
+	}
+
+	// PoolWaitCountView aggregates MeasurePoolWaitCount.
+	PoolWaitCountView = &view.View{
+		Name:        "pgrepo/pool/wait_count",
+		Description: "Number of connections waited for",
+		Measure:     MeasurePoolWaitCount,
+		TagKeys:     []tag.Key{KeyDBAlias},
+		Aggregation: view.Sum(),
+	}
+)
+
+// waitSample is the outcome of comparing two pool statistics samples.
+type waitSample struct {
+	WaitCount    int64
+	WaitDuration time.Duration
+	Suggested    int
+	Alert        bool
+}
+
+// sampleWait computes the wait deltas between two pool statistics samples.
+//
+// The suggested MaxOpenConns is inferred from the average number of waiting callers over the interval
+// (Little's law), added to the current pool size.
+func (m waitMonitorSettings) sampleWait(prev, cur sql.DBStats, interval time.Duration) waitSample {
+	sample := waitSample{
+		WaitCount:    cur.WaitCount - prev.WaitCount,
+		WaitDuration: cur.WaitDuration - prev.WaitDuration,
+	}
+
+	if sample.WaitDuration <= m.Threshold || interval <= 0 {
+		return sample
+	}
+
+	sample.Alert = true
+	waiting := int(math.Ceil(float64(sample.WaitDuration) / float64(interval)))
+	poolSize := cur.MaxOpenConnections
+	if poolSize == 0 {
+		poolSize = cur.OpenConnections
+	}
+	sample.Suggested = poolSize + waiting
+
+	return sample
+}
+
+// startWaitMonitor periodically samples the pool statistics to detect an undersized pool.
+//
+// It returns a function to stop the monitor.
+func (r *Repository) startWaitMonitor(m waitMonitorSettings) func() {
+	interval := m.Interval
+	if interval <= 0 {
+		interval = defaultSettings.PGConfig.WaitMonitor.Interval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, _ = tag.New(ctx, tag.Upsert(KeyDBAlias, r.alias))
+	db := r.db
+	l := r.log.Bg().With(zap.String("db_alias", r.alias))
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prev := db.Stats()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cur := db.Stats()
+				sample := m.sampleWait(prev, cur, interval)
+				prev = cur
+
+				stats.Record(ctx,
+					MeasurePoolWaitDuration.M(float64(sample.WaitDuration)/float64(time.Millisecond)),
+					MeasurePoolWaitCount.M(sample.WaitCount),
+				)
+
+				if sample.Alert {
+					l.Warn("connection pool is undersized: callers are waiting for connections",
+						zap.Duration("wait_duration", sample.WaitDuration),
+						zap.Int64("wait_count", sample.WaitCount),
+						zap.Duration("interval", interval),
+						zap.Int("max_open_conns", cur.MaxOpenConnections),
+						zap.Int("in_use", cur.InUse),
+						zap.Int("suggested_max_open_conns", sample.Suggested),
+					)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,33 @@
+package pgrepo
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleWait(t *testing.T) {
+	m := waitMonitorSettings{Enabled: true, Interval: time.Minute, Threshold: time.Second}
+	prev := sql.DBStats{WaitCount: 10, WaitDuration: 5 * time.Second}
+
+	t.Run("below threshold", func(t *testing.T) {
+		cur := sql.DBStats{MaxOpenConnections: 10, WaitCount: 12, WaitDuration: 5500 * time.Millisecond}
+
+		sample := m.sampleWait(prev, cur, time.Minute)
+		require.False(t, sample.Alert)
+		require.EqualValues(t, 2, sample.WaitCount)
+		require.Equal(t, 500*time.Millisecond, sample.WaitDuration)
+	})
+
+	t.Run("above threshold", func(t *testing.T) {
+		// 150s waited over 1m: on average, 2.5 callers were waiting
+		cur := sql.DBStats{MaxOpenConnections: 10, WaitCount: 110, WaitDuration: 155 * time.Second}
+
+		sample := m.sampleWait(prev, cur, time.Minute)
+		require.True(t, sample.Alert)
+		require.EqualValues(t, 100, sample.WaitCount)
+		require.Equal(t, 13, sample.Suggested)
+	})
+}
@@ -0,0 +1,97 @@
+package pgrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+type mockPinger struct {
+	calls atomic.Int32
+	ping  func(context.Context, int32) error
+}
+
+func (m *mockPinger) PingContext(ctx context.Context) error {
+	return m.ping(ctx, m.calls.Add(1))
+}
+
+func TestWaitPing(t *testing.T) {
+	errUnavailable := errors.New("connection refused")
+
+	t.Run("should succeed after a few attempts", func(t *testing.T) {
+		p := &mockPinger{ping: func(_ context.Context, n int32) error {
+			if n < 2 {
+				return errUnavailable
+			}
+
+			return nil
+		}}
+
+		require.NoError(t, waitPing(context.Background(), p, 5*time.Second, RetryPolicy{}))
+		require.EqualValues(t, 2, p.calls.Load())
+	})
+
+	t.Run("should bail on auth error", func(t *testing.T) {
+		p := &mockPinger{ping: func(_ context.Context, _ int32) error {
+			return &pgconn.PgError{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"}
+		}}
+
+		err := waitPing(context.Background(), p, 5*time.Second, RetryPolicy{})
+		require.ErrorIs(t, err, ErrPGAuth)
+		require.EqualValues(t, 1, p.calls.Load())
+	})
+
+	t.Run("should bail on invalid config", func(t *testing.T) {
+		p := &mockPinger{ping: func(_ context.Context, _ int32) error {
+			return fmt.Errorf("%w: SET parameters: unknown run-time parameter %q", ErrInvalidConfig, "wrok_mem")
+		}}
+
+		err := waitPing(context.Background(), p, 5*time.Second, RetryPolicy{})
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		require.EqualValues(t, 1, p.calls.Load())
+	})
+
+	t.Run("should time out", func(t *testing.T) {
+		p := &mockPinger{ping: func(_ context.Context, _ int32) error {
+			return errUnavailable
+		}}
+
+		err := waitPing(context.Background(), p, time.Second, RetryPolicy{})
+		require.ErrorIs(t, err, ErrStartupTimeout)
+		require.ErrorIs(t, err, errUnavailable)
+		require.NotErrorIs(t, err, ErrStartupCancelled)
+		require.Contains(t, err.Error(), "attempts")
+	})
+
+	t.Run("should be cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &mockPinger{ping: func(_ context.Context, _ int32) error {
+			cancel()
+
+			return errUnavailable
+		}}
+
+		err := waitPing(ctx, p, 5*time.Second, RetryPolicy{})
+		require.ErrorIs(t, err, ErrStartupCancelled)
+		require.ErrorIs(t, err, context.Canceled)
+		require.NotErrorIs(t, err, ErrStartupTimeout)
+	})
+
+	t.Run("should give up after max attempts", func(t *testing.T) {
+		p := &mockPinger{ping: func(_ context.Context, _ int32) error {
+			return errUnavailable
+		}}
+
+		policy := RetryPolicy{InitialInterval: 10 * time.Millisecond, Multiplier: 1, MaxAttempts: 3}
+		err := waitPing(context.Background(), p, 5*time.Second, policy)
+		require.ErrorIs(t, err, ErrStartupTimeout)
+		require.ErrorIs(t, err, errUnavailable)
+		require.EqualValues(t, 3, p.calls.Load())
+	})
+}
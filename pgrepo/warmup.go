@@ -0,0 +1,103 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/fredbi/go-trace/log"
+	"go.uber.org/zap"
+)
+
+// WithMinConns pre-establishes n connections when the repository is started, so the first burst of traffic
+// does not pay the latency of establishing connections.
+//
+// maxIdleConns is raised to n if lower, so warm connections are retained.
+//
+// With PoolConfigFor, this is the minimum number of connections maintained by the pgxpool.
+func WithMinConns(n int) PoolOption {
+	return func(o *poolSettings) {
+		o.MinConns = n
+	}
+}
+
+func (p *poolSettings) validateMinConns() error {
+	if p.MinConns < 0 {
+		return fmt.Errorf("minConns must not be negative, but got %d: %w", p.MinConns, ErrInvalidConfig)
+	}
+
+	if p.MaxOpenConns > 0 && p.MinConns > p.MaxOpenConns {
+		return fmt.Errorf("minConns (%d) must not exceed maxOpenConns (%d): %w", p.MinConns, p.MaxOpenConns, ErrInvalidConfig)
+	}
+
+	return nil
+}
+
+// warmup establishes minConns connections in parallel, then releases them to the pool as idle connections.
+//
+// The database/sql pool does not maintain a minimum number of connections: connections may be closed later on
+// when idle for longer than connMaxIdleTime, or when reaching connMaxLifeTime.
+//
+// Failures are logged: the pool establishes connections on demand anyway.
+func (r databaseSettings) warmup(ctx context.Context, db *sql.DB, l log.Logger) {
+	if r.PGConfig == nil || r.PGConfig.MinConns <= 0 {
+		return
+	}
+
+	n := r.PGConfig.MinConns
+	conns := make([]*sql.Conn, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// connections are held until all are established, so they are all distinct
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				errs[i] = err
+
+				return
+			}
+			conns[i] = conn
+			errs[i] = conn.PingContext(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	var warm int
+	for i, conn := range conns {
+		if conn == nil {
+			continue
+		}
+		if errs[i] == nil {
+			warm++
+		}
+		_ = conn.Close()
+	}
+
+	if warm < n {
+		l.Warn("could not warm up all connections",
+			zap.Int("min_conns", n),
+			zap.Int("warm_conns", warm),
+			zap.Error(firstError(errs)),
+		)
+
+		return
+	}
+
+	l.Debug("connections warmed up", zap.Int("min_conns", n))
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
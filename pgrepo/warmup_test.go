@@ -0,0 +1,49 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/fredbi/go-trace/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWarmup(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	l := log.NewFactory(zap.New(core)).Bg()
+
+	t.Run("should establish connections", func(t *testing.T) {
+		db := sql.OpenDB(&fakeReplica{})
+		t.Cleanup(func() { _ = db.Close() })
+
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithMinConns(5)})}
+		dbs.SetPool(db)
+		dbs.warmup(context.Background(), db, l)
+
+		stats := db.Stats()
+		require.Equal(t, 5, stats.OpenConnections)
+		require.Equal(t, 5, stats.Idle)
+		require.Zero(t, logs.Len())
+	})
+
+	t.Run("should log failures", func(t *testing.T) {
+		down := &fakeReplica{}
+		down.down.Store(true)
+		db := sql.OpenDB(down)
+		t.Cleanup(func() { _ = db.Close() })
+
+		dbs := databaseSettings{PGConfig: poolSettingsFromOptions([]PoolOption{WithMinConns(2)})}
+		dbs.warmup(context.Background(), db, l)
+
+		require.Equal(t, 1, logs.FilterMessage("could not warm up all connections").Len())
+	})
+}
+
+func TestValidateMinConns(t *testing.T) {
+	require.NoError(t, poolSettingsFromOptions([]PoolOption{WithMinConns(5), WithMaxOpenConns(10)}).validateMinConns())
+	require.ErrorIs(t, poolSettingsFromOptions([]PoolOption{WithMinConns(-1)}).validateMinConns(), ErrInvalidConfig)
+	require.ErrorIs(t, poolSettingsFromOptions([]PoolOption{WithMinConns(20), WithMaxOpenConns(10)}).validateMinConns(), ErrInvalidConfig)
+}
@@ -0,0 +1,187 @@
+package pgrepomock
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+)
+
+// ArgMatcher matches the argument of a recorded statement.
+type ArgMatcher interface {
+	Match(interface{}) bool
+	String() string
+}
+
+type argMatcher struct {
+	desc  string
+	match func(interface{}) bool
+}
+
+func (m argMatcher) Match(v interface{}) bool { return m.match(v) }
+func (m argMatcher) String() string           { return m.desc }
+
+// AnyArg matches any argument.
+func AnyArg() ArgMatcher {
+	return argMatcher{desc: "<any>", match: func(interface{}) bool { return true }}
+}
+
+// ArgEq matches an argument equal to v (in the sense of reflect.DeepEqual).
+func ArgEq(v interface{}) ArgMatcher {
+	return argMatcher{
+		desc:  fmt.Sprintf("%#v", v),
+		match: func(arg interface{}) bool { return reflect.DeepEqual(v, arg) },
+	}
+}
+
+// ArgFunc matches an argument with a custom function. The description is used in failure messages.
+func ArgFunc(desc string, fn func(interface{}) bool) ArgMatcher {
+	return argMatcher{desc: desc, match: fn}
+}
+
+// Expectation about a statement recorded by a Recorder.
+//
+// Statements are matched on their normalized SQL (see pgrepo.NormalizeQuery), so literal values and blanks don't matter.
+type Expectation struct {
+	normalized string
+	query      string
+	args       []ArgMatcher
+}
+
+// Expect a statement like query.
+//
+// When some args are specified, the statement must have as many arguments, matching args.
+// Arguments which are not an ArgMatcher are matched with ArgEq.
+func Expect(query string, args ...interface{}) Expectation {
+	e := Expectation{
+		normalized: pgrepo.NormalizeQuery(query),
+		query:      query,
+	}
+
+	for _, arg := range args {
+		matcher, ok := arg.(ArgMatcher)
+		if !ok {
+			matcher = ArgEq(arg)
+		}
+		e.args = append(e.args, matcher)
+	}
+
+	return e
+}
+
+// Match a recorded statement.
+func (e Expectation) Match(q Query) bool {
+	if pgrepo.NormalizeQuery(q.SQL) != e.normalized {
+		return false
+	}
+
+	if e.args == nil {
+		return true
+	}
+
+	if len(q.Args) != len(e.args) {
+		return false
+	}
+
+	for i, matcher := range e.args {
+		if !matcher.Match(q.Args[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e Expectation) String() string {
+	if e.args == nil {
+		return e.query
+	}
+
+	args := make([]string, len(e.args))
+	for i, matcher := range e.args {
+		args[i] = matcher.String()
+	}
+
+	return fmt.Sprintf("%s [%s]", e.query, strings.Join(args, ", "))
+}
+
+// AssertQueries asserts that statements matching the expectations have been recorded, in this order.
+//
+// Other statements may be recorded before, between or after the expected ones.
+//
+// It returns false and reports the recorded statements when the expectations are not met.
+func AssertQueries(t testing.TB, rec *Recorder, expectations ...Expectation) bool {
+	t.Helper()
+
+	queries := rec.Queries()
+	next := 0
+
+	for i, e := range expectations {
+		found := false
+		for ; next < len(queries); next++ {
+			if e.Match(queries[next]) {
+				found = true
+				next++
+
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("expected statement #%d was not executed in order: %s\n%s", i+1, e, formatQueries(queries))
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// AssertQueriesUnordered asserts that statements matching the expectations have been recorded, in any order.
+//
+// Every expectation is matched by a distinct statement.
+func AssertQueriesUnordered(t testing.TB, rec *Recorder, expectations ...Expectation) bool {
+	t.Helper()
+
+	queries := rec.Queries()
+	used := make([]bool, len(queries))
+	ok := true
+
+	for i, e := range expectations {
+		found := false
+		for j, q := range queries {
+			if !used[j] && e.Match(q) {
+				used[j] = true
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			t.Errorf("expected statement #%d was not executed: %s\n%s", i+1, e, formatQueries(queries))
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func formatQueries(queries []Query) string {
+	if len(queries) == 0 {
+		return "no statement recorded"
+	}
+
+	var b strings.Builder
+	b.WriteString("recorded statements:")
+	for i, q := range queries {
+		fmt.Fprintf(&b, "\n  #%d: %s %v", i+1, q.SQL, q.Args)
+		if q.Err != nil {
+			fmt.Fprintf(&b, " (error: %v)", q.Err)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,98 @@
+package pgrepomock
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// failureRecorder captures the failures reported by assertions.
+type failureRecorder struct {
+	testing.TB
+	failures []string
+}
+
+func (f *failureRecorder) Helper() {}
+
+func (f *failureRecorder) Errorf(format string, args ...interface{}) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func recordedQueries(queries ...Query) *Recorder {
+	rec := NewRecorder()
+	for _, q := range queries {
+		rec.record(q)
+	}
+
+	return rec
+}
+
+func TestAssertQueries(t *testing.T) {
+	rec := recordedQueries(
+		Query{SQL: "BEGIN"},
+		Query{SQL: "SELECT * FROM users WHERE id = $1", Args: []interface{}{int64(1)}},
+		Query{SQL: "UPDATE users SET name = 'bob' WHERE id = $1", Args: []interface{}{int64(1)}},
+		Query{SQL: "COMMIT"},
+	)
+
+	t.Run("should match in order", func(t *testing.T) {
+		require.True(t, AssertQueries(t, rec,
+			Expect("BEGIN"),
+			Expect("UPDATE users SET name = 'alice'  WHERE id = $1", int64(1)),
+			Expect("COMMIT"),
+		))
+	})
+
+	t.Run("should match arguments", func(t *testing.T) {
+		require.True(t, AssertQueries(t, rec,
+			Expect("SELECT * FROM users WHERE id = $1", AnyArg()),
+			Expect("UPDATE users SET name = 'x' WHERE id = $1", ArgFunc("positive", func(v interface{}) bool {
+				id, ok := v.(int64)
+
+				return ok && id > 0
+			})),
+		))
+
+		f := &failureRecorder{TB: t}
+		require.False(t, AssertQueries(f, rec, Expect("SELECT * FROM users WHERE id = $1", int64(2))))
+		require.Len(t, f.failures, 1)
+		require.Contains(t, f.failures[0], "[2]")
+
+		f = &failureRecorder{TB: t}
+		require.False(t, AssertQueries(f, rec, Expect("SELECT * FROM users WHERE id = $1", AnyArg(), AnyArg())))
+	})
+
+	t.Run("should fail when out of order", func(t *testing.T) {
+		f := &failureRecorder{TB: t}
+		require.False(t, AssertQueries(f, rec, Expect("COMMIT"), Expect("BEGIN")))
+		require.Len(t, f.failures, 1)
+		require.True(t, strings.HasPrefix(f.failures[0], "expected statement #2 was not executed in order: BEGIN"))
+		require.Contains(t, f.failures[0], "#4: COMMIT")
+	})
+
+	t.Run("should match in any order", func(t *testing.T) {
+		require.True(t, AssertQueriesUnordered(t, rec, Expect("COMMIT"), Expect("BEGIN")))
+
+		f := &failureRecorder{TB: t}
+		require.False(t, AssertQueriesUnordered(f, rec, Expect("BEGIN"), Expect("BEGIN"), Expect("ROLLBACK")))
+		require.Len(t, f.failures, 2)
+	})
+
+	t.Run("should compare long statements entirely", func(t *testing.T) {
+		columns := strings.Repeat("a_column, ", 200)
+		rec := recordedQueries(Query{SQL: "SELECT " + columns + "x FROM t"})
+
+		require.True(t, AssertQueries(t, rec, Expect("SELECT "+columns+"x FROM t")))
+
+		f := &failureRecorder{TB: t}
+		require.False(t, AssertQueries(f, rec, Expect("SELECT "+columns+"y FROM t")))
+	})
+
+	t.Run("should report an empty recorder", func(t *testing.T) {
+		f := &failureRecorder{TB: t}
+		require.False(t, AssertQueries(f, NewRecorder(), Expect("BEGIN")))
+		require.Contains(t, f.failures[0], "no statement recorded")
+	})
+}
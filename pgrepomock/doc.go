@@ -0,0 +1,8 @@
+// Package pgrepomock provides a mock of pgrepo.Repo, backed by sqlmock.
+//
+// Code which depends on a pgrepo.Repo rather than on a *pgrepo.Repository may be unit tested
+// without a live database: statements are matched against the expectations declared with sqlmock.
+//
+// A Recorder records executed statements, which are then asserted with AssertQueries,
+// so behavioral tests about data access don't need a live database either.
+package pgrepomock
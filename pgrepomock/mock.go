@@ -0,0 +1,169 @@
+package pgrepomock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fredbi/go-trace/log"
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+var _ pgrepo.Repo = &Repo{}
+
+// driverName is used by sqlx to select postgres bind variables ($1, $2, ...).
+const driverName = "pgx"
+
+// Repo is a pgrepo.Repo backed by sqlmock.
+//
+// There is no replica: ReplicaDB, Read and Write all use the mocked DB.
+type Repo struct {
+	db  *sqlx.DB
+	log log.Factory
+}
+
+// Option configures a mocked repository.
+type Option func(*options)
+
+type options struct {
+	logger  *zap.Logger
+	matcher sqlmock.QueryMatcher
+	pings   bool
+}
+
+// WithLogger sets the logger of the repository. Defaults to a no-op logger.
+func WithLogger(lg *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = lg
+	}
+}
+
+// WithQueryMatcher sets how sqlmock matches statements against expectations,
+// e.g. sqlmock.QueryMatcherEqual to match statements exactly. Defaults to sqlmock.QueryMatcherRegexp.
+func WithQueryMatcher(matcher sqlmock.QueryMatcher) Option {
+	return func(o *options) {
+		o.matcher = matcher
+	}
+}
+
+// WithMonitorPings expects health checks with ExpectPing. By default, pings always succeed.
+func WithMonitorPings(enabled bool) Option {
+	return func(o *options) {
+		o.pings = enabled
+	}
+}
+
+func optionsWithDefaults(opts []Option) options {
+	o := options{
+		logger:  zap.NewNop(),
+		matcher: sqlmock.QueryMatcherRegexp,
+	}
+
+	for _, apply := range opts {
+		apply(&o)
+	}
+
+	return o
+}
+
+// New mocked repository, with the sqlmock used to declare expectations.
+//
+// Unmet expectations are reported when the test completes.
+func New(t testing.TB, opts ...Option) (*Repo, sqlmock.Sqlmock) {
+	t.Helper()
+
+	o := optionsWithDefaults(opts)
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(o.matcher), sqlmock.MonitorPingsOption(o.pings))
+	if err != nil {
+		t.Fatalf("could not create sqlmock: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet sqlmock expectations: %v", err)
+		}
+
+		_ = db.Close()
+	})
+
+	return &Repo{
+		db:  sqlx.NewDb(db, driverName),
+		log: log.NewFactory(o.logger),
+	}, mock
+}
+
+// DB mocked instance
+func (r *Repo) DB() *sqlx.DB {
+	return r.db
+}
+
+// ReplicaDB returns the mocked instance.
+func (r *Repo) ReplicaDB() *sqlx.DB {
+	return r.db
+}
+
+// Logger returns a logger factory
+func (r *Repo) Logger() log.Factory {
+	return r.log
+}
+
+// HealthCheck pings the mocked database
+func (r *Repo) HealthCheck() error {
+	return r.HealthCheckContext(context.Background())
+}
+
+// HealthCheckContext pings the mocked database.
+//
+// Pings always succeed, unless sqlmock monitors them (see WithMonitorPings).
+func (r *Repo) HealthCheckContext(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// RunInTx runs a function within a transaction, which is committed if the function returns no error,
+// and rolled back otherwise.
+//
+// The transaction is expected with ExpectBegin, then ExpectCommit or ExpectRollback.
+// Transaction options are ignored: in particular, failed transactions are not retried.
+func (r *Repo) RunInTx(ctx context.Context, fn func(context.Context, *sqlx.Tx) error, _ ...pgrepo.TxOption) (err error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+
+			panic(p)
+		}
+	}()
+
+	if err = fn(ctx, tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil && !errors.Is(rerr, sql.ErrTxDone) {
+			return errors.Join(err, rerr)
+		}
+
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Read runs a function issuing read statements on the mocked DB.
+func (r *Repo) Read(ctx context.Context, fn func(context.Context, *sqlx.DB) error) error {
+	return fn(ctx, r.db)
+}
+
+// Write runs a function issuing write statements on the mocked DB.
+func (r *Repo) Write(ctx context.Context, fn func(context.Context, *sqlx.DB) error) error {
+	return fn(ctx, r.db)
+}
+
+// Stop closes the mocked DB, which is expected with ExpectClose.
+func (r *Repo) Stop() error {
+	return r.db.Close()
+}
@@ -0,0 +1,69 @@
+package pgrepomock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should query the mocked DB", func(t *testing.T) {
+		repo, mock := New(t, WithQueryMatcher(sqlmock.QueryMatcherEqual))
+		mock.ExpectQuery(`SELECT name FROM users WHERE id = $1`).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("alice"))
+
+		var name string
+		require.NoError(t, repo.Read(ctx, func(ctx context.Context, db *sqlx.DB) error {
+			return db.GetContext(ctx, &name, db.Rebind(`SELECT name FROM users WHERE id = ?`), 1)
+		}))
+		require.Equal(t, "alice", name)
+		require.NotNil(t, repo.Logger().Bg())
+	})
+
+	t.Run("should commit a transaction", func(t *testing.T) {
+		repo, mock := New(t)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE orders`).WithArgs("shipped").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		require.NoError(t, repo.RunInTx(ctx, func(ctx context.Context, tx *sqlx.Tx) error {
+			_, err := tx.ExecContext(ctx, `UPDATE orders SET status = $1`, "shipped")
+
+			return err
+		}))
+	})
+
+	t.Run("should roll back a failed transaction", func(t *testing.T) {
+		repo, mock := New(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		errFailed := errors.New("failed")
+		err := repo.RunInTx(ctx, func(context.Context, *sqlx.Tx) error {
+			return errFailed
+		})
+		require.ErrorIs(t, err, errFailed)
+	})
+
+	t.Run("should mock health checks", func(t *testing.T) {
+		repo, mock := New(t, WithMonitorPings(true))
+		errDown := errors.New("down")
+		mock.ExpectPing().WillReturnError(errDown)
+
+		require.ErrorIs(t, repo.HealthCheck(), errDown)
+	})
+
+	t.Run("should stop", func(t *testing.T) {
+		repo, mock := New(t)
+		mock.ExpectClose()
+
+		require.NoError(t, repo.Stop())
+	})
+}
@@ -0,0 +1,232 @@
+package pgrepomock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/jackc/pgx/v5"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	_ pgx.QueryTracer          = &Recorder{}
+	_ driver.Connector         = recorderConnector{}
+	_ driver.ExecerContext     = &recorderConn{}
+	_ driver.QueryerContext    = &recorderConn{}
+	_ driver.ConnBeginTx       = &recorderConn{}
+	_ driver.NamedValueChecker = &recorderConn{}
+	_ driver.RowsAffected      = 0
+	_ driver.Rows              = &recorderRows{}
+	_ driver.Tx                = recorderTx{}
+)
+
+// Query is a statement recorded by a Recorder.
+type Query struct {
+	SQL  string
+	Args []interface{}
+	Err  error
+}
+
+// Fingerprint of the statement, with literals replaced by "?" (see pgrepo.Fingerprint).
+func (q Query) Fingerprint() string {
+	return pgrepo.Fingerprint(q.SQL)
+}
+
+// Result is the stubbed result of a statement executed on the DB of a Recorder.
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+	Err          error
+}
+
+// Recorder records executed statements, for assertions with AssertQueries.
+//
+// A Recorder is a pgx tracer, which records the statements executed on a live database when configured
+// with pgrepo.WithPGXTracer.
+//
+// Without a database, the Recorder provides a DB which records statements and returns stubbed results.
+// Transactions are recorded as "BEGIN", "COMMIT" and "ROLLBACK" statements.
+type Recorder struct {
+	mx      sync.Mutex
+	queries []Query
+	stubs   map[string]Result
+}
+
+type recorderKey struct{}
+
+// NewRecorder builds a Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{stubs: make(map[string]Result)}
+}
+
+// Queries returns the statements recorded so far, in the order of their execution.
+func (r *Recorder) Queries() []Query {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	return append([]Query(nil), r.queries...)
+}
+
+// Reset forgets the statements recorded so far.
+func (r *Recorder) Reset() {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.queries = nil
+}
+
+// Stub sets the result of the statements like query (see pgrepo.NormalizeQuery), executed on the DB of the recorder.
+//
+// Statements which are not stubbed return no rows and affect no rows.
+func (r *Recorder) Stub(query string, result Result) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.stubs[pgrepo.NormalizeQuery(query)] = result
+}
+
+// DB returns a database handle which records statements, without a database.
+func (r *Recorder) DB() *sqlx.DB {
+	return sqlx.NewDb(sql.OpenDB(recorderConnector{r: r}), "pgx")
+}
+
+func (r *Recorder) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, recorderKey{}, data)
+}
+
+func (r *Recorder) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	started, ok := ctx.Value(recorderKey{}).(pgx.TraceQueryStartData)
+	if !ok {
+		return
+	}
+
+	r.record(Query{SQL: started.SQL, Args: started.Args, Err: data.Err})
+}
+
+func (r *Recorder) record(q Query) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	r.queries = append(r.queries, q)
+}
+
+// execute records a statement executed on the DB of the recorder, and returns its stubbed result.
+func (r *Recorder) execute(query string, args []driver.NamedValue) Result {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	result := r.stubs[pgrepo.NormalizeQuery(query)]
+	r.queries = append(r.queries, Query{SQL: query, Args: values, Err: result.Err})
+
+	return result
+}
+
+// recorderConnector is a database/sql connector which records statements instead of executing them.
+type recorderConnector struct {
+	r *Recorder
+}
+
+func (c recorderConnector) Connect(context.Context) (driver.Conn, error) {
+	return &recorderConn{r: c.r}, nil
+}
+
+func (c recorderConnector) Driver() driver.Driver {
+	return nil
+}
+
+type recorderConn struct {
+	r *Recorder
+}
+
+func (c *recorderConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported by the recorder")
+}
+
+func (c *recorderConn) Close() error {
+	return nil
+}
+
+func (c *recorderConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recorderConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.r.execute("BEGIN", nil)
+
+	return recorderTx{r: c.r}, nil
+}
+
+// CheckNamedValue accepts arguments of any type, like the pgx driver does.
+func (c *recorderConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *recorderConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.r.execute(query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	return driver.RowsAffected(result.RowsAffected), nil
+}
+
+func (c *recorderConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.r.execute(query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	return &recorderRows{columns: result.Columns, rows: result.Rows}, nil
+}
+
+type recorderTx struct {
+	r *Recorder
+}
+
+func (t recorderTx) Commit() error {
+	t.r.execute("COMMIT", nil)
+
+	return nil
+}
+
+func (t recorderTx) Rollback() error {
+	t.r.execute("ROLLBACK", nil)
+
+	return nil
+}
+
+type recorderRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *recorderRows) Columns() []string {
+	return r.columns
+}
+
+func (r *recorderRows) Close() error {
+	return nil
+}
+
+func (r *recorderRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[r.next])
+	r.next++
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package pgrepomock
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	rec := NewRecorder()
+	db := rec.DB()
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	rec.Stub("SELECT id, name FROM users WHERE id = $1", Result{
+		Columns: []string{"id", "name"},
+		Rows:    [][]driver.Value{{int64(1), "alice"}},
+	})
+	rec.Stub("UPDATE users SET name = 'x'", Result{RowsAffected: 3})
+	errStubbed := errors.New("stubbed")
+	rec.Stub("DELETE FROM users", Result{Err: errStubbed})
+
+	t.Run("should return stubbed rows", func(t *testing.T) {
+		var users []struct {
+			ID   int64  `db:"id"`
+			Name string `db:"name"`
+		}
+		require.NoError(t, db.SelectContext(ctx, &users, "SELECT id, name FROM users WHERE id = $1", 1))
+		require.Len(t, users, 1)
+		require.Equal(t, "alice", users[0].Name)
+	})
+
+	t.Run("should match stubs on fingerprints", func(t *testing.T) {
+		res, err := db.ExecContext(ctx, "UPDATE users  SET name = 'y'")
+		require.NoError(t, err)
+		affected, err := res.RowsAffected()
+		require.NoError(t, err)
+		require.Equal(t, int64(3), affected)
+	})
+
+	t.Run("should return stubbed errors", func(t *testing.T) {
+		_, err := db.ExecContext(ctx, "DELETE FROM users")
+		require.ErrorIs(t, err, errStubbed)
+	})
+
+	t.Run("should record transactions", func(t *testing.T) {
+		tx, err := db.BeginTxx(ctx, nil)
+		require.NoError(t, err)
+		_, err = tx.ExecContext(ctx, "INSERT INTO users(name) VALUES($1)", []string{"bob"})
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+	})
+
+	queries := rec.Queries()
+	require.Len(t, queries, 6)
+	require.Equal(t, []interface{}{1}, queries[0].Args)
+	require.ErrorIs(t, queries[2].Err, errStubbed)
+	require.Equal(t, "BEGIN", queries[3].SQL)
+	require.Equal(t, []interface{}{[]string{"bob"}}, queries[4].Args)
+	require.Equal(t, "COMMIT", queries[5].SQL)
+
+	rec.Reset()
+	require.Empty(t, rec.Queries())
+}
+
+func TestRecorderTracer(t *testing.T) {
+	rec := NewRecorder()
+	ctx := rec.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: "SELECT $1", Args: []interface{}{1}})
+	rec.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+
+	require.Equal(t, []Query{{SQL: "SELECT $1", Args: []interface{}{1}}}, rec.Queries())
+	require.Equal(t, "SELECT $1", rec.Queries()[0].Fingerprint())
+}
@@ -0,0 +1,99 @@
+package pgtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+)
+
+const (
+	// SQLSTATE object_in_use: the template is being accessed by other users, e.g. a concurrent clone
+	codeObjectInUse = "55006"
+
+	maxCloneAttempts  = 10
+	cloneRetryBackoff = 200 * time.Millisecond
+	maxDBNameLength   = 63
+)
+
+// CloneDB creates a fresh database from a template database (e.g. a database with all migrations applied),
+// and returns a started repository connected to it.
+//
+// Creating a database from a template is much faster than creating and migrating a database from scratch
+// in every test. The database server is configured by the options, like for pgrepo.New with the default
+// database alias.
+//
+// The repository is stopped and the cloned database is dropped when the test completes.
+//
+// NOTE: postgres refuses to copy a template with active connections. Close all connections to the template
+// once it is prepared. Clones created concurrently from the same template are retried.
+func CloneDB(ctx context.Context, t *testing.T, templateName string, opts ...pgrepo.Option) *pgrepo.Repository {
+	t.Helper()
+
+	dbName, err := cloneName(templateName)
+	if err != nil {
+		t.Fatalf("could not name a clone of %s: %v", templateName, err)
+	}
+
+	createOpts := append(opts[:len(opts):len(opts)], pgrepo.WithCreateOptions(pgrepo.WithTemplate(templateName)))
+	for attempt := 1; ; attempt++ {
+		_, err = pgrepo.CreateDB(ctx, dbName, createOpts...)
+		if err == nil || pgrepo.SQLState(err) != codeObjectInUse || attempt == maxCloneAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("could not clone database %s: %v", templateName, ctx.Err())
+		case <-time.After(cloneRetryBackoff):
+		}
+	}
+	if err != nil {
+		t.Fatalf("could not clone database %s: %v", templateName, err)
+	}
+
+	t.Cleanup(func() {
+		dropOpts := append(opts[:len(opts):len(opts)], pgrepo.WithForceDrop())
+		if _, err := pgrepo.DropDB(context.Background(), dbName, dropOpts...); err != nil {
+			t.Logf("could not drop database %s: %v", dbName, err)
+		}
+	})
+
+	repo := pgrepo.New(pgrepo.DefaultDBAlias, opts...)
+	if err = repo.SwitchDB(dbName); err != nil {
+		t.Fatalf("could not connect to database %s: %v", dbName, err)
+	}
+
+	if err = repo.Start(); err != nil {
+		t.Fatalf("could not start repository for database %s: %v", dbName, err)
+	}
+
+	t.Cleanup(func() {
+		_ = repo.Stop()
+	})
+
+	return repo
+}
+
+// cloneName builds a unique database name for a clone of a template.
+func cloneName(templateName string) (string, error) {
+	var suffix [6]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", err
+	}
+
+	prefix := templateName
+	if maxPrefix := maxDBNameLength - 2*len(suffix) - 1; len(prefix) > maxPrefix {
+		// do not split a multi-byte character
+		for maxPrefix > 0 && !utf8.RuneStart(prefix[maxPrefix]) {
+			maxPrefix--
+		}
+		prefix = prefix[:maxPrefix]
+	}
+
+	return prefix + "_" + hex.EncodeToString(suffix[:]), nil
+}
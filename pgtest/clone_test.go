@@ -0,0 +1,30 @@
+package pgtest
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneName(t *testing.T) {
+	t.Run("clone names are unique", func(t *testing.T) {
+		first, err := cloneName("app_template")
+		require.NoError(t, err)
+		second, err := cloneName("app_template")
+		require.NoError(t, err)
+
+		require.True(t, strings.HasPrefix(first, "app_template_"))
+		require.NotEqual(t, first, second)
+
+		long, err := cloneName(strings.Repeat("x", 80))
+		require.NoError(t, err)
+		require.Len(t, long, maxDBNameLength)
+
+		multiByte, err := cloneName("a" + strings.Repeat("é", 40))
+		require.NoError(t, err)
+		require.True(t, utf8.ValidString(multiByte))
+		require.Len(t, multiByte, maxDBNameLength-1)
+	})
+}
@@ -0,0 +1,161 @@
+// Package container provides disposable postgres servers running in docker containers, for integration tests
+// of services built with pgrepo.
+//
+// It is a separate module, so that the dependencies of testcontainers and docker are not required by pgrepo.
+package container
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	// DefaultImage is the postgres docker image used by disposable test databases.
+	DefaultImage = "postgres:16-alpine"
+
+	defaultDatabase       = "pgtest"
+	defaultUser           = "postgres"
+	defaultPassword       = "postgres"
+	defaultStartupTimeout = time.Minute
+)
+
+// Container is a disposable postgres server, running in a docker container.
+type Container struct {
+	*postgres.PostgresContainer
+
+	url string
+}
+
+// URL to connect to the database of the container.
+func (c *Container) URL() string {
+	return c.url
+}
+
+// Option configures a disposable postgres container.
+type Option func(*containerSettings)
+
+type containerSettings struct {
+	image       string
+	database    string
+	initScripts []string
+	repoOptions []pgrepo.Option
+}
+
+// WithImage sets the postgres docker image. Defaults to DefaultImage.
+func WithImage(image string) Option {
+	return func(o *containerSettings) {
+		o.image = image
+	}
+}
+
+// WithDatabase sets the name of the database created in the container.
+func WithDatabase(name string) Option {
+	return func(o *containerSettings) {
+		o.database = name
+	}
+}
+
+// WithInitScripts runs SQL or shell scripts when the container starts, e.g. to create roles or extensions.
+func WithInitScripts(scripts ...string) Option {
+	return func(o *containerSettings) {
+		o.initScripts = append(o.initScripts, scripts...)
+	}
+}
+
+// WithRepositoryOptions adds options to the repository built by NewRepository.
+//
+// The URL of the default database alias is always set to connect to the container,
+// other settings of the default database alias are preserved.
+func WithRepositoryOptions(opts ...pgrepo.Option) Option {
+	return func(o *containerSettings) {
+		o.repoOptions = append(o.repoOptions, opts...)
+	}
+}
+
+func containerSettingsFromOptions(opts []Option) containerSettings {
+	s := containerSettings{
+		image:    DefaultImage,
+		database: defaultDatabase,
+	}
+
+	for _, apply := range opts {
+		apply(&s)
+	}
+
+	return s
+}
+
+// Start a disposable postgres server in a docker container.
+//
+// The container is terminated when the test completes. The test is skipped if docker is not available.
+func Start(t *testing.T, opts ...Option) *Container {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	s := containerSettingsFromOptions(opts)
+	ctx := context.Background()
+
+	pgc, err := postgres.RunContainer(ctx,
+		testcontainers.WithImage(s.image),
+		postgres.WithDatabase(s.database),
+		postgres.WithUsername(defaultUser),
+		postgres.WithPassword(defaultPassword),
+		postgres.WithInitScripts(s.initScripts...),
+		testcontainers.WithWaitStrategy(
+			// postgres restarts once after running init scripts
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(defaultStartupTimeout),
+		),
+	)
+	if pgc != nil {
+		t.Cleanup(func() {
+			if err := pgc.Terminate(context.Background()); err != nil {
+				t.Logf("could not terminate postgres container: %v", err)
+			}
+		})
+	}
+	if err != nil {
+		t.Fatalf("could not start postgres container: %v", err)
+	}
+
+	url, err := pgc.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("could not get the URL of the postgres container: %v", err)
+	}
+
+	return &Container{PostgresContainer: pgc, url: url}
+}
+
+// NewRepository starts a disposable postgres server and returns a started repository connected to it,
+// with the default database alias.
+//
+// The repository is stopped and the container is terminated when the test completes.
+// The test is skipped if docker is not available.
+func NewRepository(t *testing.T, opts ...Option) *pgrepo.Repository {
+	t.Helper()
+
+	c := Start(t, opts...)
+	s := containerSettingsFromOptions(opts)
+
+	repoOptions := append(s.repoOptions[:len(s.repoOptions):len(s.repoOptions)],
+		pgrepo.WithDatabaseOptions(pgrepo.DefaultDBAlias, pgrepo.WithURL(c.URL())),
+	)
+
+	repo := pgrepo.New(pgrepo.DefaultDBAlias, repoOptions...)
+	if err := repo.Start(); err != nil {
+		t.Fatalf("could not start repository: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = repo.Stop()
+	})
+
+	return repo
+}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fredbi/pgxutils/pgrepo"
+	"github.com/fredbi/pgxutils/pgtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starting a postgres container is skipped in short mode")
+	}
+
+	repo := NewRepository(t,
+		WithDatabase("orders"),
+	)
+
+	var name string
+	require.NoError(t, repo.DB().GetContext(context.Background(), &name, `SELECT current_database()`))
+	require.Equal(t, "orders", name)
+}
+
+func TestContainerSettings(t *testing.T) {
+	s := containerSettingsFromOptions(nil)
+	require.Equal(t, DefaultImage, s.image)
+	require.Equal(t, defaultDatabase, s.database)
+
+	s = containerSettingsFromOptions([]Option{
+		WithImage("postgres:15"),
+		WithInitScripts("a.sql"),
+		WithInitScripts("b.sql"),
+	})
+	require.Equal(t, "postgres:15", s.image)
+	require.Equal(t, []string{"a.sql", "b.sql"}, s.initScripts)
+}
+
+func TestCloneDB(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starting a postgres container is skipped in short mode")
+	}
+
+	ctx := context.Background()
+	c := Start(t)
+	opts := []pgrepo.Option{pgrepo.WithDatabaseSettings(pgrepo.DefaultDBAlias, pgrepo.WithURL(c.URL()))}
+
+	const template = "app_template"
+	db, _, err := pgrepo.EnsureDB(ctx, template, opts...)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `CREATE TABLE users (id bigint PRIMARY KEY)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	first := pgtest.CloneDB(ctx, t, template, opts...)
+	second := pgtest.CloneDB(ctx, t, template, opts...)
+
+	_, err = first.DB().ExecContext(ctx, `INSERT INTO users (id) VALUES (1)`)
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, second.DB().GetContext(ctx, &count, `SELECT count(*) FROM users`))
+	require.Zero(t, count, "clones have the template schema, but not the data of other clones")
+}
@@ -0,0 +1,8 @@
+// Package pgtest provides test helpers for services built with pgrepo.
+//
+// Golden fixtures capture the result set of a query run against a development database,
+// so unit tests may be fed with realistic data that is easy to regenerate.
+//
+// Disposable postgres servers run in docker containers, so integration tests don't
+// depend on a pre-provisioned local server (see the container package, a separate module).
+package pgtest
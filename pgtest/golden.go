@@ -0,0 +1,291 @@
+package pgtest
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fredbi/pgxutils/pgrepomock"
+	"github.com/jmoiron/sqlx"
+	"github.com/mitchellh/mapstructure"
+)
+
+// csvNull represents a NULL value in CSV golden files.
+const csvNull = `\N`
+
+// ErrUnsupportedFormat is returned when a golden file extension is neither .json nor .csv.
+var ErrUnsupportedFormat = errors.New("unsupported golden file format")
+
+// Golden is the result set of a query, captured as a golden fixture.
+type Golden struct {
+	Query   string          `json:"query,omitempty"`
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// CaptureGolden runs a query and captures its result set.
+func CaptureGolden(ctx context.Context, db sqlx.QueryerContext, query string, args ...interface{}) (*Golden, error) {
+	rows, err := db.QueryxContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Golden{
+		Query:   query,
+		Columns: columns,
+		Rows:    make([][]interface{}, 0),
+	}
+
+	for rows.Next() {
+		values, err := rows.SliceScan()
+		if err != nil {
+			return nil, err
+		}
+
+		for i, v := range values {
+			if b, isBytes := v.([]byte); isBytes {
+				values[i] = string(b)
+			}
+		}
+
+		g.Rows = append(g.Rows, values)
+	}
+
+	return g, rows.Err()
+}
+
+// WriteGolden runs a query and writes its result set to a golden file.
+//
+// The format of the file is determined by its extension: ".json" or ".csv".
+func WriteGolden(ctx context.Context, db sqlx.QueryerContext, path, query string, args ...interface{}) error {
+	g, err := CaptureGolden(ctx, db, query, args...)
+	if err != nil {
+		return fmt.Errorf("could not capture golden result for %s: %w", path, err)
+	}
+
+	return g.WriteFile(path)
+}
+
+// WriteFile writes a golden result set to a file, as JSON or CSV depending on the file extension.
+func (g *Golden) WriteFile(path string) error {
+	var (
+		content []byte
+		err     error
+	)
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		content, err = json.MarshalIndent(g, "", "  ")
+		content = append(content, '\n')
+	case ".csv":
+		content, err = g.marshalCSV()
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0o600)
+}
+
+// LoadGolden loads a golden result set from a JSON or CSV file.
+//
+// Values loaded from CSV files are strings (NULL values are represented as `\N`).
+func LoadGolden(path string) (*Golden, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Golden{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(content, g)
+	case ".csv":
+		err = g.unmarshalCSV(content)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedFormat, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Maps returns the rows of the result set as maps keyed by column name.
+func (g *Golden) Maps() []map[string]interface{} {
+	maps := make([]map[string]interface{}, 0, len(g.Rows))
+	for _, row := range g.Rows {
+		m := make(map[string]interface{}, len(g.Columns))
+		for i, column := range g.Columns {
+			if i < len(row) {
+				m[column] = row[i]
+			}
+		}
+		maps = append(maps, m)
+	}
+
+	return maps
+}
+
+// ScanAll decodes all rows of the result set into dest, which must be a pointer to a slice of structs.
+//
+// Like sqlx, columns are mapped to struct fields with the "db" tag. Values are converted to the type of
+// the target field whenever possible (e.g. strings from CSV files, RFC3339 timestamps).
+func (g *Golden) ScanAll(dest interface{}) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:          "db",
+		WeaklyTypedInput: true,
+		DecodeHook:       mapstructure.ComposeDecodeHookFunc(nullHook, mapstructure.StringToTimeHookFunc(time.RFC3339Nano)),
+		Result:           dest,
+	})
+	if err != nil {
+		return err
+	}
+
+	return decoder.Decode(g.Maps())
+}
+
+// Result returns the result set as the stubbed result of a statement, for the DB of a pgrepomock.Recorder
+// (see pgrepomock.Recorder.Stub).
+func (g *Golden) Result() pgrepomock.Result {
+	return pgrepomock.Result{Columns: g.Columns, Rows: g.values()}
+}
+
+// MockRows returns the result set as sqlmock rows, e.g. to serve a fixture with ExpectQuery().WillReturnRows().
+func (g *Golden) MockRows() *sqlmock.Rows {
+	rows := sqlmock.NewRows(g.Columns)
+	for _, row := range g.values() {
+		rows.AddRow(row...)
+	}
+
+	return rows
+}
+
+// values converts the rows of the result set to driver values.
+//
+// NULL values from CSV files are converted to nil. Nested JSON values (objects, arrays) are
+// marshaled back to JSON, so they may be scanned as json or jsonb columns.
+func (g *Golden) values() [][]driver.Value {
+	values := make([][]driver.Value, 0, len(g.Rows))
+	for _, row := range g.Rows {
+		converted := make([]driver.Value, len(g.Columns))
+		for i := range converted {
+			if i < len(row) {
+				converted[i] = driverValue(row[i])
+			}
+		}
+		values = append(values, converted)
+	}
+
+	return values
+}
+
+func driverValue(v interface{}) driver.Value {
+	switch value := v.(type) {
+	case string:
+		if value == csvNull {
+			return nil
+		}
+
+		return value
+	case map[string]interface{}, []interface{}:
+		content, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+
+		return content
+	default:
+		return value
+	}
+}
+
+func nullHook(_, _ reflect.Type, data interface{}) (interface{}, error) {
+	if s, isString := data.(string); isString && s == csvNull {
+		return nil, nil
+	}
+
+	return data, nil
+}
+
+func (g *Golden) marshalCSV() ([]byte, error) {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+
+	if err := w.Write(g.Columns); err != nil {
+		return nil, err
+	}
+
+	for _, row := range g.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			switch value := v.(type) {
+			case nil:
+				record[i] = csvNull
+			case time.Time:
+				record[i] = value.Format(time.RFC3339Nano)
+			default:
+				record[i] = fmt.Sprint(value)
+			}
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+
+	return []byte(b.String()), w.Error()
+}
+
+func (g *Golden) unmarshalCSV(content []byte) error {
+	records, err := csv.NewReader(strings.NewReader(string(content))).ReadAll()
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	g.Columns = records[0]
+	g.Rows = make([][]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make([]interface{}, len(record))
+		for i, v := range record {
+			if v == csvNull {
+				continue
+			}
+			row[i] = v
+		}
+
+		g.Rows = append(g.Rows, row)
+	}
+
+	return nil
+}
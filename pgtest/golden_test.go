@@ -0,0 +1,125 @@
+package pgtest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fredbi/pgxutils/pgrepomock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+type goldenModel struct {
+	ID        int64     `db:"id"`
+	Name      string    `db:"name"`
+	Comment   *string   `db:"comment"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func TestGolden(t *testing.T) {
+	createdAt := time.Date(2023, 11, 12, 10, 30, 0, 0, time.UTC)
+	g := &Golden{
+		Query:   "SELECT id, name, comment, created_at FROM users",
+		Columns: []string{"id", "name", "comment", "created_at"},
+		Rows: [][]interface{}{
+			{int64(1), "alice", "hello", createdAt},
+			{int64(2), "bob", nil, createdAt},
+		},
+	}
+
+	for _, ext := range []string{".json", ".csv"} {
+		t.Run("round trip with "+ext, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fixtures", "users"+ext)
+			require.NoError(t, g.WriteFile(path))
+
+			loaded, err := LoadGolden(path)
+			require.NoError(t, err)
+			require.Equal(t, g.Columns, loaded.Columns)
+			require.Len(t, loaded.Rows, 2)
+
+			var users []goldenModel
+			require.NoError(t, loaded.ScanAll(&users))
+			require.Len(t, users, 2)
+			require.Equal(t, int64(1), users[0].ID)
+			require.Equal(t, "alice", users[0].Name)
+			require.NotNil(t, users[0].Comment)
+			require.Equal(t, "hello", *users[0].Comment)
+			require.True(t, createdAt.Equal(users[0].CreatedAt))
+			require.Nil(t, users[1].Comment)
+		})
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		require.ErrorIs(t, g.WriteFile(filepath.Join(t.TempDir(), "users.yaml")), ErrUnsupportedFormat)
+	})
+}
+
+func TestGoldenFixtures(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, (&Golden{
+		Columns: []string{"id", "name", "comment"},
+		Rows: [][]interface{}{
+			{int64(1), "alice", "hello"},
+			{int64(2), "bob", nil},
+		},
+	}).WriteFile(path))
+
+	g, err := LoadGolden(path)
+	require.NoError(t, err)
+	const query = "SELECT id, name, comment FROM users"
+
+	type user struct {
+		ID      int64   `db:"id"`
+		Name    string  `db:"name"`
+		Comment *string `db:"comment"`
+	}
+
+	assertUsers := func(t *testing.T, users []user) {
+		t.Helper()
+
+		require.Len(t, users, 2)
+		require.Equal(t, int64(1), users[0].ID)
+		require.Equal(t, "alice", users[0].Name)
+		require.NotNil(t, users[0].Comment)
+		require.Equal(t, "hello", *users[0].Comment)
+		require.Equal(t, "bob", users[1].Name)
+		require.Nil(t, users[1].Comment)
+	}
+
+	t.Run("should serve a fixture through a mocked query", func(t *testing.T) {
+		mockDB, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = mockDB.Close() })
+
+		mock.ExpectQuery(`SELECT id, name, comment FROM users`).WillReturnRows(g.MockRows())
+
+		var users []user
+		require.NoError(t, sqlx.NewDb(mockDB, "pgx").SelectContext(ctx, &users, query))
+		assertUsers(t, users)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("should serve a fixture through a recorder", func(t *testing.T) {
+		rec := pgrepomock.NewRecorder()
+		rec.Stub(query, g.Result())
+
+		var users []user
+		require.NoError(t, rec.DB().SelectContext(ctx, &users, query))
+		assertUsers(t, users)
+	})
+
+	t.Run("should serve nested JSON values", func(t *testing.T) {
+		nested := &Golden{
+			Columns: []string{"id", "attrs"},
+			Rows:    [][]interface{}{{float64(1), map[string]interface{}{"tier": "gold"}}},
+		}
+
+		values := nested.values()
+		require.Len(t, values, 1)
+		require.Equal(t, []byte(`{"tier":"gold"}`), values[0][1])
+	})
+}